@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/ofauchon/go-speedtest/speedtest"
+)
+
+// Wait after a failed call to the controller
+const agentRetryDelay = 10 * time.Second
+
+// Error of a job request for an agent the controller doesn't know, after
+// a restart
+var errUnknownAgent = errors.New("unknown agent")
+
+// Client of the controller API
+type agentClient struct {
+	base  string
+	name  string
+	token string
+	http  *http.Client
+}
+
+func (c *agentClient) call(ctx context.Context, method, path string, in, out any) (int, error) {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return 0, err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.base+"/api/v1/agents/"+url.PathEscape(c.name)+path, body)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "go-speedtest")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return resp.StatusCode, errUnknownAgent
+	case resp.StatusCode/100 != 2:
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return resp.StatusCode, fmt.Errorf("controller: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	case out != nil && resp.StatusCode != http.StatusNoContent:
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return resp.StatusCode, fmt.Errorf("controller: invalid response: %w", err)
+		}
+	}
+	return resp.StatusCode, nil
+}
+
+// Register with the controller until it succeeds or ctx is cancelled
+func (c *agentClient) register(ctx context.Context, info agentInfo) {
+	for {
+		_, err := c.call(ctx, http.MethodPut, "", info, nil)
+		if err == nil {
+			slog.Info("registered with the controller", "controller", c.base, "name", c.name)
+			return
+		}
+		slog.Warn("registration failed", "err", err)
+		select {
+		case <-time.After(agentRetryDelay):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Run the jobs of the controller until ctx is cancelled
+func runAgent(ctx context.Context, c *agentClient, info agentInfo, opts speedtest.Options, run func(context.Context, speedtest.Options) (*speedtest.Result, error)) {
+	c.register(ctx, info)
+	for ctx.Err() == nil {
+		var job agentJob
+		status, err := c.call(ctx, http.MethodGet, "/job", nil, &job)
+		switch {
+		case ctx.Err() != nil:
+			return
+		case errors.Is(err, errUnknownAgent):
+			c.register(ctx, info)
+			continue
+		case err != nil:
+			slog.Warn("no job from the controller", "err", err)
+			select {
+			case <-time.After(agentRetryDelay):
+			case <-ctx.Done():
+			}
+			continue
+		case status == http.StatusNoContent:
+			continue
+		}
+
+		o := opts
+		o.Target, o.UploadTarget, o.Upload = job.Target, job.UploadTarget, job.Upload
+		slog.Info("test started", "job", job.ID, "target", job.Target, "peer", job.Peer)
+		res, err := run(ctx, o)
+		rep := agentReport{Job: job.ID, Result: res}
+		if err != nil {
+			rep.Error, rep.Result = err.Error(), nil
+			slog.Error("test failed", "job", job.ID, "err", err)
+		} else {
+			slog.Info("test done", "job", job.ID, "download_mbps", res.BytesPerSecond()*8/1e6)
+		}
+		// Report even when interrupted, the controller waits for it
+		rctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+		if _, err := c.call(rctx, http.MethodPost, "/results", rep, nil); err != nil {
+			slog.Warn("result not sent to the controller", "err", err)
+		}
+		cancel()
+	}
+}
+
+// URL the other agents reach the built-in server on, listen with the
+// hostname if it has no host
+func serveURL(listen string) (string, error) {
+	host, port, err := net.SplitHostPort(listen)
+	if err != nil {
+		return "", err
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		if host, err = os.Hostname(); err != nil {
+			return "", err
+		}
+	}
+	return "http://" + net.JoinHostPort(host, port), nil
+}
+
+// Run the tests scheduled by a controller
+func agentCommand(args []string) {
+	fs := flag.NewFlagSet("agent", flag.ExitOnError)
+	controllerURL := fs.String("controller", "", "URL of the go-speedtest controller (e.g. http://controller:8070)")
+	token := fs.String("controller-token", "", "Bearer token sent to the controller")
+	name := fs.String("name", "", "Name of the agent (defaults to the hostname)")
+	serve := fs.String("serve", "", "Serve the built-in test server on this address, for the mesh tests of the other agents")
+	advertise := fs.String("serve-url", "", "URL the other agents reach the built-in server on (defaults to http://HOSTNAME:PORT)")
+	tf := addTestFlags(fs, allFlags)
+	parseFlags(fs, args)
+
+	if *controllerURL == "" {
+		fmt.Println("Controller URL is required.")
+		os.Exit(1)
+	}
+	if *name == "" {
+		var err error
+		if *name, err = os.Hostname(); err != nil {
+			fatal(err)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	console := tf.console()
+	tf.setupLogging(console)
+	tf.say(console, "Go SpeedTest agent")
+
+	var info agentInfo
+	if *serve != "" {
+		info.ServeURL = *advertise
+		if info.ServeURL == "" {
+			var err error
+			if info.ServeURL, err = serveURL(*serve); err != nil {
+				fatal(err)
+			}
+		}
+		ln, err := net.Listen("tcp", *serve)
+		if err != nil {
+			fatal(err)
+		}
+		go http.Serve(ln, speedtest.Handler(speedtest.DefaultServeSize))
+		slog.Info("serving test files", "url", info.ServeURL)
+	}
+
+	// Job requests wait agentPollTimeout for a job
+	c := &agentClient{base: strings.TrimSuffix(*controllerURL, "/"), name: *name, token: *token,
+		http: &http.Client{Timeout: agentPollTimeout + 30*time.Second}}
+	runAgent(ctx, c, info, tf.options(), tf.publishing(console, tf.identifying(tf.configure(speedtest.NewClient()).Run)))
+}
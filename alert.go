@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ofauchon/go-speedtest/speedtest"
+)
+
+// Send a text message to a chat service
+type notifier func(ctx context.Context, text string) error
+
+// Post a message to a Slack incoming webhook
+func slackNotifier(hook string) notifier {
+	return func(ctx context.Context, text string) error {
+		return postJSON(ctx, hook, map[string]string{"text": text})
+	}
+}
+
+// Post a message to a Discord webhook
+func discordNotifier(hook string) notifier {
+	return func(ctx context.Context, text string) error {
+		return postJSON(ctx, hook, map[string]string{"content": text})
+	}
+}
+
+// Send a message to a Telegram chat through a bot
+func telegramNotifier(token, chat string) notifier {
+	return func(ctx context.Context, text string) error {
+		return postJSON(ctx, "https://api.telegram.org/bot"+token+"/sendMessage", map[string]string{"chat_id": chat, "text": text})
+	}
+}
+
+func postJSON(ctx context.Context, target string, v any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("notification failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("notification failed: %s %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// Notify when the thresholds are broken for several consecutive runs, and
+// again when the link recovers
+type alerter struct {
+	after     int
+	notifiers []notifier
+
+	minDownload, minUpload, maxLatency float64
+
+	breaches int
+	alerting bool
+}
+
+// Check the outcome of a run, returning the message to send if the alert
+// state changed. Failed runs count as breaches.
+func (a *alerter) check(target string, res *speedtest.Result, err error) string {
+	var reasons []string
+	if err != nil {
+		reasons = append(reasons, fmt.Sprintf("test failed: %v", err))
+	} else {
+		for _, v := range checkThresholds(res, a.minDownload, a.minUpload, a.maxLatency) {
+			if v.Op == "missing" {
+				reasons = append(reasons, fmt.Sprintf("%s missing", v.Metric))
+				continue
+			}
+			reasons = append(reasons, fmt.Sprintf("%s %.2f %s %s %.2f", v.Metric, v.Value, v.Unit, v.Op, v.Limit))
+		}
+	}
+
+	if len(reasons) == 0 {
+		a.breaches = 0
+		if a.alerting {
+			a.alerting = false
+			return fmt.Sprintf("go-speedtest: %s is back to normal", target)
+		}
+		return ""
+	}
+	a.breaches++
+	if a.alerting || a.breaches < a.after {
+		return ""
+	}
+	a.alerting = true
+	return fmt.Sprintf("go-speedtest: %s below thresholds for %d consecutive runs: %s", target, a.breaches, strings.Join(reasons, ", "))
+}
+
+// Send the message to every notifier
+func (a *alerter) notify(ctx context.Context, text string) []error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	var errs []error
+	for _, n := range a.notifiers {
+		if err := n(ctx, text); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/ofauchon/go-speedtest/history"
+	"github.com/ofauchon/go-speedtest/internal/tui"
+	"github.com/ofauchon/go-speedtest/speedtest"
+)
+
+// Save a result as the baseline of the later -check-baseline runs
+func saveBaseline(path string, res *speedtest.Result) error {
+	out, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to save baseline: %w", err)
+	}
+	if err := printJSON(out, res); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to save baseline: %w", err)
+	}
+	return out.Close()
+}
+
+// Return the metrics of res worse than the baseline by more than tolerance
+// percent. Any degradation of a metric at 0 in the baseline (loss) counts.
+func checkBaseline(baseline, res *speedtest.Result, tolerance float64) []metricDelta {
+	var regressions []metricDelta
+	for _, d := range compareResults(baseline, res) {
+		if d.Better || d.Change == 0 {
+			continue
+		}
+		if d.Before == 0 || math.Abs(d.Percent) > tolerance {
+			regressions = append(regressions, d)
+		}
+	}
+	return regressions
+}
+
+// Print one key=value line per regression, in red on terminals
+func printRegressions(w *os.File, baseline *speedtest.Result, regressions []metricDelta, tolerance float64) {
+	start, end := "", ""
+	if tui.IsTerminal(w) {
+		start, end = "\033[1;31m", "\033[0m"
+	}
+	fmt.Fprintf(w, "%sRegressed from the baseline of %s (%s):%s\n", start, baseline.Start.Local().Format("2006-01-02 15:04"), baseline.Target, end)
+	for _, d := range regressions {
+		fmt.Fprintf(w, "%sREGRESSION metric=%s baseline=%.3f value=%.3f change=%+.1f%% tolerance=%.1f%% unit=%s%s\n",
+			start, strings.ReplaceAll(strings.ToLower(d.Metric), " ", "_"), d.Before, d.After, d.Percent, tolerance, d.Unit, end)
+	}
+}
+
+// Read the -check-baseline result, nil if not set, exiting on errors
+func (f *testFlags) baseline() *speedtest.Result {
+	if *f.checkBaseline == "" {
+		return nil
+	}
+	db := *f.history
+	if db == "" {
+		db = history.DefaultPath
+	}
+	res, err := loadResult(*f.checkBaseline, db)
+	if err != nil {
+		fatal(fmt.Errorf("failed to read baseline: %w", err))
+	}
+	return res
+}
+
+// Save the baseline if asked, then exit with exitThreshold if the results
+// break the thresholds or regressed from the baseline (with the plugin
+// state for -format nagios)
+func (f *testFlags) checkResults(baseline *speedtest.Result, results ...*speedtest.Result) {
+	if *f.saveBaseline != "" && len(results) > 0 {
+		if err := saveBaseline(*f.saveBaseline, results[len(results)-1]); err != nil {
+			fatal(err)
+		}
+	}
+
+	// Nagios plugins report the worst state with their exit code
+	if *f.format == "nagios" {
+		state := nagiosOK
+		for _, res := range results {
+			s, _ := f.nagiosReport(res, baseline)
+			state = max(state, s)
+		}
+		if len(results) == 0 {
+			state = nagiosUnknown
+		}
+		os.Exit(state)
+	}
+
+	var v []violation
+	var regressions []metricDelta
+	for _, res := range results {
+		v = append(v, checkThresholds(res, *f.minDownload, *f.minUpload, *f.maxLatency)...)
+		if baseline != nil {
+			regressions = append(regressions, checkBaseline(baseline, res, *f.baselineTolerance)...)
+		}
+	}
+	if len(v) > 0 {
+		printViolations(os.Stderr, v)
+	}
+	if len(regressions) > 0 {
+		printRegressions(os.Stderr, baseline, regressions, *f.baselineTolerance)
+	}
+	if len(v) > 0 || len(regressions) > 0 {
+		os.Exit(exitThreshold)
+	}
+}
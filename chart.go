@@ -0,0 +1,288 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ofauchon/go-speedtest/speedtest"
+)
+
+// The -chart images stack the throughput and latency panels under a
+// title, with room on the left for the axis labels
+const (
+	runChartTitle  = 30
+	runChartHeight = runChartTitle + 2*chartHeight
+	runChartLeft   = 80
+)
+
+// Size of the PNG glyphs: chartFont pixels are drawn as squares of
+// fontScale, with a column between characters
+const (
+	fontScale   = 2
+	fontAdvance = 6 * fontScale
+)
+
+var (
+	chartBackground = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	chartGrid       = color.RGBA{0xdd, 0xdd, 0xdd, 0xff}
+	chartText       = color.RGBA{0x55, 0x55, 0x55, 0xff}
+	chartDownload   = color.RGBA{0x1f, 0x77, 0xb4, 0xff}
+	chartUpload     = color.RGBA{0xff, 0x7f, 0x0e, 0xff}
+	chartIdle       = color.RGBA{0x2c, 0xa0, 0x2c, 0xff}
+)
+
+// Drawing surface of the -chart images
+type canvas interface {
+	line(x1, y1, x2, y2 float64, c color.RGBA, dashed bool)
+	// Write s with its baseline at y, starting at x or ending there if right
+	text(x, y float64, s string, c color.RGBA, right bool)
+	encode(w io.Writer) error
+}
+
+// Canvas writing an SVG document
+type svgCanvas struct {
+	b strings.Builder
+}
+
+func newSVGCanvas(width, height int) *svgCanvas {
+	c := &svgCanvas{}
+	fmt.Fprintf(&c.b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="12">`, width, height, width, height)
+	fmt.Fprintf(&c.b, `<rect width="100%%" height="100%%" fill="%s"/>`, hexColor(chartBackground))
+	return c
+}
+
+func (c *svgCanvas) line(x1, y1, x2, y2 float64, col color.RGBA, dashed bool) {
+	dash := ""
+	if dashed {
+		dash = ` stroke-dasharray="6,6"`
+	}
+	fmt.Fprintf(&c.b, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="%s" stroke-width="2"%s/>`, x1, y1, x2, y2, hexColor(col), dash)
+}
+
+func (c *svgCanvas) text(x, y float64, s string, col color.RGBA, right bool) {
+	anchor := ""
+	if right {
+		anchor = ` text-anchor="end"`
+	}
+	fmt.Fprintf(&c.b, `<text x="%.1f" y="%.1f" fill="%s"%s>%s</text>`, x, y, hexColor(col), anchor, html.EscapeString(s))
+}
+
+func (c *svgCanvas) encode(w io.Writer) error {
+	_, err := io.WriteString(w, c.b.String()+"</svg>\n")
+	return err
+}
+
+func hexColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// Canvas drawing on a bitmap encoded as PNG
+type pngCanvas struct {
+	img *image.RGBA
+}
+
+func newPNGCanvas(width, height int) *pngCanvas {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(chartBackground), image.Point{}, draw.Src)
+	return &pngCanvas{img: img}
+}
+
+// Fill a size x size square at x, y
+func (c *pngCanvas) dot(x, y, size int, col color.RGBA) {
+	draw.Draw(c.img, image.Rect(x, y, x+size, y+size), image.NewUniform(col), image.Point{}, draw.Src)
+}
+
+func (c *pngCanvas) line(x1, y1, x2, y2 float64, col color.RGBA, dashed bool) {
+	steps := int(math.Max(math.Abs(x2-x1), math.Abs(y2-y1)))
+	for i := 0; i <= steps; i++ {
+		if dashed && i/6%2 == 1 {
+			continue
+		}
+		t := 0.0
+		if steps > 0 {
+			t = float64(i) / float64(steps)
+		}
+		c.dot(int(math.Round(x1+t*(x2-x1))), int(math.Round(y1+t*(y2-y1))), 2, col)
+	}
+}
+
+func (c *pngCanvas) text(x, y float64, s string, col color.RGBA, right bool) {
+	if right {
+		x -= float64(len([]rune(s)) * fontAdvance)
+	}
+	top := int(y) - 7*fontScale
+	for i, r := range []rune(s) {
+		glyph, ok := chartFont[r]
+		if !ok {
+			continue
+		}
+		left := int(x) + i*fontAdvance
+		for dy, row := range glyph {
+			for dx, p := range row {
+				if p != ' ' {
+					c.dot(left+dx*fontScale, top+dy*fontScale, fontScale, col)
+				}
+			}
+		}
+	}
+}
+
+func (c *pngCanvas) encode(w io.Writer) error {
+	return png.Encode(w, c.img)
+}
+
+// A line drawn on a run chart
+type runSeries struct {
+	name   string
+	color  color.RGBA
+	dashed bool
+	// Seconds since the start of the run
+	at     []float64
+	values []float64
+}
+
+func init() {
+	registerSink(func(f *testFlags) (Sink, error) {
+		if *f.chart == "" {
+			return nil, nil
+		}
+		return sinkFunc{"chart", func(_ context.Context, res *speedtest.Result) error {
+			return writeChart(*f.chart, res)
+		}}, nil
+	})
+}
+
+// Write the chart of a run to path, as SVG if it ends in .svg and as PNG
+// otherwise
+func writeChart(path string, res *speedtest.Result) error {
+	var c canvas
+	if strings.EqualFold(filepath.Ext(path), ".svg") {
+		c = newSVGCanvas(chartWidth, runChartHeight)
+	} else {
+		c = newPNGCanvas(chartWidth, runChartHeight)
+	}
+	drawRun(c, res)
+
+	out, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create chart: %w", err)
+	}
+	if err := c.encode(out); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to write chart: %w", err)
+	}
+	return out.Close()
+}
+
+// Draw the throughput of every interval of the run and the latency probes
+// sent under load, on a common timeline
+func drawRun(c canvas, res *speedtest.Result) {
+	c.text(runChartLeft, 20, fmt.Sprintf("%s  %s", res.Target, res.Start.Format("2006-01-02 15:04")), chartText, false)
+
+	download := runSeries{name: "Download", color: chartDownload}
+	for _, iv := range res.Intervals {
+		download.at = append(download.at, iv.End.Seconds())
+		download.values = append(download.values, iv.BytesPerSecond()*8/1e6)
+	}
+	if v, ok := downloadMetric.value(res); ok {
+		download.name = fmt.Sprintf("Download (avg %.2f)", v)
+	}
+	upload := runSeries{name: "Upload", color: chartUpload}
+	if up := res.Upload; up != nil {
+		offset := up.Start.Sub(res.Start).Seconds()
+		for _, iv := range up.Intervals {
+			upload.at = append(upload.at, offset+iv.End.Seconds())
+			upload.values = append(upload.values, iv.BytesPerSecond()*8/1e6)
+		}
+		upload.name = fmt.Sprintf("Upload (avg %.2f)", up.BytesPerSecond()*8/1e6)
+	}
+
+	var latency []runSeries
+	if bb := res.Bufferbloat; bb != nil {
+		for _, l := range []struct {
+			name  string
+			color color.RGBA
+			res   *speedtest.LatencyResult
+		}{{"Download", chartDownload, bb.Download}, {"Upload", chartUpload, bb.Upload}, {"Bidirectional", chartText, bb.Bidirectional}} {
+			if l.res == nil {
+				continue
+			}
+			s := runSeries{name: l.name, color: l.color}
+			for i, at := range l.res.At {
+				s.at = append(s.at, at.Sub(res.Start).Seconds())
+				s.values = append(s.values, float64(l.res.Samples[i])/1e6)
+			}
+			latency = append(latency, s)
+		}
+	}
+
+	// Both panels share the timeline of the whole run
+	span := res.End.Sub(res.Start).Seconds()
+	for _, s := range append(latency, download, upload) {
+		for _, t := range s.at {
+			span = max(span, t)
+		}
+	}
+	if bb := res.Bufferbloat; bb != nil {
+		idle := float64(bb.Idle) / 1e6
+		latency = append(latency, runSeries{name: "Idle", color: chartIdle, dashed: true, at: []float64{0, span}, values: []float64{idle, idle}})
+	}
+	drawPanel(c, runChartTitle, "Mbit/sec", "No interval measured", span, download, upload)
+	drawPanel(c, runChartTitle+chartHeight, "ms", "No latency under load, see -bufferbloat", span, latency...)
+}
+
+// Draw series going from 0 to span seconds in the panel starting at top,
+// or the empty message if they have no values
+func drawPanel(c canvas, top float64, unit, empty string, span float64, series ...runSeries) {
+	ceil := 0.0
+	for _, s := range series {
+		for _, v := range s.values {
+			ceil = max(ceil, v)
+		}
+	}
+	c.text(runChartLeft, top+16, unit, chartText, false)
+	if ceil == 0 || span == 0 {
+		c.text(runChartLeft+40, top+chartHeight/2, empty, chartText, false)
+		return
+	}
+	ceil = niceCeil(ceil)
+	x := func(t float64) float64 {
+		return runChartLeft + t/span*float64(chartWidth-runChartLeft-chartMargin)
+	}
+	y := func(v float64) float64 {
+		return top + chartY(v, ceil)
+	}
+
+	for i := 0; i <= 4; i++ {
+		v := ceil * float64(i) / 4
+		c.line(runChartLeft, y(v), chartWidth-chartMargin, y(v), chartGrid, false)
+		c.text(runChartLeft-6, y(v)+5, fmt.Sprintf("%g", math.Round(v*100)/100), chartText, true)
+	}
+	step := niceCeil(span / 8)
+	for t := 0.0; t <= span; t += step {
+		c.text(x(t), y(0)+20, fmt.Sprintf("%gs", t), chartText, false)
+	}
+
+	lx := float64(runChartLeft + 120)
+	for _, s := range series {
+		if len(s.values) == 0 {
+			continue
+		}
+		for i := 1; i < len(s.at); i++ {
+			c.line(x(s.at[i-1]), y(s.values[i-1]), x(s.at[i]), y(s.values[i]), s.color, s.dashed)
+		}
+		c.line(lx, top+12, lx+16, top+12, s.color, s.dashed)
+		c.text(lx+22, top+16, s.name, chartText, false)
+		lx += float64(len(s.name)*fontAdvance + 40)
+	}
+}
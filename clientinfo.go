@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ofauchon/go-speedtest/speedtest"
+)
+
+// Location of the cached client lookup
+func clientCachePath() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "go-speedtest", "client.json")
+}
+
+// Return the cached lookup of provider if it is younger than ttl
+func cachedClientInfo(provider string, ttl time.Duration) *speedtest.ClientInfo {
+	data, err := os.ReadFile(clientCachePath())
+	if err != nil {
+		return nil
+	}
+	var info speedtest.ClientInfo
+	if json.Unmarshal(data, &info) != nil || info.Provider != provider || time.Since(info.Looked) > ttl {
+		return nil
+	}
+	return &info
+}
+
+func cacheClientInfo(info *speedtest.ClientInfo) error {
+	path := clientCachePath()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// Look up the public IP, ISP and location of the client before each run
+// and add them to the result. A failed lookup doesn't fail the test.
+func (f *testFlags) identifying(run func(context.Context, speedtest.Options) (*speedtest.Result, error)) func(context.Context, speedtest.Options) (*speedtest.Result, error) {
+	run = f.wireless(run)
+	if !*f.clientInfo {
+		return run
+	}
+	client := f.configure(speedtest.NewClient())
+	provider := *f.clientProvider
+	if provider == "" {
+		provider = speedtest.ProviderIPInfo
+	}
+	ttl := time.Duration(*f.clientTTL) * time.Second
+	return func(ctx context.Context, opts speedtest.Options) (*speedtest.Result, error) {
+		info := cachedClientInfo(provider, ttl)
+		if info == nil {
+			lctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+			var err error
+			info, err = client.LookupClient(lctx, provider)
+			cancel()
+			if err != nil {
+				slog.Warn("client not identified", "err", err)
+			} else if ttl > 0 {
+				if err := cacheClientInfo(info); err != nil {
+					slog.Warn("client lookup not cached", "err", err)
+				}
+			}
+		}
+		res, err := run(ctx, opts)
+		if res != nil {
+			res.Client = info
+		}
+		return res, err
+	}
+}
+
+// Capture the Wi-Fi link of the client before each run, the radio
+// conditions explaining many poor results
+func (f *testFlags) wireless(run func(context.Context, speedtest.Options) (*speedtest.Result, error)) func(context.Context, speedtest.Options) (*speedtest.Result, error) {
+	if !*f.wifi {
+		return run
+	}
+	iface := *f.iface
+	return func(ctx context.Context, opts speedtest.Options) (*speedtest.Result, error) {
+		wctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		info, err := speedtest.ReadWiFi(wctx, iface)
+		cancel()
+		if err != nil {
+			slog.Warn("Wi-Fi link not captured", "err", err)
+		}
+		res, err := run(ctx, opts)
+		if res != nil {
+			res.WiFi = info
+		}
+		return res, err
+	}
+}
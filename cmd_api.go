@@ -0,0 +1,445 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/ofauchon/go-speedtest/history"
+	"github.com/ofauchon/go-speedtest/speedtest"
+)
+
+// Tests kept in memory by the API server, older ones are dropped
+const apiKeptTests = 100
+
+// Bounds of the parameters of POST /api/v1/tests, so that a caller can't
+// start unbounded connections or tie the server up for days
+const (
+	apiMaxConcurrent = 64
+	apiMaxDuration   = 3600
+)
+
+// States of an API test
+const (
+	apiRunning   = "running"
+	apiDone      = "done"
+	apiFailed    = "failed"
+	apiCancelled = "cancelled"
+)
+
+// Test started through the API
+type apiTest struct {
+	ID       int64      `json:"id"`
+	Target   string     `json:"target"`
+	Status   string     `json:"status"`
+	Started  time.Time  `json:"started"`
+	Finished *time.Time `json:"finished,omitempty"`
+	Elapsed  float64    `json:"elapsed_seconds"`
+	// Fraction of the duration elapsed, for tests with one
+	Progress *float64 `json:"progress,omitempty"`
+	// Latest progress of the transfer running
+	Live    *speedtest.Snapshot `json:"live,omitempty"`
+	Error   string              `json:"error,omitempty"`
+	Summary *resultSummary      `json:"summary,omitempty"`
+	Result  *speedtest.Result   `json:"result,omitempty"`
+
+	duration time.Duration
+	cancel   context.CancelFunc
+}
+
+// Parameters of POST /api/v1/tests, overriding the flags of the server
+type apiTestRequest struct {
+	Target       string `json:"target"`
+	UploadTarget string `json:"upload_target"`
+	Upload       *bool  `json:"upload"`
+	Duration     *int   `json:"duration"`
+	Concurrent   *int   `json:"concurrent"`
+}
+
+// REST API running one test at a time
+type apiServer struct {
+	ctx   context.Context
+	token string
+	db    string
+	opts  speedtest.Options
+	run   func(context.Context, speedtest.Options) (*speedtest.Result, error)
+	// If set, called after each test that was not cancelled
+	onResult func(*speedtest.Result, error)
+
+	mu      sync.Mutex
+	tests   []*apiTest
+	current *apiTest
+	last    *speedtest.Result
+	nextID  int64
+}
+
+// Return the API handler, under the web dashboard if web is set
+func (s *apiServer) handler(web bool) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/v1/tests", s.startTest)
+	mux.HandleFunc("GET /api/v1/tests", s.listTests)
+	mux.HandleFunc("GET /api/v1/tests/{id}", s.getTest)
+	mux.HandleFunc("DELETE /api/v1/tests/{id}", s.cancelTest)
+	mux.HandleFunc("GET /api/v1/status", s.status)
+	mux.HandleFunc("GET /api/v1/results/last", s.lastResult)
+	mux.HandleFunc("GET /api/v1/history", s.history)
+	if !web {
+		return requireToken(s.token, mux)
+	}
+	// The page asks for the token the API calls need
+	root := http.NewServeMux()
+	root.Handle("/api/", requireToken(s.token, mux))
+	root.Handle("/", webHandler())
+	return root
+}
+
+// Check the bearer token of every request if one is configured
+func requireToken(want string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if want != "" {
+			token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(token), []byte(want)) != 1 {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="go-speedtest"`)
+				apiError(w, http.StatusUnauthorized, "invalid or missing token")
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func apiJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	printJSON(w, v)
+}
+
+func apiError(w http.ResponseWriter, code int, msg string) {
+	apiJSON(w, code, map[string]string{"error": msg})
+}
+
+// Copy of the test with its progress, taken under the lock
+func (t *apiTest) snapshot() apiTest {
+	c := *t
+	end := time.Now()
+	if t.Finished != nil {
+		end = *t.Finished
+	}
+	c.Elapsed = end.Sub(t.Started).Seconds()
+	if t.duration > 0 && t.Status == apiRunning {
+		p := min(end.Sub(t.Started).Seconds()/t.duration.Seconds(), 1)
+		c.Progress = &p
+	}
+	return c
+}
+
+func (s *apiServer) startTest(w http.ResponseWriter, r *http.Request) {
+	var req apiTestRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			apiError(w, http.StatusBadRequest, "invalid request: "+err.Error())
+			return
+		}
+	}
+	opts := s.opts
+	if req.Target != "" {
+		opts.Target = req.Target
+	}
+	if req.UploadTarget != "" {
+		opts.UploadTarget = req.UploadTarget
+	}
+	if req.Upload != nil {
+		opts.Upload = *req.Upload
+	}
+	if req.Duration != nil {
+		if *req.Duration < 1 || *req.Duration > apiMaxDuration {
+			apiError(w, http.StatusBadRequest, fmt.Sprintf("duration must be between 1 and %d seconds", apiMaxDuration))
+			return
+		}
+		opts.Duration = time.Duration(*req.Duration) * time.Second
+	}
+	// Without a duration a sized target would be transferred for as long
+	// as it takes
+	if opts.Duration <= 0 || opts.Duration > apiMaxDuration*time.Second {
+		opts.Duration = apiMaxDuration * time.Second
+	}
+	if req.Concurrent != nil {
+		if *req.Concurrent < 1 || *req.Concurrent > apiMaxConcurrent {
+			apiError(w, http.StatusBadRequest, fmt.Sprintf("concurrent must be between 1 and %d", apiMaxConcurrent))
+			return
+		}
+		opts.Concurrent = *req.Concurrent
+	}
+	if opts.Target == "" {
+		apiError(w, http.StatusBadRequest, "target is required")
+		return
+	}
+
+	s.mu.Lock()
+	t, ctx, opts := s.begin(opts)
+	if t == nil {
+		running := s.current.snapshot()
+		s.mu.Unlock()
+		apiJSON(w, http.StatusConflict, map[string]any{"error": "a test is already running", "test": running})
+		return
+	}
+	snap := t.snapshot()
+	s.mu.Unlock()
+
+	go s.runTest(ctx, t, opts)
+	w.Header().Set("Location", fmt.Sprintf("/api/v1/tests/%d", t.ID))
+	apiJSON(w, http.StatusAccepted, snap)
+}
+
+// Register a new test under the lock, returning nil while another one is
+// running, with its context and the options reporting its progress
+func (s *apiServer) begin(opts speedtest.Options) (*apiTest, context.Context, speedtest.Options) {
+	if s.current != nil {
+		return nil, nil, opts
+	}
+	s.nextID++
+	ctx, cancel := context.WithCancel(s.ctx)
+	t := &apiTest{ID: s.nextID, Target: opts.Target, Status: apiRunning, Started: time.Now(), duration: opts.Duration, cancel: cancel}
+	s.current = t
+	s.tests = append(s.tests, t)
+	if len(s.tests) > apiKeptTests {
+		s.tests = s.tests[1:]
+	}
+	opts.OnProgress = func(p speedtest.Snapshot) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		t.Live = &p
+	}
+	return t, ctx, opts
+}
+
+func (s *apiServer) runTest(ctx context.Context, t *apiTest, opts speedtest.Options) (*speedtest.Result, error) {
+	slog.Info("test started", "id", t.ID, "target", opts.Target)
+	res, err := s.run(ctx, opts)
+	cancelled := ctx.Err() != nil
+	t.cancel()
+
+	s.mu.Lock()
+	now := time.Now()
+	t.Finished, t.Live = &now, nil
+	switch {
+	case err != nil:
+		t.Status, t.Error = apiFailed, err.Error()
+	case cancelled:
+		t.Status, t.Result = apiCancelled, res
+	default:
+		t.Status, t.Result = apiDone, res
+		s.last = res
+	}
+	if t.Result != nil {
+		summary := newResultSummary(t.Result)
+		t.Summary = &summary
+	}
+	s.current = nil
+	slog.Info("test finished", "id", t.ID, "status", t.Status)
+	s.mu.Unlock()
+
+	if s.onResult != nil && !cancelled {
+		s.onResult(res, err)
+	}
+	return res, err
+}
+
+// Run a test like a POST request would, for the scheduled runs of the
+// monitor, skipping it while another test is running
+func (s *apiServer) scheduled(_ context.Context, opts speedtest.Options) (*speedtest.Result, error) {
+	s.mu.Lock()
+	t, ctx, opts := s.begin(opts)
+	s.mu.Unlock()
+	if t == nil {
+		slog.Warn("scheduled test skipped, a test is already running")
+		return nil, errors.New("a test is already running")
+	}
+	return s.runTest(ctx, t, opts)
+}
+
+// Find a test by the id of the path, writing the error if there is none
+func (s *apiServer) lookup(w http.ResponseWriter, r *http.Request) *apiTest {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		apiError(w, http.StatusBadRequest, "invalid test id")
+		return nil
+	}
+	for _, t := range s.tests {
+		if t.ID == id {
+			return t
+		}
+	}
+	apiError(w, http.StatusNotFound, "no such test")
+	return nil
+}
+
+func (s *apiServer) getTest(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t := s.lookup(w, r); t != nil {
+		apiJSON(w, http.StatusOK, t.snapshot())
+	}
+}
+
+func (s *apiServer) cancelTest(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := s.lookup(w, r)
+	if t == nil {
+		return
+	}
+	if t.Status != apiRunning {
+		apiError(w, http.StatusConflict, "test is "+t.Status)
+		return
+	}
+	t.cancel()
+	apiJSON(w, http.StatusAccepted, t.snapshot())
+}
+
+// List the tests kept in memory, most recent first, without their results
+func (s *apiServer) listTests(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tests := []apiTest{}
+	for i := len(s.tests) - 1; i >= 0; i-- {
+		t := s.tests[i].snapshot()
+		t.Result = nil
+		tests = append(tests, t)
+	}
+	apiJSON(w, http.StatusOK, tests)
+}
+
+func (s *apiServer) status(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := struct {
+		Running *apiTest `json:"running"`
+		Tests   int64    `json:"tests"`
+		Last    *string  `json:"last_result,omitempty"`
+	}{Tests: s.nextID}
+	if s.current != nil {
+		t := s.current.snapshot()
+		status.Running = &t
+	}
+	if s.last != nil {
+		at := s.last.Start.Format(time.RFC3339)
+		status.Last = &at
+	}
+	apiJSON(w, http.StatusOK, status)
+}
+
+func (s *apiServer) lastResult(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.last == nil {
+		apiError(w, http.StatusNotFound, "no test finished yet")
+		return
+	}
+	apiJSON(w, http.StatusOK, s.last)
+}
+
+// Stored runs, filtered like the history command with the from, to,
+// target and last query parameters
+func (s *apiServer) history(w http.ResponseWriter, r *http.Request) {
+	if s.db == "" {
+		apiError(w, http.StatusNotFound, "no history database, start the server with -history")
+		return
+	}
+	q := r.URL.Query()
+	filter := history.Filter{Target: q.Get("target")}
+	var err error
+	if v := q.Get("last"); v != "" {
+		if filter.Limit, err = strconv.Atoi(v); err != nil || filter.Limit < 0 {
+			apiError(w, http.StatusBadRequest, "invalid last")
+			return
+		}
+	}
+	for _, p := range []struct {
+		name string
+		t    *time.Time
+	}{{"from", &filter.From}, {"to", &filter.To}} {
+		if v := q.Get(p.name); v != "" {
+			if *p.t, err = parseDate(v); err != nil {
+				apiError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+		}
+	}
+
+	store, err := history.Open(s.db)
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer store.Close()
+	entries, err := store.List(filter)
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	runs := []map[string]any{}
+	for _, e := range entries {
+		runs = append(runs, map[string]any{"id": e.ID, "summary": newResultSummary(e.Result), "result": e.Result})
+	}
+	apiJSON(w, http.StatusOK, runs)
+}
+
+// Serve the REST API starting and reading tests
+func apiCommand(args []string) {
+	fs := flag.NewFlagSet("api", flag.ExitOnError)
+	target := fs.String("target", "", "Default target URL of the tests")
+	uploadTarget := fs.String("upload-target", "", "Default URL receiving uploads (defaults to target)")
+	listen := fs.String("api-listen", ":8090", "Address the API listens on")
+	token := fs.String("api-token", "", "Require this bearer token in the Authorization header of every request")
+	web := fs.Bool("web", false, "Also serve the web dashboard on /")
+	tf := addTestFlags(fs, allFlags)
+	parseFlags(fs, args)
+
+	opts := tf.options()
+	opts.Target = *target
+	opts.UploadTarget = *uploadTarget
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	console := tf.console()
+	tf.setupLogging(console)
+	if *token == "" {
+		slog.Warn("the API accepts requests without authentication, see -api-token")
+	}
+
+	s := &apiServer{ctx: ctx, token: *token, db: *tf.history, opts: opts,
+		run: tf.publishing(console, tf.identifying(tf.configure(speedtest.NewClient()).Run))}
+	ln, err := net.Listen("tcp", *listen)
+	if err != nil {
+		fatal(err)
+	}
+	srv := &http.Server{Handler: s.handler(*web), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		sctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(sctx)
+	}()
+	slog.Info("serving API", "url", fmt.Sprintf("http://%s/api/v1/", ln.Addr()))
+	if *web {
+		slog.Info("serving web dashboard", "url", fmt.Sprintf("http://%s/", ln.Addr()))
+	}
+	if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fatal(err)
+	}
+}
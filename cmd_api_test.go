@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ofauchon/go-speedtest/speedtest"
+)
+
+func TestAPIStartTestValidation(t *testing.T) {
+	ran := make(chan speedtest.Options, 1)
+	s := &apiServer{ctx: context.Background(), opts: speedtest.Options{Target: "http://example.com/file"},
+		run: func(ctx context.Context, opts speedtest.Options) (*speedtest.Result, error) {
+			ran <- opts
+			return &speedtest.Result{}, nil
+		}}
+
+	for _, body := range []string{
+		`{"concurrent": 0}`,
+		`{"concurrent": -4}`,
+		`{"concurrent": 1000000}`,
+		`{"duration": -1}`,
+		`{"duration": 0}`,
+		`{"duration": 86400}`,
+		`{"concurrent": "many"}`,
+	} {
+		w := httptest.NewRecorder()
+		s.startTest(w, httptest.NewRequest(http.MethodPost, "/api/v1/tests", strings.NewReader(body)))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", body, w.Code)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	s.startTest(w, httptest.NewRequest(http.MethodPost, "/api/v1/tests", strings.NewReader(`{"concurrent": 64, "duration": 3600}`)))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status %d, want 202: %s", w.Code, w.Body)
+	}
+	if opts := <-ran; opts.Concurrent != 64 {
+		t.Errorf("test run with %d connections, want 64", opts.Concurrent)
+	}
+
+	// The tests without a duration are bounded too, once the first is done
+	for done := false; !done; time.Sleep(time.Millisecond) {
+		s.mu.Lock()
+		done = s.current == nil
+		s.mu.Unlock()
+	}
+	w = httptest.NewRecorder()
+	s.startTest(w, httptest.NewRequest(http.MethodPost, "/api/v1/tests", nil))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status %d, want 202: %s", w.Code, w.Body)
+	}
+	if opts := <-ran; opts.Duration != apiMaxDuration*time.Second {
+		t.Errorf("test run for %s, want %ds", opts.Duration, apiMaxDuration)
+	}
+}
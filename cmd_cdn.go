@@ -0,0 +1,102 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ofauchon/go-speedtest/speedtest"
+)
+
+// Compare the CDNs serving the same asset
+func cdnCommand(args []string) {
+	fs := flag.NewFlagSet("cdn", flag.ExitOnError)
+	var urls stringList
+	fs.Var(&urls, "url", "URL of the asset on a CDN, optionally named (e.g. cloudfront=https://d1.cloudfront.net/file), repeat for each CDN")
+	urlsFile := fs.String("urls-file", "", "File listing one URL (or name=URL) per line")
+	tf := addTestFlags(fs, downloadFlags|latencyFlags)
+	fs.Set("duration", "10")
+	parseFlags(fs, args)
+
+	if *urlsFile != "" {
+		list, err := readTargetsFile(*urlsFile)
+		if err != nil {
+			fatal(err)
+		}
+		urls = append(urls, list...)
+	}
+	if len(urls) < 2 {
+		fmt.Println("At least two URLs are required.")
+		os.Exit(1)
+	}
+
+	// Every CDN gets the same test, identifying the edge that answers
+	opts := tf.options()
+	opts.Edge = true
+	client := tf.configure(speedtest.NewClient())
+	var tests []compareTest
+	for _, u := range urls {
+		name, target := splitNamedURL(u)
+		tests = append(tests, compareTest{name: name, target: target, run: client.Run})
+	}
+	runCompare(tf, opts, tests, false, printCDNMatrix)
+}
+
+// Split name=URL, the name being optional
+func splitNamedURL(s string) (string, string) {
+	name, u, ok := strings.Cut(s, "=")
+	if !ok || strings.Contains(name, "/") {
+		return "", s
+	}
+	return name, u
+}
+
+// Median time to first byte of the connections
+func medianTTFB(res *speedtest.Result) time.Duration {
+	var ttfb []time.Duration
+	for _, t := range res.Timings {
+		if t.TTFB > 0 {
+			ttfb = append(ttfb, t.TTFB)
+		}
+	}
+	if len(ttfb) == 0 {
+		return 0
+	}
+	slices.Sort(ttfb)
+	return ttfb[len(ttfb)/2]
+}
+
+// Print the CDNs ranked by throughput with their TTFB, latency and the
+// point of presence that served the test
+func printCDNMatrix(w io.Writer, results []comparison) {
+	latencyRank := rankLatency(results)
+	fmt.Fprintf(w, "%-4s %12s %9s %10s %5s  %-28s %s\n", "Rank", "Down Mbps", "TTFB ms", "Ping ms", "Ping#", "Edge", "CDN")
+	for i, c := range results {
+		if c.Result == nil {
+			fmt.Fprintf(w, "%-4s %12s %9s %10s %5s  %-28s %s (%s)\n", "-", "-", "-", "-", "-", "-", c.label(), c.Error)
+			continue
+		}
+		ttfb, ping, pingRank, edge := "-", "-", "-", "-"
+		if d := medianTTFB(c.Result); d > 0 {
+			ttfb = fmt.Sprintf("%.2f", float64(d)/float64(time.Millisecond))
+		}
+		if v, ok := latencyMetric.value(c.Result); ok {
+			ping = fmt.Sprintf("%.2f", v)
+			pingRank = fmt.Sprint(latencyRank[i])
+		}
+		if e := c.Result.Edge; e != nil {
+			edge = e.IP
+			if e.CDN != "" {
+				edge = e.CDN
+				if e.PoP != "" {
+					edge += " " + e.PoP
+				}
+			}
+		}
+		fmt.Fprintf(w, "%-4d %12.2f %9s %10s %5s  %-28s %s\n", i+1, c.Result.BytesPerSecond()*8/1e6, ttfb, ping, pingRank, edge, c.label())
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/ofauchon/go-speedtest/history"
+	"github.com/ofauchon/go-speedtest/speedtest"
+)
+
+// Metrics of the compare report, in order
+var compareMetrics = []resultMetric{
+	downloadMetric,
+	uploadMetric,
+	latencyMetric,
+	{"Jitter", "ms", func(r *speedtest.Result) (float64, bool) {
+		if r.Latency == nil || r.Latency.Received < 2 {
+			return 0, false
+		}
+		return float64(r.Latency.Jitter) / float64(time.Millisecond), true
+	}, true},
+	{"Loss", "%", func(r *speedtest.Result) (float64, bool) {
+		if r.Latency == nil || r.Latency.Sent == 0 {
+			return 0, false
+		}
+		return r.Latency.Loss(), true
+	}, true},
+	loadedMetric("Loaded down", func(b *speedtest.BufferbloatResult) *speedtest.LatencyResult { return b.Download }),
+	loadedMetric("Loaded up", func(b *speedtest.BufferbloatResult) *speedtest.LatencyResult { return b.Upload }),
+	{"RPM", "rpm", func(r *speedtest.Result) (float64, bool) {
+		if r.Responsiveness == nil {
+			return 0, false
+		}
+		return r.Responsiveness.RPM(), true
+	}, false},
+}
+
+// Average latency under load of one of the bufferbloat phases
+func loadedMetric(name string, phase func(*speedtest.BufferbloatResult) *speedtest.LatencyResult) resultMetric {
+	return resultMetric{name, "ms", func(r *speedtest.Result) (float64, bool) {
+		if r.Bufferbloat == nil {
+			return 0, false
+		}
+		l := phase(r.Bufferbloat)
+		if l == nil || l.Received == 0 {
+			return 0, false
+		}
+		return float64(l.Avg) / float64(time.Millisecond), true
+	}, true}
+}
+
+// Change of a metric between two results
+type metricDelta struct {
+	Metric  string  `json:"metric"`
+	Unit    string  `json:"unit"`
+	Before  float64 `json:"before"`
+	After   float64 `json:"after"`
+	Change  float64 `json:"change"`
+	Percent float64 `json:"percent,omitempty"`
+	// The change is an improvement, false for a regression or no change
+	Better bool `json:"better"`
+}
+
+// Compute the change of every metric both results have
+func compareResults(before, after *speedtest.Result) []metricDelta {
+	var deltas []metricDelta
+	for _, m := range compareMetrics {
+		a, okA := m.value(before)
+		b, okB := m.value(after)
+		if !okA || !okB {
+			continue
+		}
+		d := metricDelta{Metric: m.name, Unit: m.unit, Before: a, After: b, Change: b - a}
+		if a != 0 {
+			d.Percent = (b - a) / a * 100
+		}
+		d.Better = d.Change > 0 != m.lowerBetter && d.Change != 0
+		deltas = append(deltas, d)
+	}
+	return deltas
+}
+
+// Read a result given on the command line: a JSON file, or the ID of a run
+// of the history database
+func loadResult(arg, db string) (*speedtest.Result, error) {
+	data, err := os.ReadFile(arg)
+	if errors.Is(err, os.ErrNotExist) {
+		id, perr := strconv.ParseInt(arg, 10, 64)
+		if perr != nil {
+			return nil, err
+		}
+		store, err := history.Open(db)
+		if err != nil {
+			return nil, fmt.Errorf("history: %w", err)
+		}
+		defer store.Close()
+		e, err := store.Get(id)
+		if err != nil {
+			return nil, fmt.Errorf("history: %w", err)
+		}
+		if e == nil {
+			return nil, fmt.Errorf("no run %d in %s", id, db)
+		}
+		return e.Result, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Exports hold an array of results and --output files appended
+	// results, the last one is compared
+	var res *speedtest.Result
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+		var list []*speedtest.Result
+		if err := json.Unmarshal(data, &list); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", arg, err)
+		}
+		if len(list) > 0 {
+			res = list[len(list)-1]
+		}
+	} else {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		for {
+			var r *speedtest.Result
+			if err := dec.Decode(&r); err == io.EOF {
+				break
+			} else if err != nil {
+				return nil, fmt.Errorf("failed to parse %s: %w", arg, err)
+			}
+			res = r
+		}
+	}
+	if res == nil {
+		return nil, fmt.Errorf("no result in %s", arg)
+	}
+	return res, nil
+}
+
+// Print the change of each metric between two results
+func printCompare(w io.Writer, before, after *speedtest.Result, deltas []metricDelta) {
+	fmt.Fprintf(w, "Before: %s  %s\n", before.Start.Local().Format("2006-01-02 15:04:05"), before.Target)
+	fmt.Fprintf(w, "After:  %s  %s\n\n", after.Start.Local().Format("2006-01-02 15:04:05"), after.Target)
+	if len(deltas) == 0 {
+		fmt.Fprintln(w, "No metric measured in both results.")
+		return
+	}
+	fmt.Fprintf(w, "%-12s %12s %12s %12s %9s\n", "Metric", "Before", "After", "Change", "%")
+	for _, d := range deltas {
+		percent := "-"
+		if d.Before != 0 {
+			percent = fmt.Sprintf("%+.1f%%", d.Percent)
+		}
+		verdict := ""
+		if d.Change != 0 {
+			verdict = "  worse"
+			if d.Better {
+				verdict = "  better"
+			}
+		}
+		fmt.Fprintf(w, "%-12s %12.2f %12.2f %+12.2f %9s  %s%s\n", d.Metric, d.Before, d.After, d.Change, percent, d.Unit, verdict)
+	}
+}
+
+// Compare two saved results, before and after a change
+func compareCommand(args []string) {
+	fs := flag.NewFlagSet("compare", flag.ExitOnError)
+	db := fs.String("db", history.DefaultPath, "History database of the run IDs")
+	format := fs.String("format", "text", "Output format (text or json)")
+	parseFlags(fs, args)
+	if fs.NArg() != 2 {
+		fmt.Println("Usage: go-speedtest compare [flags] BEFORE AFTER, each a JSON result file (--format json) or a history run ID.")
+		os.Exit(1)
+	}
+
+	before, err := loadResult(fs.Arg(0), *db)
+	if err != nil {
+		fatal(err)
+	}
+	after, err := loadResult(fs.Arg(1), *db)
+	if err != nil {
+		fatal(err)
+	}
+	deltas := compareResults(before, after)
+
+	switch *format {
+	case "json":
+		if err := printJSON(os.Stdout, deltas); err != nil {
+			fatal(err)
+		}
+	case "text":
+		printCompare(os.Stdout, before, after, deltas)
+	default:
+		fatal(fmt.Errorf("unknown output format %q", *format))
+	}
+}
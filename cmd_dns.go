@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/ofauchon/go-speedtest/speedtest"
+)
+
+// Names looked up when none is given
+var defaultDNSHosts = []string{
+	"google.com", "youtube.com", "facebook.com", "wikipedia.org", "amazon.com",
+	"github.com", "cloudflare.com", "microsoft.com", "apple.com", "netflix.com",
+}
+
+// Compare the resolution latency of DNS resolvers
+func dnsCommand(args []string) {
+	fs := flag.NewFlagSet("dns", flag.ExitOnError)
+	var resolvers, hosts stringList
+	fs.Var(&resolvers, "resolver", "Resolver to benchmark: system, 1.1.1.1:53, tls://1.1.1.1 or an https:// DoH URL (repeatable, defaults to system)")
+	fs.Var(&hosts, "host", "Name to look up (repeatable, defaults to popular sites)")
+	rounds := fs.Int("rounds", 5, "Number of times each name is looked up")
+	timeout := fs.Int("timeout", 2000, "Milliseconds to wait for each answer")
+	format := fs.String("format", "text", "Output format (text or json)")
+	parseFlags(fs, args)
+
+	if len(resolvers) == 0 {
+		resolvers = append(resolvers, "system")
+	}
+	if len(hosts) == 0 {
+		hosts = defaultDNSHosts
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var results []*speedtest.DNSResult
+	for _, r := range resolvers {
+		spec := r
+		if spec == "system" {
+			spec = ""
+		}
+		res, err := speedtest.BenchmarkDNS(ctx, spec, hosts, *rounds, time.Duration(*timeout)*time.Millisecond)
+		if err != nil {
+			fatal(err)
+		}
+		res.Resolver = r
+		results = append(results, res)
+	}
+
+	switch *format {
+	case "json":
+		if err := printJSON(os.Stdout, results); err != nil {
+			fatal(err)
+		}
+	case "text":
+		printDNS(os.Stdout, results)
+	default:
+		fatal(fmt.Errorf("unknown output format %q", *format))
+	}
+}
+
+// Print a table of the resolvers, the fastest on average flagged
+func printDNS(w io.Writer, results []*speedtest.DNSResult) {
+	fastest := -1
+	for i, r := range results {
+		if r.Latency.Received > 0 && (fastest < 0 || r.Latency.Avg < results[fastest].Latency.Avg) {
+			fastest = i
+		}
+	}
+	fmt.Fprintf(w, "%-40s %10s %10s %10s %10s %10s %7s\n", "Resolver", "Min", "Avg", "Median", "Max", "Cold", "Failed")
+	for i, r := range results {
+		lat := r.Latency
+		flag := ""
+		if i == fastest && len(results) > 1 {
+			flag = " (fastest)"
+		}
+		fmt.Fprintf(w, "%-40s %10s %10s %10s %10s %10s %7s%s\n", r.Resolver,
+			lat.Min.Round(time.Microsecond), lat.Avg.Round(time.Microsecond), lat.Median.Round(time.Microsecond),
+			lat.Max.Round(time.Microsecond), r.Cold.Round(time.Microsecond), fmt.Sprintf("%d/%d", lat.Sent-lat.Received, lat.Sent), flag)
+	}
+	for _, r := range results {
+		for _, e := range r.Errors {
+			fmt.Fprintf(w, "%s: %s\n", r.Resolver, e)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/ofauchon/go-speedtest/history"
+	"github.com/ofauchon/go-speedtest/speedtest"
+)
+
+// Export stored runs for spreadsheets or other tools
+func exportCommand(args []string) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	ff := addFilterFlags(fs)
+	format := fs.String("format", "csv", "Export format (csv, json or html)")
+	output := fs.String("output", "", "Write to this file instead of stdout")
+	// The format may also be given first, as in "export html"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		fs.Set("format", args[0])
+		args = args[1:]
+	}
+	parseFlags(fs, args)
+
+	entries := ff.entries()
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		out, err := os.Create(*output)
+		if err != nil {
+			fatal(err)
+		}
+		defer out.Close()
+		w = out
+	}
+	if err := exportEntries(w, *format, entries); err != nil {
+		fatal(err)
+	}
+}
+
+// Write the runs in the given format
+func exportEntries(w io.Writer, format string, entries []history.Entry) error {
+	results := make([]*speedtest.Result, len(entries))
+	for i, e := range entries {
+		results[i] = e.Result
+	}
+	switch format {
+	case "csv":
+		for i, res := range results {
+			if err := printCSV(w, res, i == 0); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "json":
+		return printJSON(w, results)
+	case "html":
+		return printHTML(w, entries)
+	}
+	return fmt.Errorf("unknown export format %q", format)
+}
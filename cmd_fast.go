@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+
+	"github.com/ofauchon/go-speedtest/speedtest"
+)
+
+// Test against the Netflix servers selected by Fast.com
+func fastCommand(args []string) {
+	fs := flag.NewFlagSet("fast", flag.ExitOnError)
+	server := fs.String("server", "", "Use this Netflix server URL instead of asking the Fast.com API")
+	tf := addTestFlags(fs, allFlags)
+	parseFlags(fs, args)
+
+	var servers []speedtest.Server
+	if *server != "" {
+		u, err := url.Parse(*server)
+		if err != nil {
+			fmt.Printf("Invalid server URL: %v\n", err)
+			os.Exit(1)
+		}
+		servers = []speedtest.Server{{Host: u.Host, URL: *server}}
+	}
+
+	client := tf.configure(speedtest.NewClient())
+	runTest(tf, tf.options(), func(ctx context.Context, opts speedtest.Options) (*speedtest.Result, error) {
+		return client.RunFast(ctx, opts, servers)
+	})
+}
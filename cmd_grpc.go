@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/ofauchon/go-speedtest/internal/tui"
+	"github.com/ofauchon/go-speedtest/rpc"
+	"github.com/ofauchon/go-speedtest/speedtest"
+)
+
+// Serve the gRPC service running tests, or run one on a remote server
+// with -connect
+func grpcCommand(args []string) {
+	fs := flag.NewFlagSet("grpc", flag.ExitOnError)
+	target := fs.String("target", "", "Default target URL of the tests")
+	uploadTarget := fs.String("upload-target", "", "Default URL receiving uploads (defaults to target)")
+	listen := fs.String("grpc-listen", ":8091", "Address the gRPC service listens on")
+	token := fs.String("grpc-token", "", "Bearer token required by the server, or sent to it with -connect")
+	connect := fs.String("connect", "", "Run a test on the go-speedtest gRPC server at this address instead of serving")
+	tf := addTestFlags(fs, allFlags)
+	parseFlags(fs, args)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	console := tf.console()
+	tf.setupLogging(console)
+
+	if *connect != "" {
+		// Only the flags given explicitly override those of the server
+		req := &rpc.StartTestRequest{Target: *target, UploadTarget: *uploadTarget}
+		fs.Visit(func(fl *flag.Flag) {
+			switch fl.Name {
+			case "upload":
+				req.Upload = tf.upload
+			case "duration":
+				req.Duration = durationpb.New(time.Duration(*tf.duration) * time.Second)
+			case "concurrent":
+				req.Concurrent = int32(*tf.concurrent)
+			}
+		})
+		remoteTest(ctx, tf, console, *connect, *token, req)
+		return
+	}
+
+	opts := tf.options()
+	opts.Target = *target
+	opts.UploadTarget = *uploadTarget
+	if *token == "" {
+		slog.Warn("the gRPC service accepts calls without authentication, see -grpc-token")
+	}
+
+	var serverOpts []grpc.ServerOption
+	if *token != "" {
+		serverOpts = rpc.Authenticate(*token)
+	}
+	srv := grpc.NewServer(serverOpts...)
+	rpc.RegisterSpeedTestServer(srv, rpc.NewServer(ctx, opts,
+		tf.publishing(console, tf.identifying(tf.configure(speedtest.NewClient()).Run))))
+	ln, err := net.Listen("tcp", *listen)
+	if err != nil {
+		fatal(err)
+	}
+	go func() {
+		<-ctx.Done()
+		srv.GracefulStop()
+	}()
+	slog.Info("serving gRPC", "addr", ln.Addr().String())
+	if err := srv.Serve(ln); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+		fatal(err)
+	}
+}
+
+// Run a test on a remote server, showing its progress, then print its
+// result like a local test
+func remoteTest(ctx context.Context, f *testFlags, console *os.File, addr, token string, req *rpc.StartTestRequest) {
+	client, err := rpc.Dial(addr, token)
+	if err != nil {
+		fatal(err)
+	}
+	defer client.Close()
+
+	units := f.rateUnits()
+	tty := *f.progress && tui.IsTerminal(console)
+	var phase string
+	var next time.Duration
+	t, err := client.Run(ctx, req, func(p *rpc.Progress) {
+		if !*f.progress || p.Phase == "" {
+			return
+		}
+		elapsed := p.Elapsed.AsDuration()
+		if tty {
+			fmt.Fprintf(console, "\r\033[K%s %5.1fs  %s", p.Phase, elapsed.Seconds(), units.Rate(p.RateBps/8))
+			return
+		}
+		// Plain lines every few seconds in logs
+		if p.Phase != phase {
+			phase, next = p.Phase, 0
+		}
+		if elapsed >= next {
+			fmt.Fprintf(console, "%s %s %s: %s\n", time.Now().Format(time.TimeOnly), p.Phase, elapsed.Round(time.Second), units.Rate(p.RateBps/8))
+			next = elapsed.Truncate(tui.PlainInterval) + tui.PlainInterval
+		}
+	})
+	if tty {
+		fmt.Fprint(console, "\r\033[K")
+	}
+	if err != nil {
+		fatal(err)
+	}
+	switch t.State {
+	case rpc.State_STATE_FAILED:
+		fatal(fmt.Errorf("test %d failed: %s", t.Id, t.Error))
+	case rpc.State_STATE_CANCELLED:
+		f.say(console, "The test was cancelled by the server.")
+	}
+	if t.Result == nil {
+		fatal(fmt.Errorf("test %d has no result", t.Id))
+	}
+	res, err := t.Result.Decode()
+	if err != nil {
+		fatal(fmt.Errorf("failed to decode the result: %w", err))
+	}
+	if err := f.writeResult(res); err != nil {
+		fatal(err)
+	}
+	f.checkResults(f.baseline(), res)
+}
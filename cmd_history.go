@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/ofauchon/go-speedtest/history"
+	"github.com/ofauchon/go-speedtest/speedtest"
+)
+
+func init() {
+	registerSink(func(f *testFlags) (Sink, error) {
+		if *f.history == "" {
+			return nil, nil
+		}
+		return sinkFunc{"history", func(_ context.Context, res *speedtest.Result) error {
+			return recordHistory(*f.history, res)
+		}}, nil
+	})
+}
+
+// Store a result in the history database
+func recordHistory(path string, res *speedtest.Result) error {
+	store, err := history.Open(path)
+	if err != nil {
+		return fmt.Errorf("history: %w", err)
+	}
+	defer store.Close()
+	if _, err := store.Add(res); err != nil {
+		return fmt.Errorf("history: %w", err)
+	}
+	return nil
+}
+
+// Parse a date or date-time given on the command line
+func parseDate(s string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q, expected YYYY-MM-DD[ HH:MM]", s)
+}
+
+// Flags selecting stored runs
+type filterFlags struct {
+	db     *string
+	from   *string
+	to     *string
+	target *string
+	last   *int
+}
+
+func addFilterFlags(fs *flag.FlagSet) *filterFlags {
+	return &filterFlags{
+		db:     fs.String("db", history.DefaultPath, "History database"),
+		from:   fs.String("from", "", "Only runs started at or after this date"),
+		to:     fs.String("to", "", "Only runs started before this date"),
+		target: fs.String("target", "", "Only runs against this target"),
+		last:   fs.Int("last", 0, "Only the most recent N runs"),
+	}
+}
+
+// Read the selected runs, exiting on errors
+func (f *filterFlags) entries() []history.Entry {
+	filter := history.Filter{Target: *f.target, Limit: *f.last}
+	var err error
+	if *f.from != "" {
+		if filter.From, err = parseDate(*f.from); err != nil {
+			fatal(err)
+		}
+	}
+	if *f.to != "" {
+		if filter.To, err = parseDate(*f.to); err != nil {
+			fatal(err)
+		}
+	}
+
+	store, err := history.Open(*f.db)
+	if err != nil {
+		fatal(err)
+	}
+	defer store.Close()
+	entries, err := store.List(filter)
+	if err != nil {
+		fatal(err)
+	}
+	return entries
+}
+
+// List stored runs and show aggregate statistics
+func historyCommand(args []string) {
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	ff := addFilterFlags(fs)
+	stats := fs.Bool("stats", false, "Only show the aggregate statistics")
+	parseFlags(fs, args)
+
+	entries := ff.entries()
+	results := make([]*speedtest.Result, len(entries))
+	for i, e := range entries {
+		results[i] = e.Result
+	}
+
+	if !*stats {
+		fmt.Printf("%-6s %-19s %12s %12s %10s  %s\n", "ID", "Date", "Down Mbps", "Up Mbps", "Ping ms", "Target")
+		for _, e := range entries {
+			r := e.Result
+			up, ping := "-", "-"
+			if v, ok := uploadMetric.value(r); ok {
+				up = fmt.Sprintf("%.2f", v)
+			}
+			if v, ok := latencyMetric.value(r); ok {
+				ping = fmt.Sprintf("%.2f", v)
+			}
+			target := r.Target
+			if r.Partial {
+				target += " (partial)"
+			}
+			fmt.Printf("%-6d %-19s %12.2f %12s %10s  %s\n", e.ID, r.Start.Local().Format("2006-01-02 15:04:05"),
+				r.BytesPerSecond()*8/1e6, up, ping, target)
+		}
+		fmt.Println()
+	}
+
+	fmt.Printf("Runs: %d\n", len(entries))
+	for _, m := range resultMetrics {
+		st := summarize(m.values(results))
+		if st.N == 0 {
+			continue
+		}
+		fmt.Printf("%-9s min %.2f / avg %.2f / max %.2f / p50 %.2f / p90 %.2f / p95 %.2f %s\n",
+			m.name, st.Min, st.Avg, st.Max, st.P50, st.P90, st.P95, m.unit)
+	}
+}
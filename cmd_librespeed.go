@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ofauchon/go-speedtest/speedtest"
+)
+
+// Test against the nearest LibreSpeed backend
+func libreSpeedCommand(args []string) {
+	fs := flag.NewFlagSet("librespeed", flag.ExitOnError)
+	list := fs.String("servers", speedtest.LibreSpeedServersURL, "URL or file of a LibreSpeed servers JSON list")
+	server := fs.String("server", "", "Use the LibreSpeed backend installed at this URL instead of a server list")
+	tf := addTestFlags(fs, allFlags)
+	fs.Set("upload", "true")
+	parseFlags(fs, args)
+
+	client := tf.configure(speedtest.NewClient())
+	runTest(tf, tf.options(), func(ctx context.Context, opts speedtest.Options) (*speedtest.Result, error) {
+		var servers []speedtest.LibreSpeedServer
+		var err error
+		switch {
+		case *server != "":
+			servers = []speedtest.LibreSpeedServer{speedtest.NewLibreSpeedServer(*server)}
+		case strings.HasPrefix(*list, "http://") || strings.HasPrefix(*list, "https://"):
+			servers, err = client.LibreSpeedServers(ctx, *list)
+		default:
+			var data []byte
+			if data, err = os.ReadFile(*list); err == nil {
+				servers, err = speedtest.ParseLibreSpeedServers(data)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(servers) == 0 {
+			return nil, fmt.Errorf("no server in %s", *list)
+		}
+		return client.RunLibreSpeed(ctx, opts, servers)
+	})
+}
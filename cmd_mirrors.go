@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ofauchon/go-speedtest/speedtest"
+)
+
+// File downloaded from each mirror, and default release, by distribution
+var distroSamples = map[string]struct{ path, suite string }{
+	"debian": {"dists/{suite}/main/Contents-amd64.gz", "stable"},
+	"ubuntu": {"dists/{suite}/Contents-amd64.gz", "noble"},
+	"arch":   {"extra/os/x86_64/extra.files.tar.gz", ""},
+}
+
+// Rank distribution mirrors by the download speed of a sample file
+func mirrorsCommand(args []string) {
+	fs := flag.NewFlagSet("mirrors", flag.ExitOnError)
+	var list stringList
+	fs.Var(&list, "mirror", "Base URL of a mirror to test, repeat to compare several")
+	listFile := fs.String("mirrors-file", "", "File listing one mirror base URL per line")
+	distro := fs.String("distro", "", "Fetch the mirror list of this distribution ("+strings.Join(speedtest.Distros, ", ")+") when no mirror is given, and pick its sample file and snippet format")
+	country := fs.String("country", "", "Only fetch the mirrors of this country (two letter code)")
+	limit := fs.Int("max", 10, "Test at most this many mirrors of a fetched list")
+	path := fs.String("file", "", "Sample file downloaded from each mirror, relative to its base URL (defaults to a large index of --distro)")
+	suite := fs.String("suite", "", "Release of the sample file and snippet (defaults to stable for Debian, noble for Ubuntu)")
+	snippet := fs.Int("snippet", 0, "Print a sources.list or mirrorlist snippet of this many fastest mirrors")
+	tf := addTestFlags(fs, downloadFlags|latencyFlags)
+	fs.Set("duration", "10")
+	parseFlags(fs, args)
+
+	sample, known := distroSamples[*distro]
+	if *distro != "" && !known {
+		fmt.Printf("Unknown distribution %q.\n", *distro)
+		os.Exit(1)
+	}
+	if *suite == "" {
+		*suite = sample.suite
+	}
+	if *path == "" {
+		*path = strings.ReplaceAll(sample.path, "{suite}", *suite)
+	}
+	if *path == "" {
+		fmt.Println("Sample file path is required without --distro.")
+		os.Exit(1)
+	}
+
+	if *listFile != "" {
+		mirrors, err := readTargetsFile(*listFile)
+		if err != nil {
+			fatal(err)
+		}
+		list = append(list, mirrors...)
+	}
+	client := tf.configure(speedtest.NewClient())
+	if len(list) == 0 {
+		if *distro == "" {
+			fmt.Println("Mirror URL or distribution is required.")
+			os.Exit(1)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		mirrors, err := client.Mirrors(ctx, *distro, *country)
+		cancel()
+		if err != nil {
+			fatal(err)
+		}
+		for _, m := range mirrors[:min(len(mirrors), *limit)] {
+			list = append(list, m.URL)
+		}
+	}
+
+	// Results are labelled with the sample URL, mapped back to its mirror
+	base := map[string]string{}
+	var tests []compareTest
+	for _, m := range list {
+		m = strings.TrimSuffix(m, "/") + "/"
+		target := m + strings.TrimPrefix(*path, "/")
+		base[target] = m
+		tests = append(tests, compareTest{target: target, run: client.Run})
+	}
+	results := runCompare(tf, tf.options(), tests, false, printComparison)
+
+	if *snippet > 0 {
+		var fastest []string
+		for _, c := range results {
+			if c.Result != nil && len(fastest) < *snippet {
+				fastest = append(fastest, base[c.Target])
+			}
+		}
+		// Keep the JSON and CSV outputs parseable
+		w := io.Writer(os.Stdout)
+		if *tf.format != "text" {
+			w = os.Stderr
+		} else {
+			fmt.Fprintln(w)
+		}
+		printMirrorSnippet(w, *distro, *suite, fastest)
+	}
+}
+
+// Print the mirrors in the configuration format of the distribution,
+// plain URLs for an unknown one
+func printMirrorSnippet(w io.Writer, distro, suite string, mirrors []string) {
+	for _, m := range mirrors {
+		switch distro {
+		case "debian":
+			fmt.Fprintf(w, "deb %s %s main\n", m, suite)
+		case "ubuntu":
+			fmt.Fprintf(w, "deb %s %s main restricted universe multiverse\n", m, suite)
+		case "arch":
+			fmt.Fprintf(w, "Server = %s$repo/os/$arch\n", m)
+		default:
+			fmt.Fprintln(w, m)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/ofauchon/go-speedtest/speedtest"
+)
+
+// Find the path MTU to a server and check that path MTU discovery works
+func mtuCommand(args []string) {
+	fs := flag.NewFlagSet("mtu", flag.ExitOnError)
+	target := fs.String("target", "", "Host name, address or URL of the server")
+	minMTU := fs.Int("min", 576, "Smallest packet size tried, IP header included")
+	maxMTU := fs.Int("max", 0, "Largest packet size tried (defaults to the MTU of the outgoing interface)")
+	probes := fs.Int("probes", 2, "Probes sent before a size is considered too large")
+	timeout := fs.Int("timeout", 1000, "Milliseconds to wait for the answer of each probe")
+	format := fs.String("format", "text", "Output format (text or json)")
+	parseFlags(fs, args)
+
+	if *target == "" {
+		fmt.Println("Target is required.")
+		os.Exit(1)
+	}
+	host := *target
+	if u, err := url.Parse(host); err == nil && u.Hostname() != "" {
+		host = u.Hostname()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	res, err := speedtest.DiscoverMTU(ctx, host, speedtest.MTUOptions{
+		Min:     *minMTU,
+		Max:     *maxMTU,
+		Probes:  *probes,
+		Timeout: time.Duration(*timeout) * time.Millisecond,
+	})
+	if err != nil {
+		fatal(err)
+	}
+
+	switch *format {
+	case "json":
+		if err := printJSON(os.Stdout, res); err != nil {
+			fatal(err)
+		}
+	case "text":
+		printMTU(os.Stdout, res)
+	default:
+		fatal(fmt.Errorf("unknown output format %q", *format))
+	}
+}
+
+// Print the path MTU and the diagnosis of path MTU discovery
+func printMTU(w io.Writer, r *speedtest.MTUResult) {
+	fmt.Fprintf(w, "MTU to %s (%s):\n", r.Host, r.IP)
+	fmt.Fprintf(w, "Interface MTU: %d\n", r.LinkMTU)
+	fmt.Fprintf(w, "Path MTU: %d (TCP MSS %d, %d probes)\n", r.PathMTU, r.MSS(), len(r.Probes))
+	switch {
+	case r.BlackHole() && r.ReportedMTU > 0:
+		fmt.Fprintf(w, "PMTUD: BLACK HOLE, the path reports an MTU of %d but drops packets above %d\n", r.ReportedMTU, r.PathMTU)
+	case r.BlackHole():
+		fmt.Fprintf(w, "PMTUD: BLACK HOLE, packets above %d are dropped without ICMP fragmentation needed\n", r.PathMTU)
+	case r.ReportedMTU > 0:
+		fmt.Fprintf(w, "PMTUD: working, the path reports an MTU of %d\n", r.ReportedMTU)
+	default:
+		fmt.Fprintln(w, "PMTUD: not needed, the path carries packets of the interface MTU")
+	}
+	if r.BlackHole() {
+		fmt.Fprintf(w, "Lower the MTU of the interface or tunnel to %d, or clamp the TCP MSS to %d\n", r.PathMTU, r.MSS())
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"net/url"
+
+	"github.com/ofauchon/go-speedtest/speedtest"
+)
+
+// Test against the nearest M-Lab NDT7 server
+func ndt7Command(args []string) {
+	fs := flag.NewFlagSet("ndt7", flag.ExitOnError)
+	download := fs.String("download-url", "", "Use this NDT7 download URL instead of asking the M-Lab locate API")
+	upload := fs.String("upload-url", "", "NDT7 upload URL, used with -download-url")
+	tf := addTestFlags(fs, allFlags)
+	fs.Set("upload", "true")
+	parseFlags(fs, args)
+
+	var server *speedtest.NDT7Server
+	if *download != "" {
+		server = &speedtest.NDT7Server{DownloadURL: *download, UploadURL: *upload}
+		server.URL = *download
+		if u, err := url.Parse(*download); err == nil {
+			server.Host = u.Host
+		}
+	}
+
+	client := tf.configure(speedtest.NewClient())
+	runTest(tf, tf.options(), func(ctx context.Context, opts speedtest.Options) (*speedtest.Result, error) {
+		return client.RunNDT7(ctx, opts, server)
+	})
+}
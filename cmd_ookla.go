@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+
+	"github.com/ofauchon/go-speedtest/speedtest"
+)
+
+// Test against the nearest Speedtest.net server
+func ooklaCommand(args []string) {
+	fs := flag.NewFlagSet("ookla", flag.ExitOnError)
+	server := fs.String("server", "", "Use this server upload URL instead of the nearest public server")
+	tf := addTestFlags(fs, allFlags)
+	fs.Set("upload", "true")
+	parseFlags(fs, args)
+
+	var servers []speedtest.Server
+	if *server != "" {
+		u, err := url.Parse(*server)
+		if err != nil {
+			fmt.Printf("Invalid server URL: %v\n", err)
+			os.Exit(1)
+		}
+		servers = []speedtest.Server{{Host: u.Host, URL: *server}}
+	}
+
+	client := tf.configure(speedtest.NewClient())
+	runTest(tf, tf.options(), func(ctx context.Context, opts speedtest.Options) (*speedtest.Result, error) {
+		if *tf.format == "text" {
+			tf.say(os.Stdout, "Selecting the nearest server...")
+		}
+		return client.RunOokla(ctx, opts, servers)
+	})
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/ofauchon/go-speedtest/speedtest"
+)
+
+// Trace the hops to a server with their loss and latency, like mtr
+func pathCommand(args []string) {
+	fs := flag.NewFlagSet("path", flag.ExitOnError)
+	target := fs.String("target", "", "Host name, address or URL of the server")
+	probes := fs.Int("probes", 10, "Number of probes sent to each hop")
+	maxHops := fs.Int("max-hops", 30, "Highest TTL probed")
+	timeout := fs.Int("timeout", 1000, "Milliseconds to wait for the answers of each round of probes")
+	noDNS := fs.Bool("no-dns", false, "Don't look up the names of the hops")
+	format := fs.String("format", "text", "Output format (text or json)")
+	parseFlags(fs, args)
+
+	if *target == "" {
+		fmt.Println("Target is required.")
+		os.Exit(1)
+	}
+	host := *target
+	if u, err := url.Parse(host); err == nil && u.Hostname() != "" {
+		host = u.Hostname()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	path, err := speedtest.TracePath(ctx, host, speedtest.PathOptions{
+		MaxHops:      *maxHops,
+		Probes:       *probes,
+		Timeout:      time.Duration(*timeout) * time.Millisecond,
+		NoReverseDNS: *noDNS,
+	})
+	if err != nil {
+		fatal(err)
+	}
+
+	switch *format {
+	case "json":
+		if err := printJSON(os.Stdout, path); err != nil {
+			fatal(err)
+		}
+	case "text":
+		printPath(os.Stdout, path)
+	default:
+		fatal(fmt.Errorf("unknown output format %q", *format))
+	}
+}
+
+// Print a table of the hops with their loss and round trip times
+func printPath(w io.Writer, p *speedtest.PathResult) {
+	fmt.Fprintf(w, "Path to %s (%s):\n", p.Host, p.IP)
+	fmt.Fprintf(w, "%3s  %-50s %6s %5s %9s %9s %9s %9s\n", "TTL", "Host", "Loss", "Sent", "Best", "Avg", "Worst", "Jitter")
+	for _, h := range p.Hops {
+		lat := h.Latency
+		name := h.Addr
+		switch {
+		case name == "":
+			name = "???"
+		case h.Name != "":
+			name = h.Name + " (" + h.Addr + ")"
+		}
+		if lat.Received == 0 {
+			fmt.Fprintf(w, "%3d  %-50s %5.1f%% %5d\n", h.TTL, name, lat.Loss(), lat.Sent)
+			continue
+		}
+		fmt.Fprintf(w, "%3d  %-50s %5.1f%% %5d %9s %9s %9s %9s\n", h.TTL, name, lat.Loss(), lat.Sent,
+			ms(lat.Min), ms(lat.Avg), ms(lat.Max), ms(lat.Jitter))
+	}
+	if !p.Reached {
+		fmt.Fprintf(w, "%s did not answer\n", p.Host)
+	}
+}
+
+// Format a duration in milliseconds with 0.1ms precision
+func ms(d time.Duration) string {
+	return fmt.Sprintf("%.1fms", float64(d)/float64(time.Millisecond))
+}
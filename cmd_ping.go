@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/ofauchon/go-speedtest/speedtest"
+)
+
+// Measure the latency to a server with the best probe method available
+func pingCommand(args []string) {
+	fs := flag.NewFlagSet("ping", flag.ExitOnError)
+	target := fs.String("target", "", "Host name, address or URL of the server (the URL port is used by tcp probes)")
+	method := fs.String("method", speedtest.ProbeAuto, "Probe method (auto, icmp, udp, tcp or http)")
+	count := fs.Int("count", 10, "Number of probes sent")
+	interval := fs.Int("interval", 1000, "Milliseconds between probes")
+	timeout := fs.Int("timeout", 2000, "Milliseconds to wait for each answer")
+	format := fs.String("format", "text", "Output format (text or json)")
+	parseFlags(fs, args)
+
+	if *target == "" {
+		fmt.Println("Target is required.")
+		os.Exit(1)
+	}
+	// The probes take a URL, plain hosts are pinged on port 80
+	url := *target
+	if !strings.Contains(url, "://") {
+		url = "http://" + url
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	text := *format == "text"
+	onProbe := func(seq int, rtt time.Duration, err error) {
+		switch {
+		case !text:
+		case err != nil:
+			fmt.Printf("seq=%d: %v\n", seq, err)
+		default:
+			fmt.Printf("seq=%d time=%s\n", seq, ms(rtt))
+		}
+	}
+	res, err := speedtest.NewClient().Ping(ctx, url, *method, *count,
+		time.Duration(*interval)*time.Millisecond, time.Duration(*timeout)*time.Millisecond, onProbe)
+	if err != nil {
+		fatal(err)
+	}
+
+	switch *format {
+	case "json":
+		if err := printJSON(os.Stdout, res); err != nil {
+			fatal(err)
+		}
+	case "text":
+		fmt.Printf("--- %s %s ping statistics ---\n", *target, res.Method)
+		fmt.Printf("%d sent, %d received, %.1f%% loss\n", res.Sent, res.Received, res.Loss())
+		if res.Received > 0 {
+			fmt.Printf("min/avg/median/max/jitter = %s/%s/%s/%s/%s\n", ms(res.Min), ms(res.Avg), ms(res.Median), ms(res.Max), ms(res.Jitter))
+		}
+	default:
+		fatal(fmt.Errorf("unknown output format %q", *format))
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+
+	"github.com/ofauchon/go-speedtest/speedtest"
+)
+
+// Measure the pull speed of a container image from its registry
+func registryCommand(args []string) {
+	fs := flag.NewFlagSet("registry", flag.ExitOnError)
+	image := fs.String("image", "", "Image to pull (e.g. nginx:latest, ghcr.io/owner/app@sha256:...), prefix with http:// for plain HTTP registries")
+	platform := fs.String("platform", "linux/"+runtime.GOARCH, "Platform picked from multi-platform images")
+	credentials := fs.String("registry-auth", "", "user:password to log in to the registry, anonymous pull by default")
+	tf := addTestFlags(fs, downloadFlags|latencyFlags)
+	parseFlags(fs, args)
+
+	if *image == "" {
+		fmt.Println("Image is required.")
+		os.Exit(1)
+	}
+
+	client := tf.configure(speedtest.NewClient())
+	runTest(tf, tf.options(), func(ctx context.Context, opts speedtest.Options) (*speedtest.Result, error) {
+		return client.RunRegistry(ctx, opts, *image, *platform, *credentials)
+	})
+}
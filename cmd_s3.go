@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ofauchon/go-speedtest/speedtest"
+)
+
+// Test an object of an S3 compatible storage with signed requests
+func s3Command(args []string) {
+	fs := flag.NewFlagSet("s3", flag.ExitOnError)
+	endpoint := fs.String("endpoint", "", "S3 compatible endpoint URL (e.g. http://minio:9000), AWS S3 if empty")
+	region := fs.String("region", os.Getenv("AWS_REGION"), "Region signing the requests (defaults to $AWS_REGION or us-east-1)")
+	bucket := fs.String("bucket", "", "Bucket of the objects")
+	key := fs.String("object", "", "Key of the object downloaded with ranged GETs")
+	uploadKey := fs.String("upload-object", "", "Key of the object written by the multipart upload (defaults to OBJECT.upload)")
+	accessKey := fs.String("access-key", os.Getenv("AWS_ACCESS_KEY_ID"), "Access key ID (defaults to $AWS_ACCESS_KEY_ID)")
+	secretKey := fs.String("secret-key", "", "Secret access key (defaults to $AWS_SECRET_ACCESS_KEY)")
+	pathStyle := fs.Bool("path-style", false, "Address the bucket in the URL path, always done with -endpoint")
+	tf := addTestFlags(fs, allFlags)
+	parseFlags(fs, args)
+
+	if *bucket == "" || *key == "" {
+		fmt.Println("Bucket and object are required.")
+		os.Exit(1)
+	}
+	// Keep the secret out of the process list
+	if *secretKey == "" {
+		*secretKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+	}
+	if *accessKey == "" || *secretKey == "" {
+		fmt.Println("Access and secret keys are required.")
+		os.Exit(1)
+	}
+
+	cfg := speedtest.S3Config{
+		Endpoint:     *endpoint,
+		Region:       *region,
+		Bucket:       *bucket,
+		Key:          *key,
+		UploadKey:    *uploadKey,
+		AccessKey:    *accessKey,
+		SecretKey:    *secretKey,
+		SessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		PathStyle:    *pathStyle,
+	}
+	client := tf.configure(speedtest.NewClient())
+	runTest(tf, tf.options(), func(ctx context.Context, opts speedtest.Options) (*speedtest.Result, error) {
+		return client.RunS3(ctx, opts, cfg)
+	})
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/ofauchon/go-speedtest/speedtest"
+)
+
+// Run the built-in test server
+func serveCommand(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	listen := fs.String("listen", ":8080", "Address to listen on")
+	size := fs.Int64("size", speedtest.DefaultServeSize, "Size in bytes of the download file")
+	parseFlags(fs, args)
+
+	base, err := listenURL(*listen)
+	if err != nil {
+		fatal(err)
+	}
+	fmt.Println("Go SpeedTest server")
+	fmt.Printf("Listening on %s\n", *listen)
+	fmt.Printf("Download: %s/download\n", base)
+	fmt.Printf("Upload: %s/upload\n", base)
+	fmt.Printf("Ping: %s/ping\n", base)
+
+	if err := http.ListenAndServe(*listen, speedtest.Handler(*size)); err != nil {
+		fatal(err)
+	}
+}
+
+// Base URL of the server listening on addr, HOST standing for the name of
+// the machine when it listens on all addresses
+func listenURL(addr string) (string, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", err
+	}
+	if ip := net.ParseIP(host); host == "" || ip != nil && ip.IsUnspecified() {
+		host = "HOST"
+	}
+	return "http://" + net.JoinHostPort(host, port), nil
+}
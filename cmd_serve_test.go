@@ -0,0 +1,25 @@
+package main
+
+import "testing"
+
+func TestListenURL(t *testing.T) {
+	tests := []struct{ listen, want string }{
+		{":8080", "http://HOST:8080"},
+		{"127.0.0.1:8080", "http://127.0.0.1:8080"},
+		{"0.0.0.0:80", "http://HOST:80"},
+		{"[::]:8080", "http://HOST:8080"},
+		{"[::1]:8080", "http://[::1]:8080"},
+		{"speedtest.lan:9000", "http://speedtest.lan:9000"},
+	}
+	for _, tt := range tests {
+		got, err := listenURL(tt.listen)
+		if err != nil {
+			t.Errorf("listenURL(%q): %v", tt.listen, err)
+		} else if got != tt.want {
+			t.Errorf("listenURL(%q) = %q, want %q", tt.listen, got, tt.want)
+		}
+	}
+	if _, err := listenURL("8080"); err == nil {
+		t.Error("listenURL(\"8080\") succeeded, want an error")
+	}
+}
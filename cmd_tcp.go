@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/ofauchon/go-speedtest/speedtest"
+)
+
+// Raw TCP throughput test, client or server role
+func tcpCommand(args []string) {
+	fs := flag.NewFlagSet("tcp", flag.ExitOnError)
+	server := fs.Bool("server", false, "Run as server, listening on -listen (default :5201)")
+	target := fs.String("target", "", "Address (host:port) of the server to test against")
+	tf := addTestFlags(fs, allFlags)
+	fs.Set("ping-method", speedtest.ProbeTCP)
+	fs.Set("interval", "1")
+	parseFlags(fs, args)
+
+	if *server {
+		// -listen is the server address in this role
+		listen := *tf.listen
+		if listen == "" {
+			listen = ":5201"
+		}
+		ln, err := net.Listen("tcp", listen)
+		if err != nil {
+			fatal(err)
+		}
+		fmt.Println("Go SpeedTest TCP server")
+		fmt.Printf("Listening on %s\n", ln.Addr())
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		if err := speedtest.TCPServe(ctx, ln); err != nil {
+			fatal(err)
+		}
+		return
+	}
+
+	if *target == "" {
+		fmt.Println("Target address is required.")
+		os.Exit(1)
+	}
+	opts := tf.options()
+	opts.Target = *target
+
+	runTest(tf, opts, speedtest.NewClient().RunTCP)
+}
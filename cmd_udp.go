@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/ofauchon/go-speedtest/speedtest"
+)
+
+// UDP throughput and loss test, client or server role
+func udpCommand(args []string) {
+	fs := flag.NewFlagSet("udp", flag.ExitOnError)
+	server := fs.Bool("server", false, "Run as server")
+	listen := fs.String("listen", ":5201", "Address the server listens on")
+	target := fs.String("target", "", "Address (host:port) of the server to test against")
+	rate := fs.Float64("rate", speedtest.DefaultUDPRate/1e6, "Sending rate in Mbit/sec")
+	size := fs.Int("size", speedtest.DefaultUDPPacketSize, "Datagram size in bytes")
+	duration := fs.Int("duration", 10, "Test duration in seconds")
+	format := fs.String("format", "text", "Output format (text or json)")
+	parseFlags(fs, args)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if *server {
+		conn, err := net.ListenPacket("udp", *listen)
+		if err != nil {
+			fatal(err)
+		}
+		fmt.Println("Go SpeedTest UDP server")
+		fmt.Printf("Listening on %s\n", conn.LocalAddr())
+		if err := speedtest.UDPServe(ctx, conn); err != nil {
+			fatal(err)
+		}
+		return
+	}
+
+	if *target == "" {
+		fmt.Println("Target address is required.")
+		os.Exit(1)
+	}
+	if *format == "text" {
+		fmt.Println("Go SpeedTest")
+	}
+	res, err := speedtest.NewClient().RunUDP(ctx, speedtest.UDPOptions{
+		Target:     *target,
+		Rate:       int64(*rate * 1e6),
+		PacketSize: *size,
+		Duration:   time.Duration(*duration) * time.Second,
+	})
+	if err != nil {
+		fatal(err)
+	}
+
+	if *format == "json" {
+		printJSON(os.Stdout, res)
+		return
+	}
+	fmt.Printf("Summary:\n")
+	fmt.Printf("Server: %s\n", res.Target)
+	fmt.Printf("Target Rate: %.2f Mbit/sec (%d bytes datagrams)\n", float64(res.Rate)/1e6, res.PacketSize)
+	fmt.Printf("Test Time: %s\n", res.Elapsed)
+	fmt.Printf("Datagrams: %d sent, %d received, %d reordered\n", res.Sent, res.Received, res.Reordered)
+	fmt.Printf("Packet Loss: %.2f%%\n", res.Loss())
+	fmt.Printf("Jitter: %s\n", res.Jitter)
+	fmt.Printf("Throughput: %.2f Mbit/sec\n", res.BytesPerSecond()*8/1e6)
+}
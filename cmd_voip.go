@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/ofauchon/go-speedtest/speedtest"
+)
+
+// Realtime traffic simulation against a UDP server, optionally while a
+// download or upload saturates the link
+func voipCommand(args []string) {
+	fs := flag.NewFlagSet("voip", flag.ExitOnError)
+	target := fs.String("target", "", "Address (host:port) of a go-speedtest UDP server")
+	traffic := fs.String("traffic", "voip", "Traffic profile (voip or game)")
+	size := fs.Int("size", 0, "Datagram size in bytes, instead of the profile's")
+	interval := fs.Duration("interval", 0, "Interval between datagrams, instead of the profile's")
+	duration := fs.Int("duration", 10, "Test duration in seconds")
+	load := fs.String("load", "", "URL downloaded meanwhile to saturate the link")
+	loadUpload := fs.Bool("load-upload", false, "Upload to the -load URL instead, saturating the uplink")
+	concurrent := fs.Int("concurrent", 4, "Connections of the load test")
+	format := fs.String("format", "text", "Output format (text or json)")
+	parseFlags(fs, args)
+
+	if *target == "" {
+		fmt.Println("Target address is required.")
+		os.Exit(1)
+	}
+	p, ok := speedtest.VoIPProfiles[*traffic]
+	if !ok {
+		fmt.Println("Traffic must be voip or game.")
+		os.Exit(1)
+	}
+	if *size > 0 {
+		p.PacketSize = *size
+	}
+	if *interval > 0 {
+		p.Interval = *interval
+	}
+	opts := speedtest.VoIPOptions{
+		Target:   *target,
+		Profile:  p,
+		Duration: time.Duration(*duration) * time.Second,
+	}
+	if *load != "" {
+		opts.Load = &speedtest.Options{Target: *load, Concurrent: *concurrent}
+		if *loadUpload {
+			opts.Load.SkipDownload = true
+			opts.Load.Upload = true
+			opts.Load.UploadTarget = *load
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if *format == "text" {
+		fmt.Println("Go SpeedTest")
+	}
+	res, err := speedtest.NewClient().RunVoIP(ctx, opts)
+	if err != nil {
+		fatal(err)
+	}
+
+	if *format == "json" {
+		printJSON(os.Stdout, res)
+		return
+	}
+	lat := res.Latency
+	fmt.Printf("Summary:\n")
+	fmt.Printf("Server: %s\n", res.Target)
+	fmt.Printf("Profile: %s (%d bytes every %s)\n", res.Profile.Name, res.Profile.PacketSize, res.Profile.Interval)
+	fmt.Printf("Test Time: %s\n", res.Elapsed)
+	fmt.Printf("Datagrams: %d sent, %d received, %d reordered\n", lat.Sent, lat.Received, res.Reordered)
+	fmt.Printf("Packet Loss: %.2f%%\n", lat.Loss())
+	fmt.Printf("Round Trip: min %s, avg %s, max %s\n", lat.Min, lat.Avg, lat.Max)
+	fmt.Printf("Jitter: %s\n", lat.Jitter)
+	if l := res.Load; l != nil {
+		if l.Upload != nil {
+			fmt.Printf("Load: %.2f Mbit/sec upload\n", l.Upload.BytesPerSecond()*8/1e6)
+		} else {
+			fmt.Printf("Load: %.2f Mbit/sec download\n", l.BytesPerSecond()*8/1e6)
+		}
+	}
+	fmt.Printf("MOS: %.2f (R factor %.0f)\n", res.MOS(), res.RFactor())
+	fmt.Printf("Gaming: %s\n", res.Gaming())
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/ofauchon/go-speedtest/speedtest"
+)
+
+// Run the same test through a VPN interface and the physical interface
+// below it, and report what the tunnel costs
+func vpnCommand(args []string) {
+	fs := flag.NewFlagSet("vpn", flag.ExitOnError)
+	target := fs.String("target", "", "HTTP remote URL for speed testing")
+	uploadTarget := fs.String("upload-target", "", "HTTP remote URL receiving uploads (defaults to target)")
+	tunnel := fs.String("vpn", "", "VPN interface (e.g. wg0 or tun0)")
+	physical := fs.String("physical", "", "Physical interface the tunnel runs over (e.g. eth0 or wlan0)")
+	tf := addTestFlags(fs, allFlags)
+	parseFlags(fs, args)
+
+	if *target == "" || *tunnel == "" || *physical == "" {
+		fmt.Println("Target URL, VPN and physical interfaces are required.")
+		os.Exit(1)
+	}
+
+	opts := tf.options()
+	opts.UploadTarget = *uploadTarget
+	// The same flags for both clients, but the interface
+	vpnName, physicalName := "VPN ("+*tunnel+")", "Physical ("+*physical+")"
+	*tf.iface = *tunnel
+	vpnClient := tf.configure(speedtest.NewClient())
+	*tf.iface = *physical
+	physicalClient := tf.configure(speedtest.NewClient())
+	tests := []compareTest{
+		{name: physicalName, target: *target, run: physicalClient.Run},
+		{name: vpnName, target: *target, run: vpnClient.Run},
+	}
+	runCompare(tf, opts, tests, false, func(w io.Writer, results []comparison) {
+		printVPNOverhead(w, results, physicalName, vpnName)
+	})
+}
+
+// Print the metrics of both tests and the overhead of the tunnel
+func printVPNOverhead(w io.Writer, results []comparison, physicalName, vpnName string) {
+	var physical, vpn *speedtest.Result
+	for _, c := range results {
+		if c.Result == nil {
+			fmt.Fprintf(w, "%s: %s\n", c.label(), c.Error)
+			continue
+		}
+		switch c.Name {
+		case physicalName:
+			physical = c.Result
+		case vpnName:
+			vpn = c.Result
+		}
+	}
+	if physical == nil || vpn == nil {
+		return
+	}
+	deltas := compareResults(physical, vpn)
+	if len(deltas) == 0 {
+		fmt.Fprintln(w, "No metric measured by both tests.")
+		return
+	}
+	fmt.Fprintf(w, "%-12s %16s %16s %12s %9s\n", "Metric", physicalName, vpnName, "Overhead", "%")
+	for _, d := range deltas {
+		percent := "-"
+		if d.Before != 0 {
+			percent = fmt.Sprintf("%+.1f%%", d.Percent)
+		}
+		fmt.Fprintf(w, "%-12s %16.2f %16.2f %+12.2f %9s  %s\n", d.Metric, d.Before, d.After, d.Change, percent, d.Unit)
+	}
+}
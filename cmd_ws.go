@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/ofauchon/go-speedtest/speedtest"
+)
+
+// WebSocket echo latency and throughput test, client or server role
+func wsCommand(args []string) {
+	fs := flag.NewFlagSet("ws", flag.ExitOnError)
+	server := fs.Bool("server", false, "Run as server, listening on -listen (default :8765)")
+	target := fs.String("target", "", "WebSocket URL of the server to test against (e.g. ws://host:8765/ws)")
+	messageSize := fs.Int("message-size", speedtest.DefaultWSMessage, "Size in bytes of the messages of the throughput phases")
+	tf := addTestFlags(fs, allFlags)
+	parseFlags(fs, args)
+
+	if *server {
+		// -listen is the server address in this role
+		listen := *tf.listen
+		if listen == "" {
+			listen = ":8765"
+		}
+		ln, err := net.Listen("tcp", listen)
+		if err != nil {
+			fatal(err)
+		}
+		fmt.Println("Go SpeedTest WebSocket server")
+		fmt.Printf("Listening on ws://%s/ws\n", ln.Addr())
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		srv := &http.Server{Handler: speedtest.WSHandler()}
+		go func() {
+			<-ctx.Done()
+			srv.Close()
+		}()
+		if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+			fatal(err)
+		}
+		return
+	}
+
+	if *target == "" {
+		fmt.Println("Target URL is required.")
+		os.Exit(1)
+	}
+	opts := tf.options()
+	opts.Target = *target
+
+	client := tf.configure(speedtest.NewClient())
+	runTest(tf, opts, func(ctx context.Context, opts speedtest.Options) (*speedtest.Result, error) {
+		return client.RunWS(ctx, opts, *messageSize)
+	})
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/ofauchon/go-speedtest/speedtest"
+)
+
+// Repeatable string flag
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+// Read one target per line, skipping blank lines and # comments
+func readTargetsFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var targets []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			targets = append(targets, line)
+		}
+	}
+	return targets, sc.Err()
+}
+
+// One of the compared tests
+type compareTest struct {
+	name     string
+	target   string
+	protocol string
+	run      func(context.Context, speedtest.Options) (*speedtest.Result, error)
+}
+
+// Outcome of one of the compared tests
+type comparison struct {
+	Name     string            `json:"name,omitempty"`
+	Target   string            `json:"target"`
+	Protocol string            `json:"protocol,omitempty"`
+	Error    string            `json:"error,omitempty"`
+	Result   *speedtest.Result `json:"result,omitempty"`
+}
+
+// Run every test, one after the other or all at once, and print them
+// ranked by throughput, with table in text format. The ranked results are
+// returned.
+func runCompare(f *testFlags, opts speedtest.Options, tests []compareTest, parallel bool, table func(io.Writer, []comparison)) []comparison {
+	console := f.console()
+	f.setupLogging(console)
+	f.say(console, "Go SpeedTest")
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	results := make([]comparison, len(tests))
+	test := func(i int) {
+		o := opts
+		o.Target = tests[i].target
+		res, err := f.publishing(console, f.identifying(tests[i].run))(ctx, o)
+		results[i] = comparison{Name: tests[i].name, Target: tests[i].target, Protocol: tests[i].protocol, Result: res}
+		if err != nil {
+			results[i].Error = err.Error()
+		}
+	}
+
+	if parallel {
+		var wg sync.WaitGroup
+		for i := range tests {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				test(i)
+			}(i)
+		}
+		wg.Wait()
+	} else {
+		for i := range tests {
+			if ctx.Err() != nil {
+				break
+			}
+			f.say(console, "Testing %s", tests[i].label())
+			test(i)
+		}
+	}
+	// Drop tests skipped after an interrupt
+	done := results[:0]
+	for _, c := range results {
+		if c.Target != "" {
+			done = append(done, c)
+		}
+	}
+	results = done
+
+	// Best throughput first, failed tests last
+	sort.SliceStable(results, func(i, j int) bool {
+		a, b := results[i].Result, results[j].Result
+		if a == nil || b == nil {
+			return b == nil && a != nil
+		}
+		return a.BytesPerSecond() > b.BytesPerSecond()
+	})
+
+	switch *f.format {
+	case "json":
+		printJSON(os.Stdout, results)
+	case "csv":
+		for i, c := range results {
+			if c.Result != nil {
+				printCSV(os.Stdout, c.Result, i == 0)
+			}
+		}
+	case "influx":
+		for _, c := range results {
+			if c.Result != nil {
+				printInflux(os.Stdout, c.Result)
+			}
+		}
+	case "junit":
+		var suites []junitSuite
+		for _, c := range results {
+			if c.Result != nil {
+				suites = append(suites, f.junitSuite(c.Result, f.baseline()))
+			}
+		}
+		printJUnit(os.Stdout, suites...)
+	default:
+		table(os.Stdout, results)
+	}
+	return results
+}
+
+func (t compareTest) label() string {
+	if t.name != "" {
+		return t.name
+	}
+	if t.protocol != "" {
+		return t.protocol + " " + t.target
+	}
+	return t.target
+}
+
+func (c comparison) label() string {
+	return compareTest{name: c.Name, target: c.Target, protocol: c.Protocol}.label()
+}
+
+// Print the comparison table, with the latency rank next to each target
+func printComparison(w io.Writer, results []comparison) {
+	latencyRank := rankLatency(results)
+	fmt.Fprintf(w, "%-4s %12s %12s %10s %5s  %s\n", "Rank", "Down Mbps", "Up Mbps", "Ping ms", "Ping#", "Test")
+	for i, c := range results {
+		if c.Result == nil {
+			fmt.Fprintf(w, "%-4s %12s %12s %10s %5s  %s (%s)\n", "-", "-", "-", "-", "-", c.label(), c.Error)
+			continue
+		}
+		up, ping, pingRank := "-", "-", "-"
+		if v, ok := uploadMetric.value(c.Result); ok {
+			up = fmt.Sprintf("%.2f", v)
+		}
+		if v, ok := latencyMetric.value(c.Result); ok {
+			ping = fmt.Sprintf("%.2f", v)
+			pingRank = fmt.Sprint(latencyRank[i])
+		}
+		fmt.Fprintf(w, "%-4d %12.2f %12s %10s %5s  %s\n", i+1, c.Result.BytesPerSecond()*8/1e6, up, ping, pingRank, c.label())
+	}
+}
+
+// Rank of each result by average latency, from 1, for those measuring it
+func rankLatency(results []comparison) map[int]int {
+	var byLatency []int
+	for i, c := range results {
+		if c.Result == nil {
+			continue
+		}
+		if _, ok := latencyMetric.value(c.Result); ok {
+			byLatency = append(byLatency, i)
+		}
+	}
+	sort.SliceStable(byLatency, func(i, j int) bool {
+		return results[byLatency[i]].Result.Latency.Avg < results[byLatency[j]].Result.Latency.Avg
+	})
+	latencyRank := make(map[int]int)
+	for rank, i := range byLatency {
+		latencyRank[i] = rank + 1
+	}
+	return latencyRank
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Prefix of the environment variables overriding the flags, e.g.
+// GO_SPEEDTEST_CONCURRENT=8
+const envPrefix = "GO_SPEEDTEST_"
+
+// Config file content: flag values applied to every command, and named
+// profiles selected with -profile. Keys are flag names, lists set the
+// repeatable flags (target, header) several times.
+type config struct {
+	Defaults map[string]any            `yaml:"defaults"`
+	Profiles map[string]map[string]any `yaml:"profiles"`
+}
+
+// Default location of the config file, ~/.config/go-speedtest/config.yaml
+// on Linux
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "go-speedtest", "config.yaml")
+}
+
+// Parse the command line, then fill the flags it didn't set from the
+// environment, the selected profile and the config defaults, in this order
+// of precedence. Exits on errors.
+func parseFlags(set *flag.FlagSet, args []string) {
+	path := set.String("config", defaultConfigPath(), "Configuration file with default flag values and profiles")
+	profile := set.String("profile", os.Getenv(envPrefix+"PROFILE"), "Name of the configuration profile to apply")
+	set.Parse(args)
+
+	explicit := map[string]bool{}
+	set.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+
+	cfg, err := loadConfig(*path, explicit["config"])
+	if err != nil {
+		fatal(err)
+	}
+	values := map[string]any{}
+	for k, v := range cfg.Defaults {
+		values[k] = v
+	}
+	if *profile != "" {
+		p, ok := cfg.Profiles[*profile]
+		if !ok {
+			fatal(fmt.Errorf("unknown profile %q in %s", *profile, *path))
+		}
+		for k, v := range p {
+			values[k] = v
+		}
+	}
+
+	set.VisitAll(func(f *flag.Flag) {
+		if explicit[f.Name] || f.Name == "config" || f.Name == "profile" {
+			return
+		}
+		v, ok := values[f.Name]
+		if env, set := os.LookupEnv(envPrefix + strings.ToUpper(strings.ReplaceAll(f.Name, "-", "_"))); set {
+			v, ok = env, true
+			// Repeatable flags take a comma separated list
+			if isRepeatable(f) {
+				var list []any
+				for _, item := range strings.Split(env, ",") {
+					list = append(list, strings.TrimSpace(item))
+				}
+				v = list
+			}
+		}
+		if !ok {
+			return
+		}
+		list, isList := v.([]any)
+		if !isList {
+			list = []any{v}
+		}
+		for _, item := range list {
+			if err := set.Set(f.Name, fmt.Sprint(item)); err != nil {
+				fatal(fmt.Errorf("invalid value %v for %s: %w", item, f.Name, err))
+			}
+		}
+	})
+	// The probe mode changes the defaults of the flags still unset
+	if p := set.Lookup("probe"); p != nil && p.Value.String() == "true" {
+		set.Visit(func(f *flag.Flag) {
+			explicit[f.Name] = true
+		})
+		for name, v := range probeDefaults {
+			if !explicit[name] && set.Lookup(name) != nil {
+				set.Set(name, v)
+			}
+		}
+	}
+}
+
+// Read the config file. A missing file is only an error if it was asked
+// for on the command line.
+func loadConfig(path string, required bool) (*config, error) {
+	cfg := &config{}
+	if path == "" {
+		return cfg, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) && !required {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// Repeatable flags accumulate their values instead of replacing them
+func isRepeatable(f *flag.Flag) bool {
+	_, ok := f.Value.(*stringList)
+	return ok
+}
@@ -0,0 +1,444 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/ofauchon/go-speedtest/speedtest"
+)
+
+// Longest a job request of an agent waits for a job
+const agentPollTimeout = 30 * time.Second
+
+// Agents not heard from for this long are offline and get no jobs
+const agentOfflineAfter = 2 * agentPollTimeout
+
+// Jobs running for longer than this are given up, their agent being
+// presumed dead
+const agentJobTimeout = 15 * time.Minute
+
+// Jobs waiting per agent, and results kept in memory by the controller
+const (
+	agentQueueSize        = 20
+	controllerKeptResults = 1000
+)
+
+// Registration of an agent
+type agentInfo struct {
+	// Base URL of the built-in test server of the agent, tested by the
+	// other agents in mesh mode
+	ServeURL string `json:"serve_url,omitempty"`
+}
+
+// Test the controller asks an agent to run
+type agentJob struct {
+	ID           int64  `json:"id"`
+	Target       string `json:"target"`
+	UploadTarget string `json:"upload_target,omitempty"`
+	Upload       bool   `json:"upload"`
+	// Agent whose server is tested, for mesh tests
+	Peer string `json:"peer,omitempty"`
+}
+
+// Outcome of a job, sent back by the agent
+type agentReport struct {
+	Job    int64             `json:"job"`
+	Error  string            `json:"error,omitempty"`
+	Result *speedtest.Result `json:"result,omitempty"`
+}
+
+// Agent registered with the controller
+type controllerAgent struct {
+	Name     string         `json:"name"`
+	ServeURL string         `json:"serve_url,omitempty"`
+	Seen     time.Time      `json:"last_seen"`
+	Online   bool           `json:"online"`
+	Running  *agentJob      `json:"running,omitempty"`
+	Queued   int            `json:"queued"`
+	Last     *resultSummary `json:"last_result,omitempty"`
+	Error    string         `json:"last_error,omitempty"`
+
+	queue   []agentJob
+	started time.Time
+}
+
+// Result received from an agent
+type controllerResult struct {
+	Agent    string            `json:"agent"`
+	Job      agentJob          `json:"job"`
+	Received time.Time         `json:"received"`
+	Error    string            `json:"error,omitempty"`
+	Summary  *resultSummary    `json:"summary,omitempty"`
+	Result   *speedtest.Result `json:"result,omitempty"`
+}
+
+// Controller scheduling tests on the agents and collecting their results
+type controller struct {
+	token        string
+	target       string
+	uploadTarget string
+	upload       bool
+	mesh         bool
+	// Called with each result received
+	onResult func(*speedtest.Result, error)
+
+	mu      sync.Mutex
+	agents  map[string]*controllerAgent
+	results []controllerResult
+	nextJob int64
+	// Closed and replaced when a job is queued or finished
+	changed chan struct{}
+}
+
+func (c *controller) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("PUT /api/v1/agents/{name}", c.register)
+	mux.HandleFunc("GET /api/v1/agents/{name}/job", c.nextAgentJob)
+	mux.HandleFunc("POST /api/v1/agents/{name}/results", c.report)
+	mux.HandleFunc("GET /api/v1/agents", c.listAgents)
+	mux.HandleFunc("GET /api/v1/results", c.listResults)
+	mux.HandleFunc("POST /api/v1/rounds", c.startRound)
+	return requireToken(c.token, mux)
+}
+
+func (c *controller) notify() {
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+// Tell whether the agent still runs its job
+func (a *controllerAgent) busy(now time.Time) bool {
+	return a.Running != nil && now.Sub(a.started) < agentJobTimeout
+}
+
+func (a *controllerAgent) online(now time.Time) bool {
+	return a.busy(now) || now.Sub(a.Seen) < agentOfflineAfter
+}
+
+// Copy of the agent with its state, taken under the lock
+func (a *controllerAgent) snapshot(now time.Time) controllerAgent {
+	s := *a
+	s.Online, s.Queued, s.queue = a.online(now), len(a.queue), nil
+	if !a.busy(now) {
+		s.Running = nil
+	}
+	return s
+}
+
+// Tell whether the server of the agent is being tested by another one
+func (c *controller) tested(name string, now time.Time) bool {
+	for _, a := range c.agents {
+		if a.busy(now) && a.Running.Peer == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Take the first job of the agent that can run now: a test must not
+// share the link of an agent with a mesh test of its server, so mesh
+// tests run one at a time per agent
+func (c *controller) take(a *controllerAgent, now time.Time) (agentJob, bool) {
+	if c.tested(a.Name, now) {
+		return agentJob{}, false
+	}
+	for i := 0; i < len(a.queue); i++ {
+		job := a.queue[i]
+		if job.Peer != "" {
+			if peer := c.agents[job.Peer]; !peer.online(now) {
+				slog.Warn("mesh test dropped, the peer is offline", "agent", a.Name, "peer", job.Peer)
+				a.queue = slices.Delete(a.queue, i, i+1)
+				i--
+				continue
+			} else if peer.busy(now) || c.tested(job.Peer, now) {
+				continue
+			}
+		}
+		a.queue = slices.Delete(a.queue, i, i+1)
+		a.Running, a.started = &job, now
+		return job, true
+	}
+	return agentJob{}, false
+}
+
+func (c *controller) enqueue(a *controllerAgent, job agentJob) {
+	if len(a.queue) >= agentQueueSize {
+		slog.Warn("job dropped, too many jobs waiting", "agent", a.Name, "target", job.Target)
+		return
+	}
+	c.nextJob++
+	job.ID = c.nextJob
+	a.queue = append(a.queue, job)
+}
+
+// Queue a test of the target on every online agent, and in mesh mode a
+// test of the server of every other agent
+func (c *controller) round() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	var online []*controllerAgent
+	for _, a := range c.agents {
+		if a.online(now) {
+			online = append(online, a)
+		}
+	}
+	slices.SortFunc(online, func(a, b *controllerAgent) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	for _, a := range online {
+		if c.target != "" {
+			c.enqueue(a, agentJob{Target: c.target, UploadTarget: c.uploadTarget, Upload: c.upload})
+		}
+		if !c.mesh {
+			continue
+		}
+		for _, peer := range online {
+			if peer != a && peer.ServeURL != "" {
+				base := strings.TrimSuffix(peer.ServeURL, "/")
+				c.enqueue(a, agentJob{Target: base + "/download", UploadTarget: base + "/upload", Upload: c.upload, Peer: peer.Name})
+			}
+		}
+	}
+	c.notify()
+	slog.Info("round scheduled", "agents", len(online))
+}
+
+func (c *controller) register(w http.ResponseWriter, r *http.Request) {
+	var info agentInfo
+	if err := json.NewDecoder(r.Body).Decode(&info); err != nil {
+		apiError(w, http.StatusBadRequest, "invalid registration: "+err.Error())
+		return
+	}
+	name := r.PathValue("name")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	a := c.agents[name]
+	if a == nil {
+		a = &controllerAgent{Name: name}
+		c.agents[name] = a
+		slog.Info("agent registered", "agent", name, "serve_url", info.ServeURL)
+	}
+	a.ServeURL, a.Seen = info.ServeURL, time.Now()
+	apiJSON(w, http.StatusOK, a.snapshot(a.Seen))
+}
+
+// Hand the next job to an agent, waiting for one up to agentPollTimeout.
+// Unknown agents get a 404 and register again.
+func (c *controller) nextAgentJob(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	timeout := time.NewTimer(agentPollTimeout)
+	defer timeout.Stop()
+	for {
+		c.mu.Lock()
+		a := c.agents[name]
+		if a == nil {
+			c.mu.Unlock()
+			apiError(w, http.StatusNotFound, "unknown agent, register first")
+			return
+		}
+		now := time.Now()
+		a.Seen = now
+		job, ok := c.take(a, now)
+		changed := c.changed
+		c.mu.Unlock()
+		if ok {
+			apiJSON(w, http.StatusOK, job)
+			return
+		}
+		select {
+		case <-changed:
+		case <-timeout.C:
+			w.WriteHeader(http.StatusNoContent)
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (c *controller) report(w http.ResponseWriter, r *http.Request) {
+	var rep agentReport
+	if err := json.NewDecoder(r.Body).Decode(&rep); err != nil {
+		apiError(w, http.StatusBadRequest, "invalid report: "+err.Error())
+		return
+	}
+	name := r.PathValue("name")
+	c.mu.Lock()
+	a := c.agents[name]
+	if a == nil || a.Running == nil || a.Running.ID != rep.Job {
+		c.mu.Unlock()
+		apiError(w, http.StatusConflict, "no such job running on the agent")
+		return
+	}
+	res := controllerResult{Agent: name, Job: *a.Running, Received: time.Now(), Error: rep.Error, Result: rep.Result}
+	a.Running, a.Seen, a.Error = nil, res.Received, rep.Error
+	var err error
+	if rep.Error != "" {
+		err = errors.New(rep.Error)
+	} else if res.Result == nil {
+		err = errors.New("no result")
+		res.Error, a.Error = err.Error(), err.Error()
+	} else {
+		res.Result.Agent = name
+		summary := newResultSummary(res.Result)
+		res.Summary, a.Last = &summary, &summary
+	}
+	c.results = append(c.results, res)
+	if len(c.results) > controllerKeptResults {
+		c.results = c.results[1:]
+	}
+	c.notify()
+	c.mu.Unlock()
+
+	slog.Info("result received", "agent", name, "job", rep.Job, "target", res.Job.Target, "err", rep.Error)
+	if c.onResult != nil {
+		c.onResult(res.Result, err)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (c *controller) listAgents(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	agents := []controllerAgent{}
+	for _, a := range c.agents {
+		agents = append(agents, a.snapshot(now))
+	}
+	slices.SortFunc(agents, func(a, b controllerAgent) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	apiJSON(w, http.StatusOK, agents)
+}
+
+// Results received, most recent first, filtered with the agent, peer and
+// last query parameters
+func (c *controller) listResults(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	limit := 0
+	if v := q.Get("last"); v != "" {
+		var err error
+		if limit, err = strconv.Atoi(v); err != nil {
+			apiError(w, http.StatusBadRequest, "invalid last")
+			return
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	results := []controllerResult{}
+	for i := len(c.results) - 1; i >= 0 && (limit <= 0 || len(results) < limit); i-- {
+		res := c.results[i]
+		if agent := q.Get("agent"); agent != "" && res.Agent != agent {
+			continue
+		}
+		if peer := q.Get("peer"); peer != "" && res.Job.Peer != peer {
+			continue
+		}
+		results = append(results, res)
+	}
+	apiJSON(w, http.StatusOK, results)
+}
+
+// Schedule a round now, besides the schedule
+func (c *controller) startRound(w http.ResponseWriter, r *http.Request) {
+	c.round()
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// Schedule tests on the agents registered and collect their results
+func controllerCommand(args []string) {
+	fs := flag.NewFlagSet("controller", flag.ExitOnError)
+	target := fs.String("target", "", "HTTP remote URL tested by every agent")
+	uploadTarget := fs.String("upload-target", "", "HTTP remote URL receiving uploads (defaults to target)")
+	mesh := fs.Bool("mesh", false, "Also have every agent test the built-in server of the others")
+	cron := fs.String("cron", "", "Run the tests at times matching this cron expression instead of every -every seconds")
+	listen := fs.String("controller-listen", ":8070", "Address the controller listens on for the agents")
+	token := fs.String("controller-token", "", "Require this bearer token from the agents")
+	tf := addTestFlags(fs, uploadSwitch)
+	parseFlags(fs, args)
+
+	if *target == "" && !*mesh {
+		fmt.Println("Target URL or -mesh is required.")
+		os.Exit(1)
+	}
+	var sched schedule = everySchedule(time.Duration(*tf.every) * time.Second)
+	if *cron != "" {
+		c, err := parseCron(*cron)
+		if err != nil {
+			fatal(err)
+		}
+		sched = c
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	console := tf.console()
+	tf.setupLogging(console)
+	tf.say(console, "Go SpeedTest controller")
+	if *token == "" {
+		slog.Warn("the controller accepts agents without authentication, see -controller-token")
+	}
+
+	tf.print = true
+	publish := tf.publisher()
+	c := &controller{
+		token: *token, target: *target, uploadTarget: *uploadTarget, upload: *tf.upload, mesh: *mesh,
+		agents: map[string]*controllerAgent{}, changed: make(chan struct{}),
+		onResult: func(res *speedtest.Result, err error) {
+			if err != nil {
+				slog.Error("test failed", "err", err)
+				return
+			}
+			publish(ctx, res)
+		},
+	}
+
+	ln, err := net.Listen("tcp", *listen)
+	if err != nil {
+		fatal(err)
+	}
+	// Agent requests wait for jobs, so the server has no write timeout
+	srv := &http.Server{Handler: c.handler(), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		sctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(sctx)
+	}()
+	go func() {
+		// Leave the agents time to register before the first round
+		select {
+		case <-time.After(agentPollTimeout):
+		case <-ctx.Done():
+			return
+		}
+		for {
+			c.round()
+			select {
+			case <-time.After(time.Until(sched.next(time.Now()))):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	slog.Info("serving controller", "url", fmt.Sprintf("http://%s/api/v1/", ln.Addr()))
+	if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fatal(err)
+	}
+}
@@ -0,0 +1,179 @@
+// Package history stores speed test results in a SQLite database.
+package history
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ofauchon/go-speedtest/speedtest"
+
+	_ "modernc.org/sqlite"
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS runs (
+	id            INTEGER PRIMARY KEY AUTOINCREMENT,
+	start         INTEGER NOT NULL,
+	target        TEXT NOT NULL,
+	duration      REAL NOT NULL,
+	download_bps  REAL NOT NULL,
+	upload_bps    REAL,
+	latency_ms    REAL,
+	jitter_ms     REAL,
+	loss_percent  REAL,
+	errors        INTEGER NOT NULL,
+	result        TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS runs_start ON runs (start);
+`
+
+// DefaultPath is the database location used when none is given
+const DefaultPath = "~/.go-speedtest/history.db"
+
+// Store is a history database
+type Store struct {
+	db *sql.DB
+}
+
+// Entry is a stored run
+type Entry struct {
+	ID     int64
+	Result *speedtest.Result
+}
+
+// Filter selects stored runs, zero fields match everything
+type Filter struct {
+	From   time.Time
+	To     time.Time
+	Target string
+	Limit  int
+}
+
+// ExpandPath replaces a leading ~ with the home directory
+func ExpandPath(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, path[1:])
+		}
+	}
+	return path
+}
+
+// Open opens or creates the database at path
+func Open(path string) (*Store, error) {
+	path = ExpandPath(path)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Store{db: db}, nil
+}
+
+// Close closes the database
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Add records a run
+func (s *Store) Add(res *speedtest.Result) (int64, error) {
+	data, err := json.Marshal(res)
+	if err != nil {
+		return 0, err
+	}
+
+	var upload, latency, jitter, loss sql.NullFloat64
+	errs := len(res.Errors)
+	if up := res.Upload; up != nil {
+		upload = sql.NullFloat64{Float64: up.BytesPerSecond() * 8, Valid: true}
+		errs += len(up.Errors)
+	}
+	if lat := res.Latency; lat != nil {
+		latency = sql.NullFloat64{Float64: float64(lat.Avg) / float64(time.Millisecond), Valid: true}
+		jitter = sql.NullFloat64{Float64: float64(lat.Jitter) / float64(time.Millisecond), Valid: true}
+		loss = sql.NullFloat64{Float64: lat.Loss(), Valid: true}
+	}
+
+	r, err := s.db.Exec(`INSERT INTO runs
+		(start, target, duration, download_bps, upload_bps, latency_ms, jitter_ms, loss_percent, errors, result)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		res.Start.UnixNano(), res.Target, res.Elapsed.Seconds(), res.BytesPerSecond()*8,
+		upload, latency, jitter, loss, errs, string(data))
+	if err != nil {
+		return 0, err
+	}
+	return r.LastInsertId()
+}
+
+// List returns the runs matching the filter, oldest first
+func (s *Store) List(f Filter) ([]Entry, error) {
+	query := "SELECT id, result, start FROM runs WHERE 1=1"
+	var args []any
+	if !f.From.IsZero() {
+		query += " AND start >= ?"
+		args = append(args, f.From.UnixNano())
+	}
+	if !f.To.IsZero() {
+		query += " AND start < ?"
+		args = append(args, f.To.UnixNano())
+	}
+	if f.Target != "" {
+		query += " AND target = ?"
+		args = append(args, f.Target)
+	}
+	query += " ORDER BY start"
+	if f.Limit > 0 {
+		// Keep the most recent runs
+		query = "SELECT id, result, start FROM (" + strings.Replace(query, "ORDER BY start", "ORDER BY start DESC", 1) + " LIMIT ?) ORDER BY start"
+		args = append(args, f.Limit)
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []Entry
+	for rows.Next() {
+		var e Entry
+		var data string
+		var start int64
+		if err := rows.Scan(&e.ID, &data, &start); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(data), &e.Result); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// Get returns the run with the given ID, nil if there is none
+func (s *Store) Get(id int64) (*Entry, error) {
+	var data string
+	err := s.db.QueryRow("SELECT result FROM runs WHERE id = ?", id).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	e := &Entry{ID: id}
+	if err := json.Unmarshal([]byte(data), &e.Result); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
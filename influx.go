@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ofauchon/go-speedtest/speedtest"
+)
+
+// Escape a tag value of the line protocol
+var influxTagEscaper = strings.NewReplacer(",", `\,`, " ", `\ `, "=", `\=`)
+
+// Print the result as an InfluxDB line protocol point
+func printInflux(w io.Writer, res *speedtest.Result) error {
+	fields := []string{
+		fmt.Sprintf("download_bps=%g", res.BytesPerSecond()*8),
+		fmt.Sprintf("download_bytes=%di", res.Bytes),
+		fmt.Sprintf("duration_seconds=%g", res.Elapsed.Seconds()),
+	}
+	errs := len(res.Errors)
+	if up := res.Upload; up != nil {
+		fields = append(fields,
+			fmt.Sprintf("upload_bps=%g", up.BytesPerSecond()*8),
+			fmt.Sprintf("upload_bytes=%di", up.Bytes))
+		errs += len(up.Errors)
+	}
+	if lat := res.Latency; lat != nil {
+		fields = append(fields,
+			fmt.Sprintf("latency_ms=%g", float64(lat.Avg)/float64(time.Millisecond)),
+			fmt.Sprintf("jitter_ms=%g", float64(lat.Jitter)/float64(time.Millisecond)),
+			fmt.Sprintf("loss_ratio=%g", lat.Loss()/100))
+	}
+	fields = append(fields, fmt.Sprintf("errors=%di", errs))
+
+	tags := "target=" + influxTagEscaper.Replace(res.Target)
+	if res.Agent != "" {
+		tags += ",agent=" + influxTagEscaper.Replace(res.Agent)
+	}
+	_, err := fmt.Fprintf(w, "speedtest,%s %s %d\n", tags, strings.Join(fields, ","), res.Start.UnixNano())
+	return err
+}
+
+func init() {
+	registerSink(func(f *testFlags) (Sink, error) {
+		if *f.influxURL == "" {
+			return nil, nil
+		}
+		return sinkFunc{"InfluxDB", func(ctx context.Context, res *speedtest.Result) error {
+			return writeInflux(ctx, *f.influxURL, *f.influxOrg, *f.influxBucket, *f.influxToken, res)
+		}}, nil
+	})
+}
+
+// Write the result to an InfluxDB v2 server
+func writeInflux(ctx context.Context, server, org, bucket, token string, res *speedtest.Result) error {
+	var body bytes.Buffer
+	printInflux(&body, res)
+
+	q := url.Values{"org": {org}, "bucket": {bucket}, "precision": {"ns"}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(server, "/")+"/api/v2/write?"+q.Encode(), &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if token != "" {
+		req.Header.Set("Authorization", "Token "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("influx write failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("influx write failed: %s %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/ofauchon/go-speedtest/speedtest"
+)
+
+func TestPrintInflux(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	tests := []struct {
+		name string
+		res  *speedtest.Result
+		want string
+	}{{
+		name: "download",
+		res:  &speedtest.Result{Target: "http://example.com/file", Start: start, Bytes: 125000000, Elapsed: 10 * time.Second},
+		want: "speedtest,target=http://example.com/file download_bps=1e+08,download_bytes=125000000i,duration_seconds=10,errors=0i 1700000000000000000\n",
+	}, {
+		name: "escaped tags",
+		res: &speedtest.Result{Target: "http://example.com/a file,v=2", Agent: "paris office", Start: start, Bytes: 1000, Elapsed: time.Second,
+			Errors: []string{"reset"}},
+		want: `speedtest,target=http://example.com/a\ file\,v\=2,agent=paris\ office download_bps=8000,download_bytes=1000i,duration_seconds=1,errors=1i 1700000000000000000` + "\n",
+	}, {
+		name: "upload and latency",
+		res: &speedtest.Result{Target: "http://example.com/file", Start: start, Bytes: 1000, Elapsed: time.Second,
+			Upload:  &speedtest.UploadResult{Bytes: 500, Elapsed: time.Second, Errors: []string{"refused"}},
+			Latency: &speedtest.LatencyResult{Sent: 4, Received: 3, Avg: 12500 * time.Microsecond, Jitter: 500 * time.Microsecond}},
+		want: "speedtest,target=http://example.com/file download_bps=8000,download_bytes=1000i,duration_seconds=1,upload_bps=4000,upload_bytes=500i,latency_ms=12.5,jitter_ms=0.5,loss_ratio=0.25,errors=1i 1700000000000000000\n",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := printInflux(&buf, tt.res); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", buf.String(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,223 @@
+// Package stats accounts the bytes moved by the parallel connections of a
+// transfer. Counters are updated atomically by the connections and read
+// through snapshots by the progress, interval and result code.
+package stats
+
+import (
+	"math"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// Counter holds the bytes moved by one connection
+type Counter struct {
+	n atomic.Int64
+
+	// Offset from the start when the connection finished, 0 if running
+	end atomic.Int64
+
+	// Stall tracking, only touched by CheckStalls
+	last    int64
+	stalled bool
+	stalls  int
+
+	throttle func(n int64) bool
+
+	// Requests given up for lack of data and sent again
+	restarts atomic.Int64
+
+	// Requests sent again after failing
+	retries atomic.Int64
+}
+
+// Add records n more bytes, blocking if the set is throttled. Throttled
+// bytes are recorded once paced, and not at all if the throttle refuses
+// them.
+func (c *Counter) Add(n int64) {
+	if c.throttle != nil && n > 0 && !c.throttle(n) {
+		return
+	}
+	c.n.Add(n)
+}
+
+// Restart records that the connection sent its request again after a
+// stall
+func (c *Counter) Restart() {
+	c.restarts.Add(1)
+}
+
+// Retry records that the connection sent its request again after an
+// error
+func (c *Counter) Retry() {
+	c.retries.Add(1)
+}
+
+// Load returns the bytes recorded so far
+func (c *Counter) Load() int64 {
+	return c.n.Load()
+}
+
+// Set holds the counters of the connections of a transfer
+type Set struct {
+	start    time.Time
+	counters []Counter
+}
+
+// New returns a set of n counters, starting at start
+func New(n int, start time.Time) *Set {
+	return &Set{start: start, counters: make([]Counter, n)}
+}
+
+// Throttle makes the counters call fn with the bytes of each Add, fn may
+// block to pace the connections and returns false for the bytes not to
+// count, e.g. those read after the end of the transfer. It must be called
+// before they start.
+func (s *Set) Throttle(fn func(n int64) bool) {
+	for i := range s.counters {
+		s.counters[i].throttle = fn
+	}
+}
+
+// Len returns the number of counters
+func (s *Set) Len() int {
+	return len(s.counters)
+}
+
+// Counter returns the counter of connection i
+func (s *Set) Counter(i int) *Counter {
+	return &s.counters[i]
+}
+
+// Finish records that connection i ended at now
+func (s *Set) Finish(i int, now time.Time) {
+	s.counters[i].end.Store(int64(now.Sub(s.start)))
+}
+
+// CheckStalls counts a stall for each running connection which moved no
+// byte since the previous call, after having started. Consecutive idle
+// checks are one stall. It must not be called concurrently.
+func (s *Set) CheckStalls() {
+	for i := range s.counters {
+		c := &s.counters[i]
+		n := c.Load()
+		idle := n == c.last && n > 0 && c.end.Load() == 0
+		if idle && !c.stalled {
+			c.stalls++
+		}
+		c.stalled = idle
+		c.last = n
+	}
+}
+
+// Conn describes what a connection did during the transfer
+type Conn struct {
+	Bytes int64
+
+	// Time until the connection finished, or until end if still running
+	Elapsed time.Duration
+
+	Stalls   int
+	Restarts int
+	Retries  int
+}
+
+// Conns returns the statistics of each connection, those still running
+// being accounted until end. CheckStalls must not run concurrently.
+func (s *Set) Conns(end time.Time) []Conn {
+	conns := make([]Conn, len(s.counters))
+	for i := range s.counters {
+		c := &s.counters[i]
+		elapsed := time.Duration(c.end.Load())
+		if elapsed == 0 || elapsed > end.Sub(s.start) {
+			elapsed = end.Sub(s.start)
+		}
+		conns[i] = Conn{Bytes: c.Load(), Elapsed: elapsed, Stalls: c.stalls, Restarts: int(c.restarts.Load()), Retries: int(c.retries.Load())}
+	}
+	return conns
+}
+
+// Snapshot holds the counter values at a given time
+type Snapshot struct {
+	// Offset from the start of the transfer
+	At time.Duration
+
+	// Bytes moved, total and per connection
+	Total int64
+	Parts []int64
+}
+
+// BytesPerSecond returns the average throughput since the start
+func (s Snapshot) BytesPerSecond() float64 {
+	if s.At <= 0 {
+		return 0
+	}
+	return float64(s.Total) / s.At.Seconds()
+}
+
+// Snapshot reads all the counters at once
+func (s *Set) Snapshot(now time.Time) Snapshot {
+	snap := Snapshot{At: now.Sub(s.start), Parts: make([]int64, len(s.counters))}
+	for i := range s.counters {
+		snap.Parts[i] = s.counters[i].Load()
+		snap.Total += snap.Parts[i]
+	}
+	return snap
+}
+
+// Sub returns the bytes moved between prev and s, in a snapshot covering
+// only that period. The zero Snapshot stands for the start.
+func (s Snapshot) Sub(prev Snapshot) Snapshot {
+	d := Snapshot{At: s.At - prev.At, Total: s.Total - prev.Total, Parts: make([]int64, len(s.Parts))}
+	for i := range s.Parts {
+		d.Parts[i] = s.Parts[i]
+		if i < len(prev.Parts) {
+			d.Parts[i] -= prev.Parts[i]
+		}
+	}
+	return d
+}
+
+// Summary describes the distribution of a series of values
+type Summary struct {
+	N                 int
+	Min, Avg, Max     float64
+	P5, P50, P90, P95 float64
+	StdDev            float64
+}
+
+// Summarize computes the distribution of values
+func Summarize(values []float64) Summary {
+	s := Summary{N: len(values)}
+	if s.N == 0 {
+		return s
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	s.Min = sorted[0]
+	s.Max = sorted[s.N-1]
+	for _, v := range sorted {
+		s.Avg += v
+	}
+	s.Avg /= float64(s.N)
+	for _, v := range sorted {
+		s.StdDev += (v - s.Avg) * (v - s.Avg)
+	}
+	s.StdDev = math.Sqrt(s.StdDev / float64(s.N))
+	s.P5 = Percentile(sorted, 5)
+	s.P50 = Percentile(sorted, 50)
+	s.P90 = Percentile(sorted, 90)
+	s.P95 = Percentile(sorted, 95)
+	return s
+}
+
+// Percentile of sorted values, interpolating between the closest ranks
+func Percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := p / 100 * float64(len(sorted)-1)
+	lo := int(math.Floor(rank))
+	hi := int(math.Ceil(rank))
+	return sorted[lo] + (sorted[hi]-sorted[lo])*(rank-float64(lo))
+}
@@ -0,0 +1,175 @@
+// Package tui draws the live dashboard of a transfer: a sparkline of the
+// aggregate throughput, a bar per connection, the latency under load and
+// the elapsed and remaining time. On anything but a terminal (cron jobs,
+// CI logs) it degrades to a plain, timestamped progress line every few
+// seconds.
+package tui
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// Frame holds what the dashboard shows at one refresh
+type Frame struct {
+	// Phase name, e.g. Download, and label of the connection bars
+	Title string
+	Label string
+
+	// Bytes moved by each connection, and their expected share of the
+	// transfer, 0 if unknown
+	Parts    []int64
+	PartSize int64
+
+	// Total bytes, current and average speed in bytes/sec
+	Total int64
+	Rate  float64
+	Avg   float64
+
+	// Recent speeds, oldest first, drawn as a sparkline
+	History []float64
+
+	// Time since the start and test duration, 0 if unlimited
+	Elapsed  time.Duration
+	Duration time.Duration
+
+	// Latest latency measured under load, 0 if not probed
+	Latency time.Duration
+
+	// Formatter of the speeds, nil for MB/sec
+	Format func(bytesPerSecond float64) string
+}
+
+const (
+	// Delay between the plain progress lines
+	PlainInterval = 5 * time.Second
+
+	barWidth       = 40
+	sparkWidth     = 60
+	enterAltScreen = "\033[?1049h\033[?25l"
+	leaveAltScreen = "\033[?25h\033[?1049l"
+	home           = "\033[H\033[2J"
+)
+
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+// Screen draws successive frames on a writer
+type Screen struct {
+	w       io.Writer
+	tty     bool
+	started bool
+
+	// Phase and time of the next plain line
+	title string
+	next  time.Duration
+}
+
+// New returns a Screen drawing on w, full screen if it is a terminal
+func New(w io.Writer) *Screen {
+	return &Screen{w: w, tty: IsTerminal(w)}
+}
+
+// IsTerminal tells whether w is a character device
+func IsTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	st, err := f.Stat()
+	return err == nil && st.Mode()&os.ModeCharDevice != 0
+}
+
+// Draw replaces the previous frame
+func (s *Screen) Draw(f Frame) {
+	if !s.tty {
+		if f.Title != s.title {
+			s.title, s.next = f.Title, 0
+		}
+		if f.Elapsed >= s.next {
+			fmt.Fprintf(s.w, "%s %s\n", time.Now().Format(time.TimeOnly), line(f))
+			s.next = f.Elapsed.Truncate(PlainInterval) + PlainInterval
+		}
+		return
+	}
+	if !s.started {
+		fmt.Fprint(s.w, enterAltScreen)
+		s.started = true
+	}
+	var b strings.Builder
+	b.WriteString(home)
+	fmt.Fprintf(&b, "%s  %s\n\n", f.Title, clock(f))
+	fmt.Fprintf(&b, "%s\n", sparkline(f.History))
+	fmt.Fprintf(&b, "%s (avg %s), %d bytes\n", f.format(f.Rate), f.format(f.Avg), f.Total)
+	if f.Latency > 0 {
+		fmt.Fprintf(&b, "Latency under load: %s\n", f.Latency.Round(100*time.Microsecond))
+	}
+	b.WriteString("\n")
+	for i, n := range f.Parts {
+		if f.PartSize <= 0 {
+			fmt.Fprintf(&b, "%s %d: %d bytes\n", f.Label, i, n)
+			continue
+		}
+		percent := float64(n) / float64(f.PartSize) * 100
+		// Connections pulling chunks may move more than their share
+		bar := min(int(percent*barWidth/100), barWidth)
+		fmt.Fprintf(&b, "%s %d: [%-*s] %6.2f%%\n", f.Label, i, barWidth, strings.Repeat("=", bar), percent)
+	}
+	fmt.Fprint(s.w, b.String())
+}
+
+// Close restores the terminal, leaving it clean for the summary
+func (s *Screen) Close() {
+	if s.started {
+		fmt.Fprint(s.w, leaveAltScreen)
+		s.started = false
+	}
+}
+
+// Plain progress line for non terminals
+func line(f Frame) string {
+	l := fmt.Sprintf("%s %s: %d bytes, %s (avg %s)", f.Title, clock(f), f.Total, f.format(f.Rate), f.format(f.Avg))
+	if f.Latency > 0 {
+		l += fmt.Sprintf(", latency %s", f.Latency.Round(100*time.Microsecond))
+	}
+	return l
+}
+
+// Elapsed and remaining time
+func clock(f Frame) string {
+	elapsed := f.Elapsed.Round(time.Second)
+	if f.Duration <= 0 {
+		return elapsed.String()
+	}
+	return fmt.Sprintf("%s (%s left)", elapsed, max(f.Duration-f.Elapsed, 0).Round(time.Second))
+}
+
+// Draw the last values scaled to the largest
+func sparkline(values []float64) string {
+	if len(values) > sparkWidth {
+		values = values[len(values)-sparkWidth:]
+	}
+	var top float64
+	for _, v := range values {
+		top = max(top, v)
+	}
+	var b strings.Builder
+	for _, v := range values {
+		i := 0
+		if top > 0 {
+			i = int(v / top * float64(len(sparks)-1))
+		}
+		b.WriteRune(sparks[i])
+	}
+	return b.String()
+}
+
+// Format a rate in bytes/sec, in MB/sec unless the frame has a formatter
+func (f Frame) format(v float64) string {
+	if f.Format != nil {
+		return f.Format(v)
+	}
+	return fmt.Sprintf("%.2f MB/sec", v/1e6)
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ofauchon/go-speedtest/speedtest"
+)
+
+// JUnit XML report, as read by Jenkins and GitLab CI
+type junitReport struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+// Test suite of one run
+type junitSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Time       float64         `xml:"time,attr"`
+	Timestamp  string          `xml:"timestamp,attr"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	Cases      []junitCase     `xml:"testcase"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+// Test case of a measured metric, failed if it breaks a check
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+}
+
+// Build the test suite of a run: a case per metric, failed when the metric
+// breaks its threshold, and a case per metric compared with the baseline
+func (f *testFlags) junitSuite(res *speedtest.Result, baseline *speedtest.Result) junitSuite {
+	suite := junitSuite{
+		Name:      res.Target,
+		Time:      res.End.Sub(res.Start).Seconds(),
+		Timestamp: res.Start.UTC().Format("2006-01-02T15:04:05"),
+		Properties: []junitProperty{
+			{"target", res.Target},
+			{"concurrent", fmt.Sprint(res.Concurrent)},
+		},
+	}
+	add := func(c junitCase) {
+		c.ClassName = "go-speedtest"
+		suite.Cases = append(suite.Cases, c)
+		suite.Tests++
+		if c.Failure != nil {
+			suite.Failures++
+		}
+	}
+
+	violations := map[string]violation{}
+	for _, v := range checkThresholds(res, *f.minDownload, *f.minUpload, *f.maxLatency) {
+		violations[v.Metric] = v
+	}
+	limits := map[string]struct {
+		limit float64
+		op    string
+	}{
+		"download": {*f.minDownload, ">="},
+		"upload":   {*f.minUpload, ">="},
+		"latency":  {*f.maxLatency, "<="},
+	}
+	for _, m := range resultMetrics {
+		key := strings.ToLower(m.name)
+		c := junitCase{Name: key}
+		if l := limits[key]; l.limit > 0 {
+			c.Name = fmt.Sprintf("%s %s %g %s", key, l.op, l.limit, m.unit)
+		}
+		if v, ok := m.value(res); ok {
+			c.SystemOut = fmt.Sprintf("%s: %.2f %s", m.name, v, m.unit)
+		} else if limits[key].limit <= 0 {
+			continue
+		}
+		if v, ok := violations[key]; ok {
+			msg := fmt.Sprintf("%s %.2f %s %s %g", key, v.Value, v.Unit, v.Op, v.Limit)
+			if v.Op == "missing" {
+				msg = key + " not measured"
+			}
+			c.Failure = &junitFailure{Message: msg, Type: "threshold"}
+		}
+		add(c)
+	}
+
+	if baseline != nil {
+		regressed := map[string]bool{}
+		for _, d := range checkBaseline(baseline, res, *f.baselineTolerance) {
+			regressed[d.Metric] = true
+		}
+		for _, d := range compareResults(baseline, res) {
+			c := junitCase{
+				Name:      strings.ToLower(d.Metric) + " vs baseline",
+				SystemOut: fmt.Sprintf("%s: %.2f %s, baseline %.2f (%+.1f%%)", d.Metric, d.After, d.Unit, d.Before, d.Percent),
+			}
+			if regressed[d.Metric] {
+				c.Failure = &junitFailure{
+					Message: fmt.Sprintf("%s regressed %+.1f%% from the baseline, tolerance %.1f%%", strings.ToLower(d.Metric), d.Percent, *f.baselineTolerance),
+					Type:    "baseline",
+				}
+			}
+			add(c)
+		}
+	}
+	return suite
+}
+
+// Write the suites as a JUnit XML report
+func printJUnit(w io.Writer, suites ...junitSuite) error {
+	report := junitReport{Suites: suites}
+	for _, s := range suites {
+		report.Tests += s.Tests
+		report.Failures += s.Failures
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/ofauchon/go-speedtest/speedtest"
+)
+
+func TestPrintJUnit(t *testing.T) {
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	res := &speedtest.Result{
+		Target: "http://example.com/file?a=1&b=<2>", Concurrent: 4, Start: start, End: start.Add(12 * time.Second),
+		Bytes: 125000000, Elapsed: 10 * time.Second,
+		Latency: &speedtest.LatencyResult{Sent: 4, Received: 4, Avg: 12500 * time.Microsecond},
+	}
+	f := parseTestFlags(t, "-min-download", "200", "-max-latency", "40")
+	var buf bytes.Buffer
+	if err := printJUnit(&buf, f.junitSuite(res, nil), parseTestFlags(t).junitSuite(res, nil)); err != nil {
+		t.Fatal(err)
+	}
+	want := `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites tests="4" failures="1">
+  <testsuite name="http://example.com/file?a=1&amp;b=&lt;2&gt;" tests="2" failures="1" time="12" timestamp="2024-03-01T12:00:00">
+    <properties>
+      <property name="target" value="http://example.com/file?a=1&amp;b=&lt;2&gt;"></property>
+      <property name="concurrent" value="4"></property>
+    </properties>
+    <testcase name="download &gt;= 200 Mbit/sec" classname="go-speedtest">
+      <failure message="download 100.00 Mbit/sec &lt; 200" type="threshold"></failure>
+      <system-out>Download: 100.00 Mbit/sec</system-out>
+    </testcase>
+    <testcase name="latency &lt;= 40 ms" classname="go-speedtest">
+      <system-out>Latency: 12.50 ms</system-out>
+    </testcase>
+  </testsuite>
+  <testsuite name="http://example.com/file?a=1&amp;b=&lt;2&gt;" tests="2" failures="0" time="12" timestamp="2024-03-01T12:00:00">
+    <properties>
+      <property name="target" value="http://example.com/file?a=1&amp;b=&lt;2&gt;"></property>
+      <property name="concurrent" value="4"></property>
+    </properties>
+    <testcase name="download" classname="go-speedtest">
+      <system-out>Download: 100.00 Mbit/sec</system-out>
+    </testcase>
+    <testcase name="latency" classname="go-speedtest">
+      <system-out>Latency: 12.50 ms</system-out>
+    </testcase>
+  </testsuite>
+</testsuites>
+`
+	if buf.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", buf.String(), want)
+	}
+}
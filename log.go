@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/ofauchon/go-speedtest/speedtest"
+)
+
+// Send the log records to console, at the level chosen by -quiet,
+// -verbose and -debug
+func (f *testFlags) setupLogging(console io.Writer) {
+	level := slog.LevelInfo
+	switch {
+	case *f.debug:
+		level = speedtest.LevelTrace
+	case *f.verbose:
+		level = slog.LevelDebug
+	case *f.quiet:
+		level = slog.LevelError
+	}
+	setLogger(console, level)
+}
+
+// Install the default logger, writing to w the records at level or above
+func setLogger(w io.Writer, level slog.Level) {
+	slog.SetDefault(slog.New(slog.NewTextHandler(w, &slog.HandlerOptions{
+		Level: level,
+		// Timestamps only clutter an interactive run
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey && len(groups) == 0 {
+				return slog.Attr{}
+			}
+			if a.Key == slog.LevelKey && a.Value.Any() == speedtest.LevelTrace {
+				a.Value = slog.StringValue("TRACE")
+			}
+			return a
+		},
+	})))
+}
+
+// Print a human readable status line, unless -quiet is set
+func (f *testFlags) say(console io.Writer, format string, args ...any) {
+	if !*f.quiet {
+		fmt.Fprintf(console, format+"\n", args...)
+	}
+}
+
+// Log a fatal error and exit
+func fatal(err error) {
+	slog.Error(err.Error())
+	os.Exit(1)
+}
@@ -1,151 +1,256 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
-	"io"
-	"net/http"
+	"log/slog"
 	"os"
-	"os/signal"
 	"strings"
-	"sync"
-	"syscall"
-	"time"
+
+	"github.com/ofauchon/go-speedtest/speedtest"
 )
 
-func main() {
+// Commands testing target URLs, and the flags they take
+var testCommands = map[string]flagGroups{
+	"download": downloadFlags | latencyFlags,
+	"upload":   uploadFlags | latencyFlags,
+	"latency":  latencyFlags,
+	"full":     downloadFlags | uploadFlags | latencyFlags,
+}
 
-	fmt.Println("Go SpeedTest")
+// Print the list of commands
+func usage() {
+	fmt.Fprintf(os.Stderr, `Usage: go-speedtest [command] [flags]
 
-	target := flag.String("target", "", "HTTP remote URL for speed testing")
-	concurrent := flag.Int64("concurrent", 4, "Number of parallel downloads")
-	duration := flag.Int("duration", 0, "Stop the download after xx seconds")
-	progress := flag.Bool("progress", false, "Display real-time progress bar")
+Commands:
+  download    Measure the latency and download speed of a target URL
+  upload      Measure the latency and upload speed of a target URL
+  latency     Only measure the latency of a target URL
+  full        Measure the latency, download and upload speeds
+  serve       Serve test files and an upload sink
+  monitor     Test a target on a schedule with rolling statistics
+  api         Serve a REST API starting tests and reading the results
+  grpc        Serve a gRPC service running tests, or run one on a remote server
+  controller  Schedule tests on the registered agents and collect the results
+  agent       Run the tests scheduled by a controller
+  history     List the results stored in the history database
+  export      Export the stored results as CSV or JSON
+  compare     Show the changes between two results
+  dns         Compare the resolution latency of DNS resolvers
+  path        Trace the hops to a server with their loss and latency
+  mtu         Find the path MTU to a server and detect PMTUD black holes
+  vpn         Compare a test through a VPN and through the physical interface
+  ping        Measure the latency with ICMP, TCP or HTTP probes
+  tcp, udp    Raw TCP and UDP tests against a go-speedtest server
+  ws          WebSocket echo latency and throughput test, client or server
+  voip        Estimate the call and gaming quality from paced UDP echoes
+  ookla, fast, ndt7, librespeed
+              Test against public speed test services
+  registry    Measure the pull speed of a container image
+  mirrors     Rank Linux distribution mirrors by download speed
+  cdn         Compare the CDNs serving the same asset
+  s3          Test ranged GETs and multipart uploads on S3 compatible storage
 
-	flag.Parse()
+Without a command, the target is downloaded with all the test flags.
+Run "go-speedtest <command> -h" for the flags of a command.
+`)
+}
 
-	if *target == "" {
-		fmt.Println("Target URL is required.")
-		os.Exit(1)
+func main() {
+	// Errors until the flags pick the log level
+	setLogger(os.Stderr, slog.LevelInfo)
+
+	// Dispatch subcommands, the flat flag set tests a target URL
+	if len(os.Args) > 1 {
+		if groups, ok := testCommands[os.Args[1]]; ok {
+			testCommand(flag.NewFlagSet(os.Args[1], flag.ExitOnError), os.Args[2:], groups)
+			return
+		}
+		switch os.Args[1] {
+		case "agent":
+			agentCommand(os.Args[2:])
+			return
+		case "api":
+			apiCommand(os.Args[2:])
+			return
+		case "cdn":
+			cdnCommand(os.Args[2:])
+			return
+		case "compare":
+			compareCommand(os.Args[2:])
+			return
+		case "controller":
+			controllerCommand(os.Args[2:])
+			return
+		case "dns":
+			dnsCommand(os.Args[2:])
+			return
+		case "export":
+			exportCommand(os.Args[2:])
+			return
+		case "grpc":
+			grpcCommand(os.Args[2:])
+			return
+		case "help", "-h", "-help", "--help":
+			usage()
+			return
+		case "history":
+			historyCommand(os.Args[2:])
+			return
+		case "ping":
+			pingCommand(os.Args[2:])
+			return
+		case "path":
+			pathCommand(os.Args[2:])
+			return
+		case "mtu":
+			mtuCommand(os.Args[2:])
+			return
+		case "vpn":
+			vpnCommand(os.Args[2:])
+			return
+		case "monitor":
+			monitorCommand(os.Args[2:])
+			return
+		case "serve":
+			serveCommand(os.Args[2:])
+			return
+		case "tcp":
+			tcpCommand(os.Args[2:])
+			return
+		case "udp":
+			udpCommand(os.Args[2:])
+			return
+		case "ws":
+			wsCommand(os.Args[2:])
+			return
+		case "voip":
+			voipCommand(os.Args[2:])
+			return
+		case "ookla":
+			ooklaCommand(os.Args[2:])
+			return
+		case "ndt7":
+			ndt7Command(os.Args[2:])
+			return
+		case "librespeed":
+			libreSpeedCommand(os.Args[2:])
+			return
+		case "fast":
+			fastCommand(os.Args[2:])
+			return
+		case "mirrors":
+			mirrorsCommand(os.Args[2:])
+			return
+		case "s3":
+			s3Command(os.Args[2:])
+			return
+		case "registry":
+			registryCommand(os.Args[2:])
+			return
+		}
 	}
 
-	// Get the file size
-	resp, err := http.Head(*target)
-	if err != nil {
-		fmt.Printf("Failed to get file size: %v\n", err)
-		os.Exit(1)
+	// Without a command, test the target downloads with all the flags
+	testCommand(flag.CommandLine, os.Args[1:], allFlags)
+}
+
+// Test the target URLs with the flags of the given groups
+func testCommand(fs *flag.FlagSet, args []string, groups flagGroups) {
+	var targets stringList
+	fs.Var(&targets, "target", "HTTP remote URL for speed testing, repeat to compare several targets")
+	targetsFile := fs.String("targets-file", "", "File listing one target URL per line to compare")
+	parallel := fs.Bool("parallel-servers", false, "Test the compared targets concurrently instead of one after the other")
+	autoSelect := fs.Bool("auto-select", false, "Test only the lowest latency of the given targets instead of comparing them")
+	http3 := fs.Bool("http3", false, "Run the test over HTTP/3 (QUIC)")
+	multiplex := fs.Bool("h2-multiplex", false, "Send the parallel downloads as streams of a single HTTP/2 connection")
+	compareProtocols := fs.Bool("compare-protocols", false, "Run the test over HTTP/1.1, HTTP/2 and HTTP/3 and compare them")
+	uploadTarget := new(string)
+	if groups&uploadFlags != 0 {
+		uploadTarget = fs.String("upload-target", "", "HTTP remote URL receiving uploads (defaults to target)")
 	}
-	defer resp.Body.Close()
+	tf := addTestFlags(fs, groups)
+	parseFlags(fs, args)
 
-	fileSize := resp.ContentLength
-	if fileSize <= 0 {
-		fmt.Println("Invalid file size.")
+	// -h2-multiplex picks its own transport
+	if *multiplex && (*http3 || *compareProtocols) {
+		fmt.Println("-h2-multiplex can't be used with -http3 or -compare-protocols.")
 		os.Exit(1)
 	}
 
-	// Create a wait group to wait for all goroutines to finish
-	var wg sync.WaitGroup
-	start := time.Now()
-
-	// Channel to signal the end of the test
-	done := make(chan struct{})
-
-	// Channel to capture interrupt signal
-	interrupt := make(chan os.Signal, 1)
-	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
-
-	// Ticker to update progress bars every second
-	ticker := time.NewTicker(1 * time.Second)
-	defer ticker.Stop()
-
-	// Function to download a part of the file
-	downloadPart := func(part int64, progressCounters []int64) {
-		defer wg.Done()
-		req, _ := http.NewRequest("GET", *target, nil)
-		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", part*fileSize/int64(*concurrent), (part+1)*fileSize/int64(*concurrent)-1))
-
-		resp, err := http.DefaultClient.Do(req)
+	if *targetsFile != "" {
+		list, err := readTargetsFile(*targetsFile)
 		if err != nil {
-			fmt.Printf("Failed to download part %d: %v\n", part, err)
-			return
-		}
-		defer resp.Body.Close()
-
-		buf := make([]byte, 1024)
-		for {
-			n, err := resp.Body.Read(buf)
-			if err != nil && err != io.EOF {
-				fmt.Printf("Error reading data: %v\n", err)
-				return
-			}
-			if n == 0 {
-				break
-			}
-			progressCounters[part] += int64(n)
+			fatal(err)
 		}
+		targets = append(targets, list...)
 	}
-
-	// Start the downloads
-	progressCounters := make([]int64, *concurrent)
-	for i := int64(0); i < *concurrent; i++ {
-		wg.Add(1)
-		go downloadPart(i, progressCounters)
+	// Upload only tests may just name the sink
+	if len(targets) == 0 && groups&downloadFlags == 0 && *uploadTarget != "" {
+		targets = append(targets, *uploadTarget)
+	}
+	if len(targets) == 0 {
+		fmt.Println("Target URL is required.")
+		os.Exit(1)
 	}
 
-	// If duration is specified, stop the test after the specified time
-	if *duration > 0 {
-		go func() {
-			time.Sleep(time.Duration(*duration) * time.Second)
-			close(done)
-		}()
+	opts := tf.options()
+	opts.UploadTarget = *uploadTarget
+	opts.SkipDownload = groups&downloadFlags == 0
+	// Commands without -upload always run the upload if they have one
+	if groups&uploadFlags != 0 && groups&uploadSwitch == 0 {
+		opts.Upload = true
 	}
 
-	// Wait for all goroutines to finish or duration to elapse or interrupt signal
-	go func() {
-		wg.Wait()
-		close(done)
-	}()
+	client := speedtest.NewClient()
+	if *http3 {
+		var err error
+		if client, err = speedtest.NewProtocolClient(speedtest.ProtoHTTP3); err != nil {
+			fatal(err)
+		}
+	}
+	if *multiplex {
+		client = speedtest.NewMultiplexClient()
+	}
+	tf.configure(client)
 
-	// Update progress bars
-	if *progress {
-		go func() {
-			for {
-				select {
-				case <-ticker.C:
-					for i := 0; i < int(*concurrent); i++ {
-						//						fmt.Println(progressCounters[i])
-						displayProgress(i, progressCounters, fileSize/int64(*concurrent))
-					}
-				case <-done:
-					return
+	if len(targets) > 1 && *autoSelect {
+		runTest(tf, opts, func(ctx context.Context, opts speedtest.Options) (*speedtest.Result, error) {
+			if *tf.format == "text" {
+				tf.say(os.Stdout, "Selecting the nearest server...")
+			}
+			return client.RunNearest(ctx, opts, targets)
+		})
+		return
+	}
+	if *compareProtocols {
+		var tests []compareTest
+		for _, target := range targets {
+			for _, proto := range speedtest.Protocols {
+				// HTTP/2 is negotiated with TLS, and HTTP/3 runs over it
+				if proto != speedtest.ProtoHTTP1 && !strings.HasPrefix(target, "https://") {
+					slog.Warn("protocol not compared, it needs an https target", "protocol", proto, "target", target)
+					continue
 				}
+				client, err := speedtest.NewProtocolClient(proto)
+				if err != nil {
+					fatal(err)
+				}
+				tests = append(tests, compareTest{target: target, protocol: proto, run: tf.configure(client).Run})
 			}
-		}()
+		}
+		runCompare(tf, opts, tests, false, printComparison)
+		return
 	}
-
-	select {
-	case <-done:
-	case <-interrupt:
-		fmt.Println("\nInterrupt signal received. Stopping the test...")
+	if len(targets) > 1 {
+		var tests []compareTest
+		for _, target := range targets {
+			tests = append(tests, compareTest{target: target, run: client.Run})
+		}
+		runCompare(tf, opts, tests, *parallel, printComparison)
+		return
 	}
-
-	elapsed := time.Since(start)
-	downloadSpeedBytes := float64(fileSize) / elapsed.Seconds()
-	downloadSpeedMBytes := downloadSpeedBytes / (1024 * 1024)
-
-	// Print the summary
-	fmt.Printf("Summary:\n")
-	fmt.Printf("File URL: %s\n", *target)
-	fmt.Printf("File Size: %d bytes\n", fileSize)
-	fmt.Printf("Concurrent Downloads: %d\n", *concurrent)
-	fmt.Printf("Download Time: %s\n", elapsed)
-	fmt.Printf("Download Speed: %.2f bytes/sec (%.2f MB/sec)\n", downloadSpeedBytes, downloadSpeedMBytes)
-}
-
-// Function to display progress bar
-func displayProgress(part int, progressCounters []int64, total int64) {
-	const barWidth = 40
-	percent := float64(progressCounters[part]) / float64(total) * 100
-	bar := int(percent * barWidth / 100)
-	fmt.Printf("\033[%d;0HPart %d: [%-*s] %.2f%%", part+1, part, barWidth, strings.Repeat("=", bar), percent)
+	opts.Target = targets[0]
+	runTest(tf, opts, client.Run)
 }
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/ofauchon/go-speedtest/speedtest"
+)
+
+// Rolling statistics over the last results
+type rollingStats struct {
+	window  int
+	results []*speedtest.Result
+	runs    int
+	fail    int
+}
+
+func (s *rollingStats) add(res *speedtest.Result, err error) {
+	s.runs++
+	if err != nil {
+		s.fail++
+		return
+	}
+	s.results = append(s.results, res)
+	if len(s.results) > s.window {
+		s.results = s.results[1:]
+	}
+}
+
+func (s *rollingStats) print(w io.Writer) {
+	fmt.Fprintf(w, "Runs: %d (%d failed), statistics over the last %d\n", s.runs, s.fail, len(s.results))
+	for _, m := range resultMetrics {
+		if st := summarize(m.values(s.results)); st.N > 0 {
+			fmt.Fprintf(w, "  %-9s min %.2f / avg %.2f / max %.2f %s\n", m.name, st.Min, st.Avg, st.Max, m.unit)
+		}
+	}
+}
+
+// Run tests on the schedule until ctx is cancelled, calling onResult
+// after each run
+func runScheduled(ctx context.Context, sched schedule, opts speedtest.Options, run func(context.Context, speedtest.Options) (*speedtest.Result, error), onResult func(*speedtest.Result, error)) {
+	for {
+		res, err := run(ctx, opts)
+		if ctx.Err() != nil {
+			return
+		}
+		onResult(res, err)
+
+		select {
+		case <-time.After(time.Until(sched.next(time.Now()))):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Test a target on a schedule and keep rolling statistics
+func monitorCommand(args []string) {
+	fs := flag.NewFlagSet("monitor", flag.ExitOnError)
+	target := fs.String("target", "", "HTTP remote URL for speed testing")
+	uploadTarget := fs.String("upload-target", "", "HTTP remote URL receiving uploads (defaults to target)")
+	cron := fs.String("cron", "", "Run tests at times matching this cron expression instead of every -every seconds")
+	window := fs.Int("window", 12, "Number of runs kept for the rolling statistics")
+	alertAfter := fs.Int("alert-after", 3, "Notify when -min-download, -min-upload or -max-latency are broken for this many consecutive runs")
+	slack := fs.String("slack-webhook", "", "Send the alerts to this Slack incoming webhook")
+	discord := fs.String("discord-webhook", "", "Send the alerts to this Discord webhook")
+	telegramToken := fs.String("telegram-token", "", "Send the alerts through this Telegram bot token")
+	telegramChat := fs.String("telegram-chat", "", "Telegram chat ID receiving the alerts")
+	web := fs.Bool("web", false, "Serve the web dashboard and the REST API starting tests on -listen")
+	token := fs.String("api-token", "", "Require this bearer token in the Authorization header of the API requests")
+	tf := addTestFlags(fs, allFlags)
+	parseFlags(fs, args)
+
+	if *target == "" {
+		fmt.Println("Target URL is required.")
+		os.Exit(1)
+	}
+	if *web && *tf.listen == "" {
+		fmt.Println("The web dashboard is served on -listen, which is required with -web.")
+		os.Exit(1)
+	}
+	var sched schedule = everySchedule(time.Duration(*tf.every) * time.Second)
+	if *cron != "" {
+		c, err := parseCron(*cron)
+		if err != nil {
+			fatal(err)
+		}
+		sched = c
+	}
+
+	opts := tf.options()
+	opts.Target = *target
+	opts.UploadTarget = *uploadTarget
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	console := tf.console()
+	tf.setupLogging(console)
+	tf.say(console, "Go SpeedTest monitor")
+	tf.handleSignals(ctx, console, &opts)
+
+	tf.print = true
+	run := tf.publishing(console, tf.identifying(tf.configure(speedtest.NewClient()).Run))
+
+	// Serve the last result as Prometheus metrics if asked, with the web
+	// dashboard running the scheduled tests like those it starts
+	var e *exporter
+	var api *apiServer
+	if *tf.listen != "" {
+		var handler http.Handler
+		if *web {
+			if *token == "" {
+				slog.Warn("the API accepts requests without authentication, see -api-token")
+			}
+			api = &apiServer{ctx: ctx, token: *token, db: *tf.history, opts: opts, run: run}
+			handler = api.handler(true)
+		}
+		var err error
+		if e, err = startExporter(*tf.listen, handler); err != nil {
+			fatal(err)
+		}
+		if *web {
+			slog.Info("serving web dashboard", "addr", *tf.listen)
+		}
+	}
+
+	alerts := &alerter{after: *alertAfter, minDownload: *tf.minDownload, minUpload: *tf.minUpload, maxLatency: *tf.maxLatency}
+	if *slack != "" {
+		alerts.notifiers = append(alerts.notifiers, slackNotifier(*slack))
+	}
+	if *discord != "" {
+		alerts.notifiers = append(alerts.notifiers, discordNotifier(*discord))
+	}
+	if *telegramToken != "" {
+		alerts.notifiers = append(alerts.notifiers, telegramNotifier(*telegramToken, *telegramChat))
+	}
+
+	stats := &rollingStats{window: *window}
+	record := func(res *speedtest.Result, err error) {
+		stats.add(res, err)
+		if e != nil {
+			e.record(res, err)
+		}
+		if err != nil {
+			slog.Error("test failed", "err", err)
+		}
+		stats.print(console)
+		if msg := alerts.check(opts.Target, res, err); msg != "" {
+			slog.Warn(msg)
+			for _, err := range alerts.notify(ctx, msg) {
+				slog.Error("alert not sent", "err", err)
+			}
+		}
+	}
+	if api != nil {
+		// The dashboard tests are recorded when they finish
+		api.onResult = record
+		run = api.scheduled
+		record = func(*speedtest.Result, error) {}
+	}
+	runScheduled(ctx, sched, opts, run, record)
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ofauchon/go-speedtest/speedtest"
+)
+
+// Sensors announced with Home Assistant MQTT discovery
+var mqttSensors = []struct {
+	key, name, unit, class string
+}{
+	{"download_mbps", "Download", "Mbit/s", "data_rate"},
+	{"upload_mbps", "Upload", "Mbit/s", "data_rate"},
+	{"latency_ms", "Latency", "ms", "duration"},
+	{"jitter_ms", "Jitter", "ms", "duration"},
+	{"loss_percent", "Packet loss", "%", ""},
+}
+
+// Characters not allowed in discovery object ids
+var mqttIDEscaper = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// Home Assistant discovery messages of the sensors, by config topic
+func mqttDiscovery(prefix, topic string) map[string][]byte {
+	node := strings.Trim(mqttIDEscaper.ReplaceAllString(topic, "_"), "_")
+	messages := map[string][]byte{}
+	for _, s := range mqttSensors {
+		config := map[string]any{
+			"name":                s.name,
+			"unique_id":           node + "_" + s.key,
+			"state_topic":         topic,
+			"value_template":      "{{ value_json." + s.key + " }}",
+			"unit_of_measurement": s.unit,
+			"state_class":         "measurement",
+			"device": map[string]any{
+				"identifiers": []string{node},
+				"name":        "Go SpeedTest " + node,
+			},
+		}
+		if s.class != "" {
+			config["device_class"] = s.class
+		}
+		msg, _ := json.Marshal(config)
+		messages[fmt.Sprintf("%s/sensor/%s/%s/config", prefix, node, s.key)] = msg
+	}
+	return messages
+}
+
+func init() {
+	registerSink(func(f *testFlags) (Sink, error) {
+		if *f.mqtt == "" {
+			return nil, nil
+		}
+		return sinkFunc{"MQTT", func(ctx context.Context, res *speedtest.Result) error {
+			return publishMQTT(ctx, *f.mqtt, *f.mqttTopic, *f.mqttDiscovery, res)
+		}}, nil
+	})
+}
+
+// Publish the result on the state topic, preceded by the discovery
+// messages if discoveryPrefix is set. Messages are retained so new
+// subscribers get the latest values.
+func publishMQTT(ctx context.Context, broker, topic, discoveryPrefix string, res *speedtest.Result) error {
+	conn, err := dialMQTT(ctx, broker)
+	if err != nil {
+		return fmt.Errorf("mqtt: %w", err)
+	}
+	defer conn.Close()
+
+	if discoveryPrefix != "" {
+		for t, msg := range mqttDiscovery(discoveryPrefix, topic) {
+			if err := conn.publish(t, msg, true); err != nil {
+				return fmt.Errorf("mqtt: %w", err)
+			}
+		}
+	}
+	state, _ := json.Marshal(newResultSummary(res))
+	if err := conn.publish(topic, state, true); err != nil {
+		return fmt.Errorf("mqtt: %w", err)
+	}
+	return conn.disconnect()
+}
+
+// Minimal MQTT 3.1.1 client, publishing at QoS 0
+type mqttConn struct {
+	net.Conn
+	w *bufio.Writer
+}
+
+// Connect to a tcp:// or mqtt:// broker, ssl:// or mqtts:// for TLS, with
+// the credentials of the URL
+func dialMQTT(ctx context.Context, broker string) (*mqttConn, error) {
+	u, err := url.Parse(broker)
+	if err != nil {
+		return nil, err
+	}
+	host := u.Host
+	var secure bool
+	switch u.Scheme {
+	case "tcp", "mqtt":
+	case "ssl", "tls", "mqtts":
+		secure = true
+	default:
+		return nil, fmt.Errorf("unsupported broker scheme %q", u.Scheme)
+	}
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), map[bool]string{false: "1883", true: "8883"}[secure])
+	}
+
+	d := &net.Dialer{Timeout: 10 * time.Second}
+	var c net.Conn
+	if secure {
+		c, err = (&tls.Dialer{NetDialer: d}).DialContext(ctx, "tcp", host)
+	} else {
+		c, err = d.DialContext(ctx, "tcp", host)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		c.SetDeadline(deadline)
+	}
+	conn := &mqttConn{Conn: c, w: bufio.NewWriter(c)}
+
+	// CONNECT with a clean session, then wait for the CONNACK
+	var flags byte = 0x02
+	payload := mqttString(fmt.Sprintf("go-speedtest-%d", time.Now().UnixNano()))
+	if user := u.User; user != nil {
+		flags |= 0x80
+		payload = append(payload, mqttString(user.Username())...)
+		if pass, ok := user.Password(); ok {
+			flags |= 0x40
+			payload = append(payload, mqttString(pass)...)
+		}
+	}
+	header := append(mqttString("MQTT"), 4, flags, 0, 60)
+	if err := conn.packet(0x10, append(header, payload...)); err != nil {
+		c.Close()
+		return nil, err
+	}
+	ack := make([]byte, 4)
+	if _, err := io.ReadFull(c, ack); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("no CONNACK: %w", err)
+	}
+	if ack[0] != 0x20 || ack[3] != 0 {
+		c.Close()
+		return nil, fmt.Errorf("connection refused by the broker (code %d)", ack[3])
+	}
+	return conn, nil
+}
+
+func (c *mqttConn) publish(topic string, payload []byte, retain bool) error {
+	var kind byte = 0x30
+	if retain {
+		kind |= 0x01
+	}
+	return c.packet(kind, append(mqttString(topic), payload...))
+}
+
+func (c *mqttConn) disconnect() error {
+	return c.packet(0xe0, nil)
+}
+
+// Send a packet with its remaining length
+func (c *mqttConn) packet(kind byte, body []byte) error {
+	if len(body) > 268435455 {
+		return errors.New("packet too large")
+	}
+	c.w.WriteByte(kind)
+	n := len(body)
+	for {
+		b := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			b |= 0x80
+		}
+		c.w.WriteByte(b)
+		if n == 0 {
+			break
+		}
+	}
+	c.w.Write(body)
+	return c.w.Flush()
+}
+
+// Length prefixed UTF-8 string
+func mqttString(s string) []byte {
+	return append(binary.BigEndian.AppendUint16(nil, uint16(len(s))), s...)
+}
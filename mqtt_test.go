@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ofauchon/go-speedtest/speedtest"
+)
+
+func TestMQTTRemainingLength(t *testing.T) {
+	tests := []struct {
+		n    int
+		want []byte
+	}{
+		{0, []byte{0x00}},
+		{127, []byte{0x7f}},
+		{128, []byte{0x80, 0x01}},
+		{16383, []byte{0xff, 0x7f}},
+		{16384, []byte{0x80, 0x80, 0x01}},
+		{2097151, []byte{0xff, 0xff, 0x7f}},
+		{2097152, []byte{0x80, 0x80, 0x80, 0x01}},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		c := &mqttConn{w: bufio.NewWriter(&buf)}
+		if err := c.packet(0x30, make([]byte, tt.n)); err != nil {
+			t.Fatal(err)
+		}
+		header := buf.Bytes()[:1+len(tt.want)]
+		if header[0] != 0x30 || !bytes.Equal(header[1:], tt.want) || buf.Len() != len(header)+tt.n {
+			t.Errorf("packet of %d bytes starts with % x, want 30 % x", tt.n, header, tt.want)
+		}
+	}
+}
+
+// Read an MQTT packet, returning its type and flags byte and its body
+func readMQTTPacket(r *bufio.Reader) (byte, []byte, error) {
+	kind, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	n, mult := 0, 1
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		n += int(b&0x7f) * mult
+		mult *= 128
+		if b&0x80 == 0 {
+			break
+		}
+	}
+	body := make([]byte, n)
+	_, err = io.ReadFull(r, body)
+	return kind, body, err
+}
+
+// Split the length prefixed string starting body
+func readMQTTString(t *testing.T, body []byte) (string, []byte) {
+	t.Helper()
+	if len(body) < 2 || len(body) < 2+int(binary.BigEndian.Uint16(body)) {
+		t.Fatalf("truncated string in % x", body)
+	}
+	n := 2 + int(binary.BigEndian.Uint16(body))
+	return string(body[2:n]), body[n:]
+}
+
+func TestPublishMQTT(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	type message struct {
+		kind byte
+		body []byte
+	}
+	received := make(chan []message, 1)
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		r := bufio.NewReader(c)
+		var messages []message
+		for {
+			kind, body, err := readMQTTPacket(r)
+			if err != nil {
+				break
+			}
+			messages = append(messages, message{kind, body})
+			if kind == 0x10 {
+				c.Write([]byte{0x20, 0x02, 0x00, 0x00})
+			}
+			if kind == 0xe0 {
+				break
+			}
+		}
+		received <- messages
+	}()
+
+	res := &speedtest.Result{Target: "http://example.com/file", Start: time.Unix(1700000000, 0).UTC(), Bytes: 125000000, Elapsed: 10 * time.Second}
+	if err := publishMQTT(context.Background(), "mqtt://user:secret@"+ln.Addr().String(), "speedtest/home", "", res); err != nil {
+		t.Fatal(err)
+	}
+	messages := <-received
+	if len(messages) != 3 || messages[0].kind != 0x10 || messages[1].kind != 0x31 || messages[2].kind != 0xe0 {
+		t.Fatalf("packets %+v, want CONNECT, a retained PUBLISH and DISCONNECT", messages)
+	}
+
+	// Protocol name and level, flags, keep alive then the payload
+	name, rest := readMQTTString(t, messages[0].body)
+	if name != "MQTT" || !bytes.Equal(rest[:4], []byte{4, 0xc2, 0, 60}) {
+		t.Errorf("CONNECT header %q % x, want MQTT 04 c2 00 3c", name, rest[:4])
+	}
+	_, rest = readMQTTString(t, rest[4:])
+	user, rest := readMQTTString(t, rest)
+	pass, rest := readMQTTString(t, rest)
+	if user != "user" || pass != "secret" || len(rest) != 0 {
+		t.Errorf("credentials %q %q with % x left, want user secret", user, pass, rest)
+	}
+
+	topic, payload := readMQTTString(t, messages[1].body)
+	if topic != "speedtest/home" {
+		t.Errorf("state published on %q", topic)
+	}
+	var state resultSummary
+	if err := json.Unmarshal(payload, &state); err != nil {
+		t.Fatal(err)
+	}
+	if state.DownloadMbps == nil || *state.DownloadMbps != 100 || state.Timestamp != "2023-11-14T22:13:20Z" {
+		t.Errorf("state %s, want 100 Mbit/s at the start", payload)
+	}
+}
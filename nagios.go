@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ofauchon/go-speedtest/speedtest"
+)
+
+// Nagios plugin states, which are also the exit codes of the check
+const (
+	nagiosOK = iota
+	nagiosWarning
+	nagiosCritical
+	nagiosUnknown
+)
+
+var nagiosStates = []string{"OK", "WARNING", "CRITICAL", "UNKNOWN"}
+
+// Metric of the Nagios output with its limits, 0 disabling one
+type nagiosCheck struct {
+	metric resultMetric
+	// Performance data label, unit, factor from the metric value and
+	// decimals
+	label string
+	uom   string
+	scale float64
+	prec  int
+	warn  float64
+	crit  float64
+}
+
+// Checks of the -warn-* limits (WARNING) and the -min-download, -min-upload
+// and -max-latency thresholds (CRITICAL)
+func (f *testFlags) nagiosChecks() []nagiosCheck {
+	return []nagiosCheck{
+		{metric: downloadMetric, label: "download_mbps", scale: 1, prec: 2, warn: *f.warnDownload, crit: *f.minDownload},
+		{metric: uploadMetric, label: "upload_mbps", scale: 1, prec: 2, warn: *f.warnUpload, crit: *f.minUpload},
+		// Perfdata times are in seconds
+		{metric: latencyMetric, label: "latency", uom: "s", scale: 1e-3, prec: 6, warn: *f.warnLatency, crit: *f.maxLatency},
+	}
+}
+
+// Tell whether v breaks limit, a minimum unless lower is better
+func (c nagiosCheck) breaks(v, limit float64) bool {
+	if limit <= 0 {
+		return false
+	}
+	if c.metric.lowerBetter {
+		return v > limit
+	}
+	return v < limit
+}
+
+// Perfdata threshold range of a limit: lower limits alert outside "limit:"
+// and upper limits outside "0:limit"
+func (c nagiosCheck) perfRange(limit float64) string {
+	if limit <= 0 {
+		return ""
+	}
+	if c.metric.lowerBetter {
+		return strconv.FormatFloat(limit*c.scale, 'f', -1, 64)
+	}
+	return strconv.FormatFloat(limit*c.scale, 'f', -1, 64) + ":"
+}
+
+// Return the state of the result and the plugin output line, with the
+// metrics breaking their limits or regressed from the baseline flagged
+func (f *testFlags) nagiosReport(res, baseline *speedtest.Result) (int, string) {
+	state := nagiosOK
+	raise := func(s int) {
+		state = max(state, s)
+	}
+	var msgs, perf []string
+	for _, c := range f.nagiosChecks() {
+		name := strings.ToLower(c.metric.name)
+		v, ok := c.metric.value(res)
+		if !ok {
+			// Missing measurements break the limits that need them
+			if c.crit > 0 {
+				raise(nagiosCritical)
+				msgs = append(msgs, name+" not measured")
+			} else if c.warn > 0 {
+				raise(nagiosWarning)
+				msgs = append(msgs, name+" not measured")
+			}
+			continue
+		}
+		msg := fmt.Sprintf("%s %.2f %s", name, v, c.metric.unit)
+		op := "below"
+		if c.metric.lowerBetter {
+			op = "above"
+		}
+		switch {
+		case c.breaks(v, c.crit):
+			raise(nagiosCritical)
+			msg += fmt.Sprintf(" (%s %g)", op, c.crit)
+		case c.breaks(v, c.warn):
+			raise(nagiosWarning)
+			msg += fmt.Sprintf(" (%s %g)", op, c.warn)
+		}
+		msgs = append(msgs, msg)
+		perf = append(perf, fmt.Sprintf("%s=%.*f%s;%s;%s;0;", c.label, c.prec, v*c.scale, c.uom, c.perfRange(c.warn), c.perfRange(c.crit)))
+	}
+	if lat := res.Latency; lat != nil && lat.Sent > 0 {
+		perf = append(perf, fmt.Sprintf("jitter=%.6fs;;;0;", lat.Jitter.Seconds()), fmt.Sprintf("loss=%.1f%%;;;0;100", lat.Loss()))
+	}
+	if baseline != nil {
+		for _, d := range checkBaseline(baseline, res, *f.baselineTolerance) {
+			raise(nagiosWarning)
+			msgs = append(msgs, fmt.Sprintf("%s regressed %+.1f%% from the baseline", strings.ToLower(d.Metric), d.Percent))
+		}
+	}
+	return state, fmt.Sprintf("SPEEDTEST %s - %s | %s", nagiosStates[state], strings.Join(msgs, ", "), strings.Join(perf, " "))
+}
+
+// Output line of a check that could not run
+func nagiosError(err error) string {
+	return fmt.Sprintf("SPEEDTEST %s - %v", nagiosStates[nagiosUnknown], err)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"testing"
+	"time"
+
+	"github.com/ofauchon/go-speedtest/speedtest"
+)
+
+// Return the test flags parsed from args
+func parseTestFlags(t *testing.T, args ...string) *testFlags {
+	t.Helper()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	f := addTestFlags(fs, allFlags)
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestNagiosReport(t *testing.T) {
+	res := &speedtest.Result{
+		Start: time.Unix(1700000000, 0), Bytes: 125000000, Elapsed: 10 * time.Second,
+		Latency: &speedtest.LatencyResult{Sent: 4, Received: 3, Avg: 12500 * time.Microsecond, Jitter: time.Millisecond},
+	}
+	tests := []struct {
+		args  []string
+		state int
+		line  string
+	}{
+		{nil, nagiosOK,
+			"SPEEDTEST OK - download 100.00 Mbit/sec, latency 12.50 ms | download_mbps=100.00;;;0; latency=0.012500s;;;0; jitter=0.001000s;;;0; loss=25.0%;;;0;100"},
+		{[]string{"-warn-download", "150", "-min-download", "50", "-warn-latency", "20", "-max-latency", "40"}, nagiosWarning,
+			"SPEEDTEST WARNING - download 100.00 Mbit/sec (below 150), latency 12.50 ms | download_mbps=100.00;150:;50:;0; latency=0.012500s;0.02;0.04;0; jitter=0.001000s;;;0; loss=25.0%;;;0;100"},
+		{[]string{"-min-download", "200", "-warn-latency", "10"}, nagiosCritical,
+			"SPEEDTEST CRITICAL - download 100.00 Mbit/sec (below 200), latency 12.50 ms (above 10) | download_mbps=100.00;;200:;0; latency=0.012500s;0.01;;0; jitter=0.001000s;;;0; loss=25.0%;;;0;100"},
+		// The upload was not run
+		{[]string{"-min-upload", "10"}, nagiosCritical,
+			"SPEEDTEST CRITICAL - download 100.00 Mbit/sec, upload not measured, latency 12.50 ms | download_mbps=100.00;;;0; latency=0.012500s;;;0; jitter=0.001000s;;;0; loss=25.0%;;;0;100"},
+	}
+	for _, tt := range tests {
+		state, line := parseTestFlags(t, tt.args...).nagiosReport(res, nil)
+		if state != tt.state || line != tt.line {
+			t.Errorf("%q:\ngot  %s %s\nwant %s %s", tt.args, nagiosStates[state], line, nagiosStates[tt.state], tt.line)
+		}
+	}
+
+	if got, want := nagiosError(errors.New("no route to host")), "SPEEDTEST UNKNOWN - no route to host"; got != want {
+		t.Errorf("nagiosError = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ofauchon/go-speedtest/speedtest"
+)
+
+// OTLP span kinds and status codes
+const (
+	otlpSpanInternal = 1
+	otlpSpanClient   = 3
+	otlpStatusError  = 2
+)
+
+// Attribute of an OTLP resource, data point or span
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	String *string  `json:"stringValue,omitempty"`
+	Int    *string  `json:"intValue,omitempty"`
+	Double *float64 `json:"doubleValue,omitempty"`
+	Bool   *bool    `json:"boolValue,omitempty"`
+}
+
+func otlpString(key, v string) otlpAttribute {
+	return otlpAttribute{key, otlpValue{String: &v}}
+}
+
+// 64-bit integers are strings in OTLP/JSON
+func otlpInt(key string, v int64) otlpAttribute {
+	s := strconv.FormatInt(v, 10)
+	return otlpAttribute{key, otlpValue{Int: &s}}
+}
+
+func otlpDouble(key string, v float64) otlpAttribute {
+	return otlpAttribute{key, otlpValue{Double: &v}}
+}
+
+func otlpBool(key string, v bool) otlpAttribute {
+	return otlpAttribute{key, otlpValue{Bool: &v}}
+}
+
+func otlpTime(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+// Resource and instrumentation scope of everything exported
+func otlpResource() map[string]any {
+	return map[string]any{"attributes": []otlpAttribute{otlpString("service.name", "go-speedtest")}}
+}
+
+var otlpScope = map[string]string{"name": "go-speedtest"}
+
+type otlpSpan struct {
+	TraceID      string          `json:"traceId"`
+	SpanID       string          `json:"spanId"`
+	ParentSpanID string          `json:"parentSpanId,omitempty"`
+	Name         string          `json:"name"`
+	Kind         int             `json:"kind"`
+	Start        string          `json:"startTimeUnixNano"`
+	End          string          `json:"endTimeUnixNano"`
+	Attributes   []otlpAttribute `json:"attributes,omitempty"`
+	Status       *otlpStatus     `json:"status,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Build the OTLP metrics request of a result: a gauge per measured value
+func otlpMetrics(res *speedtest.Result) map[string]any {
+	summary := newResultSummary(res)
+	at := otlpTime(res.Start)
+	var metrics []map[string]any
+	for _, m := range []struct {
+		name, unit, desc string
+		value            *float64
+	}{
+		{"speedtest.download.throughput", "Mbit/s", "Download speed", summary.DownloadMbps},
+		{"speedtest.upload.throughput", "Mbit/s", "Upload speed", summary.UploadMbps},
+		{"speedtest.latency", "ms", "Average round trip time", summary.LatencyMs},
+		{"speedtest.jitter", "ms", "Average variation of the round trip time", summary.JitterMs},
+		{"speedtest.packet_loss", "%", "Probes without answer", summary.LossPercent},
+	} {
+		if m.value == nil {
+			continue
+		}
+		metrics = append(metrics, map[string]any{
+			"name":        m.name,
+			"unit":        m.unit,
+			"description": m.desc,
+			"gauge": map[string]any{"dataPoints": []map[string]any{{
+				"timeUnixNano": at,
+				"asDouble":     *m.value,
+				"attributes":   []otlpAttribute{otlpString("target", res.Target)},
+			}}},
+		})
+	}
+	// One gauge with a data point per loaded phase
+	if bb := res.Bufferbloat; bb != nil {
+		var points []map[string]any
+		for _, phase := range []struct {
+			name string
+			lat  *speedtest.LatencyResult
+		}{{"download", bb.Download}, {"upload", bb.Upload}, {"bidirectional", bb.Bidirectional}} {
+			if phase.lat == nil || phase.lat.Received == 0 {
+				continue
+			}
+			points = append(points, map[string]any{
+				"timeUnixNano": at,
+				"asDouble":     float64(phase.lat.Avg) / float64(time.Millisecond),
+				"attributes":   []otlpAttribute{otlpString("target", res.Target), otlpString("phase", phase.name)},
+			})
+		}
+		if len(points) > 0 {
+			metrics = append(metrics, map[string]any{
+				"name":        "speedtest.latency.loaded",
+				"unit":        "ms",
+				"description": "Average round trip time under load",
+				"gauge":       map[string]any{"dataPoints": points},
+			})
+		}
+	}
+	return map[string]any{"resourceMetrics": []map[string]any{{
+		"resource":     otlpResource(),
+		"scopeMetrics": []map[string]any{{"scope": otlpScope, "metrics": metrics}},
+	}}}
+}
+
+// Build the trace of a result: a root span with the download and upload
+// phases, their connections and the DNS, connect, TLS and transfer steps
+func otlpTrace(res *speedtest.Result) map[string]any {
+	traceID := randomHex(16)
+	var spans []otlpSpan
+	add := func(parent, name string, kind int, start, end time.Time, attrs ...otlpAttribute) string {
+		id := randomHex(8)
+		spans = append(spans, otlpSpan{TraceID: traceID, SpanID: id, ParentSpanID: parent, Name: name, Kind: kind,
+			Start: otlpTime(start), End: otlpTime(end), Attributes: attrs})
+		return id
+	}
+
+	end := res.End
+	if up := res.Upload; up != nil {
+		end = up.Start.Add(up.Elapsed)
+	}
+	root := add("", "speedtest", otlpSpanInternal, res.Start, end, otlpString("target", res.Target), otlpInt("concurrent", int64(res.Concurrent)))
+	if errs := len(res.Errors); errs > 0 {
+		spans[0].Status = &otlpStatus{Code: otlpStatusError, Message: strings.Join(res.Errors, "; ")}
+	}
+
+	if !res.DownloadSkipped {
+		download := add(root, "download", otlpSpanInternal, res.Start, res.Start.Add(res.Elapsed),
+			otlpInt("bytes", res.Bytes), otlpDouble("throughput_mbps", res.BytesPerSecond()*8/1e6))
+		for _, c := range res.Conns {
+			start := res.Start
+			var t *speedtest.ConnTiming
+			if c.Part < len(res.Timings) && !res.Timings[c.Part].Start.IsZero() {
+				t = &res.Timings[c.Part]
+				start = t.Start
+			}
+			attrs := []otlpAttribute{otlpInt("part", int64(c.Part)), otlpInt("bytes", c.Bytes),
+				otlpInt("restarts", int64(c.Restarts)), otlpInt("retries", int64(c.Retries)), otlpInt("errors", int64(c.Errors))}
+			if t != nil {
+				attrs = append(attrs, otlpString("net.peer.addr", t.Remote), otlpBool("reused", t.Reused))
+			}
+			connEnd := res.Start.Add(c.Elapsed)
+			conn := add(download, fmt.Sprintf("connection %d", c.Part), otlpSpanClient, start, connEnd, attrs...)
+			if t == nil {
+				continue
+			}
+			// The setup steps follow each other before the transfer
+			at := t.Start
+			for _, step := range []struct {
+				name string
+				d    time.Duration
+			}{{"dns", t.DNS}, {"connect", t.Connect}, {"tls", t.TLS}} {
+				if step.d > 0 {
+					add(conn, step.name, otlpSpanClient, at, at.Add(step.d))
+					at = at.Add(step.d)
+				}
+			}
+			add(conn, "transfer", otlpSpanClient, at, connEnd, otlpInt("ttfb_ns", int64(t.TTFB)))
+		}
+	}
+
+	if up := res.Upload; up != nil {
+		upload := add(root, "upload", otlpSpanInternal, up.Start, up.Start.Add(up.Elapsed),
+			otlpInt("bytes", up.Bytes), otlpDouble("throughput_mbps", up.BytesPerSecond()*8/1e6))
+		for _, c := range up.Conns {
+			add(upload, fmt.Sprintf("connection %d", c.Part), otlpSpanClient, up.Start, up.Start.Add(c.Elapsed),
+				otlpInt("part", int64(c.Part)), otlpInt("bytes", c.Bytes), otlpInt("errors", int64(c.Errors)))
+		}
+	}
+
+	return map[string]any{"resourceSpans": []map[string]any{{
+		"resource":   otlpResource(),
+		"scopeSpans": []map[string]any{{"scope": otlpScope, "spans": spans}},
+	}}}
+}
+
+func init() {
+	registerSink(func(f *testFlags) (Sink, error) {
+		headers, err := parseHeaders(f.otlpHeaders)
+		if err != nil || *f.otlp == "" {
+			return nil, err
+		}
+		return sinkFunc{"OpenTelemetry", func(ctx context.Context, res *speedtest.Result) error {
+			return exportOTLP(ctx, *f.otlp, headers, res)
+		}}, nil
+	})
+}
+
+// Export the result as OTLP/HTTP JSON metrics and trace to the collector
+// at endpoint (e.g. http://localhost:4318)
+func exportOTLP(ctx context.Context, endpoint string, headers http.Header, res *speedtest.Result) error {
+	endpoint = strings.TrimSuffix(endpoint, "/")
+	if err := postOTLP(ctx, endpoint+"/v1/metrics", headers, otlpMetrics(res)); err != nil {
+		return fmt.Errorf("otlp metrics: %w", err)
+	}
+	if err := postOTLP(ctx, endpoint+"/v1/traces", headers, otlpTrace(res)); err != nil {
+		return fmt.Errorf("otlp traces: %w", err)
+	}
+	return nil
+}
+
+func postOTLP(ctx context.Context, target string, headers http.Header, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range headers {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "go-speedtest")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/ofauchon/go-speedtest/speedtest"
+)
+
+func TestOTLPMetrics(t *testing.T) {
+	res := &speedtest.Result{
+		Target:  "http://example.com/file",
+		Start:   time.Unix(1700000000, 0),
+		Bytes:   125000000,
+		Elapsed: 10 * time.Second,
+		Bufferbloat: &speedtest.BufferbloatResult{
+			Download: &speedtest.LatencyResult{Sent: 10, Received: 10, Avg: 40 * time.Millisecond},
+			Upload:   &speedtest.LatencyResult{Sent: 10, Received: 10, Avg: 80 * time.Millisecond},
+			// Lost probes have no average
+			Bidirectional: &speedtest.LatencyResult{Sent: 10},
+		},
+	}
+	data, err := json.Marshal(otlpMetrics(res))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var req struct {
+		ResourceMetrics []struct {
+			ScopeMetrics []struct {
+				Metrics []struct {
+					Name  string `json:"name"`
+					Gauge struct {
+						DataPoints []struct {
+							Time       string          `json:"timeUnixNano"`
+							Value      float64         `json:"asDouble"`
+							Attributes []otlpAttribute `json:"attributes"`
+						} `json:"dataPoints"`
+					} `json:"gauge"`
+				} `json:"metrics"`
+			} `json:"scopeMetrics"`
+		} `json:"resourceMetrics"`
+	}
+	if err := json.Unmarshal(data, &req); err != nil {
+		t.Fatal(err)
+	}
+
+	metrics := req.ResourceMetrics[0].ScopeMetrics[0].Metrics
+	names := map[string]int{}
+	for _, m := range metrics {
+		names[m.Name]++
+	}
+	if names["speedtest.download.throughput"] != 1 || names["speedtest.latency.loaded"] != 1 || names["speedtest.upload.throughput"] != 0 {
+		t.Fatalf("metrics %v, want a single download and loaded latency metric", names)
+	}
+	for _, m := range metrics {
+		points := m.Gauge.DataPoints
+		switch m.Name {
+		case "speedtest.download.throughput":
+			if len(points) != 1 || points[0].Value != 100 || points[0].Time != "1700000000000000000" {
+				t.Errorf("download points %+v, want 100 Mbit/s at the start", points)
+			}
+		case "speedtest.latency.loaded":
+			phases := map[string]float64{}
+			for _, p := range points {
+				for _, a := range p.Attributes {
+					if a.Key == "phase" {
+						phases[*a.Value.String] = p.Value
+					}
+				}
+			}
+			if len(points) != 2 || phases["download"] != 40 || phases["upload"] != 80 {
+				t.Errorf("loaded latency by phase %v from %d points, want download 40 and upload 80", phases, len(points))
+			}
+		}
+	}
+}
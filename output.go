@@ -0,0 +1,445 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ofauchon/go-speedtest/speedtest"
+)
+
+func init() {
+	registerSink(func(f *testFlags) (Sink, error) {
+		if *f.jsonOutput == "" {
+			return nil, nil
+		}
+		return sinkFunc{"JSON file", func(ctx context.Context, res *speedtest.Result) error {
+			return appendFile(*f.jsonOutput, func(w io.Writer, header bool) error {
+				return printJSON(w, res)
+			})
+		}}, nil
+	})
+	registerSink(func(f *testFlags) (Sink, error) {
+		if *f.csvOutput == "" {
+			return nil, nil
+		}
+		return sinkFunc{"CSV file", func(ctx context.Context, res *speedtest.Result) error {
+			return appendFile(*f.csvOutput, func(w io.Writer, header bool) error {
+				return printCSV(w, res, header)
+			})
+		}}, nil
+	})
+}
+
+// Print the human readable summary
+func printSummary(w io.Writer, res *speedtest.Result, units speedtest.Units) {
+	if res.Partial {
+		end := res.End
+		if up := res.Upload; up != nil {
+			end = up.End
+		}
+		fmt.Fprintf(w, "Summary (PARTIAL, interrupted after %s):\n", end.Sub(res.Start).Round(time.Millisecond))
+	} else {
+		fmt.Fprintf(w, "Summary:\n")
+	}
+	if s := res.Server; s != nil {
+		fmt.Fprintf(w, "Server: %s\n", serverLabel(s))
+	}
+	if img := res.Image; img != nil {
+		fmt.Fprintf(w, "Image: %s/%s@%s\n", img.Registry, img.Repository, img.Digest)
+		if img.Platform != "" {
+			fmt.Fprintf(w, "Platform: %s\n", img.Platform)
+		}
+		fmt.Fprintf(w, "Layers: %d (auth %s / manifest %s)\n", len(img.Layers), img.Auth.Round(time.Millisecond), img.Manifest.Round(time.Millisecond))
+	}
+	if res.Agent != "" {
+		fmt.Fprintf(w, "Agent: %s\n", res.Agent)
+	}
+	if c := res.Client; c != nil {
+		fmt.Fprintf(w, "Client: %s\n", clientLabel(c))
+	}
+	if wi := res.WiFi; wi != nil {
+		fmt.Fprintf(w, "Wi-Fi: %s\n", wifiLabel(wi))
+	}
+	fmt.Fprintf(w, "File URL: %s\n", res.Target)
+	if res.FileSize > 0 {
+		if res.Partial && !res.DownloadSkipped {
+			fmt.Fprintf(w, "File Size: %d bytes (%.1f%% downloaded)\n", res.FileSize, float64(res.Bytes)*100/float64(res.FileSize))
+		} else {
+			fmt.Fprintf(w, "File Size: %d bytes\n", res.FileSize)
+		}
+	}
+	if e := res.Edge; e != nil {
+		printEdge(w, e)
+	}
+	if e := res.Eyeballs; e != nil {
+		printEyeballs(w, e)
+	}
+	if p := res.Path; p != nil {
+		printPath(w, p)
+	}
+	if !res.DownloadSkipped {
+		fmt.Fprintf(w, "Concurrent Downloads: %d\n", res.Concurrent)
+	}
+	if res.Bidirectional {
+		fmt.Fprintln(w, "Bidirectional: upload run during the download")
+	}
+	if f := res.Saved; f != nil {
+		state := "complete"
+		if !f.Complete {
+			state = "incomplete, run again to resume"
+		}
+		if f.Resumed > 0 {
+			state += fmt.Sprintf(", %d bytes resumed", f.Resumed)
+		}
+		fmt.Fprintf(w, "Saved to: %s (%s)\n", f.Path, state)
+	}
+	if res.Verified {
+		fmt.Fprintln(w, "SHA-256: verified")
+	}
+	if res.NoRange {
+		fmt.Fprintf(w, "Range requests unsupported: each connection fetches the whole file\n")
+	}
+	if lat := res.Latency; lat != nil {
+		fmt.Fprintf(w, "Latency (%s): min %s / avg %s / max %s / median %s\n", lat.Method, lat.Min, lat.Avg, lat.Max, lat.Median)
+		fmt.Fprintf(w, "Jitter: %s\n", lat.Jitter)
+		fmt.Fprintf(w, "Packet Loss: %.1f%% (%d/%d)\n", lat.Loss(), lat.Sent-lat.Received, lat.Sent)
+	}
+	if !res.DownloadSkipped {
+		fmt.Fprintf(w, "Downloaded: %d bytes\n", res.Bytes)
+		fmt.Fprintf(w, "Download Time: %s%s\n", res.Elapsed, stoppedBy(res.StoppedBy))
+		fmt.Fprintf(w, "Download Speed: %s\n", units.Rate(res.BytesPerSecond()))
+		printSpeed(w, res.Speed, units)
+		printVideo(w, res.Video())
+		printConns(w, "Connection", res.Conns, units)
+		printErrors(w, res.Errors)
+	}
+	if up := res.Upload; up != nil {
+		fmt.Fprintf(w, "Upload URL: %s\n", up.Target)
+		if up.Size > 0 {
+			fmt.Fprintf(w, "Upload Size: %d bytes\n", up.Size)
+		}
+		fmt.Fprintf(w, "Uploaded: %d bytes\n", up.Bytes)
+		fmt.Fprintf(w, "Upload Time: %s%s\n", up.Elapsed, stoppedBy(up.StoppedBy))
+		fmt.Fprintf(w, "Upload Speed: %s\n", units.Rate(up.BytesPerSecond()))
+		printSpeed(w, up.Speed, units)
+		printConns(w, "Upload connection", up.Conns, units)
+		printErrors(w, up.Errors)
+	}
+	if n := res.Counters; n != nil {
+		printCounters(w, n)
+	}
+	if r := res.Responsiveness; r != nil {
+		fmt.Fprintf(w, "Responsiveness: %.0f RPM (%d probes)\n", r.RPM(), r.Probes)
+	}
+	if b := res.Bufferbloat; b != nil {
+		fmt.Fprintf(w, "Bufferbloat: grade %s (+%s under load)\n", b.Grade(), b.Increase())
+		fmt.Fprintf(w, "Idle Latency: %s\n", b.Idle)
+		for _, l := range []struct {
+			name string
+			lat  *speedtest.LatencyResult
+		}{{"Download", b.Download}, {"Upload", b.Upload}, {"Bidirectional", b.Bidirectional}} {
+			if l.lat != nil && l.lat.Received > 0 {
+				fmt.Fprintf(w, "%s Latency: avg %s / max %s (%d probes)\n", l.name, l.lat.Avg, l.lat.Max, l.lat.Received)
+			}
+		}
+	}
+	if l := res.Cooldown; l != nil && l.Received > 0 {
+		fmt.Fprintf(w, "Cooldown Latency: avg %s / max %s (%d probes)\n", l.Avg, l.Max, l.Received)
+	}
+	if q := res.Quality; q != nil {
+		var parts []string
+		for _, c := range q.Components {
+			parts = append(parts, fmt.Sprintf("%s %.0f", c.Metric, c.Score))
+		}
+		fmt.Fprintf(w, "Connection Quality: %s (%.0f/100; %s)\n", q.Grade, q.Score, strings.Join(parts, ", "))
+	}
+}
+
+// Describe a server with whatever details the backend provided
+func serverLabel(s *speedtest.Server) string {
+	var details []string
+	for _, d := range []string{s.Name, s.Country} {
+		if d != "" && d != s.Host {
+			details = append(details, d)
+		}
+	}
+	label := s.Host
+	if s.Sponsor != "" {
+		label = s.Sponsor + " " + label
+	}
+	if len(details) > 0 {
+		label += " (" + strings.Join(details, ", ") + ")"
+	}
+	return label
+}
+
+// Describe a Wi-Fi link with its SSID, radio and rates on one line
+func wifiLabel(wi *speedtest.WiFiInfo) string {
+	var details []string
+	if wi.Band != "" || wi.Channel > 0 {
+		radio := strings.TrimSpace(fmt.Sprintf("%s channel %d", wi.Band, wi.Channel))
+		if wi.Width > 0 {
+			radio += fmt.Sprintf(" %dMHz", wi.Width)
+		}
+		details = append(details, radio)
+	}
+	if wi.PHY != "" {
+		details = append(details, wi.PHY)
+	}
+	if wi.RSSI != 0 {
+		signal := fmt.Sprintf("%d dBm", wi.RSSI)
+		if wi.Noise != 0 {
+			signal += fmt.Sprintf(" (SNR %d dB)", wi.RSSI-wi.Noise)
+		}
+		if wi.WeakSignal() {
+			signal += ", weak signal"
+		}
+		details = append(details, signal)
+	}
+	if wi.TxRate > 0 {
+		rate := fmt.Sprintf("tx %.0f", wi.TxRate)
+		if wi.RxRate > 0 {
+			rate += fmt.Sprintf(" / rx %.0f", wi.RxRate)
+		}
+		details = append(details, rate+" Mbit/s")
+	}
+	name := wi.SSID
+	if name == "" {
+		name = wi.Interface
+	}
+	if len(details) == 0 {
+		return name
+	}
+	return name + " (" + strings.Join(details, ", ") + ")"
+}
+
+// Describe the client network, e.g. "1.2.3.4 (AS3215 Orange, Paris, FR)"
+func clientLabel(c *speedtest.ClientInfo) string {
+	var details []string
+	if isp := strings.TrimSpace(c.ASN + " " + c.ISP); isp != "" {
+		details = append(details, isp)
+	}
+	for _, d := range []string{c.City, c.Country} {
+		if d != "" {
+			details = append(details, d)
+		}
+	}
+	if len(details) == 0 {
+		return c.IP
+	}
+	return c.IP + " (" + strings.Join(details, ", ") + ")"
+}
+
+// Print the server and CDN edge that answered
+func printEdge(w io.Writer, e *speedtest.EdgeInfo) {
+	if e.IP != "" {
+		ip := e.IP
+		if e.ReverseDNS != "" {
+			ip += " (" + e.ReverseDNS + ")"
+		}
+		fmt.Fprintf(w, "Server IP: %s\n", ip)
+	}
+	if len(e.Addrs) > 1 {
+		fmt.Fprintf(w, "%s resolves to: %s\n", e.Host, strings.Join(e.Addrs, ", "))
+	}
+	if e.CDN != "" {
+		cdn := e.CDN
+		if e.PoP != "" {
+			cdn += ", edge " + e.PoP
+		}
+		fmt.Fprintf(w, "CDN: %s\n", cdn)
+	}
+}
+
+// Print the bytes the interface counted next to the transferred ones
+func printCounters(w io.Writer, n *speedtest.NICCounters) {
+	share := func(overhead float64, what string) string {
+		if overhead == 0 {
+			return ""
+		}
+		return fmt.Sprintf(" (%+.1f%% over the %s bytes)", overhead*100, what)
+	}
+	rx, tx := "downloaded", "uploaded"
+	if n.Loopback {
+		rx, tx = "transferred", "transferred"
+	}
+	fmt.Fprintf(w, "Interface %s Received: %d bytes%s\n", n.Interface, n.RxBytes, share(n.RxOverhead(), rx))
+	fmt.Fprintf(w, "Interface %s Sent: %d bytes%s\n", n.Interface, n.TxBytes, share(n.TxOverhead(), tx))
+	if n.Polluted() {
+		fmt.Fprintln(w, "Warning: the interface carried more than the test and its headers, other traffic may have polluted the result")
+	}
+}
+
+// Print the Happy Eyeballs winner and the connect time of each address
+func printEyeballs(w io.Writer, e *speedtest.EyeballsResult) {
+	switch {
+	case e.Winner == "":
+		fmt.Fprintf(w, "Happy Eyeballs: no address of %s connected\n", e.Host)
+	case e.IPv6Lost():
+		fmt.Fprintf(w, "Happy Eyeballs: %s won (%s), IPv6 lost the race\n", e.Family, e.Winner)
+	default:
+		fmt.Fprintf(w, "Happy Eyeballs: %s won (%s)\n", e.Family, e.Winner)
+	}
+	for _, a := range e.Attempts {
+		if a.Err != "" {
+			fmt.Fprintf(w, "  %s %s (started +%s): failed: %s\n", a.Family, a.Addr, a.Delay, a.Err)
+		} else {
+			fmt.Fprintf(w, "  %s %s (started +%s): connected in %s\n", a.Family, a.Addr, a.Delay, a.Connect.Round(time.Microsecond))
+		}
+	}
+}
+
+// Print the distribution of the instantaneous speed
+func printSpeed(w io.Writer, s *speedtest.SpeedStats, units speedtest.Units) {
+	if s == nil {
+		return
+	}
+	v, unit := units.Scale(s.Min, s.P5, s.Median, s.Avg, s.P95, s.Max)
+	fmt.Fprintf(w, "Speed over %s samples: min %.2f / p5 %.2f / median %.2f / avg %.2f / p95 %.2f / max %.2f %s\n",
+		s.Interval, v[0], v[1], v[2], v[3], v[4], v[5], unit)
+}
+
+// Note appended to the phase time when the methodology ended the phase
+func stoppedBy(name string) string {
+	if name == "" {
+		return ""
+	}
+	return fmt.Sprintf(" (stopped by the %s methodology)", name)
+}
+
+// Print the best video tier and the streams of each tier the link carries
+func printVideo(w io.Writer, v *speedtest.VideoEstimate) {
+	if v == nil {
+		return
+	}
+	if v.Tier == "" {
+		fmt.Fprintln(w, "Video Streaming: below SD")
+		return
+	}
+	var streams []string
+	for i := len(v.Tiers) - 1; i >= 0; i-- {
+		if t := v.Tiers[i]; t.Streams > 0 {
+			streams = append(streams, fmt.Sprintf("%d %s", t.Streams, t.Name))
+		}
+	}
+	fmt.Fprintf(w, "Video Streaming: %s (up to %s streams)\n", v.Tier, strings.Join(streams, " / "))
+}
+
+// Print the statistics of each connection, flagging the slowest and the
+// fastest ones
+func printConns(w io.Writer, label string, conns []speedtest.ConnStats, units speedtest.Units) {
+	if len(conns) < 2 {
+		return
+	}
+	slowest, fastest := 0, 0
+	for i, c := range conns {
+		if c.BytesPerSecond() < conns[slowest].BytesPerSecond() {
+			slowest = i
+		}
+		if c.BytesPerSecond() > conns[fastest].BytesPerSecond() {
+			fastest = i
+		}
+	}
+	for i, c := range conns {
+		flag := ""
+		switch {
+		case slowest == fastest:
+		case i == slowest:
+			flag = " (slowest)"
+		case i == fastest:
+			flag = " (fastest)"
+		}
+		restarts := ""
+		if c.Restarts > 0 {
+			restarts = fmt.Sprintf(", %d restarts", c.Restarts)
+		}
+		if c.Retries > 0 {
+			restarts += fmt.Sprintf(", %d retries", c.Retries)
+		}
+		fmt.Fprintf(w, "%s %d: %d bytes, %s, %d stalls, %d errors%s%s\n", label, c.Part, c.Bytes,
+			units.Short(c.BytesPerSecond()), c.Stalls, c.Errors, restarts, flag)
+	}
+}
+
+// Print the kernel TCP statistics of each connection
+func printTCPInfo(w io.Writer, label string, conns []speedtest.ConnStats, units speedtest.Units) {
+	for _, c := range conns {
+		t := c.TCP
+		if t == nil {
+			continue
+		}
+		fmt.Fprintf(w, "%s %d TCP: rtt %s ± %s (min %s), %d retransmits (%d bytes), %d lost, cwnd %d x %d bytes, delivery rate %s\n",
+			label, c.Part, t.RTT, t.RTTVar, t.MinRTT, t.Retransmits, t.BytesRetrans, t.Lost, t.Cwnd, t.MSS, units.Short(t.DeliveryRate))
+	}
+}
+
+// Print the connection setup times of each part
+func printTimings(w io.Writer, timings []speedtest.ConnTiming) {
+	for _, t := range timings {
+		remote := ""
+		if t.Remote != "" {
+			remote = " (" + t.Remote + ")"
+		}
+		if t.Reused {
+			fmt.Fprintf(w, "Part %d%s: reused connection, TTFB %s\n", t.Part, remote, t.TTFB)
+			continue
+		}
+		fmt.Fprintf(w, "Part %d%s: DNS %s / Connect %s / TLS %s / TTFB %s\n", t.Part, remote, t.DNS, t.Connect, t.TLS, t.TTFB)
+	}
+}
+
+func printErrors(w io.Writer, errs []string) {
+	for _, e := range errs {
+		fmt.Fprintf(w, "Error: %s\n", e)
+	}
+}
+
+// Print a result as a single JSON document
+func printJSON(w io.Writer, res any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(res)
+}
+
+var csvHeader = []string{
+	"timestamp", "target", "duration_seconds", "download_bytes", "download_bytes_per_second",
+	"upload_bytes", "upload_bytes_per_second", "latency_ms", "jitter_ms", "loss_percent", "errors",
+}
+
+// Print the result as one CSV row, preceded by the header if asked
+func printCSV(w io.Writer, res *speedtest.Result, header bool) error {
+	float := func(f float64) string { return strconv.FormatFloat(f, 'f', 3, 64) }
+	ms := func(d time.Duration) string { return float(float64(d) / float64(time.Millisecond)) }
+
+	row := []string{
+		res.Start.Format(time.RFC3339), res.Target, float(res.Elapsed.Seconds()),
+		strconv.FormatInt(res.Bytes, 10), float(res.BytesPerSecond()),
+		"", "", "", "", "", "",
+	}
+	errs := len(res.Errors)
+	if up := res.Upload; up != nil {
+		row[5] = strconv.FormatInt(up.Bytes, 10)
+		row[6] = float(up.BytesPerSecond())
+		errs += len(up.Errors)
+	}
+	if lat := res.Latency; lat != nil {
+		row[7] = ms(lat.Avg)
+		row[8] = ms(lat.Jitter)
+		row[9] = float(lat.Loss())
+	}
+	row[10] = strconv.Itoa(errs)
+
+	cw := csv.NewWriter(w)
+	if header {
+		cw.Write(csvHeader)
+	}
+	cw.Write(row)
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ofauchon/go-speedtest/speedtest"
+)
+
+// Exit codes of the probe mode besides 0, exitThreshold and 1 for invalid
+// flags
+const (
+	exitProbeFailed  = 3
+	exitProbeTimeout = 4
+)
+
+// Defaults of the flags in probe mode, keeping a probe short and quiet.
+// The format only sends the logs to stderr, stdout gets the report.
+var probeDefaults = map[string]string{
+	"duration":      "10",
+	"dial-timeout":  "5",
+	"stall-timeout": "5",
+	"retries":       "1",
+	"pings":         "5",
+	"format":        "json",
+	"quiet":         "true",
+}
+
+// Outcome of a probe, printed as one JSON line on stdout
+type probeReport struct {
+	// ok, threshold, failed or timeout
+	Status      string            `json:"status"`
+	ExitCode    int               `json:"exit_code"`
+	Error       string            `json:"error,omitempty"`
+	Violations  []violation       `json:"violations,omitempty"`
+	Regressions []metricDelta     `json:"regressions,omitempty"`
+	Result      *speedtest.Result `json:"result,omitempty"`
+}
+
+// Run a test within -probe-timeout and check its result
+func (f *testFlags) runProbeTest(ctx context.Context, opts speedtest.Options, baseline *speedtest.Result, run func(context.Context, speedtest.Options) (*speedtest.Result, error)) probeReport {
+	tctx, cancel := context.WithTimeout(ctx, time.Duration(*f.probeTimeout)*time.Second)
+	defer cancel()
+	res, err := run(tctx, opts)
+	rep := probeReport{Result: res}
+	switch {
+	case errors.Is(tctx.Err(), context.DeadlineExceeded):
+		rep.Status, rep.ExitCode = "timeout", exitProbeTimeout
+		rep.Error = fmt.Sprintf("test not finished after %ds", *f.probeTimeout)
+	case err != nil:
+		rep.Status, rep.ExitCode, rep.Error = "failed", exitProbeFailed, err.Error()
+	case ctx.Err() != nil:
+		rep.Status, rep.ExitCode, rep.Error = "failed", exitProbeFailed, "interrupted"
+	default:
+		rep.Violations = checkThresholds(res, *f.minDownload, *f.minUpload, *f.maxLatency)
+		if baseline != nil {
+			rep.Regressions = checkBaseline(baseline, res, *f.baselineTolerance)
+		}
+		rep.Status = "ok"
+		if len(rep.Violations) > 0 || len(rep.Regressions) > 0 {
+			rep.Status, rep.ExitCode = "threshold", exitThreshold
+		}
+	}
+	return rep
+}
+
+// Run the probe once and exit with its code, or with -probe-listen every
+// -every seconds, serving the liveness and readiness endpoints
+func runProbe(ctx context.Context, f *testFlags, opts speedtest.Options, baseline *speedtest.Result, run func(context.Context, speedtest.Options) (*speedtest.Result, error)) {
+	enc := json.NewEncoder(os.Stdout)
+	if *f.probeListen == "" {
+		rep := f.runProbeTest(ctx, opts, baseline, run)
+		if err := enc.Encode(rep); err != nil {
+			fatal(err)
+		}
+		os.Exit(rep.ExitCode)
+	}
+
+	var mu sync.Mutex
+	var last *probeReport
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+	// Ready while the last probe passes, answering its report without the
+	// result
+	mux.HandleFunc("GET /readyz", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if last == nil {
+			apiError(w, http.StatusServiceUnavailable, "no probe finished yet")
+			return
+		}
+		rep := *last
+		rep.Result = nil
+		code := http.StatusOK
+		if rep.ExitCode != 0 {
+			code = http.StatusServiceUnavailable
+		}
+		apiJSON(w, code, rep)
+	})
+	ln, err := net.Listen("tcp", *f.probeListen)
+	if err != nil {
+		fatal(err)
+	}
+	go http.Serve(ln, mux)
+	slog.Info("serving probe endpoints", "url", fmt.Sprintf("http://%s/readyz", ln.Addr()))
+
+	sched := everySchedule(time.Duration(*f.every) * time.Second)
+	for {
+		rep := f.runProbeTest(ctx, opts, baseline, run)
+		if ctx.Err() != nil {
+			return
+		}
+		if err := enc.Encode(rep); err != nil {
+			slog.Error(err.Error())
+		}
+		mu.Lock()
+		last = &rep
+		mu.Unlock()
+
+		select {
+		case <-time.After(time.Until(sched.next(time.Now()))):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
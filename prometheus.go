@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ofauchon/go-speedtest/speedtest"
+)
+
+// Keeps the last result for Prometheus scrapes
+type exporter struct {
+	mu   sync.Mutex
+	last *speedtest.Result
+	runs int
+	fail int
+}
+
+func (e *exporter) record(res *speedtest.Result, err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.runs++
+	if err != nil {
+		e.fail++
+		return
+	}
+	e.last = res
+}
+
+func (e *exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	writeMetric(w, "speedtest_runs_total", "counter", "Number of tests run", "", float64(e.runs))
+	writeMetric(w, "speedtest_failures_total", "counter", "Number of tests that failed", "", float64(e.fail))
+	if e.last != nil {
+		writePrometheus(w, e.last)
+	}
+}
+
+// Write the metrics of a result in the Prometheus text format
+func writePrometheus(w io.Writer, res *speedtest.Result) {
+	labels := fmt.Sprintf("{target=%s}", strconv.Quote(res.Target))
+	writeMetric(w, "speedtest_download_bps", "gauge", "Download speed in bits per second", labels, res.BytesPerSecond()*8)
+	if up := res.Upload; up != nil {
+		writeMetric(w, "speedtest_upload_bps", "gauge", "Upload speed in bits per second", labels, up.BytesPerSecond()*8)
+	}
+	if lat := res.Latency; lat != nil {
+		writeMetric(w, "speedtest_latency_ms", "gauge", "Average idle latency in milliseconds", labels, float64(lat.Avg)/float64(time.Millisecond))
+		writeMetric(w, "speedtest_jitter_ms", "gauge", "Idle latency jitter in milliseconds", labels, float64(lat.Jitter)/float64(time.Millisecond))
+		writeMetric(w, "speedtest_loss_ratio", "gauge", "Ratio of lost latency probes", labels, lat.Loss()/100)
+	}
+	writeMetric(w, "speedtest_errors", "gauge", "Connection errors during the last test", labels, float64(len(res.Errors)))
+	writeMetric(w, "speedtest_last_run_timestamp", "gauge", "Start time of the last test in seconds since the epoch", labels, float64(res.Start.UnixNano())/1e9)
+}
+
+func writeMetric(w io.Writer, name, kind, help, labels string, value float64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s%s %g\n", name, help, name, kind, name, labels, value)
+}
+
+// Serve the metrics of an exporter on /metrics, and the other paths with
+// handler if set
+func startExporter(listen string, handler http.Handler) (*exporter, error) {
+	ln, err := net.Listen("tcp", listen)
+	if err != nil {
+		return nil, err
+	}
+	e := &exporter{}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", e)
+	if handler != nil {
+		mux.Handle("/", handler)
+	}
+	go http.Serve(ln, mux)
+	slog.Info("serving metrics", "url", fmt.Sprintf("http://%s/metrics", ln.Addr()))
+	return e, nil
+}
+
+// Run tests every period and expose the last result on /metrics until
+// ctx is cancelled
+func runExporter(ctx context.Context, listen string, period time.Duration, opts speedtest.Options, run func(context.Context, speedtest.Options) (*speedtest.Result, error)) error {
+	e, err := startExporter(listen, nil)
+	if err != nil {
+		return err
+	}
+	runScheduled(ctx, everySchedule(period), opts, run, func(res *speedtest.Result, err error) {
+		e.record(res, err)
+		if err != nil {
+			slog.Error("test failed", "err", err)
+		} else {
+			slog.Info("test done", "download_mbps", res.BytesPerSecond()*8/1e6)
+		}
+	})
+	return nil
+}
+
+func init() {
+	registerSink(func(f *testFlags) (Sink, error) {
+		if *f.pushgateway == "" {
+			return nil, nil
+		}
+		return sinkFunc{"Pushgateway", func(ctx context.Context, res *speedtest.Result) error {
+			return pushMetrics(ctx, *f.pushgateway, *f.pushgatewayJob, res)
+		}}, nil
+	})
+}
+
+// Push the metrics of a result to a Prometheus Pushgateway, replacing those
+// of the previous run of the job, for tests run by cron rather than scraped
+func pushMetrics(ctx context.Context, gateway, job string, res *speedtest.Result) error {
+	var body bytes.Buffer
+	writePrometheus(&body, res)
+	target := strings.TrimSuffix(gateway, "/") + "/metrics/job/" + url.PathEscape(job)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, target, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+	req.Header.Set("User-Agent", "go-speedtest")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("push failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("push failed: %s %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/ofauchon/go-speedtest/speedtest"
+)
+
+// Statistics of a metric across repeated runs
+type runAggregate struct {
+	Metric string  `json:"metric"`
+	Unit   string  `json:"unit"`
+	Runs   int     `json:"runs"`
+	Mean   float64 `json:"mean"`
+	StdDev float64 `json:"stddev"`
+	Best   float64 `json:"best"`
+	Worst  float64 `json:"worst"`
+}
+
+// Aggregate the metrics of the results, skipping those without values
+func aggregateRuns(results []*speedtest.Result) []runAggregate {
+	var aggs []runAggregate
+	for _, m := range resultMetrics {
+		st := summarize(m.values(results))
+		if st.N == 0 {
+			continue
+		}
+		a := runAggregate{Metric: m.name, Unit: m.unit, Runs: st.N, Mean: st.Avg, StdDev: st.StdDev, Best: st.Max, Worst: st.Min}
+		if m.lowerBetter {
+			a.Best, a.Worst = st.Min, st.Max
+		}
+		aggs = append(aggs, a)
+	}
+	return aggs
+}
+
+func printAggregates(w io.Writer, runs, failed int, aggs []runAggregate) {
+	fmt.Fprintf(w, "Runs: %d (%d failed)\n", runs, failed)
+	for _, a := range aggs {
+		fmt.Fprintf(w, "  %-9s mean %.2f / stddev %.2f / best %.2f / worst %.2f %s\n", a.Metric, a.Mean, a.StdDev, a.Best, a.Worst, a.Unit)
+	}
+}
+
+// Run the test count times, pausing between runs, print each result and
+// the statistics across runs
+func runRepeated(ctx context.Context, f *testFlags, console *os.File, opts speedtest.Options, baseline *speedtest.Result, run func(context.Context, speedtest.Options) (*speedtest.Result, error)) {
+	var results []*speedtest.Result
+	runs, failed := 0, 0
+	for i := 0; i < *f.count && ctx.Err() == nil; i++ {
+		if i > 0 {
+			select {
+			case <-time.After(time.Duration(*f.pause) * time.Second):
+			case <-ctx.Done():
+			}
+			if ctx.Err() != nil {
+				break
+			}
+		}
+		f.say(console, "Run %d/%d", i+1, *f.count)
+		runs++
+		res, err := run(ctx, opts)
+		if err != nil {
+			slog.Error("run failed", "run", i+1, "err", err)
+			failed++
+			continue
+		}
+		results = append(results, res)
+	}
+	if ctx.Err() != nil {
+		f.say(console, "\nInterrupt signal received. Stopping the test...")
+	}
+
+	aggs := aggregateRuns(results)
+	write := func(w io.Writer, header bool) error {
+		switch *f.format {
+		case "json":
+			return printJSON(w, struct {
+				Runs      []*speedtest.Result `json:"runs"`
+				Failed    int                 `json:"failed"`
+				Aggregate []runAggregate      `json:"aggregate"`
+			}{results, failed, aggs})
+		case "junit":
+			var suites []junitSuite
+			for _, res := range results {
+				suites = append(suites, f.junitSuite(res, baseline))
+			}
+			return printJUnit(w, suites...)
+		}
+		printAggregates(w, runs, failed, aggs)
+		return nil
+	}
+	// The aggregates of the other formats are not CSV nor Influx lines
+	var err error
+	switch *f.format {
+	case "json", "junit", "text":
+		err = f.writeOutput(write)
+	default:
+		err = write(console, false)
+	}
+	if err != nil {
+		fatal(err)
+	}
+
+	// An interrupted run is not worth a baseline nor a threshold failure
+	complete := slices.DeleteFunc(slices.Clone(results), func(res *speedtest.Result) bool { return res.Partial })
+	if len(complete) < len(results) {
+		f.say(console, "Partial result, the baseline and thresholds were not checked.")
+	}
+	f.checkResults(baseline, complete...)
+	if len(results) == 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/ofauchon/go-speedtest/history"
+	"github.com/ofauchon/go-speedtest/speedtest"
+)
+
+// Size of the report charts in pixels
+const (
+	chartWidth  = 860
+	chartHeight = 260
+	chartMargin = 50
+)
+
+// A line of a chart
+type chartSeries struct {
+	name   string
+	color  string
+	times  []time.Time
+	values []float64
+}
+
+// Row of the table of runs
+type reportRun struct {
+	Start    string
+	Target   string
+	Download string
+	Upload   string
+	Latency  string
+}
+
+// Statistics of a metric across the runs
+type reportStat struct {
+	Name  string
+	Unit  string
+	Stats summary
+}
+
+type reportData struct {
+	Generated  string
+	First      string
+	Last       string
+	Stats      []reportStat
+	Throughput template.HTML
+	Latency    template.HTML
+	Runs       []reportRun
+}
+
+var reportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Connection history</title>
+<style>
+body { font-family: sans-serif; margin: 2em auto; max-width: 900px; color: #222; }
+h1 { font-size: 1.6em; }
+h2 { font-size: 1.2em; margin-top: 2em; }
+table { border-collapse: collapse; width: 100%; font-size: 0.9em; }
+th, td { border-bottom: 1px solid #ddd; padding: 4px 8px; text-align: right; }
+th:first-child, td:first-child, th.text, td.text { text-align: left; }
+svg text { font-size: 11px; fill: #555; }
+.note { color: #777; font-size: 0.85em; }
+</style>
+</head>
+<body>
+<h1>Connection history</h1>
+{{if .Runs}}<p>{{len .Runs}} runs from {{.First}} to {{.Last}}.</p>
+<table>
+<tr><th></th><th>Runs</th><th>Min</th><th>Average</th><th>Max</th></tr>
+{{range .Stats}}<tr><td>{{.Name}} ({{.Unit}})</td><td>{{.Stats.N}}</td><td>{{printf "%.2f" .Stats.Min}}</td><td>{{printf "%.2f" .Stats.Avg}}</td><td>{{printf "%.2f" .Stats.Max}}</td></tr>
+{{end}}</table>
+<h2>Throughput over time</h2>
+{{.Throughput}}
+<h2>Latency distribution</h2>
+{{.Latency}}
+<h2>Runs</h2>
+<table>
+<tr><th>Start</th><th class="text">Target</th><th>Download (Mbit/sec)</th><th>Upload (Mbit/sec)</th><th>Latency (ms)</th></tr>
+{{range .Runs}}<tr><td>{{.Start}}</td><td class="text">{{.Target}}</td><td>{{.Download}}</td><td>{{.Upload}}</td><td>{{.Latency}}</td></tr>
+{{end}}</table>
+{{else}}<p>No runs recorded.</p>
+{{end}}<p class="note">Generated by go-speedtest on {{.Generated}}.</p>
+</body>
+</html>
+`))
+
+// Write the runs as a self-contained HTML report with SVG charts
+func printHTML(w io.Writer, entries []history.Entry) error {
+	results := make([]*speedtest.Result, len(entries))
+	for i, e := range entries {
+		results[i] = e.Result
+	}
+	data := reportData{Generated: time.Now().Format("2006-01-02 15:04")}
+	if len(results) > 0 {
+		data.First = results[0].Start.Format("2006-01-02 15:04")
+		data.Last = results[len(results)-1].Start.Format("2006-01-02 15:04")
+	}
+	for _, m := range resultMetrics {
+		if st := summarize(m.values(results)); st.N > 0 {
+			data.Stats = append(data.Stats, reportStat{Name: m.name, Unit: m.unit, Stats: st})
+		}
+	}
+
+	download := chartSeries{name: "Download", color: "#1f77b4"}
+	upload := chartSeries{name: "Upload", color: "#ff7f0e"}
+	var latencies []float64
+	for _, res := range results {
+		run := reportRun{Start: res.Start.Format("2006-01-02 15:04"), Target: res.Target}
+		if v, ok := downloadMetric.value(res); ok {
+			download.times = append(download.times, res.Start)
+			download.values = append(download.values, v)
+			run.Download = fmt.Sprintf("%.2f", v)
+		}
+		if v, ok := uploadMetric.value(res); ok {
+			upload.times = append(upload.times, res.Start)
+			upload.values = append(upload.values, v)
+			run.Upload = fmt.Sprintf("%.2f", v)
+		}
+		if v, ok := latencyMetric.value(res); ok {
+			latencies = append(latencies, v)
+			run.Latency = fmt.Sprintf("%.2f", v)
+		}
+		data.Runs = append(data.Runs, run)
+	}
+	data.Throughput = lineChart("Mbit/sec", download, upload)
+	data.Latency = histogram(latencies, 20, "ms")
+	return reportTemplate.Execute(w, data)
+}
+
+// Draw series of values over time as an SVG line chart
+func lineChart(unit string, series ...chartSeries) template.HTML {
+	var first, last time.Time
+	top := 0.0
+	for _, s := range series {
+		for i, t := range s.times {
+			if first.IsZero() || t.Before(first) {
+				first = t
+			}
+			if t.After(last) {
+				last = t
+			}
+			top = max(top, s.values[i])
+		}
+	}
+	if top == 0 {
+		return `<p class="note">No throughput measured.</p>`
+	}
+	top = niceCeil(top)
+	span := last.Sub(first).Seconds()
+	x := func(t time.Time) float64 {
+		if span == 0 {
+			return chartMargin + float64(chartWidth-2*chartMargin)/2
+		}
+		return chartMargin + t.Sub(first).Seconds()/span*float64(chartWidth-2*chartMargin)
+	}
+	y := func(v float64) float64 {
+		return chartY(v, top)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`, chartWidth, chartHeight, chartWidth, chartHeight)
+	chartAxes(&b, top, unit, y)
+	fmt.Fprintf(&b, `<text x="%d" y="%d">%s</text>`, chartMargin, chartHeight-8, first.Format("2006-01-02 15:04"))
+	fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="end">%s</text>`, chartWidth-chartMargin, chartHeight-8, last.Format("2006-01-02 15:04"))
+	for i, s := range series {
+		if len(s.values) == 0 {
+			continue
+		}
+		var points []string
+		for j, t := range s.times {
+			points = append(points, fmt.Sprintf("%.1f,%.1f", x(t), y(s.values[j])))
+		}
+		fmt.Fprintf(&b, `<polyline fill="none" stroke="%s" stroke-width="2" points="%s"/>`, s.color, strings.Join(points, " "))
+		for j, t := range s.times {
+			fmt.Fprintf(&b, `<circle cx="%.1f" cy="%.1f" r="2.5" fill="%s"><title>%s: %.2f %s</title></circle>`,
+				x(t), y(s.values[j]), s.color, t.Format("2006-01-02 15:04"), s.values[j], html.EscapeString(unit))
+		}
+		// Legend
+		lx := chartWidth - chartMargin - 180 + i*90
+		fmt.Fprintf(&b, `<rect x="%d" y="6" width="12" height="12" fill="%s"/><text x="%d" y="16">%s</text>`, lx, s.color, lx+16, html.EscapeString(s.name))
+	}
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
+
+// Draw the distribution of values as an SVG histogram
+func histogram(values []float64, bins int, unit string) template.HTML {
+	if len(values) == 0 {
+		return `<p class="note">No latency measured.</p>`
+	}
+	st := summarize(values)
+	width := (st.Max - st.Min) / float64(bins)
+	if width == 0 {
+		width = 1
+	}
+	counts := make([]int, bins)
+	for _, v := range values {
+		counts[min(int((v-st.Min)/width), bins-1)]++
+	}
+	top := 0
+	for _, c := range counts {
+		top = max(top, c)
+	}
+
+	y := func(v float64) float64 {
+		return chartY(v, float64(top))
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`, chartWidth, chartHeight, chartWidth, chartHeight)
+	chartAxes(&b, float64(top), "runs", y)
+	barWidth := float64(chartWidth-2*chartMargin) / float64(bins)
+	for i, c := range counts {
+		if c == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="#2ca02c"><title>%.1f-%.1f %s: %d</title></rect>`,
+			chartMargin+float64(i)*barWidth+1, y(float64(c)), barWidth-2, y(0)-y(float64(c)),
+			st.Min+float64(i)*width, st.Min+float64(i+1)*width, unit, c)
+	}
+	fmt.Fprintf(&b, `<text x="%d" y="%d">%.1f %s</text>`, chartMargin, chartHeight-8, st.Min, unit)
+	fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="end">%.1f %s</text>`, chartWidth-chartMargin, chartHeight-8, st.Max, unit)
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
+
+// Vertical position of v in a chart going from 0 to top
+func chartY(v, top float64) float64 {
+	return chartHeight - chartMargin - v/top*float64(chartHeight-2*chartMargin)
+}
+
+// Draw the horizontal grid lines of a chart going from 0 to top
+func chartAxes(b *strings.Builder, top float64, unit string, y func(float64) float64) {
+	for i := 0; i <= 4; i++ {
+		v := top * float64(i) / 4
+		fmt.Fprintf(b, `<line x1="%d" x2="%d" y1="%.1f" y2="%.1f" stroke="#ddd"/>`, chartMargin, chartWidth-chartMargin, y(v), y(v))
+		fmt.Fprintf(b, `<text x="%d" y="%.1f" text-anchor="end">%g</text>`, chartMargin-6, y(v)+4, math.Round(v*100)/100)
+	}
+	fmt.Fprintf(b, `<text x="%d" y="16">%s</text>`, chartMargin, html.EscapeString(unit))
+}
+
+// Round v up to 1, 2 or 5 times a power of ten
+func niceCeil(v float64) float64 {
+	p := math.Pow(10, math.Floor(math.Log10(v)))
+	for _, m := range []float64{1, 2, 5, 10} {
+		if v <= m*p {
+			return m * p
+		}
+	}
+	return 10 * p
+}
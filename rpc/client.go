@@ -0,0 +1,72 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// Client calls the SpeedTest service of a go-speedtest server
+type Client struct {
+	SpeedTestClient
+	conn *grpc.ClientConn
+}
+
+// Dial returns a client of the server at target (host:port), connecting
+// without TLS and sending token as bearer token if set
+func Dial(target, token string, opts ...grpc.DialOption) (*Client, error) {
+	opts = append([]grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}, opts...)
+	if token != "" {
+		opts = append(opts, grpc.WithPerRPCCredentials(bearerToken(token)))
+	}
+	conn, err := grpc.NewClient(target, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{SpeedTestClient: NewSpeedTestClient(conn), conn: conn}, nil
+}
+
+// Close closes the connection to the server
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Run starts a test and waits for its end, calling progress with each
+// update, and returns the finished test
+func (c *Client) Run(ctx context.Context, req *StartTestRequest, progress func(*Progress)) (*Test, error) {
+	t, err := c.StartTest(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	stream, err := c.StreamProgress(ctx, &StreamProgressRequest{Id: t.Id})
+	if err != nil {
+		return nil, err
+	}
+	for {
+		p, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if progress != nil {
+			progress(p)
+		}
+	}
+	return c.GetResult(ctx, &GetResultRequest{Id: t.Id})
+}
+
+// Token sent in the authorization metadata of every call
+type bearerToken string
+
+func (t bearerToken) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + string(t)}, nil
+}
+
+func (t bearerToken) RequireTransportSecurity() bool {
+	return false
+}
@@ -0,0 +1,304 @@
+// Package rpc is the gRPC service starting speed tests on a remote
+// go-speedtest and streaming their progress, with its server and client.
+package rpc
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative speedtest.proto
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/ofauchon/go-speedtest/speedtest"
+)
+
+// Tests kept in memory by the server, older ones are dropped
+const keptTests = 100
+
+// Bounds of the parameters of StartTest, so that a caller can't start
+// unbounded connections or tie the server up for days
+const (
+	maxConcurrent = 64
+	maxDuration   = time.Hour
+)
+
+// RunFunc runs a test, like (*speedtest.Client).Run
+type RunFunc func(context.Context, speedtest.Options) (*speedtest.Result, error)
+
+// Server implements the SpeedTest service, running one test at a time
+type Server struct {
+	UnimplementedSpeedTestServer
+
+	ctx  context.Context
+	opts speedtest.Options
+	run  RunFunc
+
+	mu      sync.Mutex
+	tests   []*test
+	current *test
+	nextID  int64
+}
+
+// Test started by the server, read and written under its lock
+type test struct {
+	*Test
+	progress speedtest.Snapshot
+	// Closed and replaced on each change, waking up the streams
+	changed chan struct{}
+}
+
+// NewServer returns a server running the tests with run and opts, the
+// requests overriding the target, upload and concurrency. The tests are
+// cancelled with ctx.
+func NewServer(ctx context.Context, opts speedtest.Options, run RunFunc) *Server {
+	return &Server{ctx: ctx, opts: opts, run: run}
+}
+
+func (t *test) notify() {
+	close(t.changed)
+	t.changed = make(chan struct{})
+}
+
+func (t *test) message() *Test {
+	return proto.Clone(t.Test).(*Test)
+}
+
+func (t *test) progressMessage() *Progress {
+	p := t.progress
+	msg := &Progress{
+		Id:      t.Id,
+		State:   t.State,
+		Phase:   p.Phase,
+		Elapsed: durationpb.New(p.Elapsed),
+		Bytes:   p.Bytes,
+		Parts:   append([]int64(nil), p.Parts...),
+		Size:    p.Size,
+		RateBps: p.Rate * 8,
+		AvgBps:  p.Avg * 8,
+	}
+	if p.Duration > 0 {
+		msg.Duration = durationpb.New(p.Duration)
+	}
+	if p.Latency > 0 {
+		msg.Latency = durationpb.New(p.Latency)
+	}
+	return msg
+}
+
+func (s *Server) StartTest(ctx context.Context, req *StartTestRequest) (*Test, error) {
+	opts := s.opts
+	if req.Target != "" {
+		opts.Target = req.Target
+	}
+	if req.UploadTarget != "" {
+		opts.UploadTarget = req.UploadTarget
+	}
+	if req.Upload != nil {
+		opts.Upload = *req.Upload
+	}
+	if req.Duration != nil {
+		d := req.Duration.AsDuration()
+		if d <= 0 || d > maxDuration {
+			return nil, status.Errorf(codes.InvalidArgument, "duration must be positive and at most %s", maxDuration)
+		}
+		opts.Duration = d
+	}
+	// Without a duration a sized target would be transferred for as long
+	// as it takes
+	if opts.Duration <= 0 || opts.Duration > maxDuration {
+		opts.Duration = maxDuration
+	}
+	// 0 keeps the default
+	if req.Concurrent < 0 || req.Concurrent > maxConcurrent {
+		return nil, status.Errorf(codes.InvalidArgument, "concurrent must be between 1 and %d", maxConcurrent)
+	}
+	if req.Concurrent > 0 {
+		opts.Concurrent = int(req.Concurrent)
+	}
+	if opts.Target == "" {
+		return nil, status.Error(codes.InvalidArgument, "target is required")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.current != nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "test %d is already running", s.current.Id)
+	}
+	s.nextID++
+	t := &test{
+		Test:    &Test{Id: s.nextID, Target: opts.Target, State: State_STATE_RUNNING, Started: timestamppb.Now()},
+		changed: make(chan struct{}),
+	}
+	s.current = t
+	s.tests = append(s.tests, t)
+	if len(s.tests) > keptTests {
+		s.tests = s.tests[1:]
+	}
+
+	next := opts.OnProgress
+	opts.OnProgress = func(p speedtest.Snapshot) {
+		if next != nil {
+			next(p)
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		t.progress = p
+		t.notify()
+	}
+	go s.runTest(t, opts)
+	return t.message(), nil
+}
+
+func (s *Server) runTest(t *test, opts speedtest.Options) {
+	slog.Info("test started", "id", t.Id, "target", opts.Target)
+	ctx, cancel := context.WithCancel(s.ctx)
+	defer cancel()
+	res, err := s.run(ctx, opts)
+	cancelled := ctx.Err() != nil
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t.Finished = timestamppb.Now()
+	switch {
+	case err != nil:
+		t.State, t.Error = State_STATE_FAILED, err.Error()
+	case cancelled:
+		t.State = State_STATE_CANCELLED
+	default:
+		t.State = State_STATE_DONE
+	}
+	if res != nil {
+		if t.Result, err = resultMessage(res); err != nil {
+			slog.Warn("result not encoded", "id", t.Id, "err", err)
+		}
+	}
+	s.current = nil
+	t.notify()
+	slog.Info("test finished", "id", t.Id, "state", t.State)
+}
+
+// Find a test by id, the last one started for 0
+func (s *Server) lookup(id int64) (*test, error) {
+	if id == 0 && len(s.tests) > 0 {
+		return s.tests[len(s.tests)-1], nil
+	}
+	for _, t := range s.tests {
+		if t.Id == id {
+			return t, nil
+		}
+	}
+	return nil, status.Errorf(codes.NotFound, "no test %d", id)
+}
+
+func (s *Server) StreamProgress(req *StreamProgressRequest, stream grpc.ServerStreamingServer[Progress]) error {
+	s.mu.Lock()
+	t, err := s.lookup(req.Id)
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	for {
+		s.mu.Lock()
+		msg, changed := t.progressMessage(), t.changed
+		s.mu.Unlock()
+		if err := stream.Send(msg); err != nil {
+			return err
+		}
+		if msg.State != State_STATE_RUNNING {
+			return nil
+		}
+		select {
+		case <-changed:
+		case <-stream.Context().Done():
+			return status.FromContextError(stream.Context().Err()).Err()
+		}
+	}
+}
+
+func (s *Server) GetResult(ctx context.Context, req *GetResultRequest) (*Test, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, err := s.lookup(req.Id)
+	if err != nil {
+		return nil, err
+	}
+	return t.message(), nil
+}
+
+// Convert a result to its message, with the whole result as JSON
+func resultMessage(res *speedtest.Result) (*Result, error) {
+	data, err := json.Marshal(res)
+	if err != nil {
+		return nil, err
+	}
+	msg := &Result{
+		Target:      res.Target,
+		Start:       timestamppb.New(res.Start),
+		Elapsed:     durationpb.New(res.Elapsed),
+		Bytes:       res.Bytes,
+		DownloadBps: res.BytesPerSecond() * 8,
+		Errors:      res.Errors,
+		Json:        string(data),
+	}
+	if up := res.Upload; up != nil {
+		bps := up.BytesPerSecond() * 8
+		msg.UploadBps = &bps
+	}
+	if lat := res.Latency; lat != nil && lat.Received > 0 {
+		msg.Latency = durationpb.New(lat.Avg)
+		msg.Jitter = durationpb.New(lat.Jitter)
+		msg.LossPercent = lat.Loss()
+	}
+	return msg, nil
+}
+
+// Decode returns the result carried as JSON by the message
+func (r *Result) Decode() (*speedtest.Result, error) {
+	var res speedtest.Result
+	if err := json.Unmarshal([]byte(r.Json), &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// Authenticate returns the server options requiring token as bearer token
+// in the authorization metadata of every call
+func Authenticate(token string) []grpc.ServerOption {
+	check := func(ctx context.Context) error {
+		md, _ := metadata.FromIncomingContext(ctx)
+		var got string
+		if v := md.Get("authorization"); len(v) > 0 {
+			got, _ = strings.CutPrefix(v[0], "Bearer ")
+		}
+		if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			return status.Error(codes.Unauthenticated, "invalid or missing token")
+		}
+		return nil
+	}
+	return []grpc.ServerOption{
+		grpc.UnaryInterceptor(func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			if err := check(ctx); err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if err := check(ss.Context()); err != nil {
+				return err
+			}
+			return handler(srv, ss)
+		}),
+	}
+}
@@ -0,0 +1,33 @@
+package rpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/ofauchon/go-speedtest/speedtest"
+)
+
+func TestStartTestValidation(t *testing.T) {
+	s := NewServer(context.Background(), speedtest.Options{Target: "http://example.com/file"},
+		func(ctx context.Context, opts speedtest.Options) (*speedtest.Result, error) {
+			t.Error("invalid test run")
+			return nil, nil
+		})
+	for _, req := range []*StartTestRequest{
+		{Concurrent: -1},
+		{Concurrent: 1000000},
+		{Duration: durationpb.New(-time.Second)},
+		{Duration: durationpb.New(0)},
+		{Duration: durationpb.New(48 * time.Hour)},
+	} {
+		_, err := s.StartTest(context.Background(), req)
+		if status.Code(err) != codes.InvalidArgument {
+			t.Errorf("%v: got %v, want InvalidArgument", req, err)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+// Service controlling the speed tests of a go-speedtest server, started
+// with "go-speedtest grpc".
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v5.28.3
+// source: speedtest.proto
+
+package rpc
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	SpeedTest_StartTest_FullMethodName      = "/gospeedtest.v1.SpeedTest/StartTest"
+	SpeedTest_StreamProgress_FullMethodName = "/gospeedtest.v1.SpeedTest/StreamProgress"
+	SpeedTest_GetResult_FullMethodName      = "/gospeedtest.v1.SpeedTest/GetResult"
+)
+
+// SpeedTestClient is the client API for SpeedTest service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type SpeedTestClient interface {
+	// Start a test with the flags of the server, overridden by the request.
+	// Fails with FAILED_PRECONDITION while another test is running.
+	StartTest(ctx context.Context, in *StartTestRequest, opts ...grpc.CallOption) (*Test, error)
+	// Stream the progress of a test twice a second until it finishes, the
+	// last message carrying its final state.
+	StreamProgress(ctx context.Context, in *StreamProgressRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Progress], error)
+	// Return a test, with its result once finished.
+	GetResult(ctx context.Context, in *GetResultRequest, opts ...grpc.CallOption) (*Test, error)
+}
+
+type speedTestClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewSpeedTestClient(cc grpc.ClientConnInterface) SpeedTestClient {
+	return &speedTestClient{cc}
+}
+
+func (c *speedTestClient) StartTest(ctx context.Context, in *StartTestRequest, opts ...grpc.CallOption) (*Test, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Test)
+	err := c.cc.Invoke(ctx, SpeedTest_StartTest_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *speedTestClient) StreamProgress(ctx context.Context, in *StreamProgressRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Progress], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &SpeedTest_ServiceDesc.Streams[0], SpeedTest_StreamProgress_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StreamProgressRequest, Progress]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type SpeedTest_StreamProgressClient = grpc.ServerStreamingClient[Progress]
+
+func (c *speedTestClient) GetResult(ctx context.Context, in *GetResultRequest, opts ...grpc.CallOption) (*Test, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Test)
+	err := c.cc.Invoke(ctx, SpeedTest_GetResult_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SpeedTestServer is the server API for SpeedTest service.
+// All implementations must embed UnimplementedSpeedTestServer
+// for forward compatibility.
+type SpeedTestServer interface {
+	// Start a test with the flags of the server, overridden by the request.
+	// Fails with FAILED_PRECONDITION while another test is running.
+	StartTest(context.Context, *StartTestRequest) (*Test, error)
+	// Stream the progress of a test twice a second until it finishes, the
+	// last message carrying its final state.
+	StreamProgress(*StreamProgressRequest, grpc.ServerStreamingServer[Progress]) error
+	// Return a test, with its result once finished.
+	GetResult(context.Context, *GetResultRequest) (*Test, error)
+	mustEmbedUnimplementedSpeedTestServer()
+}
+
+// UnimplementedSpeedTestServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedSpeedTestServer struct{}
+
+func (UnimplementedSpeedTestServer) StartTest(context.Context, *StartTestRequest) (*Test, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StartTest not implemented")
+}
+func (UnimplementedSpeedTestServer) StreamProgress(*StreamProgressRequest, grpc.ServerStreamingServer[Progress]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamProgress not implemented")
+}
+func (UnimplementedSpeedTestServer) GetResult(context.Context, *GetResultRequest) (*Test, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetResult not implemented")
+}
+func (UnimplementedSpeedTestServer) mustEmbedUnimplementedSpeedTestServer() {}
+func (UnimplementedSpeedTestServer) testEmbeddedByValue()                   {}
+
+// UnsafeSpeedTestServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to SpeedTestServer will
+// result in compilation errors.
+type UnsafeSpeedTestServer interface {
+	mustEmbedUnimplementedSpeedTestServer()
+}
+
+func RegisterSpeedTestServer(s grpc.ServiceRegistrar, srv SpeedTestServer) {
+	// If the following call pancis, it indicates UnimplementedSpeedTestServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&SpeedTest_ServiceDesc, srv)
+}
+
+func _SpeedTest_StartTest_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StartTestRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SpeedTestServer).StartTest(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SpeedTest_StartTest_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SpeedTestServer).StartTest(ctx, req.(*StartTestRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SpeedTest_StreamProgress_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamProgressRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SpeedTestServer).StreamProgress(m, &grpc.GenericServerStream[StreamProgressRequest, Progress]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type SpeedTest_StreamProgressServer = grpc.ServerStreamingServer[Progress]
+
+func _SpeedTest_GetResult_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetResultRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SpeedTestServer).GetResult(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SpeedTest_GetResult_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SpeedTestServer).GetResult(ctx, req.(*GetResultRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// SpeedTest_ServiceDesc is the grpc.ServiceDesc for SpeedTest service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var SpeedTest_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "gospeedtest.v1.SpeedTest",
+	HandlerType: (*SpeedTestServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "StartTest",
+			Handler:    _SpeedTest_StartTest_Handler,
+		},
+		{
+			MethodName: "GetResult",
+			Handler:    _SpeedTest_GetResult_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamProgress",
+			Handler:       _SpeedTest_StreamProgress_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "speedtest.proto",
+}
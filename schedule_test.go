@@ -0,0 +1,101 @@
+package main
+
+import (
+	"slices"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestParseCronField(t *testing.T) {
+	tests := []struct {
+		field    string
+		min, max int
+		want     []int
+	}{
+		{"*", 0, 6, []int{0, 1, 2, 3, 4, 5, 6}},
+		{"5", 0, 59, []int{5}},
+		{"1,15", 1, 31, []int{1, 15}},
+		{"9-17", 0, 23, []int{9, 10, 11, 12, 13, 14, 15, 16, 17}},
+		{"*/15", 0, 59, []int{0, 15, 30, 45}},
+		{"10-20/5", 0, 59, []int{10, 15, 20}},
+		{"50/5", 0, 59, []int{50, 55}},
+		{"1-5,0", 0, 6, []int{0, 1, 2, 3, 4, 5}},
+	}
+	for _, tt := range tests {
+		set, err := parseCronField(tt.field, tt.min, tt.max)
+		if err != nil {
+			t.Errorf("parseCronField(%q): %v", tt.field, err)
+			continue
+		}
+		var got []int
+		for v := range set {
+			got = append(got, v)
+		}
+		sort.Ints(got)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("parseCronField(%q) = %v, want %v", tt.field, got, tt.want)
+		}
+	}
+}
+
+func TestParseCronErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 7",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"1-b * * * *",
+	} {
+		if _, err := parseCron(expr); err == nil {
+			t.Errorf("parseCron(%q) succeeded, want an error", expr)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	at := func(s string) time.Time {
+		v, err := time.Parse("2006-01-02 15:04", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	tests := []struct {
+		expr, from string
+		want       []string
+	}{
+		{"*/15 * * * *", "2024-03-10 10:07", []string{"2024-03-10 10:15", "2024-03-10 10:30"}},
+		{"0 9-17 * * 1-5", "2024-03-08 17:30", []string{"2024-03-11 09:00", "2024-03-11 10:00"}},
+		{"30 2 * * *", "2024-12-31 03:00", []string{"2025-01-01 02:30"}},
+		{"0 0 29 2 *", "2024-03-01 00:00", []string{"2028-02-29 00:00"}},
+		// Both day fields restricted: the 1st of the month or a Monday
+		{"0 0 1 * 1", "2024-04-28 12:00", []string{"2024-04-29 00:00", "2024-05-01 00:00", "2024-05-06 00:00"}},
+		// One of them is *: both must match
+		{"0 0 1 * *", "2024-04-28 12:00", []string{"2024-05-01 00:00", "2024-06-01 00:00"}},
+		{"0 0 * * 1", "2024-04-28 12:00", []string{"2024-04-29 00:00", "2024-05-06 00:00"}},
+		// A field starting with * counts as *, as in cron
+		{"0 0 */10 * 1", "2024-04-28 12:00", []string{"2024-07-01 00:00", "2024-10-21 00:00"}},
+	}
+	for _, tt := range tests {
+		c, err := parseCron(tt.expr)
+		if err != nil {
+			t.Errorf("parseCron(%q): %v", tt.expr, err)
+			continue
+		}
+		next := at(tt.from)
+		for _, w := range tt.want {
+			if next = c.next(next); !next.Equal(at(w)) {
+				t.Errorf("%q: next run %s, want %s", tt.expr, next.Format("2006-01-02 15:04"), w)
+				break
+			}
+		}
+	}
+}
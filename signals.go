@@ -0,0 +1,69 @@
+//go:build unix
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/ofauchon/go-speedtest/speedtest"
+)
+
+// Handle the signals of long running tests, like network daemons do:
+// SIGUSR1 prints the progress of the running phase on the console without
+// stopping it, SIGUSR2 rotates the -output file
+func (f *testFlags) handleSignals(ctx context.Context, console *os.File, opts *speedtest.Options) {
+	var mu sync.Mutex
+	var last *speedtest.Snapshot
+	next := opts.OnProgress
+	opts.OnProgress = func(p speedtest.Snapshot) {
+		mu.Lock()
+		last = &p
+		mu.Unlock()
+		if next != nil {
+			next(p)
+		}
+	}
+
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGUSR1, syscall.SIGUSR2)
+	go func() {
+		defer signal.Stop(ch)
+		for {
+			select {
+			case sig := <-ch:
+				if sig == syscall.SIGUSR2 {
+					f.rotateOutput()
+					continue
+				}
+				mu.Lock()
+				p := last
+				mu.Unlock()
+				printInterim(console, p, f.rateUnits())
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Print the latest progress snapshot of a phase
+func printInterim(w *os.File, p *speedtest.Snapshot, units speedtest.Units) {
+	if p == nil {
+		fmt.Fprintln(w, "Interim statistics: no transfer started yet")
+		return
+	}
+	fmt.Fprintf(w, "Interim statistics: %s %s, %d bytes, %s now, %s on average", p.Phase, p.Elapsed.Round(time.Second/10), p.Bytes, units.Rate(p.Rate), units.Rate(p.Avg))
+	if p.Latency > 0 {
+		fmt.Fprintf(w, ", latency under load %s", p.Latency)
+	}
+	fmt.Fprintln(w)
+	for i, n := range p.Parts {
+		fmt.Fprintf(w, "  Connection %d: %d bytes\n", i, n)
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !unix
+
+package main
+
+import (
+	"context"
+	"os"
+
+	"github.com/ofauchon/go-speedtest/speedtest"
+)
+
+// SIGUSR1 and SIGUSR2 don't exist on this system
+func (f *testFlags) handleSignals(ctx context.Context, console *os.File, opts *speedtest.Options) {}
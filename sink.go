@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/ofauchon/go-speedtest/speedtest"
+)
+
+// Sink receives the result of every run: the console, a file, a database,
+// a metrics system, a message broker...
+type Sink interface {
+	// Name shown in the warnings, e.g. InfluxDB
+	Name() string
+
+	// Send the result, failures being logged without failing the run
+	Send(ctx context.Context, res *speedtest.Result) error
+}
+
+// Returns the sink the flags enable, nil if they don't
+type sinkFactory func(f *testFlags) (Sink, error)
+
+// Factories of the sinks each integration registers in its init function,
+// in the order the sinks get the results
+var sinkFactories []sinkFactory
+
+// Make a kind of sink available to every command running tests. Adding an
+// integration is a matter of registering its factory and adding its flags
+// to testFlags.
+func registerSink(factory sinkFactory) {
+	sinkFactories = append(sinkFactories, factory)
+}
+
+// Sink calling a function
+type sinkFunc struct {
+	name string
+	send func(context.Context, *speedtest.Result) error
+}
+
+func (s sinkFunc) Name() string { return s.name }
+
+func (s sinkFunc) Send(ctx context.Context, res *speedtest.Result) error { return s.send(ctx, res) }
+
+// Return the sinks the flags enable. Exits on invalid flags.
+func (f *testFlags) sinks() []Sink {
+	var sinks []Sink
+	for _, factory := range sinkFactories {
+		s, err := factory(f)
+		if err != nil {
+			fatal(err)
+		}
+		if s != nil {
+			sinks = append(sinks, s)
+		}
+	}
+	return sinks
+}
+
+// Return the function sending a result to the configured sinks, logging
+// their failures
+func (f *testFlags) publisher() func(context.Context, *speedtest.Result) {
+	sinks := f.sinks()
+	return func(ctx context.Context, res *speedtest.Result) {
+		// Publish results of interrupted tests too
+		pctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+		defer cancel()
+		for _, s := range sinks {
+			if err := s.Send(pctx, res); err != nil {
+				slog.Warn("result not sent", "sink", s.Name(), "err", err)
+			}
+		}
+	}
+}
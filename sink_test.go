@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ofauchon/go-speedtest/speedtest"
+)
+
+// The file sinks run together, each appending every result
+func TestFileSinks(t *testing.T) {
+	dir := t.TempDir()
+	jsonPath, csvPath := filepath.Join(dir, "results.json"), filepath.Join(dir, "results.csv")
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	f := addTestFlags(fs, allFlags)
+	if err := fs.Parse([]string{"-json-output", jsonPath, "-csv-output", csvPath}); err != nil {
+		t.Fatal(err)
+	}
+
+	publish := f.publisher()
+	for i := range 2 {
+		publish(context.Background(), &speedtest.Result{Target: "http://example.com/file", Start: time.Unix(int64(i), 0), Bytes: 1000, Elapsed: time.Second})
+	}
+
+	data, err := os.ReadFile(csvPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := bytes.Count(data, []byte("\n")); lines != 3 {
+		t.Errorf("CSV file of %d lines, want the header and 2 rows:\n%s", lines, data)
+	}
+	data, err = os.ReadFile(jsonPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	n := 0
+	for dec.More() {
+		var res speedtest.Result
+		if err := dec.Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		n++
+	}
+	if n != 2 {
+		t.Errorf("JSON file of %d results, want 2", n)
+	}
+}
@@ -0,0 +1,24 @@
+package speedtest
+
+import (
+	"net"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// Bind the sockets of d to the interface with SO_BINDTODEVICE, whatever
+// the routing table says
+func bindInterface(d *net.Dialer, name string) error {
+	if _, err := net.InterfaceByName(name); err != nil {
+		return err
+	}
+	d.Control = func(_, _ string, c syscall.RawConn) error {
+		var serr error
+		if err := c.Control(func(fd uintptr) { serr = unix.BindToDevice(int(fd), name) }); err != nil {
+			return err
+		}
+		return serr
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+//go:build !linux
+
+package speedtest
+
+import (
+	"fmt"
+	"net"
+)
+
+// Open the TCP connections of d from the first IPv4 address of the
+// interface, the systems without SO_BINDTODEVICE routing by source
+// address
+func bindInterface(d *net.Dialer, name string) error {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return err
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return err
+	}
+	for _, a := range addrs {
+		if n, ok := a.(*net.IPNet); ok && n.IP.To4() != nil {
+			d.LocalAddr = &net.TCPAddr{IP: n.IP}
+			return nil
+		}
+	}
+	return fmt.Errorf("interface %s has no IPv4 address", name)
+}
@@ -0,0 +1,66 @@
+package speedtest
+
+import (
+	"io"
+	"sync"
+
+	"github.com/ofauchon/go-speedtest/internal/stats"
+)
+
+// Default size of the buffers the response bodies are read with
+const defaultReadBuffer = 128 * 1024
+
+// Pools of read buffers by size
+var readBuffers sync.Map
+
+// Take a buffer of size bytes (defaults to 128KB) from its pool
+func getBuffer(size int) *[]byte {
+	if size <= 0 {
+		size = defaultReadBuffer
+	}
+	pool, _ := readBuffers.LoadOrStore(size, &sync.Pool{New: func() any {
+		b := make([]byte, size)
+		return &b
+	}})
+	return pool.(*sync.Pool).Get().(*[]byte)
+}
+
+// Give a buffer back to its pool
+func putBuffer(b *[]byte) {
+	if pool, ok := readBuffers.Load(len(*b)); ok {
+		pool.(*sync.Pool).Put(b)
+	}
+}
+
+// Destination of the response bodies: counts the bytes and drops them
+// unless a sink keeps them
+type bodyWriter struct {
+	counter *stats.Counter
+	sink    bodySink
+	idle    func()
+	n       int64
+	err     error
+}
+
+func (w *bodyWriter) Write(p []byte) (int, error) {
+	w.counter.Add(int64(len(p)))
+	w.n += int64(len(p))
+	if w.idle != nil {
+		w.idle()
+	}
+	if w.sink != nil {
+		if _, err := w.sink.Write(p); err != nil {
+			w.err = err
+			return 0, err
+		}
+	}
+	return io.Discard.Write(p)
+}
+
+// Read r until EOF with a pooled buffer of size bytes
+func readBody(w *bodyWriter, r io.Reader, size int) error {
+	buf := getBuffer(size)
+	defer putBuffer(buf)
+	_, err := io.CopyBuffer(w, r, *buf)
+	return err
+}
@@ -0,0 +1,152 @@
+package speedtest
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// Delay between two latency probes sent while the link is loaded
+const loadedProbeInterval = 200 * time.Millisecond
+
+// BufferbloatResult compares the idle latency with the latency measured
+// while the download and upload saturate the link
+type BufferbloatResult struct {
+	Target string        `json:"target"`
+	Idle   time.Duration `json:"idle_ns"`
+
+	// Probes sent during each phase, nil if the phase was not run
+	Download *LatencyResult `json:"download,omitempty"`
+	Upload   *LatencyResult `json:"upload,omitempty"`
+
+	// Probes sent while the download and upload ran together, with
+	// Options.Bidirectional
+	Bidirectional *LatencyResult `json:"bidirectional,omitempty"`
+}
+
+// Increase returns how much the average latency grew under load, for the
+// worst of the download, upload and bidirectional phases
+func (r *BufferbloatResult) Increase() time.Duration {
+	var worst time.Duration
+	for _, l := range []*LatencyResult{r.Download, r.Upload, r.Bidirectional} {
+		if l != nil && l.Received > 0 && l.Avg-r.Idle > worst {
+			worst = l.Avg - r.Idle
+		}
+	}
+	return worst
+}
+
+// Grade rates the latency increase like the Waveform bufferbloat test,
+// from A+ to F
+func (r *BufferbloatResult) Grade() string {
+	switch inc := r.Increase(); {
+	case inc < 5*time.Millisecond:
+		return "A+"
+	case inc < 30*time.Millisecond:
+		return "A"
+	case inc < 60*time.Millisecond:
+		return "B"
+	case inc < 200*time.Millisecond:
+		return "C"
+	case inc < 400*time.Millisecond:
+		return "D"
+	}
+	return "F"
+}
+
+// Number of probes measuring the idle latency when the latency phase
+// can't be reused
+const idleProbes = 5
+
+// Measure the idle latency of the bufferbloat target, reusing the latency
+// phase result lat if it probed the same URL
+func (c *Client) idleLatency(ctx context.Context, opts Options, lat *LatencyResult) (*BufferbloatResult, error) {
+	res := &BufferbloatResult{Target: opts.BufferbloatTarget}
+	if res.Target == "" {
+		res.Target = opts.Target
+	}
+	if lat == nil || res.Target != opts.Target {
+		opts.Target = res.Target
+		opts.LatencyProbes = idleProbes
+		var err error
+		if lat, err = c.latency(ctx, opts); err != nil {
+			return nil, err
+		}
+	}
+	res.Idle = lat.Avg
+	return res, nil
+}
+
+// Context key of the latest latency measured under load, shown by the
+// progress dashboard
+type loadedLatencyKey struct{}
+
+// Return a context carrying the latest loaded latency
+func withLoadedLatency(ctx context.Context) context.Context {
+	return context.WithValue(ctx, loadedLatencyKey{}, new(atomic.Int64))
+}
+
+// Latest latency measured under load, 0 if none
+func loadedLatency(ctx context.Context) time.Duration {
+	if last, ok := ctx.Value(loadedLatencyKey{}).(*atomic.Int64); ok {
+		return time.Duration(last.Load())
+	}
+	return 0
+}
+
+// Keep probing the latency while load runs
+func (c *Client) probeDuring(ctx context.Context, opts Options, target string, load func()) *LatencyResult {
+	method := probeMethod(opts.LatencyMethod)
+	res := &LatencyResult{Method: method}
+	probe, err := c.prober(method, target)
+	if err != nil {
+		load()
+		return res
+	}
+
+	whileLoaded(ctx, load, func(ctx context.Context) {
+		tctx, cancel := context.WithTimeout(ctx, probeTimeout(opts))
+		sent := time.Now()
+		rtt, err := probe(tctx)
+		cancel()
+		// Probes cut by the end of the phase are not lost
+		if ctx.Err() != nil {
+			return
+		}
+		res.Sent++
+		if err == nil {
+			res.Received++
+			res.Samples = append(res.Samples, rtt)
+			res.At = append(res.At, sent)
+			if last, ok := ctx.Value(loadedLatencyKey{}).(*atomic.Int64); ok {
+				last.Store(int64(rtt))
+			}
+		}
+	})
+	res.compute()
+	return res
+}
+
+// Run load, calling probe every loadedProbeInterval until it returns. The
+// context given to probe is cancelled when load is done.
+func whileLoaded(ctx context.Context, load func(), probe func(context.Context)) {
+	pctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(loadedProbeInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-pctx.Done():
+				return
+			case <-ticker.C:
+			}
+			probe(pctx)
+		}
+	}()
+
+	load()
+	cancel()
+	<-done
+}
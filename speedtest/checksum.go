@@ -0,0 +1,75 @@
+package speedtest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Destination of the body of a download request, emptied when a whole
+// file request is sent again
+type bodySink interface {
+	io.Writer
+	Reset()
+}
+
+// SHA-256 of a file downloaded in chunks arriving out of order. Chunks
+// are kept until the data before them has been hashed.
+type chunkSum struct {
+	mu      sync.Mutex
+	h       hash.Hash
+	next    int64
+	pending map[int64][]byte
+}
+
+func newChunkSum() *chunkSum {
+	return &chunkSum{h: sha256.New(), pending: map[int64][]byte{}}
+}
+
+// Add the chunk starting at offset start
+func (s *chunkSum) add(start int64, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if start < s.next {
+		return
+	}
+	s.pending[start] = data
+	for {
+		data, ok := s.pending[s.next]
+		if !ok {
+			return
+		}
+		delete(s.pending, s.next)
+		s.h.Write(data)
+		s.next += int64(len(data))
+	}
+}
+
+// Replace the chunks with the whole file, for servers ignoring ranges
+func (s *chunkSum) whole(data []byte) {
+	s.mu.Lock()
+	s.h.Reset()
+	s.next = 0
+	clear(s.pending)
+	s.mu.Unlock()
+	s.add(0, data)
+}
+
+// Return the hash if size bytes were hashed
+func (s *chunkSum) sum(size int64) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.h.Sum(nil), s.next == size
+}
+
+// Compare a hash with the expected hex SHA-256
+func checkSum(sum []byte, want string) error {
+	if got := hex.EncodeToString(sum); got != strings.ToLower(want) {
+		return fmt.Errorf("downloaded data doesn't match the expected SHA-256: got %s", got)
+	}
+	return nil
+}
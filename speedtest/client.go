@@ -0,0 +1,375 @@
+// Package speedtest measures network throughput by downloading a remote
+// file over several parallel HTTP connections.
+package speedtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Client runs speed tests
+type Client struct {
+	// Client sending the HTTP requests. Set it to test over a RoundTripper
+	// of your own, or see TransportOptions.DialContext to only replace the
+	// network stack.
+	HTTPClient *http.Client
+
+	// Logger receives the progress of the test phases at debug level,
+	// slog.Default is used if nil
+	Logger *slog.Logger
+
+	// Addresses forced by TransportOptions.Resolve, raced by the Happy
+	// Eyeballs report
+	resolve map[string]string
+
+	// Interface bound by TransportOptions.Interface, whose counters
+	// Options.Counters reads
+	iface string
+}
+
+// NewClient returns a Client using http.DefaultClient
+func NewClient() *Client {
+	return &Client{HTTPClient: http.DefaultClient}
+}
+
+// Run executes a speed test with the given options. It returns when all
+// downloads are finished, the duration elapsed or ctx is cancelled, the
+// result being marked Partial then.
+func (c *Client) Run(ctx context.Context, opts Options) (*Result, error) {
+	if opts.Target == "" {
+		return nil, errors.New("target URL is required")
+	}
+	if opts.Concurrent <= 0 || opts.Single {
+		opts.Concurrent = 1
+	}
+	if opts.RateLimit > 0 && opts.limiter == nil {
+		opts.limiter = newRateLimiter(opts.RateLimit)
+	}
+	if isFTP(opts.Target) {
+		return c.runFTP(ctx, opts)
+	}
+
+	// Get the file size
+	fileSize, ranges := int64(-1), false
+	var err error
+	if !opts.SkipDownload {
+		if fileSize, ranges, err = c.fileSize(ctx, opts.Target); err != nil {
+			return nil, err
+		}
+		c.log().Debug("target probed", "url", opts.Target, "size", fileSize, "ranges", ranges)
+	}
+	// Without a known size the target is streamed for a fixed duration
+	if fileSize < 0 && opts.Duration <= 0 {
+		opts.Duration = backendDuration
+	}
+
+	var edge *EdgeInfo
+	if opts.Edge {
+		if edge, err = c.identifyEdge(ctx, opts.Target); err != nil {
+			c.log().Warn("server not identified", "err", err)
+		}
+	}
+
+	var race *EyeballsResult
+	if opts.Eyeballs {
+		if race, err = c.eyeballs(ctx, opts.Target); err != nil {
+			c.log().Warn("addresses not raced", "err", err)
+		}
+	}
+
+	var path *PathResult
+	if opts.Path {
+		if path, err = c.tracePath(ctx, opts.Target); err != nil {
+			c.log().Warn("path not traced", "err", err)
+		}
+	}
+
+	// The idle latency is needed as a bufferbloat reference
+	bidir := opts.Bidirectional && !opts.SkipDownload
+	probeLoaded := opts.Bufferbloat || bidir
+	if probeLoaded {
+		ctx = withLoadedLatency(ctx)
+	}
+
+	var lat *LatencyResult
+	var bloat *BufferbloatResult
+	// Run a phase, probing the latency meanwhile if asked
+	loaded := func(phase func()) *LatencyResult {
+		if bloat == nil {
+			phase()
+			return nil
+		}
+		return c.probeDuring(ctx, opts, bloat.Target, phase)
+	}
+	// Log the errors of a transfer once it is done
+	logged := func(name string, bytes int64, elapsed time.Duration, errs []string) {
+		c.log().Debug(name+" done", "bytes", bytes, "elapsed", elapsed, "errors", len(errs))
+		for _, e := range errs {
+			c.log().Warn(name+" error", "err", e)
+		}
+	}
+
+	// Read the interface counters around the transfer phases only, the
+	// window of the bytes they are compared with
+	var nic *NICCounters
+	var count func() (*NICCounters, error)
+	counted := false
+	counting := func(run func(context.Context) error) func(context.Context) error {
+		if !opts.Counters {
+			return run
+		}
+		return func(ctx context.Context) (err error) {
+			if !counted {
+				counted = true
+				if count, err = c.countBytes(ctx, opts.Target); err != nil {
+					c.log().Warn("interface counters not read", "err", err)
+				}
+			}
+			if err = run(ctx); err != nil || count == nil {
+				return err
+			}
+			if nic, err = count(); err != nil {
+				c.log().Warn("interface counters not read", "err", err)
+				nic, count = nil, nil
+			}
+			return nil
+		}
+	}
+
+	res := &Result{Target: opts.Target, Concurrent: opts.Concurrent, DownloadSkipped: opts.SkipDownload}
+	var rpm *ResponsivenessResult
+	var cooldown *LatencyResult
+	err = c.runPhases(ctx, opts, []testPhase{{
+		// Measure latency before loading the link
+		name: PhaseIdle,
+		skip: opts.LatencyProbes <= 0 && opts.Phases.Idle <= 0 && !probeLoaded,
+		run: func(ctx context.Context) (err error) {
+			switch {
+			case opts.Phases.Idle > 0:
+				lat = c.probeFor(ctx, opts, opts.Target, opts.Phases.Idle)
+			case opts.LatencyProbes > 0:
+				if lat, err = c.latency(ctx, opts); err != nil {
+					return err
+				}
+			}
+			if lat != nil {
+				c.log().Debug("latency measured", "method", lat.Method, "received", lat.Received, "avg", lat.Avg)
+			}
+			if probeLoaded {
+				bloat, err = c.idleLatency(ctx, opts, lat)
+			}
+			return err
+		},
+	}, {
+		name:   string(dirDownload),
+		skip:   opts.SkipDownload,
+		events: true,
+		run: counting(func(ctx context.Context) (err error) {
+			dl := opts.lasting(opts.Phases.Download)
+			if bidir {
+				dl = dl.serialized()
+			}
+			download := func() { res, err = c.download(ctx, dl, fileSize, ranges) }
+			if opts.Responsiveness {
+				load := download
+				download = func() { rpm = c.responsiveness(ctx, dl, opts.Target, load) }
+			}
+			// Upload meanwhile, until the download is done. The dashboard
+			// shows the download only.
+			var up *UploadResult
+			var upErr error
+			if bidir {
+				uo := dl
+				uo.Progress = nil
+				if uo.UploadSize == 0 {
+					uo.UploadSize = max(fileSize, -1)
+				}
+				load := download
+				download = func() {
+					uctx, cancel := context.WithCancel(ctx)
+					done := make(chan struct{})
+					go func() {
+						defer close(done)
+						up, upErr = c.upload(uctx, uo)
+					}()
+					load()
+					cancel()
+					<-done
+				}
+			}
+			c.log().Debug("download started", "connections", opts.Concurrent)
+			probes := loaded(download)
+			if err != nil {
+				return err
+			}
+			if upErr != nil {
+				return upErr
+			}
+			logged("download", res.Bytes, res.Elapsed, res.Errors)
+			if bidir {
+				res.Upload, res.Bidirectional = up, true
+				logged("upload", up.Bytes, up.Elapsed, up.Errors)
+			}
+			if bloat != nil && bidir {
+				bloat.Bidirectional = probes
+			} else if bloat != nil {
+				bloat.Download = probes
+			}
+			return nil
+		}),
+	}, {
+		name:   string(dirUpload),
+		skip:   !opts.Upload || bidir,
+		events: true,
+		run: counting(func(ctx context.Context) (err error) {
+			uo := opts.lasting(opts.Phases.Upload)
+			if uo.UploadSize == 0 {
+				uo.UploadSize = max(fileSize, -1)
+			}
+			c.log().Debug("upload started", "connections", opts.Concurrent, "size", uo.UploadSize)
+			var up *UploadResult
+			probes := loaded(func() { up, err = c.upload(ctx, uo) })
+			if err != nil {
+				return err
+			}
+			res.Upload = up
+			logged("upload", up.Bytes, up.Elapsed, up.Errors)
+			if bloat != nil {
+				bloat.Upload = probes
+			}
+			return nil
+		}),
+	}, {
+		// See how fast the latency recovers once the link is idle again
+		name: PhaseCooldown,
+		skip: opts.Phases.Cooldown <= 0,
+		run: func(ctx context.Context) error {
+			target := opts.Target
+			if bloat != nil {
+				target = bloat.Target
+			}
+			cooldown = c.probeFor(ctx, opts, target, opts.Phases.Cooldown)
+			return nil
+		},
+	}})
+	if err != nil {
+		return nil, err
+	}
+	res.Latency = lat
+	res.Edge = edge
+	res.Path = path
+	res.Eyeballs = race
+	res.Responsiveness = rpm
+	res.Bufferbloat = bloat
+	res.Cooldown = cooldown
+	res.Partial = ctx.Err() != nil
+	if nic != nil {
+		nic.Downloaded = res.Bytes + res.Omitted
+		if res.Upload != nil {
+			nic.Uploaded = res.Upload.Bytes + res.Upload.Omitted
+		}
+		res.Counters = nic
+	}
+	return res, nil
+}
+
+// RunNearest probes each of the targets with a few HEAD requests and runs
+// the test against the one with the lowest latency. Options.Target is
+// ignored.
+func (c *Client) RunNearest(ctx context.Context, opts Options, targets []string) (*Result, error) {
+	i, rtt, err := c.nearest(ctx, len(targets), func(i int) string {
+		return targets[i]
+	})
+	if err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(targets[i])
+	if err != nil {
+		return nil, err
+	}
+	server := &Server{Host: u.Host, URL: targets[i], Latency: rtt}
+
+	opts.Target = targets[i]
+	res, err := c.Run(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	res.Server = server
+	return res, nil
+}
+
+// Run executes a speed test using a default Client
+func Run(ctx context.Context, opts Options) (*Result, error) {
+	return NewClient().Run(ctx, opts)
+}
+
+// Issue a HEAD request to find out the size of the remote file, -1 if
+// the server doesn't tell it, and whether Range requests are supported.
+// Servers rejecting HEAD are asked with a one byte GET instead.
+func (c *Client) fileSize(ctx context.Context, target string) (int64, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, target, nil)
+	if err != nil {
+		return 0, false, err
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to get file size: %w", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 || resp.ContentLength < 0 {
+		return c.rangeProbe(ctx, target)
+	}
+	if resp.ContentLength == 0 {
+		return 0, false, errors.New("invalid file size")
+	}
+
+	// Servers may support ranges without advertising them
+	switch resp.Header.Get("Accept-Ranges") {
+	case "bytes":
+		return resp.ContentLength, true, nil
+	case "none":
+		return resp.ContentLength, false, nil
+	}
+	_, ranges, err := c.rangeProbe(ctx, target)
+	return resp.ContentLength, ranges, err
+}
+
+// Ask for the first byte of the file: a partial content answer tells
+// Range requests are supported and gives the size in Content-Range
+func (c *Client) rangeProbe(ctx context.Context, target string) (int64, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return 0, false, err
+	}
+	req.Header.Set("Range", "bytes=0-0")
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to get file size: %w", err)
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		// Content-Range: bytes 0-0/size, the size may be *
+		_, total, _ := strings.Cut(resp.Header.Get("Content-Range"), "/")
+		size, err := strconv.ParseInt(total, 10, 64)
+		if err != nil || size <= 0 {
+			return -1, true, nil
+		}
+		return size, true, nil
+	case http.StatusOK:
+		if resp.ContentLength == 0 {
+			return 0, false, errors.New("invalid file size")
+		}
+		return resp.ContentLength, false, nil
+	}
+	return 0, false, fmt.Errorf("failed to get file size: %s", resp.Status)
+}
@@ -0,0 +1,51 @@
+package speedtest
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// The download and upload of a bidirectional test report to the same
+// callbacks, which must not be called concurrently (run with -race)
+func TestRunBidirectionalProgress(t *testing.T) {
+	srv := httptest.NewServer(Handler(1 << 30))
+	defer srv.Close()
+
+	// State no lock protects
+	snapshots := make(map[string]int)
+	var last Snapshot
+	var intervals bytes.Buffer
+	opts := Options{
+		Target:         srv.URL + "/download",
+		UploadTarget:   srv.URL + "/upload",
+		Concurrent:     2,
+		Duration:       1500 * time.Millisecond,
+		Bidirectional:  true,
+		LatencyProbes:  2,
+		Interval:       500 * time.Millisecond,
+		IntervalOutput: &intervals,
+		OnProgress: func(p Snapshot) {
+			snapshots[p.Phase]++
+			last = p
+		},
+	}
+	res, err := NewClient().Run(context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Bidirectional || res.Upload == nil {
+		t.Fatalf("upload not run during the download: %+v", res)
+	}
+	if snapshots["Download"] == 0 || snapshots["Upload"] == 0 {
+		t.Errorf("progress of both directions expected, got %v", snapshots)
+	}
+	if last.Phase == "" {
+		t.Error("no progress reported")
+	}
+	if !bytes.Contains(intervals.Bytes(), []byte("Upload")) {
+		t.Errorf("no upload interval in:\n%s", intervals.String())
+	}
+}
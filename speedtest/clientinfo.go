@@ -0,0 +1,104 @@
+package speedtest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Providers of LookupClient
+const (
+	ProviderIPInfo   = "ipinfo"
+	ProviderIPAPI    = "ip-api"
+	ProviderIfconfig = "ifconfig.co"
+)
+
+// ClientInfo describes the network the test ran from, as seen by a public
+// IP lookup service
+type ClientInfo struct {
+	IP       string  `json:"ip"`
+	ISP      string  `json:"isp,omitempty"`
+	ASN      string  `json:"asn,omitempty"`
+	City     string  `json:"city,omitempty"`
+	Region   string  `json:"region,omitempty"`
+	Country  string  `json:"country,omitempty"`
+	Lat      float64 `json:"lat,omitempty"`
+	Lon      float64 `json:"lon,omitempty"`
+	Provider string  `json:"provider"`
+
+	// Time of the lookup, older than the test if it was cached
+	Looked time.Time `json:"looked_up"`
+}
+
+// LookupClient asks provider for the public IP of the client, its ISP,
+// ASN and rough location
+func (c *Client) LookupClient(ctx context.Context, provider string) (*ClientInfo, error) {
+	info := &ClientInfo{Provider: provider, Looked: time.Now()}
+	var err error
+	switch provider {
+	case ProviderIPInfo, "":
+		info.Provider = ProviderIPInfo
+		var r struct {
+			IP, City, Region, Country, Loc, Org string
+		}
+		if err = c.getJSON(ctx, "https://ipinfo.io/json", &r); err == nil {
+			info.IP, info.City, info.Region, info.Country = r.IP, r.City, r.Region, r.Country
+			// Org is "AS15169 Google LLC"
+			if asn, isp, ok := strings.Cut(r.Org, " "); ok && strings.HasPrefix(asn, "AS") {
+				info.ASN, info.ISP = asn, isp
+			} else {
+				info.ISP = r.Org
+			}
+			if lat, lon, ok := strings.Cut(r.Loc, ","); ok {
+				info.Lat, _ = strconv.ParseFloat(lat, 64)
+				info.Lon, _ = strconv.ParseFloat(lon, 64)
+			}
+		}
+	case ProviderIPAPI:
+		var r struct {
+			Status, Message, Query, ISP, AS, City, RegionName, CountryCode string
+			Lat, Lon                                                       float64
+		}
+		if err = c.getJSON(ctx, "http://ip-api.com/json", &r); err == nil {
+			if r.Status != "success" {
+				return nil, fmt.Errorf("ip-api lookup failed: %s", r.Message)
+			}
+			info.IP, info.ISP, info.City, info.Region, info.Country = r.Query, r.ISP, r.City, r.RegionName, r.CountryCode
+			info.ASN, _, _ = strings.Cut(r.AS, " ")
+			info.Lat, info.Lon = r.Lat, r.Lon
+		}
+	case ProviderIfconfig:
+		var r struct {
+			IP         string  `json:"ip"`
+			ASN        string  `json:"asn"`
+			ASNOrg     string  `json:"asn_org"`
+			City       string  `json:"city"`
+			RegionName string  `json:"region_name"`
+			CountryISO string  `json:"country_iso"`
+			Latitude   float64 `json:"latitude"`
+			Longitude  float64 `json:"longitude"`
+		}
+		if err = c.getJSON(ctx, "https://ifconfig.co/json", &r); err == nil {
+			info.IP, info.ASN, info.ISP, info.City, info.Region, info.Country = r.IP, r.ASN, r.ASNOrg, r.City, r.RegionName, r.CountryISO
+			info.Lat, info.Lon = r.Latitude, r.Longitude
+		}
+	default:
+		return nil, fmt.Errorf("unknown lookup provider %q", provider)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up the client: %w", err)
+	}
+	return info, nil
+}
+
+// Fetch target and decode its JSON body into v
+func (c *Client) getJSON(ctx context.Context, target string, v any) error {
+	body, err := c.getString(ctx, target)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(body), v)
+}
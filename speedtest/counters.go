@@ -0,0 +1,125 @@
+package speedtest
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Share of the bytes the interface counted on top of those the test
+// transferred above which other traffic likely polluted the result, the
+// TCP/IP and TLS headers taking about 5%
+const countersOverhead = 0.10
+
+// NICCounters compares the bytes the interface carrying the test counted
+// with those the test transferred
+type NICCounters struct {
+	Interface string `json:"interface"`
+
+	// The interface is a loopback one, which receives whatever it sends:
+	// both counters then carry the download and the upload
+	Loopback bool `json:"loopback,omitempty"`
+
+	// Bytes the interface received and sent during the test, headers and
+	// any other traffic included
+	RxBytes int64 `json:"rx_bytes"`
+	TxBytes int64 `json:"tx_bytes"`
+
+	// Bytes the test downloaded and uploaded, payload only
+	Downloaded int64 `json:"downloaded"`
+	Uploaded   int64 `json:"uploaded"`
+}
+
+// RxOverhead is the share of received bytes on top of the downloaded
+// ones, 0 without a download
+func (n *NICCounters) RxOverhead() float64 {
+	if n.Loopback {
+		return overhead(n.RxBytes, n.Downloaded+n.Uploaded)
+	}
+	return overhead(n.RxBytes, n.Downloaded)
+}
+
+// TxOverhead is the share of sent bytes on top of the uploaded ones, 0
+// without an upload
+func (n *NICCounters) TxOverhead() float64 {
+	if n.Loopback {
+		return overhead(n.TxBytes, n.Downloaded+n.Uploaded)
+	}
+	return overhead(n.TxBytes, n.Uploaded)
+}
+
+// Polluted reports an overhead too large for the protocol headers alone:
+// other traffic shared the interface, or the test bytes were miscounted
+func (n *NICCounters) Polluted() bool {
+	return n.RxOverhead() > countersOverhead || n.TxOverhead() > countersOverhead
+}
+
+func overhead(counted, transferred int64) float64 {
+	if transferred <= 0 {
+		return 0
+	}
+	return float64(counted-transferred) / float64(transferred)
+}
+
+// Read the counters of the interface carrying the test to target: the
+// bound one if TransportOptions.Interface is set, else the one routing to
+// the target host. The function returned reads them again and gives the
+// bytes counted meanwhile.
+func (c *Client) countBytes(ctx context.Context, target string) (func() (*NICCounters, error), error) {
+	name := c.iface
+	if name == "" {
+		var err error
+		if name, err = c.routeInterface(ctx, target); err != nil {
+			return nil, err
+		}
+	}
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, err
+	}
+	rx, tx, err := readCounters(name)
+	if err != nil {
+		return nil, err
+	}
+	return func() (*NICCounters, error) {
+		rx2, tx2, err := readCounters(name)
+		if err != nil {
+			return nil, err
+		}
+		return &NICCounters{Interface: name, Loopback: iface.Flags&net.FlagLoopback != 0, RxBytes: rx2 - rx, TxBytes: tx2 - tx}, nil
+	}, nil
+}
+
+// Name of the interface whose address the system picks to reach the
+// target host, or its forced address
+func (c *Client) routeInterface(ctx context.Context, target string) (string, error) {
+	addr, err := hostPort(target)
+	if err != nil {
+		return "", err
+	}
+	if forced, ok := c.resolve[addr]; ok {
+		addr, _, _ = strings.Cut(forced, ",")
+	}
+	// Connecting UDP sends nothing, it only picks the route
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", addr)
+	if err != nil {
+		return "", err
+	}
+	local := conn.LocalAddr().(*net.UDPAddr).IP
+	conn.Close()
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return "", err
+	}
+	for _, iface := range ifaces {
+		addrs, _ := iface.Addrs()
+		for _, a := range addrs {
+			if n, ok := a.(*net.IPNet); ok && n.IP.Equal(local) {
+				return iface.Name, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("no interface has the address %s routing to %s", local, addr)
+}
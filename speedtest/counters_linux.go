@@ -0,0 +1,40 @@
+package speedtest
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Bytes received and sent by an interface since it came up, from
+// /proc/net/dev whose lines read
+//
+//	eth0: <rx bytes> <7 rx counters> <tx bytes> <7 tx counters>
+func readCounters(name string) (rx, tx int64, err error) {
+	f, err := os.Open("/proc/net/dev")
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		iface, counters, ok := strings.Cut(sc.Text(), ":")
+		if !ok || strings.TrimSpace(iface) != name {
+			continue
+		}
+		fields := strings.Fields(counters)
+		if len(fields) < 9 {
+			return 0, 0, fmt.Errorf("invalid counters of %s: %q", name, counters)
+		}
+		if rx, err = strconv.ParseInt(fields[0], 10, 64); err == nil {
+			tx, err = strconv.ParseInt(fields[8], 10, 64)
+		}
+		return rx, tx, err
+	}
+	if err := sc.Err(); err != nil {
+		return 0, 0, err
+	}
+	return 0, 0, fmt.Errorf("no counters for interface %s", name)
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package speedtest
+
+import (
+	"errors"
+	"fmt"
+)
+
+// The interface counters are only read from /proc/net/dev
+func readCounters(string) (int64, int64, error) {
+	return 0, 0, fmt.Errorf("interface counters are only read on Linux: %w", errors.ErrUnsupported)
+}
@@ -0,0 +1,34 @@
+package speedtest
+
+import (
+	"math"
+	"testing"
+)
+
+func TestNICCountersOverhead(t *testing.T) {
+	tests := []struct {
+		name     string
+		n        NICCounters
+		rx, tx   float64
+		polluted bool
+	}{
+		{"headers only", NICCounters{RxBytes: 1040, TxBytes: 30, Downloaded: 1000}, 0.04, 0, false},
+		{"other traffic", NICCounters{RxBytes: 1500, TxBytes: 30, Downloaded: 1000}, 0.5, 0, true},
+		{"busy upload", NICCounters{RxBytes: 1040, TxBytes: 2400, Downloaded: 1000, Uploaded: 2000}, 0.04, 0.2, true},
+		{"nothing transferred", NICCounters{RxBytes: 1000, TxBytes: 1000}, 0, 0, false},
+		{"loopback", NICCounters{Loopback: true, RxBytes: 3030, TxBytes: 3030, Downloaded: 1000, Uploaded: 2000}, 0.01, 0.01, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.n.RxOverhead(); math.Abs(got-tt.rx) > 1e-9 {
+				t.Errorf("RxOverhead() = %v, want %v", got, tt.rx)
+			}
+			if got := tt.n.TxOverhead(); math.Abs(got-tt.tx) > 1e-9 {
+				t.Errorf("TxOverhead() = %v, want %v", got, tt.tx)
+			}
+			if got := tt.n.Polluted(); got != tt.polluted {
+				t.Errorf("Polluted() = %v, want %v", got, tt.polluted)
+			}
+		})
+	}
+}
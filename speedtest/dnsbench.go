@@ -0,0 +1,63 @@
+package speedtest
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// DNSResult holds the resolution times of a list of names measured
+// against one resolver
+type DNSResult struct {
+	// Server resolving the names, as given to NewResolver ("" for the
+	// system resolver)
+	Resolver string `json:"resolver"`
+
+	// Statistics of the successful lookups
+	Latency *LatencyResult `json:"latency"`
+
+	// Average time of the first lookup of each name, before the resolver
+	// had it in its cache
+	Cold time.Duration `json:"cold_avg_ns"`
+
+	// Failed lookups
+	Errors []string `json:"errors,omitempty"`
+}
+
+// BenchmarkDNS looks up each host rounds times with the given resolver,
+// waiting at most timeout for each answer
+func BenchmarkDNS(ctx context.Context, resolver string, hosts []string, rounds int, timeout time.Duration) (*DNSResult, error) {
+	r, err := NewResolver(resolver)
+	if err != nil {
+		return nil, err
+	}
+	res := &DNSResult{Resolver: resolver, Latency: &LatencyResult{Method: "dns"}}
+	var cold []time.Duration
+	for round := 0; round < rounds && ctx.Err() == nil; round++ {
+		for _, host := range hosts {
+			lctx, cancel := context.WithTimeout(ctx, timeout)
+			start := time.Now()
+			_, err := r.LookupHost(lctx, host)
+			rtt := time.Since(start)
+			cancel()
+			if ctx.Err() != nil {
+				break
+			}
+			res.Latency.Sent++
+			if err != nil {
+				res.Errors = append(res.Errors, fmt.Sprintf("%s: %v", host, err))
+				continue
+			}
+			res.Latency.Received++
+			res.Latency.Samples = append(res.Latency.Samples, rtt)
+			if round == 0 {
+				cold = append(cold, rtt)
+			}
+		}
+	}
+	res.Latency.compute()
+	first := &LatencyResult{Samples: cold}
+	first.compute()
+	res.Cold = first.Avg
+	return res, nil
+}
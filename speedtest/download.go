@@ -0,0 +1,338 @@
+package speedtest
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/ofauchon/go-speedtest/internal/stats"
+)
+
+// Default size of the ranges pulled by the download connections
+const defaultChunkSize = 4 * 1024 * 1024
+
+// Default time without data after which a request is sent again, and how
+// many times it is
+const (
+	defaultStallTimeout = 10 * time.Second
+	maxRestarts         = 3
+)
+
+// Default number of retries of a failed request, and the first wait
+// before retrying
+const (
+	defaultRetries = 3
+	retryBackoff   = 250 * time.Millisecond
+)
+
+// Cause of the requests cancelled for lack of data
+var errStalled = errors.New("no data received")
+
+// Answers that sending the request again wouldn't fix: error statuses and
+// wrong ranges
+var errBadResponse = errors.New("unexpected response")
+
+// Failure to write the body to its sink
+var errSink = errors.New("failed to save data")
+
+func (c *Client) download(ctx context.Context, opts Options, fileSize int64, ranges bool) (*Result, error) {
+	if fileSize < 0 {
+		if opts.SHA256 != "" {
+			c.log().Warn("SHA-256 not checked, the server doesn't give the file size")
+		}
+		if opts.OutputFile != "" {
+			c.log().Warn("output file not written, the server doesn't give the file size")
+		}
+		return c.streamDownload(ctx, opts), nil
+	}
+	concurrent := int64(opts.Concurrent)
+	timings := make([]ConnTiming, concurrent)
+
+	// Without Range support each part downloads the whole file, otherwise
+	// the connections pull chunks from a shared queue until the end
+	split := ranges && !opts.Single && concurrent > 1
+	chunk := opts.ChunkSize
+	if chunk <= 0 {
+		chunk = defaultChunkSize
+	}
+	var next int64
+	var ignored int32
+	retry := fetchPolicy{timeout: opts.StallTimeout, retries: opts.Retries, buffer: opts.ReadBuffer}
+	if retry.timeout == 0 {
+		retry.timeout = defaultStallTimeout
+	}
+	if retry.retries == 0 {
+		retry.retries = defaultRetries
+	}
+
+	// The whole file downloaded by each connection, or the chunks, are
+	// hashed to be checked against opts.SHA256
+	verify := opts.SHA256 != ""
+	sums := make([][]byte, concurrent)
+	chunks := newChunkSum()
+
+	// Write the file to disk if asked, skipping the chunks already there
+	var out *outputFile
+	if opts.OutputFile != "" {
+		var err error
+		if out, err = openOutputFile(opts.OutputFile, fileSize, chunk, split); err != nil {
+			return nil, err
+		}
+		if out.resumed > 0 {
+			c.log().Debug("resuming output file", "path", opts.OutputFile, "bytes", out.resumed)
+		}
+	}
+
+	// Function to download chunks of the file
+	downloadPart := func(ctx context.Context, part int, counter *stats.Counter) error {
+		timings[part].Part = part
+		tctx := withTiming(ctx, &timings[part])
+		if !split {
+			var h hash.Hash
+			var sink bodySink
+			if verify {
+				h = sha256.New()
+				sink = h
+			}
+			// A single connection writes the output file
+			if out != nil && part == 0 {
+				sink = sinks(sink, out.sink(0))
+			}
+			_, err := c.downloadRange(tctx, opts.Target, part, -1, -1, retry, counter, sink)
+			if err == nil && verify {
+				sums[part] = h.Sum(nil)
+			}
+			if err == nil && out != nil && part == 0 {
+				out.markWhole()
+			}
+			return err
+		}
+		for ctx.Err() == nil {
+			start := atomic.AddInt64(&next, chunk) - chunk
+			if start >= fileSize {
+				return nil
+			}
+			end := min(start+chunk, fileSize)
+			if out != nil && out.done(start) {
+				if verify {
+					data, err := out.read(start, end-start)
+					if err != nil {
+						return err
+					}
+					chunks.add(start, data)
+				}
+				continue
+			}
+			var body bytes.Buffer
+			var sink bodySink
+			if verify {
+				sink = &body
+			}
+			if out != nil {
+				sink = sinks(sink, out.sink(start))
+			}
+			// Only the first request of a connection is traced
+			full, err := c.downloadRange(tctx, opts.Target, part, start, end-1, retry, counter, sink)
+			if err != nil {
+				return err
+			}
+			if full {
+				if verify {
+					chunks.whole(body.Bytes())
+				}
+				atomic.StoreInt32(&ignored, 1)
+				return nil
+			}
+			if verify {
+				chunks.add(start, body.Bytes())
+			}
+			if out != nil {
+				if err := out.markDone(start); err != nil {
+					return err
+				}
+			}
+			tctx = ctx
+		}
+		return nil
+	}
+
+	total := fileSize
+	if !split {
+		total = fileSize * concurrent
+	} else if out != nil {
+		total -= out.resumed
+	}
+	t := runTransfer(ctx, opts, dirDownload, total, downloadPart)
+
+	res := t.downloadResult(opts, opts.Target, fileSize)
+	if out != nil {
+		var err error
+		if res.Saved, err = out.close(); err != nil {
+			return nil, err
+		}
+	}
+	res.Timings = timings
+	res.NoRange = concurrent > 1 && (!split || ignored != 0)
+
+	if verify {
+		if split {
+			sums = sums[:0]
+			if sum, ok := chunks.sum(fileSize); ok {
+				sums = append(sums, sum)
+			}
+		}
+		for _, sum := range sums {
+			if sum != nil {
+				if err := checkSum(sum, opts.SHA256); err != nil {
+					return nil, err
+				}
+				res.Verified = true
+			}
+		}
+		if !res.Verified {
+			c.log().Warn("SHA-256 not checked, the file was not downloaded completely")
+		}
+	}
+	return res, nil
+}
+
+// How requests failing or receiving nothing for timeout are sent again,
+// and the size of the buffer reading them
+type fetchPolicy struct {
+	timeout time.Duration
+	retries int
+	buffer  int
+}
+
+// Download bytes first to last of target, or the whole file if first is
+// negative. Failed and stalled requests are sent again for the rest of the
+// range. Returns true if the server ignored the range and sent it all.
+// The body is written to sink if not nil.
+func (c *Client) downloadRange(ctx context.Context, target string, part int, first, last int64, retry fetchPolicy, counter *stats.Counter, sink bodySink) (bool, error) {
+	restarts, retries := 0, 0
+	delay := retryBackoff
+	for {
+		full, n, err := c.fetchRange(ctx, target, part, first, last, retry, counter, sink)
+		if err == nil || ctx.Err() != nil || errors.Is(err, errBadResponse) || errors.Is(err, errSink) {
+			return full, err
+		}
+		if errors.Is(err, errStalled) {
+			if restarts >= maxRestarts {
+				return full, err
+			}
+			restarts++
+			counter.Restart()
+		} else {
+			if retries >= retry.retries {
+				return full, err
+			}
+			retries++
+			counter.Retry()
+			c.log().Debug("retrying download", "part", part, "in", delay, "err", err)
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return full, err
+			}
+			delay *= 2
+		}
+		if first < 0 || full {
+			if sink != nil {
+				sink.Reset()
+			}
+		} else if first += n; first > last {
+			return false, nil
+		}
+	}
+}
+
+// Send one range request, returning the bytes received
+func (c *Client) fetchRange(ctx context.Context, target string, part int, first, last int64, policy fetchPolicy, counter *stats.Counter, sink bodySink) (bool, int64, error) {
+	timeout := policy.timeout
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	// Cancel the request if it stays idle for timeout
+	idle := func() {}
+	if timeout > 0 {
+		timer := time.AfterFunc(timeout, func() { cancel(errStalled) })
+		defer timer.Stop()
+		idle = func() { timer.Reset(timeout) }
+	}
+	stalled := func(err error) error {
+		if context.Cause(ctx) == errStalled {
+			return fmt.Errorf("part %d: %w for %s", part, errStalled, timeout)
+		}
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return false, 0, err
+	}
+	if first >= 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", first, last))
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return false, 0, stalled(fmt.Errorf("failed to download part %d: %w", part, err))
+	}
+	defer resp.Body.Close()
+	if err := checkStatus(resp, part); err != nil {
+		return false, 0, err
+	}
+	full := first >= 0 && resp.StatusCode == http.StatusOK
+	if first >= 0 && !full {
+		if err := checkContentRange(resp, first, last); err != nil {
+			return false, 0, fmt.Errorf("failed to download part %d: %w", part, err)
+		}
+	}
+
+	w := &bodyWriter{counter: counter, sink: sink, idle: idle}
+	err = readBody(w, resp.Body, policy.buffer)
+	switch {
+	case w.err != nil:
+		return full, w.n, fmt.Errorf("%w of part %d: %w", errSink, part, w.err)
+	case err != nil:
+		return full, w.n, stalled(fmt.Errorf("error reading data on part %d: %w", part, err))
+	}
+	return full, w.n, nil
+}
+
+// Check the status of a download answer. Server errors may be retried,
+// the others wrap errBadResponse.
+func checkStatus(resp *http.Response, part int) error {
+	switch {
+	case resp.StatusCode >= http.StatusInternalServerError:
+		return fmt.Errorf("failed to download part %d: server returned %s", part, resp.Status)
+	case resp.StatusCode/100 != 2:
+		return fmt.Errorf("failed to download part %d: %w: %s", part, errBadResponse, resp.Status)
+	}
+	return nil
+}
+
+// Check a partial content answer holds bytes first to last
+func checkContentRange(resp *http.Response, first, last int64) error {
+	// Content-Range: bytes first-last/size
+	var a, b int64
+	cr := resp.Header.Get("Content-Range")
+	if _, err := fmt.Sscanf(cr, "bytes %d-%d/", &a, &b); err != nil || a != first || b != last {
+		return fmt.Errorf("%w: Content-Range %q for bytes %d-%d", errBadResponse, cr, first, last)
+	}
+	return nil
+}
+
+// Download the target over and over on each connection until the duration
+// elapses, for servers not giving the file size (e.g. chunked responses)
+func (c *Client) streamDownload(ctx context.Context, opts Options) *Result {
+	t := runTransfer(ctx, opts, dirDownload, 0, c.repeatGet(opts.ReadBuffer, func(int) string {
+		return opts.Target
+	}))
+	return t.downloadResult(opts, opts.Target, 0)
+}
@@ -0,0 +1,109 @@
+package speedtest
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"strings"
+)
+
+// EdgeInfo tells which server, and for CDNs which edge location, answered
+// the test requests
+type EdgeInfo struct {
+	Host string `json:"host"`
+
+	// Addresses the host resolves to, and the one that answered
+	Addrs []string `json:"addrs,omitempty"`
+	IP    string   `json:"ip,omitempty"`
+
+	// Name of IP in the reverse DNS, if any
+	ReverseDNS string `json:"reverse_dns,omitempty"`
+
+	// CDN and point of presence guessed from the response, empty if unknown
+	CDN string `json:"cdn,omitempty"`
+	PoP string `json:"pop,omitempty"`
+
+	// CDN related response headers
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// Response headers identifying CDNs and their edge locations
+var edgeHeaders = []string{"CF-Ray", "X-Amz-Cf-Pop", "X-Served-By", "X-Cache", "Via", "Server", "X-Akamai-Request-ID"}
+
+// Send a HEAD request to target and describe the server answering it
+func (c *Client) identifyEdge(ctx context.Context, target string) (*EdgeInfo, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+	e := &EdgeInfo{Host: u.Hostname()}
+	if net.ParseIP(e.Host) == nil {
+		e.Addrs, _ = net.DefaultResolver.LookupHost(ctx, e.Host)
+	}
+
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Conn == nil {
+				return
+			}
+			if host, _, err := net.SplitHostPort(info.Conn.RemoteAddr().String()); err == nil {
+				e.IP = host
+			}
+		},
+	}
+	req, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, trace), http.MethodHead, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to identify the server: %w", err)
+	}
+	resp.Body.Close()
+
+	if e.IP != "" {
+		if names, err := net.DefaultResolver.LookupAddr(ctx, e.IP); err == nil && len(names) > 0 {
+			e.ReverseDNS = strings.TrimSuffix(names[0], ".")
+		}
+	}
+	for _, h := range edgeHeaders {
+		if v := resp.Header.Get(h); v != "" {
+			if e.Headers == nil {
+				e.Headers = map[string]string{}
+			}
+			e.Headers[h] = v
+		}
+	}
+	e.CDN, e.PoP = guessCDN(resp.Header, e.ReverseDNS)
+	return e, nil
+}
+
+// Guess the CDN and edge location from the response headers and the
+// reverse DNS name of the server
+func guessCDN(h http.Header, rdns string) (string, string) {
+	lastField := func(s, sep string) string {
+		return s[strings.LastIndex(s, sep)+1:]
+	}
+	switch {
+	case h.Get("CF-Ray") != "":
+		// CF-Ray: 8a1b2c3d4e5f6789-CDG
+		return "Cloudflare", lastField(h.Get("CF-Ray"), "-")
+	case h.Get("X-Amz-Cf-Pop") != "":
+		return "CloudFront", h.Get("X-Amz-Cf-Pop")
+	case strings.Contains(h.Get("X-Served-By"), "cache-"):
+		// X-Served-By: cache-iad-kiad7000025-IAD, cache-cdg20741-CDG,
+		// the last cache is the edge
+		last := strings.TrimSpace(lastField(h.Get("X-Served-By"), ","))
+		return "Fastly", lastField(last, "-")
+	case h.Get("X-Akamai-Request-ID") != "" || strings.HasSuffix(rdns, ".akamaitechnologies.com"):
+		return "Akamai", ""
+	case strings.Contains(strings.ToLower(h.Get("Via")), "cloudfront"):
+		return "CloudFront", ""
+	case strings.EqualFold(h.Get("Server"), "cloudflare"):
+		return "Cloudflare", ""
+	}
+	return "", ""
+}
@@ -0,0 +1,57 @@
+package speedtest
+
+import "time"
+
+// EventType tells what happened in an Event
+type EventType string
+
+const (
+	// A phase of Client.Run started or ended: Idle, Download, Upload or
+	// Cooldown
+	EventPhaseStart EventType = "phase_start"
+	EventPhaseEnd   EventType = "phase_end"
+
+	// Progress of the phase, twice a second
+	EventProgress EventType = "progress"
+
+	// A connection started or finished its part of the transfer
+	EventConnStart EventType = "conn_start"
+	EventConnEnd   EventType = "conn_end"
+
+	// A connection failed
+	EventError EventType = "error"
+)
+
+// Event is sent on Options.Events as a test runs
+type Event struct {
+	Type  EventType `json:"type"`
+	Phase string    `json:"phase"`
+	At    time.Time `json:"at"`
+
+	// Connection of the conn_start, conn_end and error events
+	Part int `json:"part"`
+
+	// Bytes moved by the connection (conn_end) or the phase (phase_end)
+	Bytes int64 `json:"bytes,omitempty"`
+
+	// State of the phase, for progress events
+	Progress *Snapshot `json:"progress,omitempty"`
+
+	// Error of the connection, for error events
+	Err string `json:"error,omitempty"`
+}
+
+// Send an event on opts.Events without blocking the test, like
+// signal.Notify: the events the channel has no room for are dropped
+func (opts Options) emit(e Event) {
+	if opts.Events == nil {
+		return
+	}
+	if e.At.IsZero() {
+		e.At = time.Now()
+	}
+	select {
+	case opts.Events <- e:
+	default:
+	}
+}
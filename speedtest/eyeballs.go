@@ -0,0 +1,214 @@
+package speedtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Delay between the connection attempts of a Happy Eyeballs race, as RFC
+// 8305 recommends
+const eyeballsDelay = 250 * time.Millisecond
+
+// Time each address is given to connect
+const eyeballsTimeout = 5 * time.Second
+
+// AddrAttempt is the connection to one address of the target host
+type AddrAttempt struct {
+	Addr   string `json:"addr"`
+	Family string `json:"family"`
+
+	// Start of the attempt after the first one in the race schedule
+	Delay time.Duration `json:"delay_ns"`
+
+	// Time to connect, or why the attempt failed
+	Connect time.Duration `json:"connect_ns,omitempty"`
+	Err     string        `json:"error,omitempty"`
+}
+
+// EyeballsResult is a Happy Eyeballs race between the addresses of the
+// target host, each attempt being timed to its end to compare them all
+type EyeballsResult struct {
+	Host string `json:"host"`
+
+	// Address connected first counting the delays, and its family (IPv4
+	// or IPv6), empty if none connected
+	Winner string `json:"winner,omitempty"`
+	Family string `json:"family,omitempty"`
+
+	// Attempts in the order they started, the families alternating
+	// from IPv6
+	Attempts []AddrAttempt `json:"attempts"`
+}
+
+// IPv6Lost reports a dual-stack host where IPv4 won the race, a sign of
+// missing, broken or slow IPv6
+func (r *EyeballsResult) IPv6Lost() bool {
+	if r.Family != "IPv4" {
+		return false
+	}
+	for _, a := range r.Attempts {
+		if a.Family == "IPv6" {
+			return true
+		}
+	}
+	return false
+}
+
+// Family name of an IP address
+func ipFamily(ip net.IP) string {
+	if ip.To4() != nil {
+		return "IPv4"
+	}
+	return "IPv6"
+}
+
+// Order addresses like RFC 8305: IPv6 first, then alternating families
+func interleaveFamilies(ips []net.IP) []net.IP {
+	var v6, v4 []net.IP
+	for _, ip := range ips {
+		if ipFamily(ip) == "IPv6" {
+			v6 = append(v6, ip)
+		} else {
+			v4 = append(v4, ip)
+		}
+	}
+	var out []net.IP
+	for i := 0; i < max(len(v6), len(v4)); i++ {
+		if i < len(v6) {
+			out = append(out, v6[i])
+		}
+		if i < len(v4) {
+			out = append(out, v4[i])
+		}
+	}
+	return out
+}
+
+// Race the addresses of the target host: the addresses it is forced to
+// (TransportOptions.Resolve) or else those it resolves to
+func (c *Client) eyeballs(ctx context.Context, target string) (*EyeballsResult, error) {
+	addr, err := hostPort(target)
+	if err != nil {
+		return nil, err
+	}
+	host, port, _ := net.SplitHostPort(addr)
+	var ips []net.IP
+	if forced, ok := c.resolve[addr]; ok {
+		for _, a := range strings.Split(forced, ",") {
+			h, _, _ := net.SplitHostPort(a)
+			if ip := net.ParseIP(h); ip != nil {
+				ips = append(ips, ip)
+			}
+		}
+	} else if ip := net.ParseIP(host); ip != nil {
+		ips = []net.IP{ip}
+	} else if ips, err = net.DefaultResolver.LookupIP(ctx, "ip", host); err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", host, err)
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("no address to connect to %s", host)
+	}
+
+	res := &EyeballsResult{Host: host}
+	ips = interleaveFamilies(ips)
+	res.Attempts = make([]AddrAttempt, len(ips))
+	dial := c.dialContext()
+	var wg sync.WaitGroup
+	for i, ip := range ips {
+		a := &res.Attempts[i]
+		a.Addr, a.Family, a.Delay = ip.String(), ipFamily(ip), time.Duration(i)*eyeballsDelay
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case <-time.After(a.Delay):
+			case <-ctx.Done():
+				a.Err = ctx.Err().Error()
+				return
+			}
+			dctx, cancel := context.WithTimeout(ctx, eyeballsTimeout)
+			defer cancel()
+			start := time.Now()
+			conn, err := dial(dctx, "tcp", net.JoinHostPort(a.Addr, port))
+			if err != nil {
+				a.Err = err.Error()
+				return
+			}
+			a.Connect = time.Since(start)
+			conn.Close()
+		}()
+	}
+	wg.Wait()
+
+	var best time.Duration
+	for _, a := range res.Attempts {
+		if a.Err == "" && (res.Winner == "" || a.Delay+a.Connect < best) {
+			res.Winner, res.Family, best = a.Addr, a.Family, a.Delay+a.Connect
+		}
+	}
+	return res, nil
+}
+
+// Connect to the first of addrs answering, starting an attempt every
+// eyeballsDelay or as soon as the previous one failed, like Happy
+// Eyeballs. The other connections are closed.
+func raceDial(ctx context.Context, dial func(ctx context.Context, network, addr string) (net.Conn, error), network string, addrs []string) (net.Conn, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	type attempt struct {
+		conn net.Conn
+		err  error
+	}
+	results := make(chan attempt, len(addrs))
+	next, pending := 0, 0
+	// Close the connections of the attempts still running
+	drain := func() {
+		go func(pending int) {
+			for ; pending > 0; pending-- {
+				if r := <-results; r.conn != nil {
+					r.conn.Close()
+				}
+			}
+		}(pending)
+	}
+	var errs []error
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				drain()
+				return r.conn, nil
+			}
+			errs = append(errs, r.err)
+			if next == len(addrs) && pending == 0 {
+				return nil, errors.Join(errs...)
+			}
+			if next == len(addrs) {
+				continue
+			}
+			timer.Stop()
+		case <-ctx.Done():
+			drain()
+			return nil, ctx.Err()
+		}
+		addr := addrs[next]
+		next++
+		pending++
+		go func() {
+			conn, err := dial(ctx, network, addr)
+			results <- attempt{conn, err}
+		}()
+		if next < len(addrs) {
+			timer.Reset(eyeballsDelay)
+		}
+	}
+}
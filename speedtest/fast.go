@@ -0,0 +1,161 @@
+package speedtest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const (
+	fastHomeURL = "https://fast.com/"
+	fastAPIURL  = "https://api.fast.com/netflix/speedtest/v2?https=true&urlCount=5&token="
+
+	// Size of each ranged request against the Netflix servers
+	fastRangeSize = 25 * 1024 * 1024
+)
+
+var (
+	fastScriptRe = regexp.MustCompile(`<script src="(/app-[^"]+\.js)"`)
+	fastTokenRe  = regexp.MustCompile(`token:"([a-zA-Z]+)"`)
+)
+
+// Fast.com API answer
+type fastTargets struct {
+	Targets []struct {
+		Name     string `json:"name"`
+		URL      string `json:"url"`
+		Location struct {
+			City    string `json:"city"`
+			Country string `json:"country"`
+		} `json:"location"`
+	} `json:"targets"`
+}
+
+// Fetch a page and return its body as a string
+func (c *Client) getString(ctx context.Context, target string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("GET %s: %s", target, resp.Status)
+	}
+	b, err := io.ReadAll(resp.Body)
+	return string(b), err
+}
+
+// The API token is embedded in the fast.com application script
+func (c *Client) fastToken(ctx context.Context) (string, error) {
+	home, err := c.getString(ctx, fastHomeURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to load fast.com: %w", err)
+	}
+	m := fastScriptRe.FindStringSubmatch(home)
+	if m == nil {
+		return "", errors.New("fast.com application script not found")
+	}
+	script, err := c.getString(ctx, strings.TrimSuffix(fastHomeURL, "/")+m[1])
+	if err != nil {
+		return "", fmt.Errorf("failed to load fast.com script: %w", err)
+	}
+	m = fastTokenRe.FindStringSubmatch(script)
+	if m == nil {
+		return "", errors.New("fast.com token not found")
+	}
+	return m[1], nil
+}
+
+// FastServers fetches the Netflix servers Fast.com would use for the client
+func (c *Client) FastServers(ctx context.Context) ([]Server, error) {
+	token, err := c.fastToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	body, err := c.getString(ctx, fastAPIURL+url.QueryEscape(token))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get server list: %w", err)
+	}
+	var list fastTargets
+	if err := json.Unmarshal([]byte(body), &list); err != nil {
+		return nil, fmt.Errorf("invalid server list: %w", err)
+	}
+	servers := make([]Server, 0, len(list.Targets))
+	for _, t := range list.Targets {
+		servers = append(servers, Server{
+			Name:    t.Location.City,
+			Country: t.Location.Country,
+			Host:    t.Name,
+			URL:     t.URL,
+		})
+	}
+	if len(servers) == 0 {
+		return nil, errNoServer
+	}
+	return servers, nil
+}
+
+// Netflix servers take the byte range in the URL path
+func fastRangeURL(target string, size int64) string {
+	u, err := url.Parse(target)
+	if err != nil {
+		return target
+	}
+	u.Path = fmt.Sprintf("%s/range/0-%d", strings.TrimSuffix(u.Path, "/"), size-1)
+	return u.String()
+}
+
+// RunFast runs a test against the Netflix servers returned by Fast.com,
+// spreading the connections over all of them like the website does. If
+// servers is empty they are fetched from the API. Options.Target is ignored.
+func (c *Client) RunFast(ctx context.Context, opts Options, servers []Server) (*Result, error) {
+	if opts.Concurrent <= 0 {
+		opts.Concurrent = 1
+	}
+	if opts.Duration <= 0 {
+		opts.Duration = backendDuration
+	}
+
+	var err error
+	if len(servers) == 0 {
+		if servers, err = c.FastServers(ctx); err != nil {
+			return nil, err
+		}
+	}
+	server := &servers[0]
+
+	lat, err := c.httpLatency(ctx, opts, fastRangeURL(server.URL, 1))
+	if err != nil {
+		return nil, err
+	}
+
+	// Each connection sticks to one of the servers
+	serverURL := func(part int, size int64) string {
+		return fastRangeURL(servers[part%len(servers)].URL, size)
+	}
+
+	t := runTransfer(ctx, opts, dirDownload, 0, c.repeatGet(opts.ReadBuffer, func(part int) string {
+		return serverURL(part, fastRangeSize)
+	}))
+	res := t.downloadResult(opts, server.URL, 0)
+	res.Server = server
+	res.Latency = lat
+
+	if opts.Upload && ctx.Err() == nil {
+		t := runTransfer(ctx, opts, dirUpload, 0, c.repeatPost(func(part int) string {
+			return serverURL(part, payloadBlockSize)
+		}, payloadBlockSize, payloadBlock(opts.UploadCompressibility)))
+		res.Upload = t.uploadResult(opts, server.URL, 0)
+	}
+	return res, nil
+}
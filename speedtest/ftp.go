@@ -0,0 +1,284 @@
+package speedtest
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/ofauchon/go-speedtest/internal/stats"
+)
+
+// Password of the anonymous FTP logins
+const ftpAnonymousPassword = "go-speedtest@"
+
+// Whether the target is an ftp:// or ftps:// URL
+func isFTP(target string) bool {
+	return strings.HasPrefix(target, "ftp://") || strings.HasPrefix(target, "ftps://")
+}
+
+// Control connection of an FTP session. ftps:// uses implicit TLS on
+// both the control and data connections.
+type ftpConn struct {
+	conn net.Conn
+	text *textproto.Conn
+	host string
+	dial func(ctx context.Context, network, addr string) (net.Conn, error)
+	tls  *tls.Config
+	stop func() bool
+}
+
+// Dialer and TLS settings of the HTTP transport, shared by the FTP
+// connections of a test so the data connections resume the TLS session
+func (c *Client) ftpDialer(u *url.URL) (func(context.Context, string, string) (net.Conn, error), *tls.Config) {
+	dial := c.dialContext()
+	cfg := &tls.Config{}
+	rt, _ := c.transport()
+	if tr, ok := rt.(*http.Transport); ok {
+		if tr.TLSClientConfig != nil {
+			cfg = tr.TLSClientConfig.Clone()
+		}
+	}
+	if u.Scheme != "ftps" {
+		return dial, nil
+	}
+	cfg.ServerName = u.Hostname()
+	cfg.ClientSessionCache = tls.NewLRUClientSessionCache(0)
+	return dial, cfg
+}
+
+// Send a command and check the reply code, see textproto.Reader.ReadResponse
+func (f *ftpConn) cmd(expect int, format string, args ...any) (int, string, error) {
+	if _, err := f.text.Cmd(format, args...); err != nil {
+		return 0, "", err
+	}
+	return f.text.ReadResponse(expect)
+}
+
+// Connect and log in to the server of u, with the credentials of the URL
+// or anonymously, in binary mode
+func (c *Client) ftpLogin(ctx context.Context, u *url.URL, dial func(context.Context, string, string) (net.Conn, error), cfg *tls.Config) (*ftpConn, error) {
+	addr, err := hostPort(u.String())
+	if err != nil {
+		return nil, err
+	}
+	conn, err := dial(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if cfg != nil {
+		tconn := tls.Client(conn, cfg)
+		if err := tconn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tconn
+	}
+	// Unblock the reads and writes when the test ends
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	f := &ftpConn{conn: conn, text: textproto.NewConn(conn), host: u.Hostname(), dial: dial, tls: cfg, stop: stop}
+
+	user, pass := "anonymous", ftpAnonymousPassword
+	if u.User != nil {
+		user = u.User.Username()
+		pass, _ = u.User.Password()
+	}
+	steps := []func() error{
+		func() error { _, _, err := f.text.ReadResponse(220); return err },
+		func() error {
+			code, _, err := f.cmd(3, "USER %s", user)
+			if code == 230 {
+				return nil
+			}
+			if err == nil || code == 331 {
+				_, _, err = f.cmd(230, "PASS %s", pass)
+			}
+			return err
+		},
+		func() error {
+			if cfg == nil {
+				return nil
+			}
+			if _, _, err := f.cmd(200, "PBSZ 0"); err != nil {
+				return err
+			}
+			_, _, err := f.cmd(200, "PROT P")
+			return err
+		},
+		func() error { _, _, err := f.cmd(200, "TYPE I"); return err },
+	}
+	for _, step := range steps {
+		if err := step(); err != nil {
+			f.close()
+			return nil, fmt.Errorf("FTP login failed: %w", err)
+		}
+	}
+	return f, nil
+}
+
+func (f *ftpConn) close() {
+	f.stop()
+	f.text.Cmd("QUIT")
+	f.conn.Close()
+}
+
+// Size of the file, -1 if the server doesn't tell it
+func (f *ftpConn) size(path string) int64 {
+	_, msg, err := f.cmd(213, "SIZE %s", path)
+	if err != nil {
+		return -1
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(msg), 10, 64)
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+// Open a passive data connection, EPSV or PASV for the servers not
+// knowing it. The PASV address is ignored, the data connection going to
+// the control host like most clients do behind NAT.
+func (f *ftpConn) passive(ctx context.Context) (net.Conn, error) {
+	var port string
+	if _, msg, err := f.cmd(229, "EPSV"); err == nil {
+		// Entering Extended Passive Mode (|||port|)
+		if i := strings.Index(msg, "(|||"); i >= 0 {
+			port, _, _ = strings.Cut(msg[i+4:], "|")
+		}
+	} else if _, msg, err := f.cmd(227, "PASV"); err == nil {
+		// Entering Passive Mode (h1,h2,h3,h4,p1,p2)
+		i, j := strings.Index(msg, "("), strings.Index(msg, ")")
+		if i >= 0 && j > i {
+			if fields := strings.Split(msg[i+1:j], ","); len(fields) == 6 {
+				p1, _ := strconv.Atoi(fields[4])
+				p2, _ := strconv.Atoi(fields[5])
+				port = strconv.Itoa(p1<<8 | p2)
+			}
+		}
+	} else {
+		return nil, fmt.Errorf("passive mode refused: %w", err)
+	}
+	if port == "" {
+		return nil, errors.New("invalid passive mode reply")
+	}
+	conn, err := f.dial(ctx, "tcp", net.JoinHostPort(f.host, port))
+	if err != nil {
+		return nil, err
+	}
+	context.AfterFunc(ctx, func() { conn.Close() })
+	return conn, nil
+}
+
+// Start downloading path from offset, returning the data connection
+func (f *ftpConn) retr(ctx context.Context, path string, offset int64) (net.Conn, error) {
+	data, err := f.passive(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if offset > 0 {
+		if _, _, err := f.cmd(350, "REST %d", offset); err != nil {
+			data.Close()
+			return nil, fmt.Errorf("the server doesn't restart transfers: %w", err)
+		}
+	}
+	if _, _, err := f.cmd(1, "RETR %s", path); err != nil {
+		data.Close()
+		return nil, err
+	}
+	// Servers start TLS on the data connection once the transfer begins
+	if f.tls != nil {
+		tconn := tls.Client(data, f.tls)
+		if err := tconn.HandshakeContext(ctx); err != nil {
+			data.Close()
+			return nil, err
+		}
+		data = tconn
+	}
+	return data, nil
+}
+
+// Download an ftp:// or ftps:// target: each connection logs in and
+// retrieves its share of the file from a REST offset
+func (c *Client) runFTP(ctx context.Context, opts Options) (*Result, error) {
+	if opts.Upload && (opts.UploadTarget == "" || isFTP(opts.UploadTarget)) {
+		return nil, errors.New("FTP uploads are not supported, give an HTTP upload target")
+	}
+	u, err := url.Parse(opts.Target)
+	if err != nil {
+		return nil, err
+	}
+	if strings.ContainsAny(u.Path, "\r\n") {
+		return nil, errors.New("invalid FTP path")
+	}
+	if opts.SHA256 != "" || opts.OutputFile != "" {
+		c.log().Warn("SHA-256 and output file are not supported over FTP")
+	}
+	dial, cfg := c.ftpDialer(u)
+
+	f, err := c.ftpLogin(ctx, u, dial, cfg)
+	if err != nil {
+		return nil, err
+	}
+	size := f.size(u.Path)
+	f.close()
+	c.log().Debug("target probed", "url", opts.Target, "size", size)
+	// Without a known size a single connection streams the file
+	if size < 0 {
+		opts.Concurrent = 1
+		if opts.Duration <= 0 {
+			opts.Duration = backendDuration
+		}
+	}
+
+	// HTTP probes can't reach an FTP server, TCP connect ones can
+	var lat *LatencyResult
+	if opts.LatencyProbes > 0 {
+		if probeMethod(opts.LatencyMethod) == ProbeHTTP {
+			opts.LatencyMethod = ProbeTCP
+		}
+		if lat, err = c.latency(ctx, opts); err != nil {
+			return nil, err
+		}
+	}
+
+	concurrent := int64(opts.Concurrent)
+	downloadPart := func(ctx context.Context, part int, counter *stats.Counter) error {
+		p := int64(part)
+		offset, length := p*size/concurrent, (p+1)*size/concurrent-p*size/concurrent
+		conn, err := c.ftpLogin(ctx, u, dial, cfg)
+		if err != nil {
+			return err
+		}
+		defer conn.close()
+		data, err := conn.retr(ctx, u.Path, offset)
+		if err != nil {
+			return fmt.Errorf("failed to download part %d: %w", part, err)
+		}
+		defer data.Close()
+		var r io.Reader = data
+		if size >= 0 {
+			r = io.LimitReader(data, length)
+		}
+		return readBody(&bodyWriter{counter: counter}, r, opts.ReadBuffer)
+	}
+	t := runTransfer(ctx, opts, dirDownload, size, downloadPart)
+
+	res := t.downloadResult(opts, opts.Target, max(size, 0))
+	res.Latency = lat
+	if opts.Upload && ctx.Err() == nil {
+		if opts.UploadSize == 0 {
+			opts.UploadSize = size
+		}
+		if res.Upload, err = c.upload(ctx, opts); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
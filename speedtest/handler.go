@@ -0,0 +1,93 @@
+package speedtest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Default size of the file served by Handler
+const DefaultServeSize = 1024 * 1024 * 1024
+
+// randomFile is a seekable file of size bytes made of a random block
+// repeated over and over
+type randomFile struct {
+	block  []byte
+	size   int64
+	offset int64
+}
+
+func (f *randomFile) Read(b []byte) (int, error) {
+	if f.offset >= f.size {
+		return 0, io.EOF
+	}
+	if int64(len(b)) > f.size-f.offset {
+		b = b[:f.size-f.offset]
+	}
+	n := 0
+	for n < len(b) {
+		n += copy(b[n:], f.block[(f.offset+int64(n))%int64(len(f.block)):])
+	}
+	f.offset += int64(n)
+	return n, nil
+}
+
+func (f *randomFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.size
+	}
+	if offset < 0 {
+		return 0, errors.New("negative offset")
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+// Handler returns the HTTP handler of the built-in test server:
+//
+//	/download  random data of size bytes (or ?size=N), with Range support
+//	/upload    discards the request body
+//	/ping      empty answer for latency probes
+//	/ws        WebSocket test endpoint, see WSHandler
+func Handler(size int64) http.Handler {
+	if size <= 0 {
+		size = DefaultServeSize
+	}
+	block := randomBlock()
+	modTime := time.Now()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/download", func(w http.ResponseWriter, r *http.Request) {
+		n := size
+		if s := r.URL.Query().Get("size"); s != "" {
+			v, err := strconv.ParseInt(s, 10, 64)
+			if err != nil || v <= 0 {
+				http.Error(w, "invalid size", http.StatusBadRequest)
+				return
+			}
+			n = v
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		http.ServeContent(w, r, "", modTime, &randomFile{block: block, size: n})
+	})
+	mux.HandleFunc("/upload", func(w http.ResponseWriter, r *http.Request) {
+		n, err := io.Copy(io.Discard, r.Body)
+		if err != nil {
+			return
+		}
+		fmt.Fprintf(w, "%d\n", n)
+	})
+	mux.Handle("/ws", WSHandler())
+	mux.HandleFunc("/ping", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusNoContent)
+	})
+	return mux
+}
@@ -0,0 +1,73 @@
+package speedtest
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ofauchon/go-speedtest/internal/stats"
+)
+
+// Interval holds the bytes transferred during one reporting interval
+type Interval struct {
+	// Offsets from the start of the test
+	Start time.Duration `json:"start_ns"`
+	End   time.Duration `json:"end_ns"`
+
+	// Bytes transferred during the interval, total and per connection
+	Bytes int64   `json:"bytes"`
+	Parts []int64 `json:"parts"`
+
+	// The interval is part of the warm-up, not counted in the results
+	Omitted bool `json:"omitted,omitempty"`
+}
+
+// BytesPerSecond returns the throughput during the interval
+func (iv Interval) BytesPerSecond() float64 {
+	return float64(iv.Bytes) / (iv.End - iv.Start).Seconds()
+}
+
+// Record the counter deltas between successive samples
+type intervalSampler struct {
+	dir       direction
+	omit      time.Duration
+	last      stats.Snapshot
+	intervals []Interval
+	output    io.Writer
+	units     Units
+}
+
+func newIntervalSampler(dir direction, omit time.Duration, output io.Writer, units Units) *intervalSampler {
+	return &intervalSampler{dir: dir, omit: omit, output: output, units: units}
+}
+
+// Close the current interval with the given counters snapshot
+func (s *intervalSampler) sample(snap stats.Snapshot) {
+	d := snap.Sub(s.last)
+	iv := Interval{Start: s.last.At, End: snap.At, Bytes: d.Total, Parts: d.Parts, Omitted: s.last.At < s.omit}
+	s.last = snap
+	s.intervals = append(s.intervals, iv)
+	if s.output != nil {
+		printInterval(s.output, s.dir, iv, s.units)
+	}
+}
+
+// Function to print the report lines of an interval, one per connection
+// followed by the total when there are several connections
+func printInterval(w io.Writer, dir direction, iv Interval, units Units) {
+	seconds := (iv.End - iv.Start).Seconds()
+	omitted := ""
+	if iv.Omitted {
+		omitted = "  (omitted)"
+	}
+	line := func(label string, bytes int64) {
+		fmt.Fprintf(w, "[%3s] %-8s %6.2f-%-6.2f sec %12d bytes %16s%s\n", label, dir,
+			iv.Start.Seconds(), iv.End.Seconds(), bytes, units.Short(float64(bytes)/seconds), omitted)
+	}
+	if len(iv.Parts) > 1 {
+		for i, b := range iv.Parts {
+			line(fmt.Sprint(i), b)
+		}
+	}
+	line("SUM", iv.Bytes)
+}
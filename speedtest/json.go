@@ -0,0 +1,96 @@
+package speedtest
+
+import "encoding/json"
+
+// Speeds derived from a transfer, added to the JSON documents
+type jsonSpeed struct {
+	ElapsedSeconds float64 `json:"elapsed_seconds"`
+	BytesPerSecond float64 `json:"bytes_per_second"`
+	BitsPerSecond  float64 `json:"bits_per_second"`
+}
+
+func newJSONSpeed(elapsed, bytesPerSecond float64) jsonSpeed {
+	return jsonSpeed{
+		ElapsedSeconds: elapsed,
+		BytesPerSecond: bytesPerSecond,
+		BitsPerSecond:  bytesPerSecond * 8,
+	}
+}
+
+// MarshalJSON adds computed speeds and the video streaming estimate to
+// the result fields
+func (r *Result) MarshalJSON() ([]byte, error) {
+	type result Result
+	return json.Marshal(struct {
+		*result
+		jsonSpeed
+		Video *VideoEstimate `json:"video,omitempty"`
+	}{(*result)(r), newJSONSpeed(r.Elapsed.Seconds(), r.BytesPerSecond()), r.Video()})
+}
+
+// MarshalJSON adds computed speeds to the upload result fields
+func (r *UploadResult) MarshalJSON() ([]byte, error) {
+	type result UploadResult
+	return json.Marshal(struct {
+		*result
+		jsonSpeed
+	}{(*result)(r), newJSONSpeed(r.Elapsed.Seconds(), r.BytesPerSecond())})
+}
+
+// MarshalJSON adds the speed to the connection fields
+func (c ConnStats) MarshalJSON() ([]byte, error) {
+	type conn ConnStats
+	return json.Marshal(struct {
+		conn
+		BytesPerSecond float64 `json:"bytes_per_second"`
+	}{conn(c), c.BytesPerSecond()})
+}
+
+// MarshalJSON adds the loss percentage to the latency fields
+func (r *LatencyResult) MarshalJSON() ([]byte, error) {
+	type result LatencyResult
+	return json.Marshal(struct {
+		*result
+		Loss float64 `json:"loss_percent"`
+	}{(*result)(r), r.Loss()})
+}
+
+// MarshalJSON adds the loss percentage and throughput to the UDP result
+func (r *UDPResult) MarshalJSON() ([]byte, error) {
+	type result UDPResult
+	return json.Marshal(struct {
+		*result
+		Loss float64 `json:"loss_percent"`
+		jsonSpeed
+	}{(*result)(r), r.Loss(), newJSONSpeed(r.Elapsed.Seconds(), r.BytesPerSecond())})
+}
+
+// MarshalJSON adds the call and gaming quality scores to the VoIP fields
+func (r *VoIPResult) MarshalJSON() ([]byte, error) {
+	type result VoIPResult
+	return json.Marshal(struct {
+		*result
+		RFactor float64 `json:"r_factor"`
+		MOS     float64 `json:"mos"`
+		Gaming  string  `json:"gaming"`
+	}{(*result)(r), r.RFactor(), r.MOS(), r.Gaming()})
+}
+
+// MarshalJSON adds the latency increase and grade to the bufferbloat fields
+func (r *BufferbloatResult) MarshalJSON() ([]byte, error) {
+	type result BufferbloatResult
+	return json.Marshal(struct {
+		*result
+		Increase int64  `json:"increase_ns"`
+		Grade    string `json:"grade"`
+	}{(*result)(r), int64(r.Increase()), r.Grade()})
+}
+
+// MarshalJSON adds the round trips per minute to the responsiveness fields
+func (r *ResponsivenessResult) MarshalJSON() ([]byte, error) {
+	type result ResponsivenessResult
+	return json.Marshal(struct {
+		*result
+		RPM float64 `json:"rpm"`
+	}{(*result)(r), r.RPM()})
+}
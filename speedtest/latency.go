@@ -0,0 +1,235 @@
+package speedtest
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"time"
+)
+
+// Latency probe methods
+const (
+	ProbeHTTP = "http"
+	ProbeTCP  = "tcp"
+	ProbeICMP = "icmp"
+)
+
+// LatencyResult holds the statistics of the latency phase
+type LatencyResult struct {
+	Method   string        `json:"method"`
+	Sent     int           `json:"sent"`
+	Received int           `json:"received"`
+	Min      time.Duration `json:"min_ns"`
+	Avg      time.Duration `json:"avg_ns"`
+	Max      time.Duration `json:"max_ns"`
+	Median   time.Duration `json:"median_ns"`
+	Jitter   time.Duration `json:"jitter_ns"`
+
+	// Round trip times of successful probes, in order
+	Samples []time.Duration `json:"samples_ns"`
+
+	// Time each sample was sent, for the probes under load
+	At []time.Time `json:"at,omitempty"`
+}
+
+// Loss returns the percentage of probes that got no answer
+func (r *LatencyResult) Loss() float64 {
+	if r.Sent == 0 {
+		return 0
+	}
+	return float64(r.Sent-r.Received) / float64(r.Sent) * 100
+}
+
+func (c *Client) latency(ctx context.Context, opts Options) (*LatencyResult, error) {
+	return c.Ping(ctx, opts.Target, opts.LatencyMethod, opts.LatencyProbes, 0, probeTimeout(opts), nil)
+}
+
+// Return a function sending one probe of the given method to target
+func (c *Client) prober(method, target string) (func(context.Context) (time.Duration, error), error) {
+	switch method {
+	case ProbeHTTP:
+		return func(ctx context.Context) (time.Duration, error) {
+			return c.httpProbe(ctx, target)
+		}, nil
+	case ProbeTCP:
+		addr, err := hostPort(target)
+		if err != nil {
+			return nil, err
+		}
+		dial := c.dialContext()
+		return func(ctx context.Context) (time.Duration, error) {
+			return tcpProbe(ctx, dial, addr)
+		}, nil
+	case ProbeICMP:
+		u, err := url.Parse(target)
+		if err != nil {
+			return nil, err
+		}
+		host := u.Hostname()
+		return func(ctx context.Context) (time.Duration, error) {
+			return icmpProbe(ctx, host)
+		}, nil
+	case ProbeUDP:
+		u, err := url.Parse(target)
+		if err != nil {
+			return nil, err
+		}
+		host := u.Hostname()
+		return func(ctx context.Context) (time.Duration, error) {
+			return udpProbe(ctx, host)
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown latency probe method %q", method)
+}
+
+// Maximum time to wait for a single probe answer
+func probeTimeout(opts Options) time.Duration {
+	if opts.LatencyTimeout <= 0 {
+		return 2 * time.Second
+	}
+	return opts.LatencyTimeout
+}
+
+// Run the latency phase with HTTP probes against url, as done by the
+// backends having a dedicated latency endpoint. Returns nil if disabled.
+func (c *Client) httpLatency(ctx context.Context, opts Options, url string) (*LatencyResult, error) {
+	if opts.LatencyProbes <= 0 {
+		return nil, nil
+	}
+	opts.Target = url
+	opts.LatencyMethod = ProbeHTTP
+	return c.latency(ctx, opts)
+}
+
+// Fill in min/avg/max/median and jitter from the samples
+func (r *LatencyResult) compute() {
+	if len(r.Samples) == 0 {
+		return
+	}
+	var sum, jitter time.Duration
+	for i, s := range r.Samples {
+		sum += s
+		if i > 0 {
+			d := s - r.Samples[i-1]
+			if d < 0 {
+				d = -d
+			}
+			jitter += d
+		}
+	}
+	r.Avg = sum / time.Duration(len(r.Samples))
+	if len(r.Samples) > 1 {
+		r.Jitter = jitter / time.Duration(len(r.Samples)-1)
+	}
+
+	sorted := append([]time.Duration(nil), r.Samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	r.Min = sorted[0]
+	r.Max = sorted[len(sorted)-1]
+	if n := len(sorted); n%2 == 1 {
+		r.Median = sorted[n/2]
+	} else {
+		r.Median = (sorted[n/2-1] + sorted[n/2]) / 2
+	}
+}
+
+// Time a HEAD request until response headers are received
+func (c *Client) httpProbe(ctx context.Context, target string) (time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, target, nil)
+	if err != nil {
+		return 0, err
+	}
+	start := time.Now()
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	rtt := time.Since(start)
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return rtt, nil
+}
+
+// Time a TCP three-way handshake
+func tcpProbe(ctx context.Context, dial func(context.Context, string, string) (net.Conn, error), addr string) (time.Duration, error) {
+	start := time.Now()
+	conn, err := dial(ctx, "tcp", addr)
+	if err != nil {
+		return 0, err
+	}
+	rtt := time.Since(start)
+	conn.Close()
+	return rtt, nil
+}
+
+// Time an ICMP echo request (needs raw socket privileges)
+func icmpProbe(ctx context.Context, host string) (time.Duration, error) {
+	ip, err := net.DefaultResolver.LookupIP(ctx, "ip4", host)
+	if err != nil {
+		return 0, err
+	}
+	conn, err := net.ListenPacket("ip4:icmp", "0.0.0.0")
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	id := uint16(os.Getpid())
+	seq := uint16(time.Now().UnixNano())
+	dst := &net.IPAddr{IP: ip[0]}
+	start := time.Now()
+	if _, err := conn.WriteTo(echoRequest(id, seq), dst); err != nil {
+		return 0, err
+	}
+	buf := make([]byte, 1500)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			return 0, err
+		}
+		// Echo reply matching our identifier and sequence
+		if n >= 8 && buf[0] == 0 &&
+			binary.BigEndian.Uint16(buf[4:]) == id &&
+			binary.BigEndian.Uint16(buf[6:]) == seq {
+			return time.Since(start), nil
+		}
+	}
+}
+
+func icmpChecksum(b []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(b); i += 2 {
+		sum += uint32(b[i])<<8 | uint32(b[i+1])
+	}
+	if len(b)%2 == 1 {
+		sum += uint32(b[len(b)-1]) << 8
+	}
+	sum = (sum >> 16) + (sum & 0xffff)
+	sum += sum >> 16
+	return ^uint16(sum)
+}
+
+// Return the host:port of a URL, using the scheme default port if needed
+func hostPort(target string) (string, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return "", err
+	}
+	port := u.Port()
+	if port == "" {
+		port = map[string]string{"https": "443", "ftp": "21", "ftps": "990"}[u.Scheme]
+		if port == "" {
+			port = "80"
+		}
+	}
+	return net.JoinHostPort(u.Hostname(), port), nil
+}
@@ -0,0 +1,150 @@
+package speedtest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Public list of LibreSpeed backends
+const LibreSpeedServersURL = "https://librespeed.org/backend-servers/servers.php"
+
+// Chunks asked to garbage.php, in MB
+const libreSpeedChunks = 100
+
+// Server entry of a LibreSpeed servers JSON list
+type libreSpeedEntry struct {
+	ID      json.Number `json:"id"`
+	Name    string      `json:"name"`
+	Server  string      `json:"server"`
+	DlURL   string      `json:"dlURL"`
+	UlURL   string      `json:"ulURL"`
+	PingURL string      `json:"pingURL"`
+	Sponsor string      `json:"sponsorName"`
+}
+
+// LibreSpeedServer is a LibreSpeed backend with its test endpoints
+type LibreSpeedServer struct {
+	Server
+	DownloadURL string
+	UploadURL   string
+	PingURL     string
+}
+
+// NewLibreSpeedServer returns a server using the default endpoint names
+// of a LibreSpeed backend installed at base
+func NewLibreSpeedServer(base string) LibreSpeedServer {
+	return libreSpeedEntry{Server: base}.server()
+}
+
+func (e libreSpeedEntry) server() LibreSpeedServer {
+	base := e.Server
+	// Lists use scheme relative URLs
+	if strings.HasPrefix(base, "//") {
+		base = "https:" + base
+	}
+	if !strings.HasSuffix(base, "/") {
+		base += "/"
+	}
+	endpoint := func(path, def string) string {
+		if path == "" {
+			path = def
+		}
+		return base + strings.TrimPrefix(path, "/")
+	}
+	host := strings.TrimPrefix(strings.TrimPrefix(base, "https://"), "http://")
+	return LibreSpeedServer{
+		Server: Server{
+			ID:      e.ID.String(),
+			Name:    e.Name,
+			Sponsor: e.Sponsor,
+			Host:    host[:strings.Index(host, "/")],
+			URL:     base,
+		},
+		DownloadURL: endpoint(e.DlURL, "garbage.php"),
+		UploadURL:   endpoint(e.UlURL, "empty.php"),
+		PingURL:     endpoint(e.PingURL, "empty.php"),
+	}
+}
+
+// ParseLibreSpeedServers decodes a LibreSpeed servers JSON list
+func ParseLibreSpeedServers(data []byte) ([]LibreSpeedServer, error) {
+	var list []libreSpeedEntry
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("invalid server list: %w", err)
+	}
+	servers := make([]LibreSpeedServer, 0, len(list))
+	for _, e := range list {
+		servers = append(servers, e.server())
+	}
+	return servers, nil
+}
+
+// LibreSpeedServers fetches a LibreSpeed servers JSON list
+func (c *Client) LibreSpeedServers(ctx context.Context, listURL string) ([]LibreSpeedServer, error) {
+	body, err := c.getString(ctx, listURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get server list: %w", err)
+	}
+	return ParseLibreSpeedServers([]byte(body))
+}
+
+// RunLibreSpeed runs a test against the LibreSpeed backend answering the
+// fastest to ping probes. If servers is empty the public list is fetched.
+// Options.Target is ignored.
+func (c *Client) RunLibreSpeed(ctx context.Context, opts Options, servers []LibreSpeedServer) (*Result, error) {
+	if opts.Concurrent <= 0 {
+		opts.Concurrent = 1
+	}
+	if opts.Duration <= 0 {
+		opts.Duration = backendDuration
+	}
+
+	var err error
+	if len(servers) == 0 {
+		if servers, err = c.LibreSpeedServers(ctx, LibreSpeedServersURL); err != nil {
+			return nil, err
+		}
+	}
+
+	i, rtt, err := c.nearest(ctx, len(servers), func(i int) string {
+		return servers[i].PingURL
+	})
+	if err != nil {
+		return nil, err
+	}
+	server := servers[i]
+	server.Latency = rtt
+
+	lat, err := c.httpLatency(ctx, opts, server.PingURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var seq int64
+	cacheBuster := func(endpoint, query string) string {
+		sep := "?"
+		if strings.Contains(endpoint, "?") {
+			sep = "&"
+		}
+		return fmt.Sprintf("%s%s%sr=%d.%d", endpoint, sep, query, time.Now().UnixNano(), atomic.AddInt64(&seq, 1))
+	}
+
+	t := runTransfer(ctx, opts, dirDownload, 0, c.repeatGet(opts.ReadBuffer, func(int) string {
+		return cacheBuster(server.DownloadURL, fmt.Sprintf("ckSize=%d&", libreSpeedChunks))
+	}))
+	res := t.downloadResult(opts, server.DownloadURL, 0)
+	res.Server = &server.Server
+	res.Latency = lat
+
+	if opts.Upload && ctx.Err() == nil {
+		t := runTransfer(ctx, opts, dirUpload, 0, c.repeatPost(func(int) string {
+			return cacheBuster(server.UploadURL, "")
+		}, payloadBlockSize, payloadBlock(opts.UploadCompressibility)))
+		res.Upload = t.uploadResult(opts, server.UploadURL, 0)
+	}
+	return res, nil
+}
@@ -0,0 +1,60 @@
+package speedtest
+
+import (
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// LevelTrace is below slog.LevelDebug, it logs the headers of every request
+// and response when TransportOptions.DumpHeaders is set
+const LevelTrace = slog.LevelDebug - 4
+
+// Logger of the client, slog.Default if none was set
+func (c *Client) log() *slog.Logger {
+	if c.Logger != nil {
+		return c.Logger
+	}
+	return slog.Default()
+}
+
+// Log the headers of the requests sent through rt and of their responses
+type dumpTransport struct {
+	rt  http.RoundTripper
+	log func() *slog.Logger
+}
+
+func (t *dumpTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	log := t.log()
+	log.Log(req.Context(), LevelTrace, "request", "method", req.Method, "url", req.URL.String(), "headers", dumpHeader(req.Header))
+	resp, err := t.rt.RoundTrip(req)
+	if err != nil {
+		log.Log(req.Context(), LevelTrace, "request failed", "url", req.URL.String(), "err", err)
+		return nil, err
+	}
+	log.Log(req.Context(), LevelTrace, "response", "status", resp.Status, "proto", resp.Proto, "headers", dumpHeader(resp.Header))
+	return resp, nil
+}
+
+// Format headers on one line, sorted by name, hiding the credentials
+func dumpHeader(h http.Header) string {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, k := range keys {
+		v := strings.Join(h[k], ", ")
+		switch k {
+		case "Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie":
+			v = "[redacted]"
+		}
+		if b.Len() > 0 {
+			b.WriteString("; ")
+		}
+		b.WriteString(k + ": " + v)
+	}
+	return b.String()
+}
@@ -0,0 +1,137 @@
+package speedtest
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Methodology decides when a download or upload phase has measured
+// enough. The end of the file, Options.Duration and the cancellation of
+// the test still end the phase first.
+type Methodology interface {
+	// Name recorded in the results, e.g. "stable"
+	Name() string
+
+	// Done is called after each throughput sample, true ending the phase
+	Done(m Measurement) bool
+}
+
+// Measurement is the state of a phase given to Methodology.Done, counting
+// from the end of the warm-up (Options.Omit)
+type Measurement struct {
+	Elapsed time.Duration
+	Bytes   int64
+
+	// Throughput of each Interval so far, in bytes/sec
+	Samples  []float64
+	Interval time.Duration
+}
+
+// FixedDuration ends the phase after a duration, like Options.Duration
+type FixedDuration time.Duration
+
+func (d FixedDuration) Name() string { return "duration" }
+
+func (d FixedDuration) Done(m Measurement) bool { return m.Elapsed >= time.Duration(d) }
+
+// FixedBytes ends the phase once this many bytes were moved
+type FixedBytes int64
+
+func (n FixedBytes) Name() string { return "bytes" }
+
+func (n FixedBytes) Done(m Measurement) bool { return m.Bytes >= int64(n) }
+
+// UntilStable ends the phase once the throughput settled, like Ookla's
+// tests do: the average rates of the last Windows windows of Window vary
+// by less than Threshold (their standard deviation over their mean)
+type UntilStable struct {
+	// Defaults to 1s
+	Window time.Duration
+
+	// Defaults to 4
+	Windows int
+
+	// Defaults to 0.05 (5%)
+	Threshold float64
+}
+
+func (u UntilStable) Name() string { return "stable" }
+
+func (u UntilStable) Done(m Measurement) bool {
+	window, windows, threshold := u.Window, u.Windows, u.Threshold
+	if window <= 0 {
+		window = time.Second
+	}
+	if windows <= 0 {
+		windows = 4
+	}
+	if threshold <= 0 {
+		threshold = 0.05
+	}
+	per := max(int(window/max(m.Interval, time.Millisecond)), 1)
+	if windows < 2 || len(m.Samples) < per*windows {
+		return false
+	}
+	samples := m.Samples[len(m.Samples)-per*windows:]
+	rates := make([]float64, windows)
+	var mean float64
+	for i := range rates {
+		for _, s := range samples[i*per : (i+1)*per] {
+			rates[i] += s / float64(per)
+		}
+		mean += rates[i] / float64(windows)
+	}
+	if mean <= 0 {
+		return false
+	}
+	var variance float64
+	for _, r := range rates {
+		variance += (r - mean) * (r - mean) / float64(windows)
+	}
+	return math.Sqrt(variance)/mean < threshold
+}
+
+// ParseMethodology reads a methodology: duration:D (e.g. duration:15s),
+// bytes:N (e.g. bytes:100000000), or stable[:threshold%[:windows]] (e.g.
+// stable or stable:3:5). An empty spec returns nil, the default of
+// transferring the file or for Options.Duration.
+func ParseMethodology(spec string) (Methodology, error) {
+	name, arg, _ := strings.Cut(strings.TrimSpace(spec), ":")
+	switch name {
+	case "":
+		return nil, nil
+	case "duration":
+		d, err := time.ParseDuration(arg)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid methodology %q, expected duration:D like duration:15s", spec)
+		}
+		return FixedDuration(d), nil
+	case "bytes":
+		n, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid methodology %q, expected bytes:N like bytes:100000000", spec)
+		}
+		return FixedBytes(n), nil
+	case "stable":
+		var u UntilStable
+		if arg == "" {
+			return u, nil
+		}
+		threshold, windows, _ := strings.Cut(arg, ":")
+		pct, err := strconv.ParseFloat(strings.TrimSuffix(threshold, "%"), 64)
+		if err != nil || pct <= 0 {
+			return nil, fmt.Errorf("invalid methodology %q, expected stable[:threshold%%[:windows]] like stable:3:5", spec)
+		}
+		u.Threshold = pct / 100
+		if windows != "" {
+			if u.Windows, err = strconv.Atoi(windows); err != nil || u.Windows < 2 {
+				return nil, fmt.Errorf("invalid methodology %q: at least 2 windows are compared", spec)
+			}
+		}
+		return u, nil
+	}
+	return nil, fmt.Errorf("unknown methodology %q, expected duration, bytes or stable", name)
+}
@@ -0,0 +1,107 @@
+package speedtest
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseMethodology(t *testing.T) {
+	tests := []struct {
+		spec string
+		want Methodology
+	}{
+		{"", nil},
+		{"  ", nil},
+		{"duration:15s", FixedDuration(15 * time.Second)},
+		{"bytes:100000000", FixedBytes(100000000)},
+		{"stable", UntilStable{}},
+		{"stable:", UntilStable{}},
+		{"stable:3", UntilStable{Threshold: 0.03}},
+		{"stable:2.5%", UntilStable{Threshold: 0.025}},
+		{"stable:3:5", UntilStable{Threshold: 0.03, Windows: 5}},
+	}
+	for _, tt := range tests {
+		got, err := ParseMethodology(tt.spec)
+		if err != nil {
+			t.Errorf("ParseMethodology(%q): %v", tt.spec, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseMethodology(%q) = %#v, want %#v", tt.spec, got, tt.want)
+		}
+	}
+
+	for _, spec := range []string{
+		"forever",
+		"duration",
+		"duration:0s",
+		"duration:-5s",
+		"duration:fast",
+		"bytes:",
+		"bytes:0",
+		"bytes:1e9",
+		"stable:0",
+		"stable:x",
+		"stable:3:1",
+		"stable:3:many",
+	} {
+		if got, err := ParseMethodology(spec); err == nil {
+			t.Errorf("ParseMethodology(%q) = %#v, want an error", spec, got)
+		}
+	}
+}
+
+func TestFixedMethodologies(t *testing.T) {
+	m := Measurement{Elapsed: 10 * time.Second, Bytes: 1000}
+	if !FixedDuration(10 * time.Second).Done(m) {
+		t.Error("FixedDuration(10s) not done after 10s")
+	}
+	if FixedDuration(11 * time.Second).Done(m) {
+		t.Error("FixedDuration(11s) done after 10s")
+	}
+	if !FixedBytes(1000).Done(m) {
+		t.Error("FixedBytes(1000) not done after 1000 bytes")
+	}
+	if FixedBytes(1001).Done(m) {
+		t.Error("FixedBytes(1001) done after 1000 bytes")
+	}
+}
+
+func TestUntilStable(t *testing.T) {
+	// Samples of 250ms: 4 per window of 1s
+	repeat := func(rate float64, n int) []float64 {
+		s := make([]float64, n)
+		for i := range s {
+			s[i] = rate
+		}
+		return s
+	}
+	ramp := append(append(repeat(10, 4), repeat(50, 4)...), repeat(100, 8)...)
+	tests := []struct {
+		name    string
+		u       UntilStable
+		samples []float64
+		want    bool
+	}{
+		{"no samples", UntilStable{}, nil, false},
+		{"fewer samples than the windows", UntilStable{}, repeat(100, 15), false},
+		{"flat", UntilStable{}, repeat(100, 16), true},
+		{"still ramping up", UntilStable{}, ramp, false},
+		{"settled after the ramp", UntilStable{}, append(ramp, repeat(100, 8)...), true},
+		// Windows of 100, 100, 100, 110: 4.2% deviation
+		{"under the threshold", UntilStable{}, append(repeat(100, 12), repeat(110, 4)...), true},
+		{"over a lower threshold", UntilStable{Threshold: 0.04}, append(repeat(100, 12), repeat(110, 4)...), false},
+		// Alternating samples average out within each window
+		{"noisy samples", UntilStable{}, []float64{90, 110, 90, 110, 90, 110, 90, 110, 90, 110, 90, 110, 90, 110, 90, 110}, true},
+		{"two longer windows", UntilStable{Window: 2 * time.Second, Windows: 2}, repeat(100, 16), true},
+		{"nothing moved", UntilStable{}, repeat(0, 16), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := Measurement{Samples: tt.samples, Interval: 250 * time.Millisecond}
+			if got := tt.u.Done(m); got != tt.want {
+				t.Errorf("Done(%v) = %v, want %v", tt.samples, got, tt.want)
+			}
+		})
+	}
+}
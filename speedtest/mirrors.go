@@ -0,0 +1,148 @@
+package speedtest
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Official mirror lists of the distributions
+const (
+	DebianMirrorsURL = "https://salsa.debian.org/mirror-team/masterlist/-/raw/master/Mirrors.masterlist"
+	UbuntuMirrorsURL = "http://mirrors.ubuntu.com/mirrors.txt"
+	ArchMirrorsURL   = "https://archlinux.org/mirrors/status/json/"
+)
+
+// Distributions with a known mirror list
+var Distros = []string{"debian", "ubuntu", "arch"}
+
+// Mirror is a distribution mirror, URL being the base of the archive
+type Mirror struct {
+	URL     string `json:"url"`
+	Country string `json:"country,omitempty"`
+}
+
+// ParseDebianMirrors reads the Debian masterlist, stanzas of Site,
+// Archive-http and Country fields
+func ParseDebianMirrors(data string) []Mirror {
+	var mirrors []Mirror
+	var site, path, country string
+	flush := func() {
+		if site != "" && path != "" {
+			mirrors = append(mirrors, Mirror{URL: "http://" + site + path, Country: country})
+		}
+		site, path, country = "", "", ""
+	}
+	sc := bufio.NewScanner(strings.NewReader(data))
+	for sc.Scan() {
+		line := sc.Text()
+		if strings.TrimSpace(line) == "" {
+			flush()
+			continue
+		}
+		k, v, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		v = strings.TrimSpace(v)
+		switch k {
+		case "Site":
+			site = v
+		case "Archive-http":
+			path = v
+		case "Country":
+			// Two letter code followed by the name
+			country, _, _ = strings.Cut(v, " ")
+		}
+	}
+	flush()
+	return mirrors
+}
+
+// ParseUbuntuMirrors reads a mirrors.ubuntu.com list, one URL per line
+func ParseUbuntuMirrors(data string) []Mirror {
+	var mirrors []Mirror
+	for _, line := range strings.Fields(data) {
+		if strings.HasPrefix(line, "http://") || strings.HasPrefix(line, "https://") {
+			mirrors = append(mirrors, Mirror{URL: line})
+		}
+	}
+	return mirrors
+}
+
+// ParseArchMirrors reads the Arch Linux mirror status, keeping the active
+// and fully synced HTTP mirrors, best score first
+func ParseArchMirrors(data string) ([]Mirror, error) {
+	var status struct {
+		URLs []struct {
+			URL         string   `json:"url"`
+			Protocol    string   `json:"protocol"`
+			CountryCode string   `json:"country_code"`
+			Active      bool     `json:"active"`
+			Completion  float64  `json:"completion_pct"`
+			Score       *float64 `json:"score"`
+		} `json:"urls"`
+	}
+	if err := json.Unmarshal([]byte(data), &status); err != nil {
+		return nil, fmt.Errorf("invalid mirror status: %w", err)
+	}
+	urls := status.URLs[:0]
+	for _, u := range status.URLs {
+		if u.Active && u.Completion == 1 && u.Score != nil && (u.Protocol == "https" || u.Protocol == "http") {
+			urls = append(urls, u)
+		}
+	}
+	// Lower scores are better
+	sort.SliceStable(urls, func(i, j int) bool { return *urls[i].Score < *urls[j].Score })
+	mirrors := make([]Mirror, 0, len(urls))
+	for _, u := range urls {
+		mirrors = append(mirrors, Mirror{URL: u.URL, Country: u.CountryCode})
+	}
+	return mirrors, nil
+}
+
+// Mirrors fetches the official mirror list of a distribution, restricted
+// to a country (two letter code) if not empty
+func (c *Client) Mirrors(ctx context.Context, distro, country string) ([]Mirror, error) {
+	country = strings.ToUpper(country)
+	listURL := map[string]string{"debian": DebianMirrorsURL, "ubuntu": UbuntuMirrorsURL, "arch": ArchMirrorsURL}[distro]
+	if listURL == "" {
+		return nil, fmt.Errorf("unknown distribution %q", distro)
+	}
+	// Ubuntu serves a list per country, the default one is geolocated
+	if distro == "ubuntu" && country != "" {
+		listURL = "http://mirrors.ubuntu.com/" + country + ".txt"
+	}
+	body, err := c.getString(ctx, listURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get mirror list: %w", err)
+	}
+
+	var mirrors []Mirror
+	switch distro {
+	case "debian":
+		mirrors = ParseDebianMirrors(body)
+	case "ubuntu":
+		mirrors = ParseUbuntuMirrors(body)
+	case "arch":
+		if mirrors, err = ParseArchMirrors(body); err != nil {
+			return nil, err
+		}
+	}
+	if country != "" && distro != "ubuntu" {
+		kept := mirrors[:0]
+		for _, m := range mirrors {
+			if m.Country == country {
+				kept = append(kept, m)
+			}
+		}
+		mirrors = kept
+	}
+	if len(mirrors) == 0 {
+		return nil, errNoServer
+	}
+	return mirrors, nil
+}
@@ -0,0 +1,93 @@
+package speedtest
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// Smallest MTU every IPv4 host must accept
+const minMTU = 576
+
+// Bytes of the IPv4 and ICMP or UDP headers in a probe
+const probeHeaders = 28
+
+// MTUOptions tunes the path MTU discovery
+type MTUOptions struct {
+	// Bounds of the search, IP header included (default to 576 and the
+	// MTU of the interface the probes leave from)
+	Min int
+	Max int
+
+	// Probes sent before a size is considered too large (defaults to 2)
+	Probes int
+
+	// Time to wait for the answer of each probe (defaults to 1s)
+	Timeout time.Duration
+}
+
+// MTUProbe is a packet size tried by the search
+type MTUProbe struct {
+	Size int  `json:"size"`
+	OK   bool `json:"ok"`
+}
+
+// MTUResult is the largest packet getting through the path to a host, and
+// whether the path tells about its limit as path MTU discovery needs
+type MTUResult struct {
+	Host string `json:"host"`
+	IP   string `json:"ip"`
+
+	// MTU of the interface the packets leave from
+	LinkMTU int `json:"link_mtu"`
+
+	// Largest packet, IP header included, which reached the host and got an
+	// answer with the Don't Fragment bit set
+	PathMTU int `json:"path_mtu"`
+
+	// Path MTU the kernel learned from ICMP Fragmentation Needed messages,
+	// 0 if none arrived
+	ReportedMTU int `json:"reported_mtu,omitempty"`
+
+	// Sizes tried, in order
+	Probes []MTUProbe `json:"probes"`
+}
+
+// MSS is the largest TCP segment fitting the path MTU
+func (r *MTUResult) MSS() int {
+	return max(r.PathMTU-40, 0)
+}
+
+// BlackHole reports packets larger than the path MTU being dropped
+// without the Fragmentation Needed message (or with a wrong MTU in it):
+// path MTU discovery then can't work, large TCP segments stalling until
+// the connections time out. Common on tunnels and VPNs filtering ICMP.
+func (r *MTUResult) BlackHole() bool {
+	limit := r.LinkMTU
+	if r.ReportedMTU > 0 {
+		limit = r.ReportedMTU
+	}
+	return r.PathMTU > 0 && r.PathMTU < limit
+}
+
+// DiscoverMTU searches the path MTU to host by bisection with ICMP echo
+// requests which must not be fragmented, then sends a UDP datagram as
+// large as the interface allows to see whether the path reports its MTU.
+// It only supports IPv4 on Linux, and needs raw socket privileges unless
+// net.ipv4.ping_group_range allows unprivileged ICMP.
+func DiscoverMTU(ctx context.Context, host string, o MTUOptions) (*MTUResult, error) {
+	if o.Min <= 0 {
+		o.Min = minMTU
+	}
+	if o.Probes <= 0 {
+		o.Probes = 2
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = time.Second
+	}
+	ips, err := net.DefaultResolver.LookupIP(ctx, "ip4", host)
+	if err != nil {
+		return nil, err
+	}
+	return discoverMTU(ctx, host, ips[0], o)
+}
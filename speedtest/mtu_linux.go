@@ -0,0 +1,230 @@
+package speedtest
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// Largest IPv4 packet
+const maxIPPacket = 65535
+
+func discoverMTU(ctx context.Context, host string, ip net.IP, o MTUOptions) (*MTUResult, error) {
+	res := &MTUResult{Host: host, IP: ip.String()}
+	link, err := linkMTU(ip)
+	if err != nil {
+		return nil, err
+	}
+	res.LinkMTU = link
+	if o.Max <= 0 || o.Max > link {
+		o.Max = link
+	}
+	if o.Min > o.Max {
+		return nil, fmt.Errorf("minimum MTU %d above the maximum %d", o.Min, o.Max)
+	}
+
+	p, err := newMTUProber(ip, o)
+	if err != nil {
+		return nil, err
+	}
+	defer p.close()
+	try := func(size int) (bool, error) {
+		ok, err := p.fits(ctx, size)
+		if err == nil {
+			res.Probes = append(res.Probes, MTUProbe{Size: size, OK: ok})
+		}
+		return ok, err
+	}
+
+	// The largest size first, most paths carrying it
+	ok, err := try(o.Max)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		res.PathMTU = o.Max
+	} else {
+		if ok, err = try(o.Min); err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, fmt.Errorf("no echo reply from %s even at %d bytes, ICMP may be filtered", ip, o.Min)
+		}
+		lo, hi := o.Min, o.Max
+		for hi-lo > 1 && ctx.Err() == nil {
+			mid := (lo + hi) / 2
+			if ok, err = try(mid); err != nil {
+				return nil, err
+			}
+			if ok {
+				lo = mid
+			} else {
+				hi = mid
+			}
+		}
+		res.PathMTU = lo
+	}
+
+	if res.ReportedMTU, err = learnedMTU(ctx, ip, link, o.Timeout); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// MTU of the interface routing to ip
+func linkMTU(ip net.IP) (int, error) {
+	conn, err := net.DialUDP("udp4", nil, &net.UDPAddr{IP: ip, Port: 33434})
+	if err != nil {
+		return 0, err
+	}
+	local := conn.LocalAddr().(*net.UDPAddr).IP
+	conn.Close()
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return 0, err
+	}
+	for _, iface := range ifaces {
+		addrs, _ := iface.Addrs()
+		for _, a := range addrs {
+			if n, ok := a.(*net.IPNet); ok && n.IP.Equal(local) {
+				return min(iface.MTU, maxIPPacket), nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("no interface has the address %s routing to %s", local, ip)
+}
+
+// Open an ICMP socket sending echo requests with the Don't Fragment bit
+// set, ignoring the path MTU the kernel learned: an unprivileged one if
+// allowed, else a raw one
+func listenDF() (net.PacketConn, bool, error) {
+	var errs []error
+	for _, typ := range []int{unix.SOCK_DGRAM, unix.SOCK_RAW} {
+		fd, err := unix.Socket(unix.AF_INET, typ|unix.SOCK_CLOEXEC, unix.IPPROTO_ICMP)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := unix.SetsockoptInt(fd, unix.IPPROTO_IP, unix.IP_MTU_DISCOVER, unix.IP_PMTUDISC_PROBE); err != nil {
+			unix.Close(fd)
+			return nil, false, err
+		}
+		f := os.NewFile(uintptr(fd), "icmp")
+		conn, err := net.FilePacketConn(f)
+		f.Close()
+		return conn, typ == unix.SOCK_DGRAM, err
+	}
+	return nil, false, fmt.Errorf("failed to open an ICMP socket: %w", errors.Join(errs...))
+}
+
+// Sends echo requests of a given size to the host
+type mtuProber struct {
+	conn    net.PacketConn
+	dgram   bool
+	dst     net.Addr
+	id, seq uint16
+	probes  int
+	timeout time.Duration
+	buf     []byte
+}
+
+func newMTUProber(ip net.IP, o MTUOptions) (*mtuProber, error) {
+	conn, dgram, err := listenDF()
+	if err != nil {
+		return nil, err
+	}
+	p := &mtuProber{conn: conn, dgram: dgram, id: uint16(os.Getpid()), probes: o.Probes, timeout: o.Timeout, buf: make([]byte, maxIPPacket)}
+	if dgram {
+		p.dst = &net.UDPAddr{IP: ip}
+	} else {
+		p.dst = &net.IPAddr{IP: ip}
+	}
+	return p, nil
+}
+
+func (p *mtuProber) close() { p.conn.Close() }
+
+// Whether a packet of size bytes, IP header included, gets an answer
+func (p *mtuProber) fits(ctx context.Context, size int) (bool, error) {
+	for i := 0; i < p.probes && ctx.Err() == nil; i++ {
+		p.seq++
+		msg := make([]byte, size-20)
+		msg[0] = 8
+		binary.BigEndian.PutUint16(msg[4:], p.id)
+		binary.BigEndian.PutUint16(msg[6:], p.seq)
+		binary.BigEndian.PutUint16(msg[2:], icmpChecksum(msg))
+		if _, err := p.conn.WriteTo(msg, p.dst); err != nil {
+			// Larger than the interface or the local route allows
+			if errors.Is(err, unix.EMSGSIZE) {
+				return false, nil
+			}
+			return false, err
+		}
+
+		deadline := time.Now().Add(p.timeout)
+		if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+			deadline = d
+		}
+		p.conn.SetReadDeadline(deadline)
+		for {
+			n, _, err := p.conn.ReadFrom(p.buf)
+			if err != nil {
+				var ne net.Error
+				if errors.As(err, &ne) && ne.Timeout() {
+					break
+				}
+				return false, err
+			}
+			// The kernel sets the identifier of unprivileged sockets
+			if n >= 8 && p.buf[0] == 0 && binary.BigEndian.Uint16(p.buf[6:]) == p.seq &&
+				(p.dgram || binary.BigEndian.Uint16(p.buf[4:]) == p.id) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// Send a UDP datagram of size bytes with the Don't Fragment bit to ip,
+// and return the path MTU the kernel learned from the Fragmentation Needed
+// message the path answers with, 0 if none came
+func learnedMTU(ctx context.Context, ip net.IP, size int, timeout time.Duration) (int, error) {
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return 0, err
+	}
+	defer unix.Close(fd)
+	if err := unix.SetsockoptInt(fd, unix.IPPROTO_IP, unix.IP_MTU_DISCOVER, unix.IP_PMTUDISC_DO); err != nil {
+		return 0, err
+	}
+	sa := &unix.SockaddrInet4{Port: 33434}
+	copy(sa.Addr[:], ip.To4())
+	if err := unix.Connect(fd, sa); err != nil {
+		return 0, err
+	}
+	// A second datagram once the message had time to arrive, the errors
+	// (too large, port unreachable) being expected
+	payload := make([]byte, size-probeHeaders)
+	for i := 0; i < 2; i++ {
+		unix.Write(fd, payload)
+		select {
+		case <-time.After(timeout):
+		case <-ctx.Done():
+			return 0, nil
+		}
+	}
+	mtu, err := unix.GetsockoptInt(fd, unix.IPPROTO_IP, unix.IP_MTU)
+	if err != nil {
+		return 0, err
+	}
+	if mtu >= size {
+		return 0, nil
+	}
+	return mtu, nil
+}
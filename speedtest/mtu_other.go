@@ -0,0 +1,15 @@
+//go:build !linux
+
+package speedtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+)
+
+// The Don't Fragment bit and the learned path MTU are only handled on Linux
+func discoverMTU(context.Context, string, net.IP, MTUOptions) (*MTUResult, error) {
+	return nil, fmt.Errorf("path MTU discovery: %w", errors.ErrUnsupported)
+}
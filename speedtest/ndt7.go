@@ -0,0 +1,231 @@
+package speedtest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/ofauchon/go-speedtest/internal/stats"
+)
+
+const (
+	ndt7LocateURL    = "https://locate.measurementlab.net/v2/nearest/ndt/ndt7"
+	ndt7Protocol     = "net.measurementlab.ndt.v7"
+	ndt7DownloadPath = "wss:///ndt/v7/download"
+	ndt7UploadPath   = "wss:///ndt/v7/upload"
+
+	// Upload phase length defined by the protocol, and the hard limit of
+	// a whole phase
+	ndt7UploadTime = 10 * time.Second
+	ndt7MaxTime    = 15 * time.Second
+
+	// Upload message sizes, grown as the test goes
+	ndt7MinMessage = 1 << 13
+	ndt7MaxMessage = 1 << 24
+)
+
+// Answer of the M-Lab locate API
+type ndt7Locate struct {
+	Results []struct {
+		Machine  string `json:"machine"`
+		Location struct {
+			City    string `json:"city"`
+			Country string `json:"country"`
+		} `json:"location"`
+		URLs map[string]string `json:"urls"`
+	} `json:"results"`
+}
+
+// Measurement sent by the server as text messages
+type ndt7Measurement struct {
+	TCPInfo *struct {
+		RTT    int64 `json:"RTT"`
+		MinRTT int64 `json:"MinRTT"`
+	} `json:"TCPInfo"`
+}
+
+// NDT7Server is an M-Lab server with the signed URLs of both test
+// directions
+type NDT7Server struct {
+	Server
+	DownloadURL string
+	UploadURL   string
+}
+
+// NDT7Servers asks the M-Lab locate API for the nearest NDT7 servers
+func (c *Client) NDT7Servers(ctx context.Context) ([]NDT7Server, error) {
+	body, err := c.getString(ctx, ndt7LocateURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to locate server: %w", err)
+	}
+	var loc ndt7Locate
+	if err := json.Unmarshal([]byte(body), &loc); err != nil {
+		return nil, fmt.Errorf("invalid locate answer: %w", err)
+	}
+	var servers []NDT7Server
+	for _, r := range loc.Results {
+		servers = append(servers, NDT7Server{
+			Server: Server{
+				Name:    r.Location.City,
+				Country: r.Location.Country,
+				Host:    r.Machine,
+				URL:     r.URLs[ndt7DownloadPath],
+			},
+			DownloadURL: r.URLs[ndt7DownloadPath],
+			UploadURL:   r.URLs[ndt7UploadPath],
+		})
+	}
+	if len(servers) == 0 {
+		return nil, errNoServer
+	}
+	return servers, nil
+}
+
+// Open a WebSocket with dialer, reusing the transport settings of the
+// client
+func (c *Client) wsDial(ctx context.Context, dialer websocket.Dialer, target string) (*websocket.Conn, error) {
+	rt, header := c.transport()
+	if tr, ok := rt.(*http.Transport); ok {
+		dialer.Proxy = tr.Proxy
+		dialer.TLSClientConfig = tr.TLSClientConfig
+		dialer.NetDialContext = tr.DialContext
+	}
+	conn, _, err := dialer.DialContext(ctx, target, header)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", target, err)
+	}
+	// Unblock reads and writes when the test ends
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+	return conn, nil
+}
+
+// Open an NDT7 WebSocket
+func (c *Client) ndt7Dial(ctx context.Context, target string) (*websocket.Conn, error) {
+	return c.wsDial(ctx, websocket.Dialer{
+		Subprotocols:     []string{ndt7Protocol},
+		HandshakeTimeout: 10 * time.Second,
+		ReadBufferSize:   ndt7MaxMessage,
+	}, target)
+}
+
+// Keep the round trip times reported by the server
+func (m *ndt7Measurement) record(samples *[]time.Duration) {
+	if m.TCPInfo != nil && m.TCPInfo.RTT > 0 {
+		*samples = append(*samples, time.Duration(m.TCPInfo.RTT)*time.Microsecond)
+	}
+}
+
+// RunNDT7 runs the M-Lab NDT7 download and upload tests. Each direction
+// uses a single WebSocket connection as required by the protocol, so
+// Options.Concurrent is ignored. If server is nil the locate API is used.
+// Latency figures are the TCP round trip times measured by the server.
+func (c *Client) RunNDT7(ctx context.Context, opts Options, server *NDT7Server) (*Result, error) {
+	if server == nil {
+		servers, err := c.NDT7Servers(ctx)
+		if err != nil {
+			return nil, err
+		}
+		server = &servers[0]
+	}
+	opts.Concurrent = 1
+	if opts.Duration <= 0 || opts.Duration > ndt7MaxTime {
+		opts.Duration = ndt7MaxTime
+	}
+
+	var rtts []time.Duration
+
+	// The server streams binary data and sends its measurements as text
+	// messages until it closes the connection
+	t := runTransfer(ctx, opts, dirDownload, 0, func(ctx context.Context, part int, counter *stats.Counter) error {
+		conn, err := c.ndt7Dial(ctx, server.DownloadURL)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		for {
+			kind, msg, err := conn.ReadMessage()
+			if err != nil {
+				if websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+					return nil
+				}
+				return fmt.Errorf("error reading data: %w", err)
+			}
+			counter.Add(int64(len(msg)))
+			if kind == websocket.TextMessage {
+				var m ndt7Measurement
+				if json.Unmarshal(msg, &m) == nil {
+					m.record(&rtts)
+				}
+			}
+		}
+	})
+	res := t.downloadResult(opts, server.DownloadURL, 0)
+	res.Server = &server.Server
+
+	if opts.Upload && ctx.Err() == nil {
+		uopts := opts
+		if uopts.Duration > ndt7UploadTime {
+			uopts.Duration = ndt7UploadTime
+		}
+		t := runTransfer(ctx, uopts, dirUpload, 0, func(ctx context.Context, part int, counter *stats.Counter) error {
+			conn, err := c.ndt7Dial(ctx, server.UploadURL)
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+
+			// Drain the server measurements
+			readDone := make(chan struct{})
+			go func() {
+				defer close(readDone)
+				for {
+					kind, msg, err := conn.ReadMessage()
+					if err != nil {
+						return
+					}
+					if kind == websocket.TextMessage {
+						var m ndt7Measurement
+						if json.Unmarshal(msg, &m) == nil {
+							m.record(&rtts)
+						}
+					}
+				}
+			}()
+
+			block := make([]byte, ndt7MaxMessage)
+			random := payloadBlock(opts.UploadCompressibility)
+			for i := 0; i < len(block); i += len(random) {
+				copy(block[i:], random)
+			}
+			size := ndt7MinMessage
+			var total int64
+			for ctx.Err() == nil {
+				if err := conn.WriteMessage(websocket.BinaryMessage, block[:size]); err != nil {
+					break
+				}
+				total += int64(size)
+				counter.Add(int64(size))
+				// Grow messages once enough were sent at the current size
+				if size < ndt7MaxMessage && total >= 16*int64(size) {
+					size *= 2
+				}
+			}
+			conn.Close()
+			<-readDone
+			return nil
+		})
+		res.Upload = t.uploadResult(uopts, server.UploadURL, 0)
+	}
+
+	if opts.LatencyProbes > 0 && len(rtts) > 0 {
+		res.Latency = &LatencyResult{Method: "ndt7", Sent: len(rtts), Received: len(rtts), Samples: rtts}
+		res.Latency.compute()
+	}
+	return res, nil
+}
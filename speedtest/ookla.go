@@ -0,0 +1,114 @@
+package speedtest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Public list of Speedtest.net servers, sorted by distance to the client
+const ooklaServersURL = "https://www.speedtest.net/api/js/servers?engine=js&limit=10&https_functional=1"
+
+// Server entry of the Speedtest.net API
+type ooklaServer struct {
+	URL      string  `json:"url"`
+	Name     string  `json:"name"`
+	Country  string  `json:"country"`
+	Sponsor  string  `json:"sponsor"`
+	ID       string  `json:"id"`
+	Host     string  `json:"host"`
+	Distance float64 `json:"distance"`
+}
+
+// OoklaServers fetches the Speedtest.net servers closest to the client
+func (c *Client) OoklaServers(ctx context.Context) ([]Server, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ooklaServersURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get server list: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get server list: %s", resp.Status)
+	}
+
+	var list []ooklaServer
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("invalid server list: %w", err)
+	}
+	servers := make([]Server, 0, len(list))
+	for _, s := range list {
+		servers = append(servers, Server{
+			ID:       s.ID,
+			Name:     s.Name,
+			Sponsor:  s.Sponsor,
+			Country:  s.Country,
+			Host:     s.Host,
+			URL:      s.URL,
+			Distance: s.Distance,
+		})
+	}
+	return servers, nil
+}
+
+// Base URL of the server test files, the API gives the upload script URL
+func ooklaBase(s Server) string {
+	return s.URL[:strings.LastIndex(s.URL, "/")+1]
+}
+
+// RunOokla runs a latency, download and upload test against the nearest
+// Speedtest.net server. If servers is empty the public list is fetched.
+// Options.Target is ignored.
+func (c *Client) RunOokla(ctx context.Context, opts Options, servers []Server) (*Result, error) {
+	if opts.Concurrent <= 0 {
+		opts.Concurrent = 1
+	}
+	if opts.Duration <= 0 {
+		opts.Duration = backendDuration
+	}
+
+	var err error
+	if len(servers) == 0 {
+		if servers, err = c.OoklaServers(ctx); err != nil {
+			return nil, err
+		}
+	}
+	i, rtt, err := c.nearest(ctx, len(servers), func(i int) string {
+		return ooklaBase(servers[i]) + "latency.txt"
+	})
+	if err != nil {
+		return nil, err
+	}
+	server := &servers[i]
+	server.Latency = rtt
+	base := ooklaBase(*server)
+
+	lat, err := c.httpLatency(ctx, opts, base+"latency.txt")
+	if err != nil {
+		return nil, err
+	}
+
+	// Download generated images, adding a cache buster to each request
+	var seq int64
+	t := runTransfer(ctx, opts, dirDownload, 0, c.repeatGet(opts.ReadBuffer, func(part int) string {
+		return fmt.Sprintf("%srandom4000x4000.jpg?x=%d.%d", base, time.Now().UnixNano(), atomic.AddInt64(&seq, 1))
+	}))
+	res := t.downloadResult(opts, base, 0)
+	res.Server = server
+	res.Latency = lat
+
+	if opts.Upload && ctx.Err() == nil {
+		t := runTransfer(ctx, opts, dirUpload, 0, c.repeatPost(func(int) string {
+			return server.URL
+		}, payloadBlockSize, payloadBlock(opts.UploadCompressibility)))
+		res.Upload = t.uploadResult(opts, server.URL, 0)
+	}
+	return res, nil
+}
@@ -0,0 +1,160 @@
+package speedtest
+
+import (
+	"io"
+	"time"
+)
+
+// Options describes a single speed test run
+type Options struct {
+	// HTTP remote URL for speed testing
+	Target string
+
+	// Number of parallel downloads
+	Concurrent int
+
+	// Size of the ranges the connections pull from a shared queue, so
+	// a slow connection doesn't hold a large part back (defaults to 4MB)
+	ChunkSize int64
+
+	// Measure a single flow: one connection downloading the whole file
+	// without Range requests, Concurrent is ignored
+	Single bool
+
+	// Stop the download after this duration (0 means no limit)
+	Duration time.Duration
+
+	// Decides when the download and upload have measured enough, before
+	// the end of the file or Duration (see ParseMethodology)
+	Methodology Methodology
+
+	// Lengths of the idle, download, upload and cooldown phases, which
+	// run in that order
+	Phases PhaseDurations
+
+	// Warm-up period excluded from the results, like iperf --omit. It
+	// runs before Duration and still shows in the intervals.
+	Omit time.Duration
+
+	// Number of latency probes sent before the throughput test (0 disables)
+	LatencyProbes int
+
+	// Latency probe method: ProbeHTTP (default), ProbeTCP, ProbeICMP,
+	// ProbeUDP or ProbeAuto
+	LatencyMethod string
+
+	// Maximum time to wait for a single probe answer
+	LatencyTimeout time.Duration
+
+	// Keep probing the latency during the download and upload to measure
+	// bufferbloat
+	Bufferbloat bool
+
+	// URL probed under load (defaults to Target)
+	BufferbloatTarget string
+
+	// Identify the server and CDN edge answering Target
+	Edge bool
+
+	// Race the addresses of the Target host before the test, like Happy
+	// Eyeballs, timing the connection to each of them
+	Eyeballs bool
+
+	// Trace the hops to the Target host before the test (see TracePath)
+	Path bool
+
+	// Read the byte counters of the interface carrying the test before
+	// the download and after the upload, to compare them with the bytes
+	// transferred (the Omit warm-up included). Linux only, the other
+	// systems logging a warning.
+	Counters bool
+
+	// Measure the responsiveness (RPM) of Target during the download
+	Responsiveness bool
+
+	// Also run an upload test after the download
+	Upload bool
+
+	// Run the upload during the download instead of after it, to measure
+	// the link full-duplex. The latency is probed meanwhile, as with
+	// Bufferbloat. Upload is implied and the upload ends with the download.
+	Bidirectional bool
+
+	// Only run the latency and upload phases. The upload then lasts
+	// Duration unless UploadSize is set.
+	SkipDownload bool
+
+	// URL receiving uploaded data (defaults to Target)
+	UploadTarget string
+
+	// HTTP method used for uploads (defaults to POST)
+	UploadMethod string
+
+	// Total number of bytes to upload, split across connections. Negative
+	// means uploading until Duration elapses.
+	UploadSize int64
+
+	// Fraction (0 to 1) of the upload payload made of zeros, to see how
+	// compressing middleboxes affect the results. 0 sends random data.
+	UploadCompressibility float64
+
+	// Length of the throughput samples summarized in the result
+	// (defaults to 250ms)
+	SampleInterval time.Duration
+
+	// Expected hex SHA-256 of the file, checked if it is downloaded
+	// completely. Data buffered to restore the chunk order takes memory.
+	SHA256 string
+
+	// Write the downloaded file to this path. The chunks written are
+	// recorded in path.resume so that a later run downloads the others.
+	OutputFile string
+
+	// Download requests receiving no data for this long are sent again
+	// for the rest of their range, up to 3 times (defaults to 10s,
+	// negative disables)
+	StallTimeout time.Duration
+
+	// Times a failed download request (network error, 5xx) is sent again,
+	// waiting 250ms then twice as long each time (defaults to 3, negative
+	// disables)
+	Retries int
+
+	// Size in bytes of the buffers the downloads are read with, larger
+	// ones cost fewer system calls on fast links (defaults to 128KB)
+	ReadBuffer int
+
+	// Cap in bytes/sec of the traffic of all the connections together,
+	// both directions included, 0 for no limit
+	RateLimit float64
+
+	// Reporting interval of the throughput statistics (0 disables)
+	Interval time.Duration
+
+	// If set, a line is printed on this writer at each interval
+	IntervalOutput io.Writer
+
+	// If set, real-time progress bars are drawn on this writer
+	Progress io.Writer
+
+	// If set, called twice a second with the progress of the download and
+	// upload, from another goroutine. It is never called concurrently, even
+	// when Bidirectional runs both at once.
+	OnProgress func(Snapshot)
+
+	// If set, the phases, progress, connections and errors of the
+	// download and upload are sent on this channel as they happen. The
+	// events are dropped when the channel is full, so give it a buffer
+	// (64 events hold a few seconds). It is never closed.
+	Events chan<- Event
+
+	// Units of the rates printed on Progress and IntervalOutput
+	Units Units
+
+	// Upload with the S3 multipart API, set by RunS3
+	multipart bool
+
+	// Bucket enforcing RateLimit, shared by the transfers of a test so
+	// that the download and upload of Bidirectional split the rate
+	limiter *rateLimiter
+}
@@ -0,0 +1,200 @@
+package speedtest
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/net/ipv4"
+)
+
+// PathOptions tunes the path analysis
+type PathOptions struct {
+	// Highest TTL probed (defaults to 30)
+	MaxHops int
+
+	// Probes sent to each hop (defaults to 10)
+	Probes int
+
+	// Time to wait for the answers of a round of probes (defaults to 1s)
+	Timeout time.Duration
+
+	// Don't look up the names of the hops
+	NoReverseDNS bool
+}
+
+// PathResult holds the hops to a host, like mtr
+type PathResult struct {
+	Host string `json:"host"`
+	IP   string `json:"ip"`
+
+	// The host answered, the last hop is the host itself
+	Reached bool  `json:"reached"`
+	Hops    []Hop `json:"hops"`
+}
+
+// Hop is a router on the path, or the host itself
+type Hop struct {
+	TTL int `json:"ttl"`
+
+	// First address answering at this TTL, empty if none did
+	Addr string `json:"addr,omitempty"`
+	Name string `json:"name,omitempty"`
+
+	// Round trip times and loss of the probes
+	Latency *LatencyResult `json:"latency"`
+}
+
+// TracePath sends rounds of ICMP echo requests with increasing TTLs to
+// host and collects the answers of each hop: time exceeded from the
+// routers, echo replies from the host. It needs raw socket privileges and
+// only supports IPv4.
+func TracePath(ctx context.Context, host string, o PathOptions) (*PathResult, error) {
+	if o.MaxHops <= 0 {
+		o.MaxHops = 30
+	}
+	if o.Probes <= 0 {
+		o.Probes = 10
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = time.Second
+	}
+	ips, err := net.DefaultResolver.LookupIP(ctx, "ip4", host)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenPacket("ip4:icmp", "0.0.0.0")
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	pc := ipv4.NewPacketConn(conn)
+
+	res := &PathResult{Host: host, IP: ips[0].String()}
+	hops := make([]Hop, o.MaxHops)
+	for i := range hops {
+		hops[i] = Hop{TTL: i + 1, Latency: &LatencyResult{Method: ProbeICMP}}
+	}
+	dst := &net.IPAddr{IP: ips[0]}
+	id := uint16(os.Getpid())
+	last := o.MaxHops
+	buf := make([]byte, 1500)
+
+	for round := 0; round < o.Probes && ctx.Err() == nil; round++ {
+		// One probe per TTL, the sequence number tells the round and TTL
+		sent := map[uint16]time.Time{}
+		for ttl := 1; ttl <= last; ttl++ {
+			seq := uint16(round)<<8 | uint16(ttl)
+			if err := pc.SetTTL(ttl); err != nil {
+				return nil, err
+			}
+			sent[seq] = time.Now()
+			hops[ttl-1].Latency.Sent++
+			if _, err := conn.WriteTo(echoRequest(id, seq), dst); err != nil {
+				return nil, err
+			}
+		}
+
+		deadline := time.Now().Add(o.Timeout)
+		if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+			deadline = d
+		}
+		conn.SetReadDeadline(deadline)
+		for len(sent) > 0 {
+			n, from, err := conn.ReadFrom(buf)
+			if err != nil {
+				var ne net.Error
+				if errors.As(err, &ne) && ne.Timeout() {
+					break
+				}
+				return nil, err
+			}
+			reply, seq, ok := parseEcho(buf[:n], id)
+			if !ok {
+				continue
+			}
+			start, ok := sent[seq]
+			if !ok {
+				continue
+			}
+			delete(sent, seq)
+			ttl := int(seq & 0xff)
+			h := &hops[ttl-1]
+			h.Latency.Received++
+			h.Latency.Samples = append(h.Latency.Samples, time.Since(start))
+			if h.Addr == "" {
+				h.Addr = from.String()
+			}
+			// The host answered, the probes beyond its TTL are useless
+			if reply {
+				res.Reached = true
+				last = min(last, ttl)
+			}
+		}
+	}
+
+	// Drop the hops beyond the host, or the silent ones at the end
+	if !res.Reached {
+		for last > 0 && hops[last-1].Latency.Received == 0 {
+			last--
+		}
+	}
+	res.Hops = hops[:last]
+	for i := range res.Hops {
+		h := &res.Hops[i]
+		h.Latency.compute()
+		if h.Addr != "" && !o.NoReverseDNS {
+			lctx, cancel := context.WithTimeout(ctx, time.Second)
+			if names, err := net.DefaultResolver.LookupAddr(lctx, h.Addr); err == nil && len(names) > 0 {
+				h.Name = strings.TrimSuffix(names[0], ".")
+			}
+			cancel()
+		}
+	}
+	return res, nil
+}
+
+// Trace the path to the host of a target URL before a test
+func (c *Client) tracePath(ctx context.Context, target string) (*PathResult, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+	c.log().Debug("tracing path", "host", u.Hostname())
+	return TracePath(ctx, u.Hostname(), PathOptions{})
+}
+
+// Return the sequence number of the echo request an ICMP message answers,
+// and whether it is an echo reply rather than a time exceeded or
+// unreachable error quoting the request
+func parseEcho(msg []byte, id uint16) (bool, uint16, bool) {
+	if len(msg) < 8 {
+		return false, 0, false
+	}
+	reply := msg[0] == 0
+	switch msg[0] {
+	case 0:
+	case 3, 11:
+		// The error quotes the IP header and 8 bytes of the request
+		inner := msg[8:]
+		if len(inner) < 20 {
+			return false, 0, false
+		}
+		ihl := int(inner[0]&0x0f) * 4
+		if len(inner) < ihl+8 || inner[ihl] != 8 {
+			return false, 0, false
+		}
+		msg = inner[ihl:]
+	default:
+		return false, 0, false
+	}
+	if binary.BigEndian.Uint16(msg[4:]) != id {
+		return false, 0, false
+	}
+	return reply, binary.BigEndian.Uint16(msg[6:]), true
+}
@@ -0,0 +1,69 @@
+package speedtest
+
+import (
+	"context"
+	"io"
+	"math/rand"
+
+	"github.com/ofauchon/go-speedtest/internal/stats"
+)
+
+// Size of the block repeated to build upload payloads
+const payloadBlockSize = 1024 * 1024
+
+// Payload blocks are built of chunks small enough for the compressible
+// part to be seen by any compressor window
+const payloadChunkSize = 4096
+
+// payloadReader streams a block over and over and counts what was read.
+// It stops after remain bytes, or never if remain is negative, and when
+// ctx is done: the transports keep reading the bodies of cancelled
+// requests for a while, which would be counted past the end of the test.
+type payloadReader struct {
+	ctx     context.Context
+	block   []byte
+	offset  int
+	remain  int64
+	counter *stats.Counter
+}
+
+func (p *payloadReader) Read(b []byte) (int, error) {
+	if p.remain == 0 {
+		return 0, io.EOF
+	}
+	if err := p.ctx.Err(); err != nil {
+		return 0, err
+	}
+	if p.remain > 0 && int64(len(b)) > p.remain {
+		b = b[:p.remain]
+	}
+	n := copy(b, p.block[p.offset:])
+	p.offset = (p.offset + n) % len(p.block)
+	if p.remain > 0 {
+		p.remain -= int64(n)
+	}
+	p.counter.Add(int64(n))
+	return n, nil
+}
+
+func randomBlock() []byte {
+	return payloadBlock(0)
+}
+
+// Build a payload block where the given fraction (0 to 1) of each chunk
+// is made of zeros, the rest being random. 0 gives incompressible data.
+func payloadBlock(compressibility float64) []byte {
+	block := make([]byte, payloadBlockSize)
+	rand.Read(block)
+	if compressibility <= 0 {
+		return block
+	}
+	if compressibility > 1 {
+		compressibility = 1
+	}
+	zeros := int(compressibility * payloadChunkSize)
+	for i := 0; i < len(block); i += payloadChunkSize {
+		clear(block[i+payloadChunkSize-zeros : i+payloadChunkSize])
+	}
+	return block
+}
@@ -0,0 +1,116 @@
+package speedtest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Phases of Client.Run, in the order they run. Events of the Idle and
+// Cooldown phases carry these names, the transfers report theirs as
+// "Download" and "Upload".
+const (
+	PhaseIdle     = "Idle"
+	PhaseCooldown = "Cooldown"
+)
+
+// PhaseDurations sets the length of each phase of Client.Run, 0 keeping
+// the default
+type PhaseDurations struct {
+	// Probe the idle latency for this long instead of sending
+	// Options.LatencyProbes probes
+	Idle time.Duration
+
+	// Length of the download and upload, instead of Options.Duration
+	Download time.Duration
+	Upload   time.Duration
+
+	// Keep probing the latency for this long after the load, to see how
+	// fast the queues drain. Not run by default.
+	Cooldown time.Duration
+}
+
+// ParsePhaseDurations reads a comma separated list of phase=duration with
+// the phases idle, download, upload and cooldown, e.g.
+// "idle=5s,download=15s,upload=10s,cooldown=5s"
+func ParsePhaseDurations(spec string) (PhaseDurations, error) {
+	var p PhaseDurations
+	fields := map[string]*time.Duration{
+		"idle": &p.Idle, "download": &p.Download, "upload": &p.Upload, "cooldown": &p.Cooldown,
+	}
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, value, _ := strings.Cut(item, "=")
+		field, ok := fields[name]
+		if !ok {
+			return p, fmt.Errorf("unknown phase %q, expected idle, download, upload or cooldown", name)
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			return p, fmt.Errorf("invalid phase duration %q, expected phase=duration like download=15s", item)
+		}
+		*field = d
+	}
+	return p, nil
+}
+
+// Step of a test run by runPhases
+type testPhase struct {
+	name string
+	skip bool
+
+	// The phase sends its own start and end events (runTransfer)
+	events bool
+
+	run func(ctx context.Context) error
+}
+
+// Run the phases in order until one fails or ctx is cancelled, the phases
+// left being skipped then
+func (c *Client) runPhases(ctx context.Context, opts Options, phases []testPhase) error {
+	for _, p := range phases {
+		if p.skip {
+			continue
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+		c.log().Debug("phase started", "phase", p.name)
+		start := time.Now()
+		if !p.events {
+			opts.emit(Event{Type: EventPhaseStart, Phase: p.name, At: start})
+		}
+		if err := p.run(ctx); err != nil {
+			return err
+		}
+		if !p.events {
+			opts.emit(Event{Type: EventPhaseEnd, Phase: p.name})
+		}
+		c.log().Debug("phase done", "phase", p.name, "elapsed", time.Since(start))
+	}
+	return nil
+}
+
+// Options of a transfer phase lasting d, Options.Duration if 0
+func (opts Options) lasting(d time.Duration) Options {
+	if d > 0 {
+		opts.Duration = d
+	}
+	return opts
+}
+
+// Probe the latency of target for d, nothing loading the link
+func (c *Client) probeFor(ctx context.Context, opts Options, target string, d time.Duration) *LatencyResult {
+	return c.probeDuring(ctx, opts, target, func() {
+		t := time.NewTimer(d)
+		defer t.Stop()
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+		}
+	})
+}
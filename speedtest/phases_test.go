@@ -0,0 +1,43 @@
+package speedtest
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParsePhaseDurations(t *testing.T) {
+	tests := []struct {
+		spec string
+		want PhaseDurations
+	}{
+		{"", PhaseDurations{}},
+		{"idle=5s,download=15s,upload=10s,cooldown=5s", PhaseDurations{
+			Idle: 5 * time.Second, Download: 15 * time.Second, Upload: 10 * time.Second, Cooldown: 5 * time.Second,
+		}},
+		{"download=1m", PhaseDurations{Download: time.Minute}},
+		{" cooldown=500ms , idle=0s ,", PhaseDurations{Cooldown: 500 * time.Millisecond}},
+	}
+	for _, tt := range tests {
+		got, err := ParsePhaseDurations(tt.spec)
+		if err != nil {
+			t.Errorf("ParsePhaseDurations(%q): %v", tt.spec, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParsePhaseDurations(%q) = %+v, want %+v", tt.spec, got, tt.want)
+		}
+	}
+
+	for _, spec := range []string{
+		"warmup=5s",
+		"download",
+		"download=",
+		"download=15",
+		"download=-5s",
+		"upload=fast",
+	} {
+		if got, err := ParsePhaseDurations(spec); err == nil {
+			t.Errorf("ParsePhaseDurations(%q) = %+v, want an error", spec, got)
+		}
+	}
+}
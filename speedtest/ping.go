@@ -0,0 +1,125 @@
+package speedtest
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"golang.org/x/net/icmp"
+)
+
+// More latency probe methods: ICMP echo over an unprivileged datagram
+// socket, and the best method available
+const (
+	ProbeUDP  = "udp"
+	ProbeAuto = "auto"
+)
+
+// Return the probe method to use for the requested one: HTTP by default,
+// and for auto ICMP if raw sockets are allowed, else unprivileged ICMP if
+// the system allows it (net.ipv4.ping_group_range on Linux), else TCP
+func probeMethod(method string) string {
+	switch method {
+	case "":
+		return ProbeHTTP
+	case ProbeAuto:
+		if conn, err := net.ListenPacket("ip4:icmp", "0.0.0.0"); err == nil {
+			conn.Close()
+			return ProbeICMP
+		}
+		if conn, err := icmp.ListenPacket("udp4", "0.0.0.0"); err == nil {
+			conn.Close()
+			return ProbeUDP
+		}
+		return ProbeTCP
+	}
+	return method
+}
+
+// Ping sends count probes to target, interval apart, with the given
+// method and returns their statistics. onProbe, if set, is called after
+// each probe with its sequence number and round trip time or error.
+func (c *Client) Ping(ctx context.Context, target, method string, count int, interval, timeout time.Duration, onProbe func(seq int, rtt time.Duration, err error)) (*LatencyResult, error) {
+	method = probeMethod(method)
+	probe, err := c.prober(method, target)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &LatencyResult{Method: method}
+	for i := 0; i < count; i++ {
+		if i > 0 && interval > 0 {
+			select {
+			case <-time.After(interval):
+			case <-ctx.Done():
+			}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		res.Sent++
+		pctx, cancel := context.WithTimeout(ctx, timeout)
+		rtt, err := probe(pctx)
+		cancel()
+		if onProbe != nil {
+			onProbe(i+1, rtt, err)
+		}
+		if err != nil {
+			// A missing ICMP permission won't get better on the next probe
+			if errors.Is(err, os.ErrPermission) {
+				return nil, fmt.Errorf("%s probe: %w", method, err)
+			}
+			continue
+		}
+		res.Received++
+		res.Samples = append(res.Samples, rtt)
+	}
+	res.compute()
+	return res, nil
+}
+
+// Time an ICMP echo request sent over an unprivileged ICMP socket. The
+// kernel sets the identifier, replies are matched on the sequence.
+func udpProbe(ctx context.Context, host string) (time.Duration, error) {
+	ip, err := net.DefaultResolver.LookupIP(ctx, "ip4", host)
+	if err != nil {
+		return 0, err
+	}
+	conn, err := icmp.ListenPacket("udp4", "0.0.0.0")
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	seq := uint16(time.Now().UnixNano())
+	start := time.Now()
+	if _, err := conn.WriteTo(echoRequest(0, seq), &net.UDPAddr{IP: ip[0]}); err != nil {
+		return 0, err
+	}
+	buf := make([]byte, 1500)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			return 0, err
+		}
+		if n >= 8 && buf[0] == 0 && binary.BigEndian.Uint16(buf[6:]) == seq {
+			return time.Since(start), nil
+		}
+	}
+}
+
+// Build an ICMP echo request
+func echoRequest(id, seq uint16) []byte {
+	msg := []byte{8, 0, 0, 0, 0, 0, 0, 0}
+	binary.BigEndian.PutUint16(msg[4:], id)
+	binary.BigEndian.PutUint16(msg[6:], seq)
+	binary.BigEndian.PutUint16(msg[2:], icmpChecksum(msg))
+	return msg
+}
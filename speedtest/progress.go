@@ -0,0 +1,64 @@
+package speedtest
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// Snapshot is the progress of a download or upload, given to
+// Options.OnProgress and in the progress events while it runs
+type Snapshot struct {
+	// "Download" or "Upload"
+	Phase string `json:"phase"`
+
+	// Time since the start of the phase
+	Elapsed time.Duration `json:"elapsed_ns"`
+
+	// Planned length of the phase, 0 if it ends with the file
+	Duration time.Duration `json:"duration_ns,omitempty"`
+
+	// Bytes moved so far, all connections together and by each of them
+	Bytes int64   `json:"bytes"`
+	Parts []int64 `json:"parts"`
+
+	// Bytes to move in all, 0 if the phase is limited by its duration
+	Size int64 `json:"size,omitempty"`
+
+	// Throughput since the previous snapshot and since the start, in
+	// bytes/sec
+	Rate float64 `json:"rate"`
+	Avg  float64 `json:"avg"`
+
+	// Latest latency measured under load, 0 if none
+	Latency time.Duration `json:"latency_ns,omitempty"`
+}
+
+// Options whose OnProgress and IntervalOutput are called by one transfer
+// at a time, for the download and upload run together
+func (opts Options) serialized() Options {
+	mu := new(sync.Mutex)
+	if f := opts.OnProgress; f != nil {
+		opts.OnProgress = func(p Snapshot) {
+			mu.Lock()
+			defer mu.Unlock()
+			f(p)
+		}
+	}
+	if w := opts.IntervalOutput; w != nil {
+		opts.IntervalOutput = &lockedWriter{mu: mu, w: w}
+	}
+	return opts
+}
+
+// Writer shared by concurrent transfers, each line written whole
+type lockedWriter struct {
+	mu *sync.Mutex
+	w  io.Writer
+}
+
+func (l *lockedWriter) Write(b []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(b)
+}
@@ -0,0 +1,74 @@
+package speedtest
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/quic-go/quic-go/http3"
+	"golang.org/x/net/http2"
+)
+
+// HTTP protocol versions a Client can be restricted to
+const (
+	ProtoHTTP1 = "http/1.1"
+	ProtoHTTP2 = "h2"
+	ProtoHTTP3 = "h3"
+)
+
+// Protocols lists the HTTP versions compared by the protocol comparison
+var Protocols = []string{ProtoHTTP1, ProtoHTTP2, ProtoHTTP3}
+
+// NewProtocolClient returns a Client speaking only the given HTTP version.
+// HTTP/2 needs an https target, HTTP/3 runs over QUIC.
+func NewProtocolClient(proto string) (*Client, error) {
+	var rt http.RoundTripper
+	switch proto {
+	case ProtoHTTP1:
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.ForceAttemptHTTP2 = false
+		t.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+		rt = t
+	case ProtoHTTP2:
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.ForceAttemptHTTP2 = true
+		// Rather than speaking HTTP/1.1 to http targets
+		rt = schemeTransport{"https": t}
+	case ProtoHTTP3:
+		rt = &http3.Transport{}
+	default:
+		return nil, fmt.Errorf("unknown HTTP protocol %q", proto)
+	}
+	return &Client{HTTPClient: &http.Client{Transport: rt}}, nil
+}
+
+// NewMultiplexClient returns a Client sending all its requests as streams
+// of a single HTTP/2 connection per server, instead of one connection per
+// part. Plain http targets are spoken to with cleartext HTTP/2 (h2c).
+func NewMultiplexClient() *Client {
+	tlsTransport := http.DefaultTransport.(*http.Transport).Clone()
+	tlsTransport.ForceAttemptHTTP2 = true
+	tlsTransport.MaxConnsPerHost = 1
+
+	h2c := &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, addr)
+		},
+	}
+	return &Client{HTTPClient: &http.Client{Transport: schemeTransport{"http": h2c, "https": tlsTransport}}}
+}
+
+// Route requests to a transport according to the URL scheme
+type schemeTransport map[string]http.RoundTripper
+
+func (t schemeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rt, ok := t[req.URL.Scheme]
+	if !ok {
+		return nil, fmt.Errorf("unsupported protocol scheme %q", req.URL.Scheme)
+	}
+	return rt.RoundTrip(req)
+}
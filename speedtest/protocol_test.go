@@ -0,0 +1,37 @@
+package speedtest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// The HTTP/2 client doesn't fall back to HTTP/1.1 on cleartext targets
+func TestProtocolClientHTTP2(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	c, err := NewProtocolClient(ProtoHTTP2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp, err := c.HTTPClient.Get(srv.URL); err == nil {
+		resp.Body.Close()
+		t.Errorf("http target answered with %s, want an error", resp.Proto)
+	}
+
+	secure := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	secure.EnableHTTP2 = true
+	secure.StartTLS()
+	defer secure.Close()
+	if err := c.Configure(TransportOptions{TLS: secure.Client().Transport.(*http.Transport).TLSClientConfig}); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := c.HTTPClient.Get(secure.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.ProtoMajor != 2 {
+		t.Errorf("https target answered with %s, want HTTP/2", resp.Proto)
+	}
+}
@@ -0,0 +1,194 @@
+package speedtest
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// QualityComponent scores one metric from 0 to 100: 100 at Good or
+// better, 0 at Bad or worse, linearly in between (on a logarithmic scale
+// for the speeds). Its weight in the overall score is Weight, 0 ignoring
+// it. Speeds are in Mbit/sec, latencies in ms and the loss in percent.
+type QualityComponent struct {
+	Weight float64 `json:"weight"`
+	Good   float64 `json:"good"`
+	Bad    float64 `json:"bad"`
+}
+
+// QualityScoring is the scoring function of the connection quality grade,
+// one component per metric:
+//
+//	download  download speed
+//	upload    upload speed
+//	latency   idle latency
+//	loaded    latency under load (with Options.Bufferbloat)
+//	loss      packet loss of the latency probes
+type QualityScoring map[string]QualityComponent
+
+// DefaultQualityScoring rates a link for the daily use of a household:
+// video calls, streaming, gaming and backups
+var DefaultQualityScoring = QualityScoring{
+	"download": {Weight: 30, Good: 100, Bad: 1},
+	"upload":   {Weight: 20, Good: 20, Bad: 0.5},
+	"latency":  {Weight: 20, Good: 20, Bad: 200},
+	"loaded":   {Weight: 20, Good: 50, Bad: 500},
+	"loss":     {Weight: 10, Good: 0, Bad: 5},
+}
+
+// Metrics scored on a logarithmic scale
+var qualityLogScale = map[string]bool{"download": true, "upload": true}
+
+// Order of the components in the results
+var qualityMetrics = []string{"download", "upload", "latency", "loaded", "loss"}
+
+// ParseQualityScoring changes components of the default scoring with a
+// comma separated list of name=weight or name=weight:good:bad, e.g.
+// "upload=40,latency=20:10:100,loss=0"
+func ParseQualityScoring(spec string) (QualityScoring, error) {
+	s := QualityScoring{}
+	for k, v := range DefaultQualityScoring {
+		s[k] = v
+	}
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, value, _ := strings.Cut(item, "=")
+		c, ok := s[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown quality metric %q, expected one of %s", name, strings.Join(qualityMetrics, ", "))
+		}
+		fields := strings.Split(value, ":")
+		if len(fields) != 1 && len(fields) != 3 {
+			return nil, fmt.Errorf("invalid quality component %q, expected name=weight or name=weight:good:bad", item)
+		}
+		var nums []float64
+		for _, f := range fields {
+			n, err := strconv.ParseFloat(f, 64)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid quality component %q", item)
+			}
+			nums = append(nums, n)
+		}
+		c.Weight = nums[0]
+		if len(nums) == 3 {
+			c.Good, c.Bad = nums[1], nums[2]
+		}
+		if c.Good == c.Bad || qualityLogScale[name] && (c.Good <= 0 || c.Bad <= 0) {
+			return nil, fmt.Errorf("invalid quality component %q: good and bad must differ, and be positive for speeds", item)
+		}
+		s[name] = c
+	}
+	return s, nil
+}
+
+// QualityScore is the score of one measured metric
+type QualityScore struct {
+	Metric string  `json:"metric"`
+	Value  float64 `json:"value"`
+	Score  float64 `json:"score"`
+	Weight float64 `json:"weight"`
+}
+
+// QualityResult is the overall connection quality
+type QualityResult struct {
+	// Weighted average of the component scores, from 0 to 100
+	Score float64 `json:"score"`
+
+	// Grade of the score, from A+ to F
+	Grade string `json:"grade"`
+
+	// Scores of the metrics measured
+	Components []QualityScore `json:"components"`
+}
+
+// Score of the value of a metric, from 0 to 100
+func (c QualityComponent) score(v float64, log bool) float64 {
+	good, bad := c.Good, c.Bad
+	if log {
+		v, good, bad = math.Log(math.Max(v, 1e-9)), math.Log(good), math.Log(bad)
+	}
+	return math.Max(0, math.Min(100, 100*(v-bad)/(good-bad)))
+}
+
+// Values of the metrics the result measured, in the units of QualityScoring
+func (r *Result) qualityValues() map[string]float64 {
+	ms := func(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+	m := map[string]float64{}
+	if !r.DownloadSkipped && r.Elapsed > 0 {
+		m["download"] = r.BytesPerSecond() * 8 / 1e6
+	}
+	if up := r.Upload; up != nil && up.Elapsed > 0 {
+		m["upload"] = up.BytesPerSecond() * 8 / 1e6
+	}
+	if lat := r.Latency; lat != nil && lat.Received > 0 {
+		m["latency"] = ms(lat.Avg)
+	}
+	if lat := r.Latency; lat != nil && lat.Sent > 0 {
+		m["loss"] = lat.Loss()
+	}
+	if b := r.Bufferbloat; b != nil {
+		if _, ok := m["latency"]; !ok && b.Idle > 0 {
+			m["latency"] = ms(b.Idle)
+		}
+		var loaded time.Duration
+		for _, l := range []*LatencyResult{b.Download, b.Upload, b.Bidirectional} {
+			if l != nil && l.Received > 0 {
+				loaded = max(loaded, l.Avg)
+			}
+		}
+		if loaded > 0 {
+			m["loaded"] = ms(loaded)
+		}
+	}
+	return m
+}
+
+// Grade combines the metrics the result measured into one quality grade
+// with the scoring function s (DefaultQualityScoring if nil). Nil if no
+// scored metric was measured.
+func (r *Result) Grade(s QualityScoring) *QualityResult {
+	if s == nil {
+		s = DefaultQualityScoring
+	}
+	values := r.qualityValues()
+	res := &QualityResult{}
+	var sum, weights float64
+	for _, name := range qualityMetrics {
+		v, ok := values[name]
+		c := s[name]
+		if !ok || c.Weight <= 0 {
+			continue
+		}
+		score := c.score(v, qualityLogScale[name])
+		res.Components = append(res.Components, QualityScore{Metric: name, Value: v, Score: score, Weight: c.Weight})
+		sum += score * c.Weight
+		weights += c.Weight
+	}
+	if weights == 0 {
+		return nil
+	}
+	res.Score = sum / weights
+	res.Grade = qualityGrade(res.Score)
+	return res
+}
+
+func qualityGrade(score float64) string {
+	switch {
+	case score >= 95:
+		return "A+"
+	case score >= 85:
+		return "A"
+	case score >= 70:
+		return "B"
+	case score >= 55:
+		return "C"
+	case score >= 40:
+		return "D"
+	}
+	return "F"
+}
@@ -0,0 +1,92 @@
+package speedtest
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Token bucket shared by the connections of a test, in both directions
+type rateLimiter struct {
+	rate  float64
+	burst float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// Return a limiter letting rate bytes/sec through
+func newRateLimiter(rate float64) *rateLimiter {
+	// Allow bursts of 50ms, at least a few reads
+	burst := max(rate/20, 64*1024)
+	return &rateLimiter{rate: rate, burst: burst, tokens: burst, last: time.Now()}
+}
+
+// Take n bytes from the bucket, sleeping until they are paid back if it
+// runs short. Concurrent callers queue up behind each other's debt. It
+// returns false if ctx is done first, the bytes coming past the end.
+func (l *rateLimiter) wait(ctx context.Context, n int64) bool {
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	l.tokens -= float64(n)
+	debt := -l.tokens
+	l.mu.Unlock()
+	if debt <= 0 {
+		return ctx.Err() == nil
+	}
+	t := time.NewTimer(time.Duration(debt / l.rate * float64(time.Second)))
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return ctx.Err() == nil
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// Multipliers of the rate prefixes
+var ratePrefixes = map[string]float64{
+	"": 1, "k": 1e3, "m": 1e6, "g": 1e9,
+	"ki": 1 << 10, "mi": 1 << 20, "gi": 1 << 30,
+}
+
+// ParseRate reads a rate such as 50Mbps, 50M, 6MB/s or 100kbit/s and
+// returns it in bytes/sec. An uppercase B means bytes, a bare prefix means
+// bits per second like ISP plans.
+func ParseRate(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if i <= 0 {
+		return 0, fmt.Errorf("invalid rate %q, expected a number and a unit like 50Mbps", s)
+	}
+	v, err := strconv.ParseFloat(s[:i], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid rate %q: %w", s, err)
+	}
+	unit := strings.TrimSpace(s[i:])
+	bytes := strings.Contains(unit, "B")
+
+	prefix := strings.ToLower(unit)
+	for _, suffix := range []string{"/sec", "/s", "ps"} {
+		prefix = strings.TrimSuffix(prefix, suffix)
+	}
+	for _, base := range []string{"bit", "b"} {
+		prefix = strings.TrimSuffix(prefix, base)
+	}
+	f, ok := ratePrefixes[prefix]
+	if !ok || unit == "" {
+		return 0, fmt.Errorf("invalid rate unit %q, expected bps, kbps, Mbps, Gbps, B/s, kB/s, MB/s or GB/s", unit)
+	}
+	if !bytes {
+		f /= 8
+	}
+	return v * f, nil
+}
@@ -0,0 +1,74 @@
+package speedtest
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		in   string
+		want float64
+	}{
+		{"50Mbps", 50e6 / 8},
+		{"50M", 50e6 / 8},
+		{"50mbit/s", 50e6 / 8},
+		{"100kbit/s", 100e3 / 8},
+		{"1.5Gbps", 1.5e9 / 8},
+		{"6MB/s", 6e6},
+		{"6MBps", 6e6},
+		{"10MiB/s", 10 << 20},
+		{"512kB/s", 512e3},
+		{"800bps", 100},
+		{" 20 Mbps ", 20e6 / 8},
+	}
+	for _, tt := range tests {
+		got, err := ParseRate(tt.in)
+		if err != nil {
+			t.Errorf("ParseRate(%q): %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseRate(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+
+	for _, in := range []string{"", "fast", "Mbps", "50", "50Tbps", "50xB/s", "-5Mbps"} {
+		if got, err := ParseRate(in); err == nil {
+			t.Errorf("ParseRate(%q) = %v, want an error", in, got)
+		}
+	}
+}
+
+// Two transfers sharing a limiter, like the download and upload of a
+// bidirectional test, split its rate
+func TestRateLimiterShared(t *testing.T) {
+	const rate = 4 << 20
+	l := newRateLimiter(rate)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	var moved atomic.Int64
+	var wg sync.WaitGroup
+	for range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for l.wait(ctx, 32<<10) {
+				moved.Add(32 << 10)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// The initial burst comes on top of the rate
+	if got, limit := float64(moved.Load()), rate+l.burst; got > limit*1.1 || got < rate*0.8 {
+		t.Errorf("moved %.0f bytes in 1s through a %d bytes/sec limiter", got, rate)
+	}
+	if l.wait(ctx, 1) {
+		t.Error("bytes accepted after the end")
+	}
+}
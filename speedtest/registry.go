@@ -0,0 +1,399 @@
+package speedtest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/ofauchon/go-speedtest/internal/stats"
+)
+
+// Registry of the image names without one, and the host serving its API
+const (
+	dockerHub        = "docker.io"
+	dockerHubAPIHost = "registry-1.docker.io"
+)
+
+// Manifest types accepted, image indexes listing a manifest per platform
+var registryManifestTypes = []string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+// ImageInfo describes the image pulled by a registry test
+type ImageInfo struct {
+	// Image reference as given, and its parts
+	Reference  string `json:"reference"`
+	Registry   string `json:"registry"`
+	Repository string `json:"repository"`
+	Tag        string `json:"tag,omitempty"`
+
+	// Digest of the manifest of the platform pulled
+	Digest   string `json:"digest"`
+	Platform string `json:"platform,omitempty"`
+
+	Layers []ImageLayer `json:"layers"`
+
+	// Time spent getting a token and the manifests before the download
+	Auth     time.Duration `json:"auth_ns"`
+	Manifest time.Duration `json:"manifest_ns"`
+}
+
+// ImageLayer is a blob of the image
+type ImageLayer struct {
+	Digest    string `json:"digest"`
+	MediaType string `json:"media_type"`
+	Size      int64  `json:"size"`
+}
+
+// Image manifest or index, only the fields the test needs
+type registryManifest struct {
+	MediaType string       `json:"mediaType"`
+	Layers    []ImageLayer `json:"layers"`
+	Manifests []struct {
+		Digest   string `json:"digest"`
+		Platform struct {
+			OS           string `json:"os"`
+			Architecture string `json:"architecture"`
+			Variant      string `json:"variant"`
+		} `json:"platform"`
+	} `json:"manifests"`
+}
+
+// Split an image reference like nginx:1.27, ghcr.io/owner/app@sha256:...
+// or http://localhost:5000/app into the base URL of the registry API, the
+// repository and the tag or digest. Plain HTTP needs the http:// prefix.
+func parseImageRef(ref string) (info ImageInfo, base, reference string, err error) {
+	info.Reference = ref
+	scheme := "https"
+	if rest, ok := strings.CutPrefix(ref, "http://"); ok {
+		scheme, ref = "http", rest
+	}
+	ref = strings.TrimPrefix(ref, "https://")
+	if ref == "" {
+		return info, "", "", errors.New("image reference is required")
+	}
+
+	name := ref
+	if n, digest, ok := strings.Cut(ref, "@"); ok {
+		name, reference = n, digest
+	}
+	// A tag follows the last colon after the last slash
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		info.Tag = name[i+1:]
+		name = name[:i]
+	}
+	if reference == "" {
+		if info.Tag == "" {
+			info.Tag = "latest"
+		}
+		reference = info.Tag
+	}
+
+	info.Registry = dockerHub
+	if first, rest, ok := strings.Cut(name, "/"); ok && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		info.Registry, name = first, rest
+	}
+	host := info.Registry
+	if host == dockerHub {
+		host = dockerHubAPIHost
+		if !strings.Contains(name, "/") {
+			name = "library/" + name
+		}
+	}
+	if name == "" {
+		return info, "", "", fmt.Errorf("invalid image reference %q", info.Reference)
+	}
+	info.Repository = name
+	return info, scheme + "://" + host, reference, nil
+}
+
+// Adds the registry credentials to the requests sent to its host only,
+// so the blob storage the registry redirects to doesn't get them
+type registryAuth struct {
+	base   http.RoundTripper
+	host   string
+	header string
+}
+
+func (t *registryAuth) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.header != "" && req.URL.Host == t.host {
+		req = req.Clone(req.Context())
+		req.Header.Set("Authorization", t.header)
+	}
+	return t.base.RoundTrip(req)
+}
+
+// Parse a WWW-Authenticate challenge: Bearer realm="...",service="..."
+func parseChallenge(h string) (string, map[string]string) {
+	scheme, rest, _ := strings.Cut(h, " ")
+	params := map[string]string{}
+	for _, p := range strings.Split(rest, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(p), "=")
+		if ok {
+			params[strings.ToLower(k)] = strings.Trim(v, `"`)
+		}
+	}
+	return strings.ToLower(scheme), params
+}
+
+// Authenticate with the registry as its /v2/ endpoint asks: a bearer
+// token for pulling the repository, anonymous unless user:password
+// credentials are given, or basic authentication
+func (c *Client) registryLogin(ctx context.Context, base, repo, credentials string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+"/v2/", nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("registry not reachable: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		return "", nil
+	}
+	user, pass, _ := strings.Cut(credentials, ":")
+	scheme, params := parseChallenge(resp.Header.Get("WWW-Authenticate"))
+	switch scheme {
+	case "basic":
+		if credentials == "" {
+			return "", errors.New("the registry needs credentials")
+		}
+		req.SetBasicAuth(user, pass)
+		return req.Header.Get("Authorization"), nil
+	case "bearer":
+	default:
+		return "", fmt.Errorf("unsupported registry authentication %q", scheme)
+	}
+
+	realm, err := url.Parse(params["realm"])
+	if err != nil || realm.Host == "" {
+		return "", fmt.Errorf("invalid token realm %q", params["realm"])
+	}
+	q := realm.Query()
+	if s := params["service"]; s != "" {
+		q.Set("service", s)
+	}
+	q.Set("scope", "repository:"+repo+":pull")
+	realm.RawQuery = q.Encode()
+	treq, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if credentials != "" {
+		treq.SetBasicAuth(user, pass)
+	}
+	tresp, err := c.HTTPClient.Do(treq)
+	if err != nil {
+		return "", fmt.Errorf("failed to get a registry token: %w", err)
+	}
+	defer tresp.Body.Close()
+	if tresp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to get a registry token: %s", tresp.Status)
+	}
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(tresp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("invalid registry token: %w", err)
+	}
+	if token.Token == "" {
+		token.Token = token.AccessToken
+	}
+	return "Bearer " + token.Token, nil
+}
+
+// Fetch a manifest, returning it with its digest
+func (c *Client) fetchManifest(ctx context.Context, base, repo, reference string) (*registryManifest, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+"/v2/"+repo+"/manifests/"+reference, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("Accept", strings.Join(registryManifestTypes, ", "))
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get manifest: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("failed to get manifest %s: %s", reference, resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get manifest: %w", err)
+	}
+	var m registryManifest
+	if err := json.Unmarshal(body, &m); err != nil {
+		return nil, "", fmt.Errorf("invalid manifest: %w", err)
+	}
+	if m.MediaType == "" {
+		m.MediaType = resp.Header.Get("Content-Type")
+	}
+	digest := resp.Header.Get("Docker-Content-Digest")
+	if digest == "" {
+		sum := sha256.Sum256(body)
+		digest = "sha256:" + hex.EncodeToString(sum[:])
+	}
+	return &m, digest, nil
+}
+
+// Blob part fetched by the connections
+type blobChunk struct {
+	url         string
+	first, last int64
+}
+
+// Resolve the URL a blob is served from, following the redirect of the
+// registry to its storage, and split it into chunks if Range is supported
+func (c *Client) blobChunks(ctx context.Context, blobURL string, size, chunk int64) ([]blobChunk, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, blobURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Range", "bytes=0-0")
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get blob: %w", err)
+	}
+	resp.Body.Close()
+	target := resp.Request.URL.String()
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusOK:
+		return []blobChunk{{url: target, first: -1, last: -1}}, nil
+	default:
+		return nil, fmt.Errorf("failed to get blob: %s", resp.Status)
+	}
+	var chunks []blobChunk
+	for start := int64(0); start < size; start += chunk {
+		chunks = append(chunks, blobChunk{url: target, first: start, last: min(start+chunk, size) - 1})
+	}
+	return chunks, nil
+}
+
+// RunRegistry measures how fast an image is pulled from its registry: the
+// manifest of the platform (e.g. linux/amd64) is fetched, then the layer
+// blobs are downloaded with Range requests over opts.Concurrent
+// connections pulling chunks from a shared queue. credentials
+// (user:password) log in to private repositories. Options.Target is
+// ignored.
+func (c *Client) RunRegistry(ctx context.Context, opts Options, image, platform, credentials string) (*Result, error) {
+	if opts.Concurrent <= 0 || opts.Single {
+		opts.Concurrent = 1
+	}
+	info, base, reference, err := parseImageRef(image)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	auth, err := c.registryLogin(ctx, base, info.Repository, credentials)
+	if err != nil {
+		return nil, err
+	}
+	info.Auth = time.Since(start)
+	u, _ := url.Parse(base)
+	hc := *c.HTTPClient
+	transport := hc.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	hc.Transport = &registryAuth{base: transport, host: u.Host, header: auth}
+	rc := &Client{HTTPClient: &hc, Logger: c.Logger}
+
+	start = time.Now()
+	m, digest, err := rc.fetchManifest(ctx, base, info.Repository, reference)
+	if err != nil {
+		return nil, err
+	}
+	if len(m.Manifests) > 0 {
+		digest = ""
+		for _, p := range m.Manifests {
+			name := p.Platform.OS + "/" + p.Platform.Architecture
+			if platform == name || (p.Platform.Variant != "" && platform == name+"/"+p.Platform.Variant) {
+				digest = p.Digest
+				break
+			}
+		}
+		if digest == "" {
+			return nil, fmt.Errorf("no %s image in %s", platform, image)
+		}
+		if m, digest, err = rc.fetchManifest(ctx, base, info.Repository, digest); err != nil {
+			return nil, err
+		}
+		info.Platform = platform
+	}
+	info.Manifest = time.Since(start)
+	info.Digest, info.Layers = digest, m.Layers
+	if len(m.Layers) == 0 {
+		return nil, errors.New("the image has no layers")
+	}
+	c.log().Debug("manifest fetched", "digest", digest, "layers", len(m.Layers))
+
+	lat, err := c.httpLatency(ctx, opts, base+"/v2/")
+	if err != nil {
+		return nil, err
+	}
+
+	chunk := opts.ChunkSize
+	if chunk <= 0 {
+		chunk = defaultChunkSize
+	}
+	var chunks []blobChunk
+	var total int64
+	for _, l := range m.Layers {
+		cs, err := rc.blobChunks(ctx, base+"/v2/"+info.Repository+"/blobs/"+l.Digest, l.Size, chunk)
+		if err != nil {
+			return nil, fmt.Errorf("layer %s: %w", l.Digest, err)
+		}
+		chunks = append(chunks, cs...)
+		total += l.Size
+	}
+
+	retry := fetchPolicy{timeout: opts.StallTimeout, retries: opts.Retries, buffer: opts.ReadBuffer}
+	if retry.timeout == 0 {
+		retry.timeout = defaultStallTimeout
+	}
+	if retry.retries == 0 {
+		retry.retries = defaultRetries
+	}
+	timings := make([]ConnTiming, opts.Concurrent)
+	var next int64
+	pull := func(ctx context.Context, part int, counter *stats.Counter) error {
+		timings[part].Part = part
+		tctx := withTiming(ctx, &timings[part])
+		for ctx.Err() == nil {
+			i := atomic.AddInt64(&next, 1) - 1
+			if i >= int64(len(chunks)) {
+				return nil
+			}
+			ch := chunks[i]
+			if _, err := rc.downloadRange(tctx, ch.url, part, ch.first, ch.last, retry, counter, nil); err != nil {
+				return err
+			}
+			// Only the first request of a connection is traced
+			tctx = ctx
+		}
+		return nil
+	}
+	t := runTransfer(ctx, opts, dirDownload, total, pull)
+
+	res := t.downloadResult(opts, image, total)
+	res.Timings = timings
+	res.Latency = lat
+	res.Image = &info
+	return res, nil
+}
@@ -0,0 +1,137 @@
+package speedtest
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// NewResolver returns a resolver sending its queries to the given server:
+// host[:port] for plain DNS, tls://host[:port] for DNS over TLS or an
+// https:// URL for DNS over HTTPS. An empty spec returns the system
+// resolver.
+func NewResolver(spec string) (*net.Resolver, error) {
+	var dial func(ctx context.Context) (net.Conn, error)
+	switch {
+	case spec == "":
+		return net.DefaultResolver, nil
+	case strings.HasPrefix(spec, "https://"):
+		dial = func(ctx context.Context) (net.Conn, error) {
+			return &dohConn{url: spec}, nil
+		}
+	case strings.HasPrefix(spec, "tls://"):
+		addr := withPort(strings.TrimPrefix(spec, "tls://"), "853")
+		host, _, _ := net.SplitHostPort(addr)
+		d := &tls.Dialer{Config: &tls.Config{ServerName: host}}
+		dial = func(ctx context.Context) (net.Conn, error) {
+			return d.DialContext(ctx, "tcp", addr)
+		}
+	default:
+		if strings.Contains(spec, "://") {
+			return nil, fmt.Errorf("unsupported DNS server %q", spec)
+		}
+		addr := withPort(spec, "53")
+		dial = func(ctx context.Context) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "udp", addr)
+		}
+	}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dial(ctx)
+		},
+	}, nil
+}
+
+// Add the default port to addr if it has none
+func withPort(addr, port string) string {
+	if _, _, err := net.SplitHostPort(addr); err == nil {
+		return addr
+	}
+	return net.JoinHostPort(strings.Trim(addr, "[]"), port)
+}
+
+// Connection of the Go resolver to a DNS over HTTPS server. The resolver
+// speaks DNS over TCP to it: each message prefixed with its length is
+// posted to the server when the answer is read.
+type dohConn struct {
+	url      string
+	query    bytes.Buffer
+	answer   bytes.Buffer
+	deadline time.Time
+}
+
+func (c *dohConn) Write(p []byte) (int, error) {
+	return c.query.Write(p)
+}
+
+func (c *dohConn) Read(p []byte) (int, error) {
+	if c.answer.Len() == 0 {
+		if err := c.exchange(); err != nil {
+			return 0, err
+		}
+	}
+	return c.answer.Read(p)
+}
+
+// Post the pending query and buffer the answer
+func (c *dohConn) exchange() error {
+	if c.query.Len() < 2 {
+		return io.EOF
+	}
+	n := int(binary.BigEndian.Uint16(c.query.Next(2)))
+	if c.query.Len() < n {
+		return errors.New("truncated DNS query")
+	}
+	msg := c.query.Next(n)
+
+	ctx := context.Background()
+	if !c.deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, c.deadline)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(msg))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("DNS over HTTPS query failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("DNS over HTTPS query failed: %s", resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 65535))
+	if err != nil {
+		return err
+	}
+	c.answer.Write(binary.BigEndian.AppendUint16(nil, uint16(len(body))))
+	c.answer.Write(body)
+	return nil
+}
+
+func (c *dohConn) Close() error                       { return nil }
+func (c *dohConn) LocalAddr() net.Addr                { return dohAddr(c.url) }
+func (c *dohConn) RemoteAddr() net.Addr               { return dohAddr(c.url) }
+func (c *dohConn) SetDeadline(t time.Time) error      { c.deadline = t; return nil }
+func (c *dohConn) SetReadDeadline(t time.Time) error  { c.deadline = t; return nil }
+func (c *dohConn) SetWriteDeadline(t time.Time) error { return nil }
+
+// Address of a DNS over HTTPS server
+type dohAddr string
+
+func (a dohAddr) Network() string { return "https" }
+func (a dohAddr) String() string  { return string(a) }
@@ -0,0 +1,175 @@
+package speedtest
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Result holds the outcome of a speed test run
+type Result struct {
+	Target     string        `json:"target"`
+	FileSize   int64         `json:"file_size"`
+	Concurrent int           `json:"concurrent"`
+	Start      time.Time     `json:"start"`
+	End        time.Time     `json:"end"`
+	Elapsed    time.Duration `json:"elapsed_ns"`
+
+	// Test server picked by the backend, nil for plain URL tests
+	Server *Server `json:"server,omitempty"`
+
+	// Image pulled by a registry test
+	Image *ImageInfo `json:"image,omitempty"`
+
+	// Network the test ran from, nil unless looked up
+	Client *ClientInfo `json:"client,omitempty"`
+
+	// Wireless link of the client, nil unless captured
+	WiFi *WiFiInfo `json:"wifi,omitempty"`
+
+	// Name of the agent that ran the test for a controller, if any
+	Agent string `json:"agent,omitempty"`
+
+	// Server that answered the target URL, nil unless Options.Edge is set
+	Edge *EdgeInfo `json:"edge,omitempty"`
+
+	// Hops to the target host, nil unless Options.Path is set
+	Path *PathResult `json:"path,omitempty"`
+
+	// Bytes counted by the interface during the test, nil unless
+	// Options.Counters is set
+	Counters *NICCounters `json:"counters,omitempty"`
+
+	// Bytes actually received, all connections together
+	Bytes int64 `json:"bytes"`
+
+	// Bytes received during the Options.Omit warm-up, not counted in Bytes
+	Omitted int64 `json:"omitted_bytes,omitempty"`
+
+	// Bytes received by each connection
+	Parts []int64 `json:"parts"`
+
+	// Errors reported by the connections
+	Errors []string `json:"errors,omitempty"`
+
+	// What each connection did
+	Conns []ConnStats `json:"connections,omitempty"`
+
+	// Distribution of the instantaneous throughput
+	Speed *SpeedStats `json:"speed,omitempty"`
+
+	// Methodology which ended the phase (Options.Methodology), empty if
+	// the file or the duration did
+	StoppedBy string `json:"stopped_by,omitempty"`
+
+	// The server doesn't support Range requests, each connection
+	// downloaded the whole file instead of a part of it
+	NoRange bool `json:"no_range,omitempty"`
+
+	// Connection setup timings of each part, for plain URL tests
+	Timings []ConnTiming `json:"timings,omitempty"`
+
+	// Throughput at each reporting interval, if enabled
+	Intervals []Interval `json:"intervals,omitempty"`
+
+	// Connections to each address of the target host, nil unless
+	// requested
+	Eyeballs *EyeballsResult `json:"eyeballs,omitempty"`
+
+	// Latency phase result, nil if no probes were sent
+	Latency *LatencyResult `json:"latency,omitempty"`
+
+	// Upload phase result, nil if no upload was run
+	Upload *UploadResult `json:"upload,omitempty"`
+
+	// Latency under load, nil unless requested
+	Bufferbloat *BufferbloatResult `json:"bufferbloat,omitempty"`
+
+	// Latency probed once the load stopped, nil unless
+	// Options.Phases.Cooldown is set
+	Cooldown *LatencyResult `json:"cooldown,omitempty"`
+
+	// Round trips under load, nil unless requested
+	Responsiveness *ResponsivenessResult `json:"responsiveness,omitempty"`
+
+	// Overall connection quality, see Result.Grade
+	Quality *QualityResult `json:"quality,omitempty"`
+
+	// The downloaded file matched Options.SHA256
+	Verified bool `json:"sha256_verified,omitempty"`
+
+	// File written by Options.OutputFile
+	Saved *SavedFile `json:"saved,omitempty"`
+
+	// The test was interrupted: the figures only cover what ran until
+	// then, and the phases left are missing
+	Partial bool `json:"partial,omitempty"`
+
+	// The upload ran during the download (Options.Bidirectional)
+	Bidirectional bool `json:"bidirectional,omitempty"`
+
+	// The download phase was not run (Options.SkipDownload)
+	DownloadSkipped bool `json:"download_skipped,omitempty"`
+}
+
+// BytesPerSecond returns the download speed in bytes/sec, computed from
+// the bytes actually received
+func (r *Result) BytesPerSecond() float64 {
+	if r.Elapsed <= 0 {
+		return 0
+	}
+	return float64(r.Bytes) / r.Elapsed.Seconds()
+}
+
+// MBytesPerSecond returns the download speed in MB/sec
+func (r *Result) MBytesPerSecond() float64 {
+	return r.BytesPerSecond() / (1024 * 1024)
+}
+
+// ConnStats describes what one of the parallel connections did
+type ConnStats struct {
+	Part  int   `json:"part"`
+	Bytes int64 `json:"bytes"`
+
+	// Time until the connection finished or the test ended
+	Elapsed time.Duration `json:"elapsed_ns"`
+
+	// Times the connection moved no data for a while, and errors reported
+	Stalls int `json:"stalls"`
+	Errors int `json:"errors"`
+
+	// Requests sent again after moving no data for Options.StallTimeout
+	Restarts int `json:"restarts,omitempty"`
+
+	// Requests sent again after failing, up to Options.Retries each
+	Retries int `json:"retries,omitempty"`
+
+	// Kernel statistics of the TCP connection, nil if unavailable
+	TCP *TCPInfo `json:"tcp_info,omitempty"`
+}
+
+// BytesPerSecond returns the average speed of the connection
+func (c ConnStats) BytesPerSecond() float64 {
+	if c.Elapsed <= 0 {
+		return 0
+	}
+	return float64(c.Bytes) / c.Elapsed.Seconds()
+}
+
+// errorList collects errors reported by concurrent connections
+type errorList struct {
+	mu   sync.Mutex
+	errs []string
+}
+
+func (l *errorList) add(format string, args ...any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.errs = append(l.errs, fmt.Sprintf(format, args...))
+}
+
+func (l *errorList) list() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.errs...)
+}
@@ -0,0 +1,150 @@
+package speedtest
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ResponsivenessResult holds the round trip times measured under load
+// following the IETF "Responsiveness under Working Conditions" draft
+type ResponsivenessResult struct {
+	Target string `json:"target"`
+	Probes int    `json:"probes"`
+
+	// Trimmed means of the foreign probes, sent on new connections
+	TCP  time.Duration `json:"tcp_ns"`
+	TLS  time.Duration `json:"tls_ns,omitempty"`
+	HTTP time.Duration `json:"http_ns"`
+
+	// Trimmed mean of the self probes, sent on the client connections
+	Self time.Duration `json:"self_ns"`
+}
+
+// RPM returns the number of round trips per minute under load
+func (r *ResponsivenessResult) RPM() float64 {
+	rtt := (r.TCP+r.TLS+r.HTTP)/6 + r.Self/2
+	if rtt <= 0 {
+		return 0
+	}
+	return float64(time.Minute) / float64(rtt)
+}
+
+// Responsiveness probes timings, appended concurrently
+type rpmSamples struct {
+	mu                  sync.Mutex
+	tcp, tls, http, own []time.Duration
+}
+
+func (s *rpmSamples) add(d *[]time.Duration, v time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	*d = append(*d, v)
+}
+
+// Probe the responsiveness of target while load runs
+func (c *Client) responsiveness(ctx context.Context, opts Options, target string, load func()) *ResponsivenessResult {
+	// Foreign probes must not reuse the loaded connections
+	var transport *http.Transport
+	rt, header := c.transport()
+	if t, ok := rt.(*http.Transport); ok {
+		transport = t.Clone()
+	} else {
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	}
+	transport.DisableKeepAlives = true
+	defer transport.CloseIdleConnections()
+	foreign := &http.Client{Transport: &headerTransport{rt: transport, header: header}}
+
+	var samples rpmSamples
+	var wg sync.WaitGroup
+	whileLoaded(ctx, load, func(ctx context.Context) {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			c.foreignProbe(ctx, opts, foreign, target, &samples)
+		}()
+		go func() {
+			defer wg.Done()
+			pctx, cancel := context.WithTimeout(ctx, probeTimeout(opts))
+			defer cancel()
+			if rtt, err := c.httpProbe(pctx, target); err == nil && ctx.Err() == nil {
+				samples.add(&samples.own, rtt)
+			}
+		}()
+	})
+	wg.Wait()
+
+	return &ResponsivenessResult{
+		Target: target,
+		Probes: len(samples.own),
+		TCP:    trimmedMean(samples.tcp),
+		TLS:    trimmedMean(samples.tls),
+		HTTP:   trimmedMean(samples.http),
+		Self:   trimmedMean(samples.own),
+	}
+}
+
+// Time the connection, TLS handshake and first request of a new connection
+func (c *Client) foreignProbe(ctx context.Context, opts Options, client *http.Client, target string, samples *rpmSamples) {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout(opts))
+	defer cancel()
+
+	var connStart, tlsStart, wrote time.Time
+	var tcp, tlsTime, first time.Duration
+	trace := &httptrace.ClientTrace{
+		ConnectStart: func(string, string) { connStart = time.Now() },
+		ConnectDone: func(_, _ string, err error) {
+			if err == nil {
+				tcp = time.Since(connStart)
+			}
+		},
+		TLSHandshakeStart: func() { tlsStart = time.Now() },
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			if err == nil {
+				tlsTime = time.Since(tlsStart)
+			}
+		},
+		WroteRequest:         func(httptrace.WroteRequestInfo) { wrote = time.Now() },
+		GotFirstResponseByte: func() { first = time.Since(wrote) },
+	}
+	req, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, trace), http.MethodHead, target, nil)
+	if err != nil {
+		return
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if ctx.Err() != nil || tcp == 0 || first == 0 {
+		return
+	}
+
+	samples.add(&samples.tcp, tcp)
+	if tlsTime > 0 {
+		samples.add(&samples.tls, tlsTime)
+	}
+	samples.add(&samples.http, first)
+}
+
+// Mean of the samples without the slowest 5%, as the draft recommends
+func trimmedMean(samples []time.Duration) time.Duration {
+	if len(samples) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration(nil), samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	n := (len(sorted)*95 + 99) / 100
+	var sum time.Duration
+	for _, s := range sorted[:n] {
+		sum += s
+	}
+	return sum / time.Duration(n)
+}
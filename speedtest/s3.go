@@ -0,0 +1,330 @@
+package speedtest
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ofauchon/go-speedtest/internal/stats"
+)
+
+// Smallest part S3 accepts in a multipart upload, but the last one
+const s3MinPartSize = 5 * 1024 * 1024
+
+// S3Config locates an object on an S3 compatible storage and the
+// credentials signing the requests
+type S3Config struct {
+	// Endpoint URL, AWS S3 of Region if empty (e.g. http://minio:9000)
+	Endpoint string
+	Region   string
+
+	Bucket string
+
+	// Object downloaded, and object written by the upload (defaults to
+	// Key + ".upload")
+	Key       string
+	UploadKey string
+
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+
+	// Put the bucket in the path instead of the host name, as most
+	// S3 compatible servers expect. Set for custom endpoints.
+	PathStyle bool
+}
+
+// URL of an object of the bucket
+func (s S3Config) objectURL(key string) (string, error) {
+	endpoint := s.Endpoint
+	if endpoint == "" {
+		endpoint = "https://s3." + s.Region + ".amazonaws.com"
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return "", fmt.Errorf("invalid endpoint %q", endpoint)
+	}
+	key = strings.TrimPrefix(key, "/")
+	if s.PathStyle || s.Endpoint != "" {
+		u.Path = "/" + s.Bucket + "/" + key
+	} else {
+		u.Host = s.Bucket + "." + u.Host
+		u.Path = "/" + key
+	}
+	return u.String(), nil
+}
+
+// Signs the requests with AWS Signature Version 4. The payload is not
+// hashed so the request bodies can be streamed.
+type s3Signer struct {
+	base http.RoundTripper
+	cfg  S3Config
+}
+
+// Percent encode as SigV4 wants it, keeping the slashes of paths
+func awsEscape(s string, path bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '.', c == '_', c == '~', path && c == '/':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func (s *s3Signer) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	if s.cfg.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.cfg.SessionToken)
+	}
+	sig := s.cfg.signV4(req, time.Now(), "UNSIGNED-PAYLOAD")
+	req.Header.Set("Authorization", sig.authorization)
+	return s.base.RoundTrip(req)
+}
+
+// AWS Signature Version 4 of a request, with the intermediate steps
+type sigV4 struct {
+	canonical     string
+	toSign        string
+	signature     string
+	authorization string
+}
+
+// Sign req at t, setting its X-Amz-Date and X-Amz-Content-Sha256 headers
+// (payloadHash being the hex SHA-256 of the body or UNSIGNED-PAYLOAD).
+// The host, Date, Range and X-Amz-* headers are signed.
+func (s S3Config) signV4(req *http.Request, t time.Time, payloadHash string) sigV4 {
+	t = t.UTC()
+	amzDate := t.Format("20060102T150405Z")
+	day := t.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for k, vs := range req.Header {
+		k = strings.ToLower(k)
+		if k == "date" || k == "range" || strings.HasPrefix(k, "x-amz-") {
+			headers[k] = strings.Join(strings.Fields(strings.Join(vs, ",")), " ")
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonHeaders strings.Builder
+	for _, k := range names {
+		canonHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signed := strings.Join(names, ";")
+
+	// Parameters sorted by name, then value
+	var params [][2]string
+	for k, vs := range req.URL.Query() {
+		for _, v := range vs {
+			params = append(params, [2]string{awsEscape(k, false), awsEscape(v, false)})
+		}
+	}
+	sort.Slice(params, func(i, j int) bool {
+		if params[i][0] != params[j][0] {
+			return params[i][0] < params[j][0]
+		}
+		return params[i][1] < params[j][1]
+	})
+	query := make([]string, len(params))
+	for i, p := range params {
+		query[i] = p[0] + "=" + p[1]
+	}
+	path := req.URL.Path
+	if path == "" {
+		path = "/"
+	}
+
+	var sig sigV4
+	sig.canonical = strings.Join([]string{req.Method, awsEscape(path, true), strings.Join(query, "&"),
+		canonHeaders.String(), signed, payloadHash}, "\n")
+	scope := day + "/" + s.Region + "/s3/aws4_request"
+	sum := sha256.Sum256([]byte(sig.canonical))
+	sig.toSign = "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(sum[:])
+	key := hmacSHA256([]byte("AWS4"+s.SecretKey), day)
+	for _, k := range []string{s.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, k)
+	}
+	sig.signature = hex.EncodeToString(hmacSHA256(key, sig.toSign))
+	sig.authorization = fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKey, scope, signed, sig.signature)
+	return sig
+}
+
+// Error answered by S3
+type s3Error struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+// Send a request without payload and decode the XML answer into out
+func (c *Client) s3Call(ctx context.Context, method, target string, body []byte, out any) error {
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	// Completing an upload may fail after a 200 status
+	var e s3Error
+	if xml.Unmarshal(data, &e) == nil && e.Code != "" {
+		return fmt.Errorf("%s: %s", e.Code, e.Message)
+	}
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s %s", method, resp.Status)
+	}
+	if out != nil {
+		return xml.Unmarshal(data, out)
+	}
+	return nil
+}
+
+// Upload the payload as the parts of a multipart upload, the connections
+// pulling part numbers from a shared queue. The upload is completed if
+// every part was sent, aborted otherwise so the storage drops the parts.
+func (c *Client) multipartUpload(ctx context.Context, opts Options) (*UploadResult, error) {
+	target := opts.UploadTarget
+	var created struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if err := c.s3Call(ctx, http.MethodPost, target+"?uploads", nil, &created); err != nil {
+		return nil, fmt.Errorf("failed to create multipart upload: %w", err)
+	}
+	upload := target + "?uploadId=" + url.QueryEscape(created.UploadID)
+
+	size := opts.UploadSize
+	partSize := max(opts.ChunkSize, s3MinPartSize)
+	block := payloadBlock(opts.UploadCompressibility)
+	var next int64
+	var mu sync.Mutex
+	etags := map[int64]string{}
+	uploadPart := func(ctx context.Context, part int, counter *stats.Counter) error {
+		for ctx.Err() == nil {
+			n := atomic.AddInt64(&next, 1)
+			offset := (n - 1) * partSize
+			length := partSize
+			if size >= 0 {
+				if offset >= size {
+					return nil
+				}
+				length = min(partSize, size-offset)
+			}
+			body := &payloadReader{ctx: ctx, block: block, remain: length, counter: counter}
+			req, err := http.NewRequestWithContext(ctx, http.MethodPut, fmt.Sprintf("%s&partNumber=%d", upload, n), body)
+			if err != nil {
+				return err
+			}
+			req.ContentLength = length
+			resp, err := c.HTTPClient.Do(req)
+			if err != nil {
+				return fmt.Errorf("failed to upload part %d: %w", n, err)
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				return fmt.Errorf("failed to upload part %d: %s", n, resp.Status)
+			}
+			mu.Lock()
+			etags[n] = resp.Header.Get("ETag")
+			mu.Unlock()
+		}
+		return nil
+	}
+	t := runTransfer(ctx, opts, dirUpload, size, uploadPart)
+	res := t.uploadResult(opts, target, size)
+
+	// Finish even when interrupted, not to leave parts behind
+	fctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+	defer cancel()
+	complete := size >= 0 && int64(len(etags))*partSize >= size && len(res.Errors) == 0
+	if !complete {
+		if err := c.s3Call(fctx, http.MethodDelete, upload, nil, nil); err != nil {
+			c.log().Warn("multipart upload not aborted", "err", err)
+		}
+		return res, nil
+	}
+	var list bytes.Buffer
+	list.WriteString("<CompleteMultipartUpload>")
+	for n := int64(1); n <= int64(len(etags)); n++ {
+		fmt.Fprintf(&list, "<Part><PartNumber>%d</PartNumber><ETag>%s</ETag></Part>", n, etags[n])
+	}
+	list.WriteString("</CompleteMultipartUpload>")
+	if err := c.s3Call(fctx, http.MethodPost, upload, list.Bytes(), nil); err != nil {
+		return nil, fmt.Errorf("failed to complete multipart upload: %w", err)
+	}
+	return res, nil
+}
+
+// RunS3 runs the test against an object of an S3 compatible storage,
+// signing the requests: ranged GETs of Key, then with opts.Upload a
+// multipart upload of UploadKey in parts of opts.ChunkSize (5 MiB at
+// least). Options.Target and Options.UploadTarget are ignored.
+func (c *Client) RunS3(ctx context.Context, opts Options, cfg S3Config) (*Result, error) {
+	if cfg.Bucket == "" || cfg.Key == "" {
+		return nil, errors.New("bucket and key are required")
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	if cfg.UploadKey == "" {
+		cfg.UploadKey = cfg.Key + ".upload"
+	}
+	var err error
+	if opts.Target, err = cfg.objectURL(cfg.Key); err != nil {
+		return nil, err
+	}
+	if opts.UploadTarget, err = cfg.objectURL(cfg.UploadKey); err != nil {
+		return nil, err
+	}
+	opts.multipart = true
+
+	hc := *c.HTTPClient
+	transport := hc.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	hc.Transport = &s3Signer{base: transport, cfg: cfg}
+	// Keep the forced addresses and the interface of c
+	signing := *c
+	signing.HTTPClient = &hc
+	return signing.Run(ctx, opts)
+}
@@ -0,0 +1,181 @@
+package speedtest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sync"
+)
+
+// SavedFile describes the file written by Options.OutputFile
+type SavedFile struct {
+	Path string `json:"path"`
+
+	// All the data was written, otherwise a later run resumes it
+	Complete bool `json:"complete"`
+
+	// Bytes already on disk from a previous run, not downloaded again
+	Resumed int64 `json:"resumed_bytes,omitempty"`
+}
+
+// Chunks of the output file written so far, kept next to it until the
+// download completes
+type resumeState struct {
+	Size  int64   `json:"size"`
+	Chunk int64   `json:"chunk"`
+	Done  []int64 `json:"done"`
+}
+
+// File the downloaded data is written to
+type outputFile struct {
+	mu      sync.Mutex
+	f       *os.File
+	path    string
+	state   resumeState
+	ranges  bool
+	whole   bool
+	resumed int64
+}
+
+// Open the output file for a download of size bytes in chunks, resuming
+// the chunks a previous run wrote if it had the same size and chunks.
+// Without ranges the file is written from the start by one connection.
+func openOutputFile(path string, size, chunk int64, ranges bool) (*outputFile, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open output file: %w", err)
+	}
+	o := &outputFile{f: f, path: path, ranges: ranges, state: resumeState{Size: size, Chunk: chunk}}
+	if ranges {
+		var prev resumeState
+		if b, err := os.ReadFile(o.resumePath()); err == nil && json.Unmarshal(b, &prev) == nil &&
+			prev.Size == size && prev.Chunk == chunk {
+			o.state.Done = prev.Done
+			for _, start := range prev.Done {
+				o.resumed += min(chunk, size-start)
+			}
+		}
+	}
+	if err := f.Truncate(size); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to allocate output file: %w", err)
+	}
+	return o, nil
+}
+
+func (o *outputFile) resumePath() string {
+	return o.path + ".resume"
+}
+
+// Tell whether the chunk starting at start is already on disk
+func (o *outputFile) done(start int64) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return slices.Contains(o.state.Done, start)
+}
+
+// Read back a chunk written by a previous run
+func (o *outputFile) read(start, n int64) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := o.f.ReadAt(b, start); err != nil {
+		return nil, fmt.Errorf("failed to read output file: %w", err)
+	}
+	return b, nil
+}
+
+// Return the sink writing a request body at offset start
+func (o *outputFile) sink(start int64) bodySink {
+	return fileSink{io.NewOffsetWriter(o.f, start)}
+}
+
+// Record that the chunk starting at start was written
+func (o *outputFile) markDone(start int64) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.state.Done = append(o.state.Done, start)
+	b, err := json.Marshal(o.state)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(o.resumePath(), b, 0o644); err != nil {
+		return fmt.Errorf("failed to save download state: %w", err)
+	}
+	return nil
+}
+
+// Record that the whole file was written at once
+func (o *outputFile) markWhole() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.whole = true
+}
+
+// Close the file, forgetting the resume state once it is complete
+func (o *outputFile) close() (*SavedFile, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	saved := &SavedFile{Path: o.path, Resumed: o.resumed}
+	if o.ranges {
+		chunks := (o.state.Size + o.state.Chunk - 1) / o.state.Chunk
+		saved.Complete = int64(len(o.state.Done)) == chunks
+	}
+	saved.Complete = saved.Complete || o.whole
+	err := o.f.Close()
+	if saved.Complete {
+		if rerr := os.Remove(o.resumePath()); rerr != nil && !errors.Is(rerr, os.ErrNotExist) {
+			err = errors.Join(err, rerr)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to save output file: %w", err)
+	}
+	return saved, nil
+}
+
+// Sink writing at an offset of the output file, back at the offset when
+// the request is sent again
+type fileSink struct {
+	*io.OffsetWriter
+}
+
+func (s fileSink) Reset() {
+	s.Seek(0, io.SeekStart)
+}
+
+// Sink writing to several sinks, nil ones skipped
+type multiSink []bodySink
+
+// Combine sinks, returning nil if they are all nil
+func sinks(all ...bodySink) bodySink {
+	var m multiSink
+	for _, s := range all {
+		if s != nil {
+			m = append(m, s)
+		}
+	}
+	switch len(m) {
+	case 0:
+		return nil
+	case 1:
+		return m[0]
+	}
+	return m
+}
+
+func (m multiSink) Write(p []byte) (int, error) {
+	for _, s := range m {
+		if _, err := s.Write(p); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+func (m multiSink) Reset() {
+	for _, s := range m {
+		s.Reset()
+	}
+}
@@ -0,0 +1,58 @@
+package speedtest
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Default duration of each phase for the public test networks
+const backendDuration = 10 * time.Second
+
+var errNoServer = errors.New("no reachable test server")
+
+// Server describes a remote test server of one of the public speed test
+// networks
+type Server struct {
+	ID       string  `json:"id,omitempty"`
+	Name     string  `json:"name"`
+	Sponsor  string  `json:"sponsor,omitempty"`
+	Country  string  `json:"country,omitempty"`
+	Host     string  `json:"host"`
+	URL      string  `json:"url"`
+	Distance float64 `json:"distance_km,omitempty"`
+
+	// Best round trip time measured while selecting the server
+	Latency time.Duration `json:"latency_ns,omitempty"`
+}
+
+// Return the index of the server answering the fastest to a few HTTP
+// probes on probeURL(i), and its best round trip time. Unreachable
+// servers are skipped.
+func (c *Client) nearest(ctx context.Context, n int, probeURL func(i int) string) (int, time.Duration, error) {
+	best, bestRTT := -1, time.Duration(0)
+	for i := 0; i < n; i++ {
+		var rtt time.Duration
+		for j := 0; j < 3; j++ {
+			pctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+			r, err := c.httpProbe(pctx, probeURL(i))
+			cancel()
+			if err != nil {
+				continue
+			}
+			if rtt == 0 || r < rtt {
+				rtt = r
+			}
+		}
+		if rtt > 0 && (best < 0 || rtt < bestRTT) {
+			best, bestRTT = i, rtt
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return 0, 0, err
+	}
+	if best < 0 {
+		return 0, 0, errNoServer
+	}
+	return best, bestRTT, nil
+}
@@ -0,0 +1,38 @@
+package speedtest
+
+import (
+	"time"
+
+	"github.com/ofauchon/go-speedtest/internal/stats"
+)
+
+// SpeedStats describes the distribution of the instantaneous throughput,
+// sampled over short periods during the transfer, in bytes/sec
+type SpeedStats struct {
+	Interval time.Duration `json:"interval_ns"`
+	Samples  int           `json:"samples"`
+	Min      float64       `json:"min"`
+	Avg      float64       `json:"avg"`
+	Max      float64       `json:"max"`
+	Median   float64       `json:"median"`
+	P5       float64       `json:"p5"`
+	P95      float64       `json:"p95"`
+}
+
+// Summarize the throughput samples, nil if there are none
+func newSpeedStats(interval time.Duration, rates []float64) *SpeedStats {
+	if len(rates) == 0 {
+		return nil
+	}
+	s := stats.Summarize(rates)
+	return &SpeedStats{
+		Interval: interval,
+		Samples:  s.N,
+		Min:      s.Min,
+		Avg:      s.Avg,
+		Max:      s.Max,
+		Median:   s.P50,
+		P5:       s.P5,
+		P95:      s.P95,
+	}
+}
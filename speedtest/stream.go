@@ -0,0 +1,64 @@
+package speedtest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/ofauchon/go-speedtest/internal/stats"
+)
+
+// Return a transferFunc downloading the URLs given by next over and over
+// until the test ends. Used by backends serving generated data instead of
+// a single file. The bodies are read with buffers of size bytes.
+func (c *Client) repeatGet(size int, next func(part int) string) transferFunc {
+	return func(ctx context.Context, part int, counter *stats.Counter) error {
+		for ctx.Err() == nil {
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, next(part), nil)
+			if err != nil {
+				return err
+			}
+			resp, err := c.HTTPClient.Do(req)
+			if err != nil {
+				return fmt.Errorf("failed to download part %d: %w", part, err)
+			}
+			if err := checkStatus(resp, part); err != nil {
+				resp.Body.Close()
+				return err
+			}
+			err = readBody(&bodyWriter{counter: counter}, resp.Body, size)
+			resp.Body.Close()
+			if err != nil {
+				return fmt.Errorf("error reading data on part %d: %w", part, err)
+			}
+		}
+		return nil
+	}
+}
+
+// Return a transferFunc posting size bytes of the payload block to the
+// URLs given by next over and over until the test ends
+func (c *Client) repeatPost(next func(part int) string, size int64, block []byte) transferFunc {
+	return func(ctx context.Context, part int, counter *stats.Counter) error {
+		for ctx.Err() == nil {
+			body := &payloadReader{ctx: ctx, block: block, remain: size, counter: counter}
+			req, err := http.NewRequestWithContext(ctx, http.MethodPost, next(part), body)
+			if err != nil {
+				return err
+			}
+			req.ContentLength = size
+			req.Header.Set("Content-Type", "application/octet-stream")
+			resp, err := c.HTTPClient.Do(req)
+			if err != nil {
+				return fmt.Errorf("failed to upload part %d: %w", part, err)
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			if err := checkUploadStatus(resp, part); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
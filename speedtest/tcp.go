@@ -0,0 +1,140 @@
+package speedtest
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/ofauchon/go-speedtest/internal/stats"
+)
+
+// First line sent by raw TCP clients, followed by the direction
+const tcpHello = "GOSPEEDTEST"
+
+// TCPServe accepts raw TCP test connections on ln until ctx is cancelled.
+// Depending on what the client asks, the server either streams random
+// data or discards everything it receives.
+func TCPServe(ctx context.Context, ln net.Listener) error {
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+	block := randomBlock()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		go serveTCPConn(ctx, conn, block)
+	}
+}
+
+func serveTCPConn(ctx context.Context, conn net.Conn, block []byte) {
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	r := bufio.NewReader(conn)
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return
+	}
+	switch strings.TrimSpace(line) {
+	case tcpHello + " download":
+		for {
+			if _, err := conn.Write(block); err != nil {
+				return
+			}
+		}
+	case tcpHello + " upload":
+		io.Copy(io.Discard, r)
+	}
+}
+
+// Open a raw TCP test connection in the given direction
+func (c *Client) dialTCP(ctx context.Context, addr string, dir direction) (net.Conn, error) {
+	conn, err := c.dialContext()(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := fmt.Fprintf(conn, "%s %s\n", tcpHello, strings.ToLower(string(dir))); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	// Unblock reads and writes when the test ends
+	context.AfterFunc(ctx, func() { conn.Close() })
+	return conn, nil
+}
+
+// RunTCP measures raw TCP throughput against a server started with
+// TCPServe. Options.Target is the host:port of the server, the test runs
+// for Options.Duration (10 seconds by default). Latency is measured with
+// TCP connect probes.
+func (c *Client) RunTCP(ctx context.Context, opts Options) (*Result, error) {
+	if opts.Target == "" {
+		return nil, errors.New("target address is required")
+	}
+	if opts.Concurrent <= 0 {
+		opts.Concurrent = 1
+	}
+	if opts.Duration <= 0 {
+		opts.Duration = backendDuration
+	}
+	addr := opts.Target
+
+	var lat *LatencyResult
+	if opts.LatencyProbes > 0 {
+		lopts := opts
+		lopts.Target = "tcp://" + addr
+		lopts.LatencyMethod = ProbeTCP
+		var err error
+		if lat, err = c.latency(ctx, lopts); err != nil {
+			return nil, err
+		}
+	}
+
+	t := runTransfer(ctx, opts, dirDownload, 0, func(ctx context.Context, part int, counter *stats.Counter) error {
+		conn, err := c.dialTCP(ctx, addr, dirDownload)
+		if err != nil {
+			return fmt.Errorf("failed to connect part %d: %w", part, err)
+		}
+		defer conn.Close()
+		buf := make([]byte, 128*1024)
+		for {
+			n, err := conn.Read(buf)
+			counter.Add(int64(n))
+			if err != nil {
+				return fmt.Errorf("error reading data on part %d: %w", part, err)
+			}
+		}
+	})
+	res := t.downloadResult(opts, addr, 0)
+	res.Latency = lat
+
+	if opts.Upload && ctx.Err() == nil {
+		block := payloadBlock(opts.UploadCompressibility)
+		t := runTransfer(ctx, opts, dirUpload, 0, func(ctx context.Context, part int, counter *stats.Counter) error {
+			conn, err := c.dialTCP(ctx, addr, dirUpload)
+			if err != nil {
+				return fmt.Errorf("failed to connect part %d: %w", part, err)
+			}
+			defer conn.Close()
+			for {
+				n, err := conn.Write(block)
+				counter.Add(int64(n))
+				if err != nil {
+					return fmt.Errorf("error sending data on part %d: %w", part, err)
+				}
+			}
+		})
+		res.Upload = t.uploadResult(opts, addr, 0)
+	}
+	return res, nil
+}
@@ -0,0 +1,72 @@
+package speedtest
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// TCPInfo holds the kernel statistics of a TCP connection (Linux only)
+type TCPInfo struct {
+	// Segments retransmitted and lost over the connection lifetime
+	Retransmits  uint32 `json:"retransmits"`
+	BytesRetrans uint64 `json:"bytes_retrans"`
+	Lost         uint32 `json:"lost"`
+
+	// Smoothed round trip time, its variation and the lowest seen
+	RTT    time.Duration `json:"rtt_ns"`
+	RTTVar time.Duration `json:"rttvar_ns"`
+	MinRTT time.Duration `json:"min_rtt_ns"`
+
+	// Congestion window in segments, and the segment size
+	Cwnd uint32 `json:"cwnd"`
+	MSS  uint32 `json:"mss"`
+
+	// Latest delivery rate estimate of the kernel, in bytes/sec
+	DeliveryRate float64 `json:"delivery_rate"`
+}
+
+// Track the connection used by the requests of one part and keep its
+// latest TCP statistics, as they can't be read once it is closed
+type connWatch struct {
+	mu   sync.Mutex
+	conn net.Conn
+	last *TCPInfo
+}
+
+// Return a context recording in w the connection of the requests made
+// with it
+func (w *connWatch) context(ctx context.Context) context.Context {
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			w.mu.Lock()
+			defer w.mu.Unlock()
+			w.conn = info.Conn
+		},
+	})
+}
+
+// Read the statistics of the current connection
+func (w *connWatch) sample() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	conn := w.conn
+	if tc, ok := conn.(*tls.Conn); ok {
+		conn = tc.NetConn()
+	}
+	if conn == nil {
+		return
+	}
+	if info, err := readTCPInfo(conn); err == nil {
+		w.last = info
+	}
+}
+
+func (w *connWatch) info() *TCPInfo {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.last
+}
@@ -0,0 +1,44 @@
+package speedtest
+
+import (
+	"errors"
+	"net"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// Read TCP_INFO from the socket of conn
+func readTCPInfo(conn net.Conn) (*TCPInfo, error) {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+	var ti *unix.TCPInfo
+	var serr error
+	err = raw.Control(func(fd uintptr) {
+		ti, serr = unix.GetsockoptTCPInfo(int(fd), unix.IPPROTO_TCP, unix.TCP_INFO)
+	})
+	if err == nil {
+		err = serr
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &TCPInfo{
+		Retransmits:  ti.Total_retrans,
+		BytesRetrans: ti.Bytes_retrans,
+		Lost:         ti.Lost,
+		RTT:          time.Duration(ti.Rtt) * time.Microsecond,
+		RTTVar:       time.Duration(ti.Rttvar) * time.Microsecond,
+		MinRTT:       time.Duration(ti.Min_rtt) * time.Microsecond,
+		Cwnd:         ti.Snd_cwnd,
+		MSS:          ti.Snd_mss,
+		DeliveryRate: float64(ti.Delivery_rate),
+	}, nil
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package speedtest
+
+import (
+	"errors"
+	"net"
+)
+
+// TCP_INFO is only read on Linux
+func readTCPInfo(net.Conn) (*TCPInfo, error) {
+	return nil, errors.ErrUnsupported
+}
@@ -0,0 +1,56 @@
+package speedtest
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http/httptrace"
+	"time"
+)
+
+// ConnTiming breaks down the time a connection spent before receiving the
+// first byte. Durations are zero for steps skipped by a reused connection.
+type ConnTiming struct {
+	Part    int           `json:"part"`
+	Reused  bool          `json:"reused"`
+	Remote  string        `json:"remote,omitempty"`
+	DNS     time.Duration `json:"dns_ns"`
+	Connect time.Duration `json:"connect_ns"`
+	TLS     time.Duration `json:"tls_ns"`
+
+	// From the start of the request to the first response byte
+	TTFB time.Duration `json:"ttfb_ns"`
+
+	// When the first request of the part was sent
+	Start time.Time `json:"start"`
+}
+
+// Return a context recording the timings of the request made with it in t
+func withTiming(ctx context.Context, t *ConnTiming) context.Context {
+	var start, dnsStart, connStart, tlsStart time.Time
+	trace := &httptrace.ClientTrace{
+		GetConn: func(string) {
+			start = time.Now()
+			if t.Start.IsZero() {
+				t.Start = start
+			}
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.Reused = info.Reused
+			if info.Conn != nil {
+				t.Remote = info.Conn.RemoteAddr().String()
+			}
+		},
+		DNSStart: func(httptrace.DNSStartInfo) { dnsStart = time.Now() },
+		DNSDone:  func(httptrace.DNSDoneInfo) { t.DNS = time.Since(dnsStart) },
+		ConnectStart: func(string, string) {
+			connStart = time.Now()
+		},
+		ConnectDone:       func(string, string, error) { t.Connect = time.Since(connStart) },
+		TLSHandshakeStart: func() { tlsStart = time.Now() },
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.TLS = time.Since(tlsStart)
+		},
+		GotFirstResponseByte: func() { t.TTFB = time.Since(start) },
+	}
+	return httptrace.WithClientTrace(ctx, trace)
+}
@@ -0,0 +1,361 @@
+package speedtest
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ofauchon/go-speedtest/internal/stats"
+	"github.com/ofauchon/go-speedtest/internal/tui"
+)
+
+// Direction of a transfer
+type direction string
+
+const (
+	dirDownload direction = "Download"
+	dirUpload   direction = "Upload"
+)
+
+// Label of the progress bars
+func (d direction) barLabel() string {
+	if d == dirDownload {
+		return "Part"
+	}
+	return "Upload"
+}
+
+// Outcome of a set of parallel transfers
+type transfer struct {
+	start     time.Time
+	end       time.Time
+	bytes     int64
+	omitted   int64
+	parts     []int64
+	errs      []string
+	intervals []Interval
+	conns     []ConnStats
+	speed     *SpeedStats
+
+	// Name of the methodology which ended the transfer, if it did
+	stoppedBy string
+}
+
+// Default length of the throughput samples
+const defaultSampleInterval = 250 * time.Millisecond
+
+// Refresh period of the progress dashboard
+const progressInterval = 500 * time.Millisecond
+
+// Period without any byte moved after which a connection is stalled
+const stallInterval = 500 * time.Millisecond
+
+// Function transferring one part, adding the bytes moved to counter
+type transferFunc func(ctx context.Context, part int, counter *stats.Counter) error
+
+// Run one transferFunc per connection until they all finish, the test
+// duration elapses or ctx is cancelled. In-flight requests are cancelled
+// and waited for before returning.
+func runTransfer(ctx context.Context, opts Options, dir direction, size int64, fn transferFunc) *transfer {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// If duration is specified, stop the test after the specified time,
+	// not counting the omitted warm-up
+	if opts.Duration > 0 {
+		ctx, cancel = context.WithTimeout(ctx, opts.Omit+opts.Duration)
+		defer cancel()
+	}
+
+	var errs errorList
+
+	// Create a wait group to wait for all goroutines to finish
+	var wg sync.WaitGroup
+	start := time.Now()
+
+	counters := stats.New(opts.Concurrent, start)
+	partErrors := make([]int, opts.Concurrent)
+	watches := make([]connWatch, opts.Concurrent)
+	// Client.Run shares its limiter between the download and the upload
+	if l := opts.limiter; l != nil || opts.RateLimit > 0 {
+		if l == nil {
+			l = newRateLimiter(opts.RateLimit)
+		}
+		counters.Throttle(func(n int64) bool { return l.wait(ctx, n) })
+	}
+	phase := string(dir)
+	opts.emit(Event{Type: EventPhaseStart, Phase: phase, At: start})
+	for i := 0; i < opts.Concurrent; i++ {
+		wg.Add(1)
+		go func(part int) {
+			defer wg.Done()
+			opts.emit(Event{Type: EventConnStart, Phase: phase, Part: part})
+			err := fn(watches[part].context(ctx), part, counters.Counter(part))
+			counters.Finish(part, time.Now())
+			watches[part].sample()
+			// Errors caused by the end of the test are expected
+			if err != nil && ctx.Err() == nil {
+				errs.add("%v", err)
+				partErrors[part]++
+				opts.emit(Event{Type: EventError, Phase: phase, Part: part, Err: err.Error()})
+			}
+			opts.emit(Event{Type: EventConnEnd, Phase: phase, Part: part, Bytes: counters.Counter(part).Load()})
+		}(i)
+	}
+
+	// Watch for connections making no progress, and keep their TCP
+	// statistics while they are open
+	stallsDone := make(chan struct{})
+	go func() {
+		defer close(stallsDone)
+		ticker := time.NewTicker(stallInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				counters.CheckStalls()
+				for i := range watches {
+					watches[i].sample()
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	// Cancel the context once all parts are done
+	go func() {
+		wg.Wait()
+		cancel()
+	}()
+
+	// Refresh the dashboard and report the progress twice a second
+	progressDone := make(chan struct{})
+	if opts.Progress != nil || opts.OnProgress != nil || opts.Events != nil {
+		var screen *tui.Screen
+		if opts.Progress != nil {
+			screen = tui.New(opts.Progress)
+		}
+		go func() {
+			defer close(progressDone)
+			if screen != nil {
+				defer screen.Close()
+			}
+			ticker := time.NewTicker(progressInterval)
+			defer ticker.Stop()
+			var prev stats.Snapshot
+			var history []float64
+			for {
+				select {
+				case now := <-ticker.C:
+					snap := counters.Snapshot(now)
+					rate := snap.Sub(prev).BytesPerSecond()
+					history = append(history, rate)
+					prev = snap
+					p := Snapshot{
+						Phase:    phase,
+						Elapsed:  snap.At,
+						Duration: opts.Duration + opts.Omit,
+						Bytes:    snap.Total,
+						Parts:    snap.Parts,
+						Size:     max(size, 0),
+						Rate:     rate,
+						Avg:      snap.BytesPerSecond(),
+						Latency:  loadedLatency(ctx),
+					}
+					if opts.OnProgress != nil {
+						opts.OnProgress(p)
+					}
+					opts.emit(Event{Type: EventProgress, Phase: phase, At: now, Progress: &p})
+					if screen == nil {
+						continue
+					}
+					screen.Draw(tui.Frame{
+						Title:    string(dir),
+						Label:    dir.barLabel(),
+						Parts:    snap.Parts,
+						PartSize: size / int64(opts.Concurrent),
+						Total:    snap.Total,
+						Rate:     rate,
+						Avg:      snap.BytesPerSecond(),
+						History:  history,
+						Elapsed:  snap.At,
+						Duration: opts.Duration + opts.Omit,
+						Latency:  loadedLatency(ctx),
+					})
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	} else {
+		close(progressDone)
+	}
+
+	// Remember the counters at the end of the warm-up, the results only
+	// count what was transferred after it
+	var omitMu sync.Mutex
+	var omitted stats.Snapshot
+	if opts.Omit > 0 {
+		timer := time.AfterFunc(opts.Omit, func() {
+			omitMu.Lock()
+			defer omitMu.Unlock()
+			if ctx.Err() == nil {
+				omitted = counters.Snapshot(time.Now())
+			}
+		})
+		defer timer.Stop()
+	}
+
+	// Sample the instantaneous throughput, and end the transfer when the
+	// methodology has measured enough after the warm-up
+	bucket := opts.SampleInterval
+	if bucket <= 0 {
+		bucket = defaultSampleInterval
+	}
+	var rates []float64
+	var stoppedBy string
+	ratesDone := make(chan struct{})
+	go func() {
+		defer close(ratesDone)
+		ticker := time.NewTicker(bucket)
+		defer ticker.Stop()
+		var prev stats.Snapshot
+		for {
+			select {
+			case now := <-ticker.C:
+				snap := counters.Snapshot(now)
+				rates = append(rates, snap.Sub(prev).BytesPerSecond())
+				prev = snap
+				if opts.Methodology == nil || snap.At < opts.Omit {
+					continue
+				}
+				omitMu.Lock()
+				base := omitted
+				omitMu.Unlock()
+				if opts.Omit > 0 && base.At == 0 {
+					continue
+				}
+				d := snap.Sub(base)
+				m := Measurement{Elapsed: d.At, Bytes: d.Total, Samples: rates[min(int(base.At/bucket), len(rates)):], Interval: bucket}
+				if opts.Methodology.Done(m) {
+					stoppedBy = opts.Methodology.Name()
+					cancel()
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	// Sample the counters at each reporting interval
+	var sampler *intervalSampler
+	samplerDone := make(chan struct{})
+	if opts.Interval > 0 {
+		sampler = newIntervalSampler(dir, opts.Omit, opts.IntervalOutput, opts.Units)
+		go func() {
+			defer close(samplerDone)
+			ticker := time.NewTicker(opts.Interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case now := <-ticker.C:
+					sampler.sample(counters.Snapshot(now))
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	} else {
+		close(samplerDone)
+	}
+
+	<-ctx.Done()
+	end := time.Now()
+	final := counters.Snapshot(end)
+
+	// Close the last, possibly shorter, interval
+	<-samplerDone
+	var intervals []Interval
+	if sampler != nil {
+		if final.At-sampler.last.At > opts.Interval/10 {
+			sampler.sample(final)
+		}
+		intervals = sampler.intervals
+	}
+
+	// Let the cancelled requests close their bodies
+	wg.Wait()
+	<-stallsDone
+	<-ratesDone
+	<-progressDone
+
+	omitMu.Lock()
+	base := omitted
+	omitMu.Unlock()
+	counted := final.Sub(base)
+	if skip := int(base.At / bucket); skip < len(rates) {
+		rates = rates[skip:]
+	}
+
+	opts.emit(Event{Type: EventPhaseEnd, Phase: phase, Bytes: counted.Total})
+
+	conns := make([]ConnStats, opts.Concurrent)
+	for i, c := range counters.Conns(end) {
+		conns[i] = ConnStats{Part: i, Bytes: counted.Parts[i], Elapsed: max(c.Elapsed-base.At, 0), Stalls: c.Stalls, Errors: partErrors[i], Restarts: c.Restarts, Retries: c.Retries, TCP: watches[i].info()}
+	}
+
+	return &transfer{
+		start:     start.Add(base.At),
+		end:       end,
+		bytes:     counted.Total,
+		omitted:   base.Total,
+		parts:     counted.Parts,
+		errs:      errs.list(),
+		intervals: intervals,
+		conns:     conns,
+		speed:     newSpeedStats(bucket, rates),
+		stoppedBy: stoppedBy,
+	}
+}
+
+// Build the download result of a transfer
+func (t *transfer) downloadResult(opts Options, target string, fileSize int64) *Result {
+	return &Result{
+		Target:     target,
+		FileSize:   fileSize,
+		Concurrent: opts.Concurrent,
+		Start:      t.start,
+		End:        t.end,
+		Elapsed:    t.end.Sub(t.start),
+		Bytes:      t.bytes,
+		Omitted:    t.omitted,
+		Parts:      t.parts,
+		Errors:     t.errs,
+		Intervals:  t.intervals,
+		Conns:      t.conns,
+		Speed:      t.speed,
+		StoppedBy:  t.stoppedBy,
+	}
+}
+
+// Build the upload result of a transfer
+func (t *transfer) uploadResult(opts Options, target string, size int64) *UploadResult {
+	return &UploadResult{
+		Target:     target,
+		Size:       size,
+		Concurrent: opts.Concurrent,
+		Start:      t.start,
+		End:        t.end,
+		Elapsed:    t.end.Sub(t.start),
+		Bytes:      t.bytes,
+		Omitted:    t.omitted,
+		Parts:      t.parts,
+		Errors:     t.errs,
+		Intervals:  t.intervals,
+		Conns:      t.conns,
+		Speed:      t.speed,
+		StoppedBy:  t.stoppedBy,
+	}
+}
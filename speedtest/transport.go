@@ -0,0 +1,279 @@
+package speedtest
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/quic-go/quic-go/http3"
+	"golang.org/x/net/http2"
+)
+
+// TransportOptions tunes the connections opened by a Client
+type TransportOptions struct {
+	// Proxy URL (http://, https:// or socks5://), nil to use the
+	// HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables
+	Proxy *url.URL
+
+	// Ignore the proxy environment variables
+	NoProxyEnv bool
+
+	// TLS settings of https connections (verification, client
+	// certificates, versions), nil keeps the defaults
+	TLS *tls.Config
+
+	// Headers added to every request, e.g. Authorization or Cookie
+	Header http.Header
+
+	// Log the request and response headers at LevelTrace
+	DumpHeaders bool
+
+	// Idle connections kept per server for reuse (0 keeps the default
+	// of 2)
+	MaxIdleConnsPerHost int
+
+	// Don't ask for gzip compressed responses
+	DisableCompression bool
+
+	// Open a new connection for every request
+	DisableKeepAlives bool
+
+	// Kernel receive and send buffer sizes of the TCP connections in
+	// bytes, 0 keeps the system defaults
+	ReadBuffer  int
+	WriteBuffer int
+
+	// Maximum time to establish a TCP connection (0 keeps the default of
+	// 30s)
+	DialTimeout time.Duration
+
+	// Cache TLS sessions so that new connections resume them instead of
+	// doing a full handshake
+	TLSSessionCache bool
+
+	// Resolver of the server names, nil for the system one (see
+	// NewResolver)
+	Resolver *net.Resolver
+
+	// Addresses to connect to instead of resolving, by host:port, like
+	// curl --resolve. The values are ip:port, or several of them comma
+	// separated which are raced like Happy Eyeballs.
+	Resolve map[string]string
+
+	// Send the traffic through this network interface (e.g. wg0 or eth0)
+	// rather than the one the routing table picks. Linux binds the
+	// sockets to the device, other systems use the interface's address
+	// as the source. Not used with DialContext, nor by ICMP probes and
+	// HTTP/3.
+	Interface string
+
+	// Open the connections with this function instead of the system
+	// network stack, e.g. the Dial method of a VPN library or of a
+	// Tailscale tsnet.Server. It serves the HTTP requests and the TCP
+	// probes, raw TCP, UDP, FTP and WebSocket tests; ICMP probes and
+	// HTTP/3 keep using the system stack. Resolver is not used, the
+	// function resolving the names itself.
+	DialContext func(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+// Configure applies the transport options to the client. Clients sharing
+// http.DefaultClient get their own copy of the default transport first.
+func (c *Client) Configure(o TransportOptions) error {
+	if c.HTTPClient == nil || c.HTTPClient == http.DefaultClient || c.HTTPClient.Transport == nil {
+		c.HTTPClient = &http.Client{Transport: http.DefaultTransport.(*http.Transport).Clone()}
+	}
+	if o.Proxy != nil {
+		switch o.Proxy.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return fmt.Errorf("unsupported proxy scheme %q", o.Proxy.Scheme)
+		}
+	}
+	c.resolve, c.iface = o.Resolve, o.Interface
+	if o.Interface != "" {
+		if err := bindInterface(&net.Dialer{}, o.Interface); err != nil {
+			return fmt.Errorf("invalid interface: %w", err)
+		}
+	}
+	if err := configure(c.HTTPClient.Transport, o); err != nil {
+		return err
+	}
+	if len(o.Header) > 0 {
+		c.HTTPClient.Transport = &headerTransport{rt: c.HTTPClient.Transport, header: o.Header}
+	}
+	if o.DumpHeaders {
+		c.HTTPClient.Transport = &dumpTransport{rt: c.HTTPClient.Transport, log: c.log}
+	}
+	return nil
+}
+
+func configure(rt http.RoundTripper, o TransportOptions) error {
+	switch t := rt.(type) {
+	case *http.Transport:
+		switch {
+		case o.Proxy != nil:
+			t.Proxy = http.ProxyURL(o.Proxy)
+		case o.NoProxyEnv:
+			t.Proxy = nil
+		}
+		t.TLSClientConfig = tlsConfig(t.TLSClientConfig, o)
+		if o.MaxIdleConnsPerHost > 0 {
+			t.MaxIdleConnsPerHost = o.MaxIdleConnsPerHost
+		}
+		t.DisableCompression = t.DisableCompression || o.DisableCompression
+		t.DisableKeepAlives = t.DisableKeepAlives || o.DisableKeepAlives
+		if o.customDial() {
+			t.DialContext = dialer(o)
+		}
+	case *headerTransport:
+		return configure(t.rt, o)
+	case *dumpTransport:
+		return configure(t.rt, o)
+	case schemeTransport:
+		for _, rt := range t {
+			if err := configure(rt, o); err != nil {
+				return err
+			}
+		}
+	case *http2.Transport:
+		t.TLSClientConfig = tlsConfig(t.TLSClientConfig, o)
+		t.DisableCompression = t.DisableCompression || o.DisableCompression
+		// Cleartext HTTP/2 dials plain connections through DialTLSContext
+		if t.AllowHTTP && o.customDial() {
+			dial := dialer(o)
+			t.DialTLSContext = func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+				return dial(ctx, network, addr)
+			}
+		}
+	case *http3.Transport:
+		t.TLSClientConfig = tlsConfig(t.TLSClientConfig, o)
+		t.DisableCompression = t.DisableCompression || o.DisableCompression
+	}
+	// These dial their own connections, a proxy can't be used
+	switch rt.(type) {
+	case *http2.Transport, *http3.Transport:
+		if o.Proxy != nil {
+			return fmt.Errorf("proxies are not supported with %T", rt)
+		}
+	}
+	if _, ok := rt.(*http3.Transport); ok && (o.DialContext != nil || o.Interface != "") {
+		return fmt.Errorf("custom dial functions and interfaces are not supported with %T", rt)
+	}
+	return nil
+}
+
+// Return the TLS configuration of a transport with the options applied
+func tlsConfig(config *tls.Config, o TransportOptions) *tls.Config {
+	if o.TLS != nil {
+		config = o.TLS.Clone()
+	}
+	if o.TLSSessionCache {
+		if config == nil {
+			config = &tls.Config{}
+		}
+		config.ClientSessionCache = tls.NewLRUClientSessionCache(0)
+	}
+	return config
+}
+
+// Tell if the options change how connections are dialed
+func (o TransportOptions) customDial() bool {
+	return o.DialTimeout > 0 || o.ReadBuffer > 0 || o.WriteBuffer > 0 || o.Resolver != nil || len(o.Resolve) > 0 || o.DialContext != nil || o.Interface != ""
+}
+
+// Return a dial function with the timeout, socket buffer sizes, name
+// resolution, interface and custom dial function of the options
+func dialer(o TransportOptions) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	d := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, Resolver: o.Resolver}
+	if o.DialTimeout > 0 {
+		d.Timeout = o.DialTimeout
+	}
+	if o.Interface != "" {
+		// Checked by Configure
+		bindInterface(d, o.Interface)
+	}
+	dial := d.DialContext
+	if o.DialContext != nil {
+		// Bound the custom dials by the timeout too
+		dial = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			ctx, cancel := context.WithTimeout(ctx, d.Timeout)
+			defer cancel()
+			return o.DialContext(ctx, network, addr)
+		}
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		addrs := []string{addr}
+		if to, ok := o.Resolve[addr]; ok {
+			addrs = strings.Split(to, ",")
+		}
+		var conn net.Conn
+		var err error
+		if len(addrs) > 1 {
+			conn, err = raceDial(ctx, dial, network, addrs)
+		} else {
+			conn, err = dial(ctx, network, addrs[0])
+		}
+		if err != nil {
+			return nil, err
+		}
+		if tc, ok := conn.(*net.TCPConn); ok {
+			if o.ReadBuffer > 0 {
+				err = tc.SetReadBuffer(o.ReadBuffer)
+			}
+			if o.WriteBuffer > 0 && err == nil {
+				err = tc.SetWriteBuffer(o.WriteBuffer)
+			}
+			if err != nil {
+				conn.Close()
+				return nil, fmt.Errorf("failed to set socket buffers: %w", err)
+			}
+		}
+		return conn, nil
+	}
+}
+
+// Add headers to the requests sent through rt
+type headerTransport struct {
+	rt     http.RoundTripper
+	header http.Header
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for k, v := range t.header {
+		req.Header[k] = v
+	}
+	return t.rt.RoundTrip(req)
+}
+
+// Return the transport of the client below the dump and header wrappers,
+// and the headers they add
+func (c *Client) transport() (http.RoundTripper, http.Header) {
+	rt := c.HTTPClient.Transport
+	if t, ok := rt.(*dumpTransport); ok {
+		rt = t.rt
+	}
+	if t, ok := rt.(*headerTransport); ok {
+		return t.rt, t.header.Clone()
+	}
+	return rt, http.Header{}
+}
+
+// Dial function of the client transport, for the connections opened
+// outside of HTTP requests: TCP probes, raw TCP, UDP and FTP tests
+func (c *Client) dialContext() func(ctx context.Context, network, addr string) (net.Conn, error) {
+	rt, _ := c.transport()
+	if t, ok := rt.(schemeTransport); ok {
+		rt = t["https"]
+	}
+	if t, ok := rt.(*http.Transport); ok && t.DialContext != nil {
+		return t.DialContext
+	}
+	var d net.Dialer
+	return d.DialContext
+}
@@ -0,0 +1,33 @@
+package speedtest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// The cleartext HTTP/2 connections are dialed with the options
+func TestMultiplexResolve(t *testing.T) {
+	srv := httptest.NewServer(h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	}), &http2.Server{}))
+	defer srv.Close()
+
+	c := NewMultiplexClient()
+	addr := strings.TrimPrefix(srv.URL, "http://")
+	if err := c.Configure(TransportOptions{Resolve: map[string]string{"speedtest.invalid:80": addr}}); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := c.HTTPClient.Get("http://speedtest.invalid/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.ProtoMajor != 2 {
+		t.Errorf("answered with %s, want HTTP/2", resp.Proto)
+	}
+}
@@ -0,0 +1,264 @@
+package speedtest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// UDP test datagram layout: magic, kind, session, sequence number and
+// send timestamp, followed by padding up to the packet size
+const (
+	udpMagic      = "GSTU"
+	udpHeaderSize = 4 + 1 + 8 + 8 + 8
+
+	udpData  = 'D'
+	udpFin   = 'F'
+	udpStats = 'S'
+	udpEcho  = 'E'
+
+	// Defaults of the UDP test
+	DefaultUDPRate       = 10 * 1000 * 1000
+	DefaultUDPPacketSize = 1200
+)
+
+// UDPOptions describes a UDP throughput test
+type UDPOptions struct {
+	// Address (host:port) of a server started with UDPServe
+	Target string
+
+	// Sending rate in bits/sec
+	Rate int64
+
+	// Size of each datagram in bytes
+	PacketSize int
+
+	// Test duration (10 seconds by default)
+	Duration time.Duration
+}
+
+// UDPResult holds the outcome of a UDP test, as seen by the server
+type UDPResult struct {
+	Target     string        `json:"target"`
+	Rate       int64         `json:"rate_bps"`
+	PacketSize int           `json:"packet_size"`
+	Start      time.Time     `json:"start"`
+	Elapsed    time.Duration `json:"elapsed_ns"`
+
+	Sent      int64         `json:"sent"`
+	Received  int64         `json:"received"`
+	Reordered int64         `json:"reordered"`
+	Bytes     int64         `json:"bytes"`
+	Jitter    time.Duration `json:"jitter_ns"`
+}
+
+// Loss returns the percentage of datagrams that didn't reach the server
+func (r *UDPResult) Loss() float64 {
+	if r.Sent == 0 {
+		return 0
+	}
+	lost := r.Sent - r.Received
+	if lost < 0 {
+		lost = 0
+	}
+	return float64(lost) / float64(r.Sent) * 100
+}
+
+// BytesPerSecond returns the throughput received by the server
+func (r *UDPResult) BytesPerSecond() float64 {
+	return float64(r.Bytes) / r.Elapsed.Seconds()
+}
+
+// Statistics kept by the server for each client session
+type udpSession struct {
+	Received  int64         `json:"received"`
+	Reordered int64         `json:"reordered"`
+	Bytes     int64         `json:"bytes"`
+	Jitter    time.Duration `json:"jitter_ns"`
+
+	maxSeq      int64
+	lastTransit time.Duration
+	jitter      float64
+	lastSeen    time.Time
+}
+
+// Update the session with a data datagram, jitter is computed as in
+// RFC 3550
+func (s *udpSession) add(seq int64, sent time.Time, size int, now time.Time) {
+	s.Received++
+	s.Bytes += int64(size)
+	if seq <= s.maxSeq {
+		s.Reordered++
+	} else {
+		s.maxSeq = seq
+	}
+	transit := now.Sub(sent)
+	if s.Received > 1 {
+		d := transit - s.lastTransit
+		if d < 0 {
+			d = -d
+		}
+		s.jitter += (float64(d) - s.jitter) / 16
+	}
+	s.lastTransit = transit
+	s.Jitter = time.Duration(s.jitter)
+}
+
+func udpPacket(kind byte, session []byte, seq int64, buf []byte) []byte {
+	copy(buf, udpMagic)
+	buf[4] = kind
+	copy(buf[5:13], session)
+	binary.BigEndian.PutUint64(buf[13:], uint64(seq))
+	binary.BigEndian.PutUint64(buf[21:], uint64(time.Now().UnixNano()))
+	return buf
+}
+
+// UDPServe answers UDP test clients on conn until ctx is cancelled
+func UDPServe(ctx context.Context, conn net.PacketConn) error {
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	sessions := make(map[string]*udpSession)
+	buf := make([]byte, 65536)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		now := time.Now()
+		if n < udpHeaderSize || string(buf[:4]) != udpMagic {
+			continue
+		}
+		// Echoed datagrams keep no state, their sender times them
+		if buf[4] == udpEcho {
+			conn.WriteTo(buf[:n], addr)
+			continue
+		}
+		key := addr.String() + string(buf[5:13])
+		s := sessions[key]
+		if s == nil {
+			s = &udpSession{maxSeq: -1}
+			sessions[key] = s
+		}
+		s.lastSeen = now
+		seq := int64(binary.BigEndian.Uint64(buf[13:]))
+
+		switch buf[4] {
+		case udpData:
+			sent := time.Unix(0, int64(binary.BigEndian.Uint64(buf[21:])))
+			s.add(seq, sent, n, now)
+		case udpFin:
+			stats, _ := json.Marshal(s)
+			reply := append(udpPacket(udpStats, buf[5:13], 0, make([]byte, udpHeaderSize)), stats...)
+			conn.WriteTo(reply, addr)
+
+			// Forget sessions gone for a while, keeping recent ones for
+			// retried FINs
+			for k, old := range sessions {
+				if now.Sub(old.lastSeen) > time.Minute {
+					delete(sessions, k)
+				}
+			}
+		}
+	}
+}
+
+// RunUDP sends paced datagrams to a UDPServe server and reports the
+// throughput, loss, reordering and jitter it measured
+func (c *Client) RunUDP(ctx context.Context, opts UDPOptions) (*UDPResult, error) {
+	if opts.Target == "" {
+		return nil, errors.New("target address is required")
+	}
+	if opts.Rate <= 0 {
+		opts.Rate = DefaultUDPRate
+	}
+	if opts.PacketSize < udpHeaderSize {
+		opts.PacketSize = DefaultUDPPacketSize
+	}
+	if opts.Duration <= 0 {
+		opts.Duration = backendDuration
+	}
+
+	conn, err := c.dialContext()(ctx, "udp", opts.Target)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	session := make([]byte, 8)
+	rand.Read(session)
+
+	// Send datagrams at the requested pace until the duration elapses
+	tctx, cancel := context.WithTimeout(ctx, opts.Duration)
+	defer cancel()
+	gap := time.Duration(float64(opts.PacketSize*8) / float64(opts.Rate) * float64(time.Second))
+	buf := make([]byte, opts.PacketSize)
+	start := time.Now()
+	var seq int64
+	for tctx.Err() == nil {
+		next := start.Add(time.Duration(seq) * gap)
+		if wait := time.Until(next); wait > 0 {
+			select {
+			case <-time.After(wait):
+			case <-tctx.Done():
+				continue
+			}
+		}
+		// Errors such as ICMP port unreachable only mean lost datagrams
+		conn.Write(udpPacket(udpData, session, seq, buf))
+		seq++
+	}
+	elapsed := time.Since(start)
+
+	// Ask the server for its statistics, retrying lost FINs. This is done
+	// even if ctx was cancelled to report a partial result.
+	stats, err := udpFinish(conn, session, seq)
+	if err != nil {
+		return nil, err
+	}
+	return &UDPResult{
+		Target:     opts.Target,
+		Rate:       opts.Rate,
+		PacketSize: opts.PacketSize,
+		Start:      start,
+		Elapsed:    elapsed,
+		Sent:       seq,
+		Received:   stats.Received,
+		Reordered:  stats.Reordered,
+		Bytes:      stats.Bytes,
+		Jitter:     stats.Jitter,
+	}, nil
+}
+
+func udpFinish(conn net.Conn, session []byte, sent int64) (*udpSession, error) {
+	fin := udpPacket(udpFin, session, sent, make([]byte, udpHeaderSize))
+	reply := make([]byte, 65536)
+	for i := 0; i < 5; i++ {
+		if _, err := conn.Write(fin); err != nil {
+			return nil, err
+		}
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, err := conn.Read(reply)
+		if err != nil {
+			continue
+		}
+		if n > udpHeaderSize && string(reply[:4]) == udpMagic && reply[4] == udpStats && string(reply[5:13]) == string(session) {
+			var s udpSession
+			if err := json.Unmarshal(reply[udpHeaderSize:n], &s); err != nil {
+				return nil, fmt.Errorf("invalid server statistics: %w", err)
+			}
+			return &s, nil
+		}
+	}
+	return nil, errors.New("no statistics received from the server")
+}
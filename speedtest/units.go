@@ -0,0 +1,88 @@
+package speedtest
+
+import (
+	"fmt"
+	"math"
+)
+
+// Rate units of Units.Mode
+const (
+	UnitsAuto  = "auto"
+	UnitsBits  = "bits"
+	UnitsBytes = "bytes"
+)
+
+// Units selects how the progress, interval and summary output print rates
+type Units struct {
+	// UnitsBits (Mbit/sec, like ISP plans), UnitsBytes (MB/sec) or
+	// UnitsAuto, the default, giving bits followed by bytes
+	Mode string
+
+	// Use binary prefixes (Mibit/sec, MiB/sec) instead of decimal ones
+	IEC bool
+}
+
+// ParseUnits checks the name of a units mode
+func ParseUnits(mode string, iec bool) (Units, error) {
+	switch mode {
+	case "", UnitsAuto, UnitsBits, UnitsBytes:
+		return Units{Mode: mode, IEC: iec}, nil
+	}
+	return Units{}, fmt.Errorf("invalid units %q, expected bits, bytes or auto", mode)
+}
+
+var (
+	siPrefixes  = []string{"", "k", "M", "G", "T"}
+	iecPrefixes = []string{"", "Ki", "Mi", "Gi", "Ti"}
+)
+
+// Return the divisor and the name of the unit fitting v bytes/sec
+func (u Units) unit(v float64, bits bool) (float64, string) {
+	base, prefixes := 1000.0, siPrefixes
+	if u.IEC {
+		base, prefixes = 1024, iecPrefixes
+	}
+	name, div := "B/sec", 1.0
+	if bits {
+		name, div = "bit/sec", 1.0/8
+	}
+	i := 0
+	for i < len(prefixes)-1 && math.Abs(v)/div >= base {
+		div *= base
+		i++
+	}
+	return div, prefixes[i] + name
+}
+
+// Short formats a rate in bytes/sec in the main unit only, bits unless the
+// mode is UnitsBytes
+func (u Units) Short(v float64) string {
+	div, name := u.unit(v, u.Mode != UnitsBytes)
+	return fmt.Sprintf("%.2f %s", v/div, name)
+}
+
+// Rate formats a rate in bytes/sec, followed by the bytes in parentheses
+// in auto mode
+func (u Units) Rate(v float64) string {
+	s := u.Short(v)
+	if u.Mode == "" || u.Mode == UnitsAuto {
+		div, name := u.unit(v, false)
+		s += fmt.Sprintf(" (%.2f %s)", v/div, name)
+	}
+	return s
+}
+
+// Scale converts several rates in bytes/sec to the main unit fitting the
+// largest one, so they can be printed side by side
+func (u Units) Scale(values ...float64) ([]float64, string) {
+	var top float64
+	for _, v := range values {
+		top = max(top, v)
+	}
+	div, name := u.unit(top, u.Mode != UnitsBytes)
+	scaled := make([]float64, len(values))
+	for i, v := range values {
+		scaled[i] = v / div
+	}
+	return scaled, name
+}
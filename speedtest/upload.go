@@ -0,0 +1,115 @@
+package speedtest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/ofauchon/go-speedtest/internal/stats"
+)
+
+// UploadResult holds the outcome of the upload phase
+type UploadResult struct {
+	Target     string        `json:"target"`
+	Size       int64         `json:"size"`
+	Concurrent int           `json:"concurrent"`
+	Start      time.Time     `json:"start"`
+	End        time.Time     `json:"end"`
+	Elapsed    time.Duration `json:"elapsed_ns"`
+
+	// Bytes actually sent, all connections together
+	Bytes int64 `json:"bytes"`
+
+	// Bytes sent during the Options.Omit warm-up, not counted in Bytes
+	Omitted int64 `json:"omitted_bytes,omitempty"`
+
+	// Bytes sent by each connection
+	Parts []int64 `json:"parts"`
+
+	// Errors reported by the connections
+	Errors []string `json:"errors,omitempty"`
+
+	// What each connection did
+	Conns []ConnStats `json:"connections,omitempty"`
+
+	// Distribution of the instantaneous throughput
+	Speed *SpeedStats `json:"speed,omitempty"`
+
+	// Methodology which ended the phase (Options.Methodology), empty if
+	// the file or the duration did
+	StoppedBy string `json:"stopped_by,omitempty"`
+
+	// Throughput at each reporting interval, if enabled
+	Intervals []Interval `json:"intervals,omitempty"`
+}
+
+// BytesPerSecond returns the upload speed in bytes/sec, computed from
+// the bytes actually sent
+func (r *UploadResult) BytesPerSecond() float64 {
+	if r.Elapsed <= 0 {
+		return 0
+	}
+	return float64(r.Bytes) / r.Elapsed.Seconds()
+}
+
+// MBytesPerSecond returns the upload speed in MB/sec
+func (r *UploadResult) MBytesPerSecond() float64 {
+	return r.BytesPerSecond() / (1024 * 1024)
+}
+
+func (c *Client) upload(ctx context.Context, opts Options) (*UploadResult, error) {
+	if opts.multipart {
+		return c.multipartUpload(ctx, opts)
+	}
+	concurrent := int64(opts.Concurrent)
+	size := opts.UploadSize
+	target := opts.UploadTarget
+	if target == "" {
+		target = opts.Target
+	}
+	method := opts.UploadMethod
+	if method == "" {
+		method = http.MethodPost
+	}
+
+	block := payloadBlock(opts.UploadCompressibility)
+
+	// Function to upload a part of the payload
+	uploadPart := func(ctx context.Context, part int, counter *stats.Counter) error {
+		p := int64(part)
+		partSize := (p+1)*size/concurrent - p*size/concurrent
+		if size < 0 {
+			partSize = -1
+		}
+		body := &payloadReader{ctx: ctx, block: block, remain: partSize, counter: counter}
+		req, err := http.NewRequestWithContext(ctx, method, target, body)
+		if err != nil {
+			return err
+		}
+		req.ContentLength = partSize
+		req.Header.Set("Content-Type", "application/octet-stream")
+
+		resp, err := c.HTTPClient.Do(req)
+		if err != nil {
+			return fmt.Errorf("failed to upload part %d: %w", part, err)
+		}
+		defer resp.Body.Close()
+		io.Copy(io.Discard, resp.Body)
+		return checkUploadStatus(resp, part)
+	}
+
+	t := runTransfer(ctx, opts, dirUpload, size, uploadPart)
+
+	return t.uploadResult(opts, target, size), nil
+}
+
+// Check the status of an upload answer, the bytes sent are not accepted
+// by a server answering an error
+func checkUploadStatus(resp *http.Response, part int) error {
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("failed to upload part %d: server returned %s", part, resp.Status)
+	}
+	return nil
+}
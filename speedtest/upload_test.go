@@ -0,0 +1,41 @@
+package speedtest
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Bytes refused by the server are reported as errors
+func TestUploadStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		http.Error(w, "too large", http.StatusRequestEntityTooLarge)
+	}))
+	defer srv.Close()
+
+	res, err := NewClient().Run(context.Background(), Options{
+		Target:       srv.URL,
+		Concurrent:   2,
+		Upload:       true,
+		SkipDownload: true,
+		UploadSize:   1 << 20,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Upload == nil {
+		t.Fatalf("no upload result: %+v", res)
+	}
+	if len(res.Upload.Errors) != 2 {
+		t.Fatalf("errors %q, want one per connection", res.Upload.Errors)
+	}
+	for _, e := range res.Upload.Errors {
+		if !strings.Contains(e, "413") {
+			t.Errorf("error %q, want the status", e)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package speedtest
+
+// VideoTier is a video streaming quality and the bitrate a stream needs
+type VideoTier struct {
+	Name    string `json:"name"`
+	Bitrate int64  `json:"bitrate_bps"`
+}
+
+// Streaming tiers, lowest first, at the bitrates streaming services
+// recommend per stream
+var VideoTiers = []VideoTier{
+	{"SD", 3_000_000},
+	{"HD", 5_000_000},
+	{"4K", 15_000_000},
+}
+
+// VideoStreams is how many streams of a tier the link carries at once
+type VideoStreams struct {
+	VideoTier
+	Streams int `json:"streams"`
+}
+
+// VideoEstimate is the streaming capability of the link
+type VideoEstimate struct {
+	// Throughput the estimate is based on, in bytes/sec
+	Sustained float64 `json:"sustained_bytes_per_second"`
+
+	// Highest tier with at least one stream, empty if none
+	Tier string `json:"tier"`
+
+	Tiers []VideoStreams `json:"tiers"`
+}
+
+// Video estimates the video streams the link supports from the download.
+// Players buffer a few seconds, so the rate has to be sustained rather
+// than reached: it is the throughput kept 95% of the time, but not less
+// than half the median, short dips such as the slow start being absorbed.
+// Nil if no download was run.
+func (r *Result) Video() *VideoEstimate {
+	if r.DownloadSkipped || r.Elapsed <= 0 {
+		return nil
+	}
+	sustained := r.BytesPerSecond()
+	if s := r.Speed; s != nil && s.Samples >= 10 {
+		sustained = min(sustained, max(s.P5, s.Median/2))
+	}
+	v := &VideoEstimate{Sustained: sustained}
+	for _, t := range VideoTiers {
+		n := int(sustained * 8 / float64(t.Bitrate))
+		if n > 0 {
+			v.Tier = t.Name
+		}
+		v.Tiers = append(v.Tiers, VideoStreams{t, n})
+	}
+	return v
+}
@@ -0,0 +1,231 @@
+package speedtest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Time to wait for the last echoes after the stream ends
+const voipDrain = time.Second
+
+// VoIPProfile is the traffic pattern of a realtime application
+type VoIPProfile struct {
+	Name       string        `json:"name"`
+	PacketSize int           `json:"packet_size"`
+	Interval   time.Duration `json:"interval_ns"`
+}
+
+// Built-in profiles: a G.711 call (160 bytes of audio and the RTP header
+// every 20ms) and a fast paced game sending small updates at 64 Hz
+var VoIPProfiles = map[string]VoIPProfile{
+	"voip": {Name: "voip", PacketSize: 172, Interval: 20 * time.Millisecond},
+	"game": {Name: "game", PacketSize: 64, Interval: time.Second / 64},
+}
+
+// VoIPOptions describes a realtime traffic simulation
+type VoIPOptions struct {
+	// Address (host:port) of a server started with UDPServe
+	Target string
+
+	// Traffic pattern, VoIPProfiles["voip"] if zero
+	Profile VoIPProfile
+
+	// Test duration (10 seconds by default)
+	Duration time.Duration
+
+	// If set, a test with these options loads the link during the
+	// simulation, to see the call quality while the link is saturated
+	Load *Options
+}
+
+// VoIPResult holds the quality of a simulated realtime stream
+type VoIPResult struct {
+	Target  string        `json:"target"`
+	Profile VoIPProfile   `json:"profile"`
+	Start   time.Time     `json:"start"`
+	Elapsed time.Duration `json:"elapsed_ns"`
+
+	// Round trip times of the echoed datagrams, their jitter and loss
+	Latency   *LatencyResult `json:"latency"`
+	Reordered int            `json:"reordered"`
+
+	// Load test run meanwhile, if any
+	Load *Result `json:"load,omitempty"`
+}
+
+// RFactor is the transmission rating of the simplified ITU-T G.107
+// E-model, from 0 to 100, counting half the round trip as the one way
+// delay and the jitter buffer as twice the jitter
+func (r *VoIPResult) RFactor() float64 {
+	lat := r.Latency
+	if lat == nil || lat.Received == 0 {
+		return 0
+	}
+	delay := float64(lat.Avg/2+2*lat.Jitter)/float64(time.Millisecond) + 10
+	rf := 93.2 - delay/40
+	if delay >= 160 {
+		rf = 93.2 - (delay-120)/10
+	}
+	rf -= 2.5 * lat.Loss()
+	return math.Max(0, math.Min(100, rf))
+}
+
+// MOS estimates the mean opinion score of a call from the R factor, from
+// 1 (bad) to 4.5 (best narrowband quality)
+func (r *VoIPResult) MOS() float64 {
+	rf := r.RFactor()
+	if rf <= 0 {
+		return 1
+	}
+	return 1 + 0.035*rf + 7e-6*rf*(rf-60)*(100-rf)
+}
+
+// Gaming rates the suitability of the link for online games from its
+// round trip time, jitter and loss: excellent, good, fair or poor
+func (r *VoIPResult) Gaming() string {
+	lat := r.Latency
+	if lat == nil || lat.Received == 0 {
+		return "poor"
+	}
+	levels := []struct {
+		name        string
+		rtt, jitter time.Duration
+		loss        float64
+	}{
+		{"excellent", 30 * time.Millisecond, 5 * time.Millisecond, 0.1},
+		{"good", 60 * time.Millisecond, 10 * time.Millisecond, 0.5},
+		{"fair", 100 * time.Millisecond, 20 * time.Millisecond, 1},
+	}
+	for _, l := range levels {
+		if lat.Avg <= l.rtt && lat.Jitter <= l.jitter && lat.Loss() <= l.loss {
+			return l.name
+		}
+	}
+	return "poor"
+}
+
+// RunVoIP sends datagrams following the profile to a UDPServe server,
+// which echoes them, and measures their round trip time, jitter, loss and
+// reordering. Datagrams not back within a second of the end are lost.
+func (c *Client) RunVoIP(ctx context.Context, opts VoIPOptions) (*VoIPResult, error) {
+	if opts.Target == "" {
+		return nil, errors.New("target address is required")
+	}
+	if opts.Profile.Interval <= 0 {
+		opts.Profile = VoIPProfiles["voip"]
+	}
+	if opts.Profile.PacketSize < udpHeaderSize {
+		return nil, fmt.Errorf("packets are %d bytes at least", udpHeaderSize)
+	}
+	if opts.Duration <= 0 {
+		opts.Duration = backendDuration
+	}
+
+	conn, err := c.dialContext()(ctx, "udp", opts.Target)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	session := make([]byte, 8)
+	rand.Read(session)
+
+	// Saturate the link first, the stream starting once the load ramped up
+	var load *Result
+	loadDone := make(chan struct{})
+	lctx, stopLoad := context.WithCancel(ctx)
+	defer stopLoad()
+	if opts.Load != nil {
+		lopts := *opts.Load
+		lopts.Duration = opts.Duration + 2*voipDrain
+		go func() {
+			defer close(loadDone)
+			var err error
+			if load, err = c.Run(lctx, lopts); err != nil && lctx.Err() == nil {
+				c.log().Warn("load test failed", "err", err)
+			}
+		}()
+		select {
+		case <-time.After(voipDrain):
+		case <-ctx.Done():
+		}
+	} else {
+		close(loadDone)
+	}
+
+	// Read the echoes until the drain delay after the last datagram
+	type echo struct {
+		seq int64
+		rtt time.Duration
+	}
+	echoes := make(chan echo, 64)
+	go func() {
+		defer close(echoes)
+		buf := make([]byte, 65536)
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				return
+			}
+			now := time.Now()
+			if n < udpHeaderSize || string(buf[:4]) != udpMagic || buf[4] != udpEcho || string(buf[5:13]) != string(session) {
+				continue
+			}
+			sent := time.Unix(0, int64(binary.BigEndian.Uint64(buf[21:])))
+			echoes <- echo{int64(binary.BigEndian.Uint64(buf[13:])), now.Sub(sent)}
+		}
+	}()
+	lat := &LatencyResult{Method: "udp-echo"}
+	res := &VoIPResult{Target: opts.Target, Profile: opts.Profile, Latency: lat}
+	received := make(map[int64]bool)
+	collected := make(chan struct{})
+	go func() {
+		defer close(collected)
+		maxSeq := int64(-1)
+		for e := range echoes {
+			if received[e.seq] {
+				continue
+			}
+			received[e.seq] = true
+			if e.seq < maxSeq {
+				res.Reordered++
+			}
+			maxSeq = max(maxSeq, e.seq)
+			lat.Samples = append(lat.Samples, e.rtt)
+		}
+	}()
+
+	tctx, cancel := context.WithTimeout(ctx, opts.Duration)
+	defer cancel()
+	buf := make([]byte, opts.Profile.PacketSize)
+	res.Start = time.Now()
+	var seq int64
+	for tctx.Err() == nil {
+		next := res.Start.Add(time.Duration(seq) * opts.Profile.Interval)
+		if wait := time.Until(next); wait > 0 {
+			select {
+			case <-time.After(wait):
+			case <-tctx.Done():
+				continue
+			}
+		}
+		// Errors such as ICMP port unreachable only mean lost datagrams
+		conn.Write(udpPacket(udpEcho, session, seq, buf))
+		seq++
+	}
+	res.Elapsed = time.Since(res.Start)
+
+	conn.SetReadDeadline(time.Now().Add(voipDrain))
+	<-collected
+	stopLoad()
+	<-loadDone
+	res.Load = load
+
+	lat.Sent, lat.Received = int(seq), len(lat.Samples)
+	lat.compute()
+	return res, nil
+}
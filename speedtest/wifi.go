@@ -0,0 +1,89 @@
+package speedtest
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Signal below which a Wi-Fi link is considered weak, rates dropping and
+// retries rising past it
+const weakRSSI = -70
+
+// WiFiInfo describes the wireless link of the client when the test ran
+type WiFiInfo struct {
+	Interface string `json:"interface"`
+	SSID      string `json:"ssid,omitempty"`
+	BSSID     string `json:"bssid,omitempty"`
+
+	// Band ("2.4GHz", "5GHz" or "6GHz"), channel and its width
+	Band      string `json:"band,omitempty"`
+	Channel   int    `json:"channel,omitempty"`
+	Frequency int    `json:"frequency_mhz,omitempty"`
+	Width     int    `json:"width_mhz,omitempty"`
+
+	// Signal strength (and noise if the system tells) in dBm
+	RSSI  int `json:"rssi_dbm,omitempty"`
+	Noise int `json:"noise_dbm,omitempty"`
+
+	// Negotiated PHY rates in Mbit/s and the standard they use (e.g.
+	// "802.11ac" or "HE"), the receive rate being unknown on macOS
+	TxRate float64 `json:"tx_rate_mbps,omitempty"`
+	RxRate float64 `json:"rx_rate_mbps,omitempty"`
+	PHY    string  `json:"phy,omitempty"`
+
+	Captured time.Time `json:"captured"`
+}
+
+// WeakSignal reports an RSSI low enough to limit the results
+func (w *WiFiInfo) WeakSignal() bool {
+	return w.RSSI != 0 && w.RSSI < weakRSSI
+}
+
+// ReadWiFi captures the link of a wireless interface, the first one
+// connected if iface is empty. It runs "iw" on Linux and system_profiler
+// on macOS, other systems being unsupported.
+func ReadWiFi(ctx context.Context, iface string) (*WiFiInfo, error) {
+	w, err := readWiFi(ctx, iface)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the Wi-Fi link: %w", err)
+	}
+	w.Captured = time.Now()
+	if w.Band == "" {
+		w.Band = wifiBand(w.Frequency)
+	}
+	if w.Channel == 0 {
+		w.Channel = wifiChannel(w.Frequency)
+	}
+	return w, nil
+}
+
+// Band of a channel frequency in MHz
+func wifiBand(freq int) string {
+	switch {
+	case freq >= 2400 && freq < 2500:
+		return "2.4GHz"
+	case freq >= 5150 && freq < 5925:
+		return "5GHz"
+	case freq >= 5925 && freq < 7125:
+		return "6GHz"
+	}
+	return ""
+}
+
+// Channel number of a frequency in MHz, 0 if unknown
+func wifiChannel(freq int) int {
+	switch {
+	case freq == 2484:
+		return 14
+	case freq >= 2412 && freq < 2484:
+		return (freq - 2407) / 5
+	case freq >= 5150 && freq < 5925:
+		return (freq - 5000) / 5
+	case freq == 5935:
+		return 2
+	case freq >= 5950 && freq < 7125:
+		return (freq - 5950) / 5
+	}
+	return 0
+}
@@ -0,0 +1,80 @@
+package speedtest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+func readWiFi(ctx context.Context, iface string) (*WiFiInfo, error) {
+	out, err := exec.CommandContext(ctx, "system_profiler", "-json", "SPAirPortDataType").Output()
+	if err != nil {
+		return nil, fmt.Errorf("system_profiler: %w", err)
+	}
+	return parseAirPort(out, iface)
+}
+
+// Parse the Wi-Fi report of system_profiler, whose current network reads
+//
+//	"_name": "home",
+//	"spairport_network_channel": "36 (5GHz, 80MHz)",
+//	"spairport_network_phymode": "802.11ac",
+//	"spairport_network_rate": 867,
+//	"spairport_signal_noise": "-55 dBm / -90 dBm"
+func parseAirPort(out []byte, iface string) (*WiFiInfo, error) {
+	var report struct {
+		Data []struct {
+			Interfaces []struct {
+				Name    string `json:"_name"`
+				Current *struct {
+					Name        string `json:"_name"`
+					BSSID       string `json:"spairport_network_bssid"`
+					Channel     any    `json:"spairport_network_channel"`
+					PHYMode     string `json:"spairport_network_phymode"`
+					Rate        any    `json:"spairport_network_rate"`
+					SignalNoise string `json:"spairport_signal_noise"`
+				} `json:"spairport_current_network_information"`
+			} `json:"spairport_airport_interfaces"`
+		} `json:"SPAirPortDataType"`
+	}
+	if err := json.Unmarshal(out, &report); err != nil {
+		return nil, fmt.Errorf("invalid system_profiler report: %w", err)
+	}
+	for _, d := range report.Data {
+		for _, i := range d.Interfaces {
+			if (iface != "" && i.Name != iface) || i.Current == nil {
+				continue
+			}
+			cur := i.Current
+			w := &WiFiInfo{Interface: i.Name, SSID: cur.Name, BSSID: cur.BSSID, PHY: cur.PHYMode}
+			// "36 (5GHz, 80MHz)"
+			channel, details, _ := strings.Cut(fmt.Sprint(cur.Channel), " ")
+			w.Channel, _ = strconv.Atoi(channel)
+			for _, f := range strings.FieldsFunc(details, func(r rune) bool { return r == '(' || r == ')' || r == ',' || r == ' ' }) {
+				if strings.HasSuffix(f, "GHz") {
+					w.Band = f
+				} else if mhz, ok := strings.CutSuffix(f, "MHz"); ok {
+					w.Width, _ = strconv.Atoi(mhz)
+				}
+			}
+			w.TxRate, _ = strconv.ParseFloat(fmt.Sprint(cur.Rate), 64)
+			// "-55 dBm / -90 dBm"
+			signal, noise, _ := strings.Cut(cur.SignalNoise, "/")
+			if f := strings.Fields(signal); len(f) > 0 {
+				w.RSSI, _ = strconv.Atoi(f[0])
+			}
+			if f := strings.Fields(noise); len(f) > 0 {
+				w.Noise, _ = strconv.Atoi(f[0])
+			}
+			return w, nil
+		}
+	}
+	if iface != "" {
+		return nil, fmt.Errorf("%s not connected", iface)
+	}
+	return nil, errors.New("no connected wireless interface")
+}
@@ -0,0 +1,100 @@
+package speedtest
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Standards of the MCS names iw prints in the bitrates
+var iwPHYs = []struct{ prefix, phy string }{
+	{"EHT-MCS", "802.11be"},
+	{"HE-MCS", "802.11ax"},
+	{"VHT-MCS", "802.11ac"},
+	{"MCS", "802.11n"},
+}
+
+func readWiFi(ctx context.Context, iface string) (*WiFiInfo, error) {
+	ifaces := []string{iface}
+	if iface == "" {
+		ifaces, _ = filepath.Glob("/sys/class/net/*/wireless")
+		for i, dir := range ifaces {
+			ifaces[i] = filepath.Base(filepath.Dir(dir))
+		}
+		if len(ifaces) == 0 {
+			return nil, errors.New("no wireless interface")
+		}
+	} else if _, err := os.Stat(filepath.Join("/sys/class/net", iface, "wireless")); err != nil {
+		return nil, fmt.Errorf("%s is not a wireless interface", iface)
+	}
+	for _, name := range ifaces {
+		out, err := exec.CommandContext(ctx, "iw", "dev", name, "link").Output()
+		if err != nil {
+			return nil, fmt.Errorf("iw dev %s link: %w", name, err)
+		}
+		if w := parseIWLink(string(out)); w != nil {
+			w.Interface = name
+			return w, nil
+		}
+	}
+	return nil, fmt.Errorf("%s not connected", strings.Join(ifaces, ", "))
+}
+
+// Parse the output of "iw dev <iface> link", nil if not connected:
+//
+//	Connected to aa:bb:cc:dd:ee:ff (on wlan0)
+//		SSID: home
+//		freq: 5180
+//		signal: -52 dBm
+//		rx bitrate: 866.7 MBit/s VHT-MCS 9 80MHz short GI VHT-NSS 2
+//		tx bitrate: 780.0 MBit/s VHT-MCS 8 80MHz short GI VHT-NSS 2
+func parseIWLink(out string) *WiFiInfo {
+	var w *WiFiInfo
+	sc := bufio.NewScanner(strings.NewReader(out))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if rest, ok := strings.CutPrefix(line, "Connected to "); ok {
+			w = &WiFiInfo{BSSID: strings.Fields(rest)[0]}
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if w == nil || !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		fields := strings.Fields(value)
+		if len(fields) == 0 {
+			continue
+		}
+		switch key {
+		case "SSID":
+			w.SSID = value
+		case "freq":
+			f, _ := strconv.ParseFloat(fields[0], 64)
+			w.Frequency = int(f)
+		case "signal":
+			w.RSSI, _ = strconv.Atoi(fields[0])
+		case "rx bitrate":
+			w.RxRate, _ = strconv.ParseFloat(fields[0], 64)
+		case "tx bitrate":
+			w.TxRate, _ = strconv.ParseFloat(fields[0], 64)
+			for _, f := range fields[1:] {
+				if mhz, ok := strings.CutSuffix(f, "MHz"); ok {
+					w.Width, _ = strconv.Atoi(mhz)
+				}
+				for _, p := range iwPHYs {
+					if w.PHY == "" && f == p.prefix {
+						w.PHY = p.phy
+					}
+				}
+			}
+		}
+	}
+	return w
+}
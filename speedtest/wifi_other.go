@@ -0,0 +1,13 @@
+//go:build !linux && !darwin
+
+package speedtest
+
+import (
+	"context"
+	"errors"
+)
+
+// Only the link reports of Linux and macOS are known
+func readWiFi(context.Context, string) (*WiFiInfo, error) {
+	return nil, errors.ErrUnsupported
+}
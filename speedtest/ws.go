@@ -0,0 +1,249 @@
+package speedtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/ofauchon/go-speedtest/internal/stats"
+)
+
+const (
+	// Size of the messages of the throughput phases by default, and at
+	// most
+	DefaultWSMessage = 64 * 1024
+	wsMaxMessage     = payloadBlockSize
+)
+
+// WSHandler returns the handler of the WebSocket test endpoint. The mode
+// query parameter picks what the server does with the connection:
+//
+//	echo      sends every message back, for the round trip probes
+//	download  streams binary messages of size bytes (?size=N)
+//	upload    discards the messages received
+func WSHandler() http.Handler {
+	block := randomBlock()
+	upgrader := websocket.Upgrader{
+		ReadBufferSize:  DefaultWSMessage,
+		WriteBufferSize: DefaultWSMessage,
+		// Test clients may come from any page
+		CheckOrigin: func(*http.Request) bool { return true },
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		size := DefaultWSMessage
+		if s := q.Get("size"); s != "" {
+			v, err := strconv.Atoi(s)
+			if err != nil || v <= 0 || v > wsMaxMessage {
+				http.Error(w, "invalid size", http.StatusBadRequest)
+				return
+			}
+			size = v
+		}
+		mode := q.Get("mode")
+		switch mode {
+		case "echo", "download", "upload":
+		default:
+			http.Error(w, "mode must be echo, download or upload", http.StatusBadRequest)
+			return
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		switch mode {
+		case "echo":
+			for {
+				kind, msg, err := conn.ReadMessage()
+				if err != nil {
+					return
+				}
+				if err := conn.WriteMessage(kind, msg); err != nil {
+					return
+				}
+			}
+		case "download":
+			// Stop sending when the client closes the connection
+			closed := make(chan struct{})
+			go func() {
+				defer close(closed)
+				for {
+					if _, _, err := conn.NextReader(); err != nil {
+						return
+					}
+				}
+			}()
+			for {
+				select {
+				case <-closed:
+					return
+				default:
+				}
+				if err := conn.WriteMessage(websocket.BinaryMessage, block[:size]); err != nil {
+					return
+				}
+			}
+		case "upload":
+			for {
+				_, r, err := conn.NextReader()
+				if err != nil {
+					return
+				}
+				io.Copy(io.Discard, r)
+			}
+		}
+	})
+}
+
+// URL of the endpoint in the given mode, http(s):// targets being turned
+// into ws(s)://
+func wsURL(target, mode string, size int) (string, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return "", err
+	}
+	switch u.Scheme {
+	case "http":
+		u.Scheme = "ws"
+	case "https":
+		u.Scheme = "wss"
+	case "ws", "wss":
+	default:
+		return "", fmt.Errorf("invalid WebSocket URL %q", target)
+	}
+	q := u.Query()
+	q.Set("mode", mode)
+	if mode != "echo" {
+		q.Set("size", strconv.Itoa(size))
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// Send the latency probes as text messages echoed by the server over a
+// single connection, the latency being their round trip time
+func (c *Client) wsLatency(ctx context.Context, opts Options, dialer websocket.Dialer, target string) (*LatencyResult, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	conn, err := c.wsDial(ctx, dialer, target)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	res := &LatencyResult{Method: "websocket"}
+	timeout := probeTimeout(opts)
+	for i := 0; i < opts.LatencyProbes && ctx.Err() == nil; i++ {
+		res.Sent++
+		seq := strconv.Itoa(i)
+		start := time.Now()
+		conn.SetWriteDeadline(start.Add(timeout))
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(seq)); err != nil {
+			break
+		}
+		// A lost answer leaves the connection out of step, stop there
+		conn.SetReadDeadline(start.Add(timeout))
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			break
+		}
+		if string(msg) != seq {
+			return nil, errors.New("unexpected echo message")
+		}
+		res.Received++
+		res.Samples = append(res.Samples, time.Since(start))
+	}
+	res.compute()
+	return res, nil
+}
+
+// RunWS tests a WebSocket endpoint served by WSHandler (e.g.
+// ws://host:8080/ws): the latency is the round trip time of echoed
+// messages, then the connections receive, and with opts.Upload send,
+// binary messages of messageSize bytes for Options.Duration (10 seconds
+// by default).
+func (c *Client) RunWS(ctx context.Context, opts Options, messageSize int) (*Result, error) {
+	if opts.Target == "" {
+		return nil, errors.New("target URL is required")
+	}
+	if opts.Concurrent <= 0 || opts.Single {
+		opts.Concurrent = 1
+	}
+	if opts.Duration <= 0 {
+		opts.Duration = backendDuration
+	}
+	if messageSize <= 0 {
+		messageSize = DefaultWSMessage
+	}
+	if messageSize > wsMaxMessage {
+		return nil, fmt.Errorf("message size is at most %d bytes", wsMaxMessage)
+	}
+	dialer := websocket.Dialer{
+		HandshakeTimeout: 10 * time.Second,
+		ReadBufferSize:   messageSize,
+		WriteBufferSize:  messageSize,
+	}
+	echo, err := wsURL(opts.Target, "echo", 0)
+	if err != nil {
+		return nil, err
+	}
+	download, _ := wsURL(opts.Target, "download", messageSize)
+	upload, _ := wsURL(opts.Target, "upload", messageSize)
+
+	var lat *LatencyResult
+	if opts.LatencyProbes > 0 {
+		if lat, err = c.wsLatency(ctx, opts, dialer, echo); err != nil {
+			return nil, err
+		}
+	}
+
+	t := runTransfer(ctx, opts, dirDownload, 0, func(ctx context.Context, part int, counter *stats.Counter) error {
+		conn, err := c.wsDial(ctx, dialer, download)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		w := &bodyWriter{counter: counter}
+		for {
+			_, r, err := conn.NextReader()
+			if err == nil {
+				err = readBody(w, r, opts.ReadBuffer)
+			}
+			if websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("error reading data on part %d: %w", part, err)
+			}
+		}
+	})
+	res := t.downloadResult(opts, opts.Target, 0)
+	res.Latency = lat
+
+	if opts.Upload && ctx.Err() == nil {
+		block := payloadBlock(opts.UploadCompressibility)[:messageSize]
+		t := runTransfer(ctx, opts, dirUpload, 0, func(ctx context.Context, part int, counter *stats.Counter) error {
+			conn, err := c.wsDial(ctx, dialer, upload)
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+			for {
+				if err := conn.WriteMessage(websocket.BinaryMessage, block); err != nil {
+					return fmt.Errorf("error sending data on part %d: %w", part, err)
+				}
+				counter.Add(int64(len(block)))
+			}
+		})
+		res.Upload = t.uploadResult(opts, opts.Target, 0)
+	}
+	return res, nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"time"
+
+	"github.com/ofauchon/go-speedtest/internal/stats"
+	"github.com/ofauchon/go-speedtest/speedtest"
+)
+
+// A metric aggregated over several results
+type resultMetric struct {
+	name string
+	unit string
+	// Return the value of a result, false if it has none
+	value func(*speedtest.Result) (float64, bool)
+	// Smaller values are better
+	lowerBetter bool
+}
+
+var (
+	downloadMetric = resultMetric{"Download", "Mbit/sec", func(r *speedtest.Result) (float64, bool) {
+		return r.BytesPerSecond() * 8 / 1e6, !r.DownloadSkipped
+	}, false}
+	uploadMetric = resultMetric{"Upload", "Mbit/sec", func(r *speedtest.Result) (float64, bool) {
+		if r.Upload == nil {
+			return 0, false
+		}
+		return r.Upload.BytesPerSecond() * 8 / 1e6, true
+	}, false}
+	latencyMetric = resultMetric{"Latency", "ms", func(r *speedtest.Result) (float64, bool) {
+		if r.Latency == nil || r.Latency.Received == 0 {
+			return 0, false
+		}
+		return float64(r.Latency.Avg) / float64(time.Millisecond), true
+	}, true}
+
+	resultMetrics = []resultMetric{downloadMetric, uploadMetric, latencyMetric}
+)
+
+// Collect the values of the metric, skipping results without one
+func (m resultMetric) values(results []*speedtest.Result) []float64 {
+	var values []float64
+	for _, r := range results {
+		if v, ok := m.value(r); ok {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// Aggregate statistics of a series of values
+type summary = stats.Summary
+
+func summarize(values []float64) summary {
+	return stats.Summarize(values)
+}
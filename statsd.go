@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ofauchon/go-speedtest/speedtest"
+)
+
+// Build the StatsD gauges of a result, one per line. With tags the lines
+// use the DogStatsD format and also carry the target host.
+func statsdLines(res *speedtest.Result, prefix string, tags []string) []string {
+	suffix := ""
+	if len(tags) > 0 {
+		host := res.Target
+		if u, err := url.Parse(res.Target); err == nil && u.Host != "" {
+			host = u.Hostname()
+		}
+		// Clipped so that the results don't share the tags array
+		suffix = "|#" + strings.Join(append(slices.Clip(tags), "target:"+host), ",")
+	}
+	summary := newResultSummary(res)
+	var lines []string
+	for _, g := range []struct {
+		name  string
+		value *float64
+	}{
+		{"download_mbps", summary.DownloadMbps},
+		{"upload_mbps", summary.UploadMbps},
+		{"latency_ms", summary.LatencyMs},
+		{"jitter_ms", summary.JitterMs},
+		{"loss_percent", summary.LossPercent},
+	} {
+		if g.value != nil {
+			lines = append(lines, prefix+g.name+":"+strconv.FormatFloat(*g.value, 'f', 3, 64)+"|g"+suffix)
+		}
+	}
+	return lines
+}
+
+// Parse the comma separated -statsd-tags
+func parseStatsdTags(s string) []string {
+	var tags []string
+	for _, t := range strings.Split(s, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
+
+func init() {
+	registerSink(func(f *testFlags) (Sink, error) {
+		if *f.statsd == "" {
+			return nil, nil
+		}
+		tags := parseStatsdTags(*f.statsdTags)
+		return sinkFunc{"StatsD", func(ctx context.Context, res *speedtest.Result) error {
+			return sendStatsd(ctx, *f.statsd, *f.statsdPrefix, tags, res)
+		}}, nil
+	})
+}
+
+// Send the gauges of the result to a StatsD server over UDP, in a single
+// datagram
+func sendStatsd(ctx context.Context, addr, prefix string, tags []string, res *speedtest.Result) error {
+	lines := statsdLines(res, prefix, tags)
+	if len(lines) == 0 {
+		return nil
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", addr)
+	if err != nil {
+		return fmt.Errorf("statsd: %w", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(strings.Join(lines, "\n"))); err != nil {
+		return fmt.Errorf("statsd: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ofauchon/go-speedtest/speedtest"
+)
+
+func TestStatsdLines(t *testing.T) {
+	res := &speedtest.Result{
+		Target: "https://speed.example.com:8443/file", Start: time.Unix(1700000000, 0), Bytes: 125000000, Elapsed: 10 * time.Second,
+		Latency: &speedtest.LatencyResult{Sent: 4, Received: 4, Avg: 12500 * time.Microsecond, Jitter: time.Millisecond},
+	}
+	tests := []struct {
+		prefix string
+		tags   string
+		want   []string
+	}{
+		{"speedtest.", "", []string{
+			"speedtest.download_mbps:100.000|g",
+			"speedtest.latency_ms:12.500|g",
+			"speedtest.jitter_ms:1.000|g",
+			"speedtest.loss_percent:0.000|g",
+		}},
+		{"", " env:prod, site:paris ,rack:3", []string{
+			"download_mbps:100.000|g|#env:prod,site:paris,rack:3,target:speed.example.com",
+			"latency_ms:12.500|g|#env:prod,site:paris,rack:3,target:speed.example.com",
+			"jitter_ms:1.000|g|#env:prod,site:paris,rack:3,target:speed.example.com",
+			"loss_percent:0.000|g|#env:prod,site:paris,rack:3,target:speed.example.com",
+		}},
+	}
+	for _, tt := range tests {
+		tags := parseStatsdTags(tt.tags)
+		before := slices.Clone(tags[:cap(tags)])
+		if got := statsdLines(res, tt.prefix, tags); !slices.Equal(got, tt.want) {
+			t.Errorf("statsdLines(%q, %q) = %q, want %q", tt.prefix, tt.tags, got, tt.want)
+		}
+		if !slices.Equal(tags[:cap(tags)], before) {
+			t.Errorf("tags array changed to %q", tags[:cap(tags)])
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"time"
+
+	"github.com/ofauchon/go-speedtest/speedtest"
+)
+
+// Main values of a result, e.g. the MQTT state, StatsD and Zabbix items
+// and the summaries of the REST API and controller
+type resultSummary struct {
+	Target       string   `json:"target"`
+	Timestamp    string   `json:"timestamp"`
+	DownloadMbps *float64 `json:"download_mbps,omitempty"`
+	UploadMbps   *float64 `json:"upload_mbps,omitempty"`
+	LatencyMs    *float64 `json:"latency_ms,omitempty"`
+	JitterMs     *float64 `json:"jitter_ms,omitempty"`
+	LossPercent  *float64 `json:"loss_percent,omitempty"`
+}
+
+func newResultSummary(res *speedtest.Result) resultSummary {
+	ms := func(d time.Duration) *float64 {
+		v := float64(d) / float64(time.Millisecond)
+		return &v
+	}
+	s := resultSummary{Target: res.Target, Timestamp: res.Start.Format(time.RFC3339)}
+	if v, ok := downloadMetric.value(res); ok {
+		s.DownloadMbps = &v
+	}
+	if v, ok := uploadMetric.value(res); ok {
+		s.UploadMbps = &v
+	}
+	if lat := res.Latency; lat != nil && lat.Received > 0 {
+		loss := lat.Loss()
+		s.LatencyMs, s.JitterMs, s.LossPercent = ms(lat.Avg), ms(lat.Jitter), &loss
+	}
+	return s
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ofauchon/go-speedtest/speedtest"
+)
+
+// Exit code of a test violating its thresholds or regressing from its
+// baseline, errors exit with 1
+const exitThreshold = 2
+
+// A result value breaking a threshold
+type violation struct {
+	Metric string  `json:"metric"`
+	Value  float64 `json:"value"`
+	Limit  float64 `json:"limit"`
+	Unit   string  `json:"unit"`
+	Op     string  `json:"op"`
+}
+
+// Compare the result with the thresholds, zero limits are disabled.
+// Missing measurements break the thresholds that need them.
+func checkThresholds(res *speedtest.Result, minDownload, minUpload, maxLatency float64) []violation {
+	var v []violation
+	if minDownload > 0 {
+		if d := res.BytesPerSecond() * 8 / 1e6; d < minDownload {
+			v = append(v, violation{"download", d, minDownload, "Mbit/sec", "<"})
+		}
+	}
+	if minUpload > 0 {
+		var u float64
+		if res.Upload != nil {
+			u = res.Upload.BytesPerSecond() * 8 / 1e6
+		}
+		if u < minUpload {
+			v = append(v, violation{"upload", u, minUpload, "Mbit/sec", "<"})
+		}
+	}
+	if maxLatency > 0 {
+		if res.Latency == nil || res.Latency.Received == 0 {
+			v = append(v, violation{"latency", 0, maxLatency, "ms", "missing"})
+		} else if l := float64(res.Latency.Avg) / float64(time.Millisecond); l > maxLatency {
+			v = append(v, violation{"latency", l, maxLatency, "ms", ">"})
+		}
+	}
+	return v
+}
+
+// Print one key=value line per violation
+func printViolations(w io.Writer, violations []violation) {
+	for _, v := range violations {
+		fmt.Fprintf(w, "THRESHOLD metric=%s value=%.3f op=%s limit=%.3f unit=%s\n", v.Metric, v.Value, v.Op, v.Limit, v.Unit)
+	}
+}
@@ -0,0 +1,195 @@
+// Dashboard of go-speedtest, driving the REST API of the api and monitor
+// commands
+"use strict";
+
+const $ = (id) => document.getElementById(id);
+
+// Scales of the gauge, in Mbit/s
+const gaugeScales = [10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000, 25000, 100000];
+
+let following = null;
+let peak = 0;
+
+// Call the API, asking for the token when it is required
+async function api(path, options = {}) {
+  const headers = { ...options.headers };
+  const token = localStorage.getItem("go-speedtest-token");
+  if (token) {
+    headers.Authorization = "Bearer " + token;
+  }
+  const resp = await fetch("api/v1/" + path, { ...options, headers });
+  if (resp.status === 401) {
+    const t = prompt("API token");
+    if (t !== null) {
+      localStorage.setItem("go-speedtest-token", t);
+      return api(path, options);
+    }
+  }
+  const body = await resp.json().catch(() => ({}));
+  return { status: resp.status, body };
+}
+
+function mbps(bytesPerSecond) {
+  return (bytesPerSecond * 8) / 1e6;
+}
+
+function fixed(v, unit, digits = 2) {
+  return v === undefined || v === null ? "–" : v.toFixed(digits) + " " + unit;
+}
+
+function setGauge(value, upload) {
+  peak = Math.max(peak, value);
+  const scale = gaugeScales.find((s) => s >= peak) || gaugeScales[gaugeScales.length - 1];
+  const pct = Math.min(value / scale, 1) * 100;
+  $("gauge-arc").setAttribute("stroke-dasharray", pct + " 100");
+  $("gauge-value").textContent = value ? value.toFixed(value < 100 ? 1 : 0) : "–";
+  $("gauge-max").textContent = scale >= 1000 ? scale / 1000 + "G" : scale;
+  $("gauge").classList.toggle("upload", !!upload);
+}
+
+function showSummary(s) {
+  $("r-download").textContent = fixed(s && s.download_mbps, "Mbit/s");
+  $("r-upload").textContent = fixed(s && s.upload_mbps, "Mbit/s");
+  $("r-latency").textContent = fixed(s && s.latency_ms, "ms");
+  $("r-jitter").textContent = fixed(s && s.jitter_ms, "ms");
+  $("r-loss").textContent = fixed(s && s.loss_percent, "%", 1);
+}
+
+// Show a test, polling it until it finishes
+async function follow(id) {
+  following = id;
+  peak = 0;
+  $("start").disabled = true;
+  $("error").textContent = "";
+  showSummary(null);
+  while (following === id) {
+    const { status, body } = await api("tests/" + id);
+    if (status !== 200) {
+      $("error").textContent = body.error || "test " + id + " not found";
+      break;
+    }
+    $("target").textContent = body.target;
+    if (body.status !== "running") {
+      setGauge(0);
+      $("phase").textContent = "Test " + body.status;
+      $("progress").value = body.status === "done" ? 1 : 0;
+      $("error").textContent = body.error || "";
+      showSummary(body.summary);
+      loadHistory();
+      break;
+    }
+    const live = body.live;
+    if (live) {
+      const upload = live.phase === "Upload";
+      if (upload && !$("gauge").classList.contains("upload")) {
+        peak = 0;
+      }
+      setGauge(mbps(live.rate), upload);
+      $("phase").textContent = live.phase + " " + fixed(mbps(live.avg), "Mbit/s") + " average";
+      if (live.size) {
+        $("progress").value = live.bytes / live.size;
+      } else if (live.duration_ns) {
+        $("progress").value = live.elapsed_ns / live.duration_ns;
+      }
+    } else {
+      $("phase").textContent = "Measuring the latency";
+      $("progress").removeAttribute("value");
+    }
+    await new Promise((r) => setTimeout(r, 500));
+  }
+  following = null;
+  $("start").disabled = false;
+}
+
+async function startTest() {
+  const { status, body } = await api("tests", {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify({ upload: $("upload").checked }),
+  });
+  if (status === 202) {
+    follow(body.id);
+  } else if (status === 409 && body.test) {
+    follow(body.test.id);
+  } else {
+    $("error").textContent = body.error || "test not started (" + status + ")";
+  }
+}
+
+// Draw the series of points {t, v} as lines sharing the time axis
+function drawChart(svg, series) {
+  const w = 600, h = 180, left = 40, bottom = 20, top = 8;
+  const points = series.flatMap((s) => s.points);
+  svg.innerHTML = "";
+  if (points.length === 0) {
+    return;
+  }
+  const t0 = Math.min(...points.map((p) => p.t));
+  const t1 = Math.max(...points.map((p) => p.t));
+  const vmax = Math.max(...points.map((p) => p.v)) * 1.1 || 1;
+  const x = (t) => left + (t1 > t0 ? ((t - t0) / (t1 - t0)) * (w - left - 10) : (w - left) / 2);
+  const y = (v) => top + (1 - v / vmax) * (h - top - bottom);
+  const el = (name, attrs, text) => {
+    const e = document.createElementNS("http://www.w3.org/2000/svg", name);
+    for (const [k, v] of Object.entries(attrs)) {
+      e.setAttribute(k, v);
+    }
+    if (text !== undefined) {
+      e.textContent = text;
+    }
+    svg.appendChild(e);
+  };
+  for (let i = 0; i <= 4; i++) {
+    const v = (vmax * i) / 4;
+    el("line", { x1: left, x2: w, y1: y(v), y2: y(v), class: "grid" });
+    el("text", { x: left - 4, y: y(v) + 4, "text-anchor": "end", class: "label" }, v < 10 ? v.toFixed(1) : Math.round(v));
+  }
+  for (const t of [t0, t1]) {
+    const d = new Date(t);
+    el("text", { x: x(t), y: h - 4, "text-anchor": t === t0 ? "start" : "end", class: "label" }, d.toLocaleString());
+  }
+  for (const s of series) {
+    if (s.points.length > 0) {
+      el("polyline", { points: s.points.map((p) => x(p.t) + "," + y(p.v)).join(" "), class: s.name });
+    }
+  }
+}
+
+// Chart the runs of the history database, or the tests kept in memory
+async function loadHistory() {
+  let runs;
+  let { status, body } = await api("history?last=100");
+  if (status === 200) {
+    runs = body.map((r) => r.summary);
+    $("history-note").textContent = runs.length + " runs of the history database";
+  } else {
+    ({ status, body } = await api("tests"));
+    if (status !== 200) {
+      return;
+    }
+    runs = body.filter((t) => t.summary).map((t) => t.summary).reverse();
+    $("history-note").textContent = runs.length + " tests since the server started, run it with -history to keep them";
+  }
+  const series = (name, key) => ({
+    name,
+    points: runs.filter((r) => r[key] !== undefined).map((r) => ({ t: Date.parse(r.timestamp), v: r[key] })),
+  });
+  drawChart($("chart-speed"), [series("download", "download_mbps"), series("upload", "upload_mbps")]);
+  drawChart($("chart-latency"), [series("latency", "latency_ms")]);
+}
+
+// Follow the tests started elsewhere, like the scheduled runs of the
+// monitor
+async function poll() {
+  if (following === null) {
+    const { status, body } = await api("status");
+    if (status === 200 && body.running) {
+      follow(body.running.id);
+    }
+  }
+  setTimeout(poll, 5000);
+}
+
+$("start").addEventListener("click", startTest);
+loadHistory();
+poll();
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/ofauchon/go-speedtest/speedtest"
+)
+
+// Header carrying the HMAC-SHA256 of the body, like GitHub webhooks
+const webhookSignatureHeader = "X-Speedtest-Signature"
+
+// Delay before the first retry, doubled after each failure
+const webhookBackoff = time.Second
+
+func init() {
+	registerSink(func(f *testFlags) (Sink, error) {
+		if *f.webhook == "" {
+			return nil, nil
+		}
+		return sinkFunc{"webhook", func(ctx context.Context, res *speedtest.Result) error {
+			return sendWebhook(ctx, *f.webhook, *f.webhookSecret, *f.webhookRetries, res)
+		}}, nil
+	})
+}
+
+// POST the JSON result to a webhook, retrying on network errors and 5xx
+// or 429 answers. The body is signed with secret if set.
+func sendWebhook(ctx context.Context, target, secret string, retries int, res *speedtest.Result) error {
+	body, err := json.Marshal(res)
+	if err != nil {
+		return err
+	}
+	signature := ""
+	if secret != "" {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		signature = "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	}
+
+	delay := webhookBackoff
+	for attempt := 0; ; attempt++ {
+		retry, err := postWebhook(ctx, target, signature, body)
+		if err == nil || !retry || attempt >= retries {
+			return err
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return err
+		}
+		delay *= 2
+	}
+}
+
+// Send one webhook request, returning whether a failure is worth a retry
+func postWebhook(ctx context.Context, target, signature string, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "go-speedtest")
+	if signature != "" {
+		req.Header.Set(webhookSignatureHeader, signature)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("webhook failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+		return retry, fmt.Errorf("webhook failed: %s %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return false, nil
+}
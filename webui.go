@@ -0,0 +1,19 @@
+package main
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+// Static files of the web dashboard, calling the REST API
+//
+//go:embed web
+var webFiles embed.FS
+
+// Serve the web dashboard
+func webHandler() http.Handler {
+	// Only fails for invalid paths
+	files, _ := fs.Sub(webFiles, "web")
+	return http.FileServerFS(files)
+}
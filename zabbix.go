@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ofauchon/go-speedtest/speedtest"
+)
+
+// Header of the Zabbix protocol packets, followed by the data length
+var zabbixHeader = []byte("ZBXD\x01")
+
+// Largest answer accepted from the server
+const zabbixMaxResponse = 1 << 20
+
+// Value sent to a Zabbix trapper item
+type zabbixItem struct {
+	Host  string `json:"host"`
+	Key   string `json:"key"`
+	Value string `json:"value"`
+	Clock int64  `json:"clock"`
+}
+
+// Build the items of a result, keyed prefix + name unless keys maps the
+// name (download, upload, latency, jitter, loss) to another key
+func zabbixItems(res *speedtest.Result, host, prefix string, keys map[string]string) []zabbixItem {
+	summary := newResultSummary(res)
+	values := []struct {
+		name  string
+		value *float64
+	}{
+		{"download", summary.DownloadMbps},
+		{"upload", summary.UploadMbps},
+		{"latency", summary.LatencyMs},
+		{"jitter", summary.JitterMs},
+		{"loss", summary.LossPercent},
+	}
+	var items []zabbixItem
+	for _, v := range values {
+		if v.value == nil {
+			continue
+		}
+		key := prefix + v.name
+		if k, ok := keys[v.name]; ok {
+			key = k
+		}
+		items = append(items, zabbixItem{Host: host, Key: key, Value: strconv.FormatFloat(*v.value, 'f', 3, 64), Clock: res.Start.Unix()})
+	}
+	return items
+}
+
+// Parse the -zabbix-key name=key overrides
+func parseZabbixKeys(list []string) (map[string]string, error) {
+	keys := map[string]string{}
+	for _, s := range list {
+		name, key, ok := strings.Cut(s, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid Zabbix key %q, expected name=key", s)
+		}
+		switch name {
+		case "download", "upload", "latency", "jitter", "loss":
+		default:
+			return nil, fmt.Errorf("unknown Zabbix value %q (download, upload, latency, jitter or loss)", name)
+		}
+		keys[name] = key
+	}
+	return keys, nil
+}
+
+// Count of values the server failed to process, in its info string
+var zabbixFailed = regexp.MustCompile(`failed: (\d+)`)
+
+func init() {
+	registerSink(func(f *testFlags) (Sink, error) {
+		keys, err := parseZabbixKeys(f.zabbixKeys)
+		if err != nil || *f.zabbix == "" {
+			return nil, err
+		}
+		return sinkFunc{"Zabbix", func(ctx context.Context, res *speedtest.Result) error {
+			return sendZabbix(ctx, *f.zabbix, *f.zabbixHost, *f.zabbixPrefix, keys, res)
+		}}, nil
+	})
+}
+
+// Send the result to a Zabbix server or proxy with the sender protocol.
+// The items must exist as trapper items of host, which defaults to the
+// hostname.
+func sendZabbix(ctx context.Context, server, host, prefix string, keys map[string]string, res *speedtest.Result) error {
+	if host == "" {
+		var err error
+		if host, err = os.Hostname(); err != nil {
+			return fmt.Errorf("zabbix: %w", err)
+		}
+	}
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "10051")
+	}
+	items := zabbixItems(res, host, prefix, keys)
+	if len(items) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(map[string]any{"request": "sender data", "data": items, "clock": time.Now().Unix()})
+	if err != nil {
+		return fmt.Errorf("zabbix: %w", err)
+	}
+
+	d := &net.Dialer{Timeout: 10 * time.Second}
+	conn, err := d.DialContext(ctx, "tcp", server)
+	if err != nil {
+		return fmt.Errorf("zabbix: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	packet := append([]byte(nil), zabbixHeader...)
+	packet = binary.LittleEndian.AppendUint64(packet, uint64(len(data)))
+	if _, err := conn.Write(append(packet, data...)); err != nil {
+		return fmt.Errorf("zabbix: %w", err)
+	}
+
+	head := make([]byte, len(zabbixHeader)+8)
+	if _, err := io.ReadFull(conn, head); err != nil {
+		return fmt.Errorf("zabbix: no response: %w", err)
+	}
+	if !bytes.Equal(head[:len(zabbixHeader)], zabbixHeader) {
+		return errors.New("zabbix: invalid response header")
+	}
+	n := binary.LittleEndian.Uint64(head[len(zabbixHeader):])
+	if n > zabbixMaxResponse {
+		return fmt.Errorf("zabbix: response too large (%d bytes)", n)
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(conn, body); err != nil {
+		return fmt.Errorf("zabbix: %w", err)
+	}
+	var resp struct {
+		Response string `json:"response"`
+		Info     string `json:"info"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return fmt.Errorf("zabbix: invalid response: %w", err)
+	}
+	if resp.Response != "success" {
+		return fmt.Errorf("zabbix: %s: %s", resp.Response, resp.Info)
+	}
+	// Values of unknown hosts or items are dropped with a success response
+	if m := zabbixFailed.FindStringSubmatch(resp.Info); m != nil && m[1] != "0" {
+		return fmt.Errorf("zabbix: values rejected (%s), check the host and trapper items", resp.Info)
+	}
+	return nil
+}
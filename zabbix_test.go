@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ofauchon/go-speedtest/speedtest"
+)
+
+func TestZabbixItems(t *testing.T) {
+	res := &speedtest.Result{
+		Start: time.Unix(1700000000, 0), Bytes: 125000000, Elapsed: 10 * time.Second,
+		Latency: &speedtest.LatencyResult{Sent: 4, Received: 4, Avg: 12500 * time.Microsecond, Jitter: time.Millisecond},
+	}
+	items := zabbixItems(res, "home", "speedtest.", map[string]string{"jitter": "net.jitter"})
+	want := []zabbixItem{
+		{"home", "speedtest.download", "100.000", 1700000000},
+		{"home", "speedtest.latency", "12.500", 1700000000},
+		{"home", "net.jitter", "1.000", 1700000000},
+		{"home", "speedtest.loss", "0.000", 1700000000},
+	}
+	if len(items) != len(want) {
+		t.Fatalf("items %+v, want %+v", items, want)
+	}
+	for i := range want {
+		if items[i] != want[i] {
+			t.Errorf("item %d = %+v, want %+v", i, items[i], want[i])
+		}
+	}
+
+	for _, keys := range [][]string{{"download"}, {"download="}, {"speed=net.speed"}} {
+		if _, err := parseZabbixKeys(keys); err == nil {
+			t.Errorf("parseZabbixKeys(%q) accepted", keys)
+		}
+	}
+}
+
+// Answer one sender request with the info string, returning the request
+func zabbixServer(t *testing.T, response, info string) (string, <-chan []byte) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	requests := make(chan []byte, 1)
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		head := make([]byte, 13)
+		if _, err := io.ReadFull(c, head); err != nil {
+			return
+		}
+		body := make([]byte, binary.LittleEndian.Uint64(head[5:]))
+		if _, err := io.ReadFull(c, body); err != nil {
+			return
+		}
+		requests <- append(head, body...)
+		answer, _ := json.Marshal(map[string]string{"response": response, "info": info})
+		c.Write(binary.LittleEndian.AppendUint64([]byte("ZBXD\x01"), uint64(len(answer))))
+		c.Write(answer)
+	}()
+	return ln.Addr().String(), requests
+}
+
+func TestSendZabbix(t *testing.T) {
+	res := &speedtest.Result{Start: time.Unix(1700000000, 0), Bytes: 125000000, Elapsed: 10 * time.Second}
+
+	server, requests := zabbixServer(t, "success", "processed: 1; failed: 0; total: 1; seconds spent: 0.000055")
+	if err := sendZabbix(context.Background(), server, "home", "speedtest.", nil, res); err != nil {
+		t.Fatal(err)
+	}
+	packet := <-requests
+	if !bytes.HasPrefix(packet, []byte("ZBXD\x01")) {
+		t.Fatalf("packet header % x, want ZBXD 01", packet[:5])
+	}
+	if n := binary.LittleEndian.Uint64(packet[5:13]); n != uint64(len(packet)-13) {
+		t.Errorf("data length %d in the header, sent %d", n, len(packet)-13)
+	}
+	var req struct {
+		Request string       `json:"request"`
+		Data    []zabbixItem `json:"data"`
+	}
+	if err := json.Unmarshal(packet[13:], &req); err != nil {
+		t.Fatal(err)
+	}
+	if req.Request != "sender data" || len(req.Data) != 1 || req.Data[0].Key != "speedtest.download" {
+		t.Errorf("request %+v, want the sender data of the download", req)
+	}
+
+	for _, answer := range []struct{ response, info string }{
+		{"success", "processed: 0; failed: 1; total: 1; seconds spent: 0.000055"},
+		{"failed", "invalid request"},
+	} {
+		server, _ := zabbixServer(t, answer.response, answer.info)
+		if err := sendZabbix(context.Background(), server, "home", "speedtest.", nil, res); err == nil {
+			t.Errorf("%s %q accepted", answer.response, answer.info)
+		}
+	}
+}